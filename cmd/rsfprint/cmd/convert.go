@@ -0,0 +1,42 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	rsf "github.com/rstudio/repository-snapshot-format"
+	"github.com/spf13/cobra"
+)
+
+var toVersion int
+
+func init() {
+	ConvertCmd.Flags().IntVar(&toVersion, "to-version", rsf.Version2, "The index version of the converted file.")
+	PrintCmd.AddCommand(ConvertCmd)
+}
+
+var ConvertCmd = &cobra.Command{
+	Use:   "convert <in.rsf> <out.rsf>",
+	Short: "Convert RSF data to another index version",
+	Long:  "Convert RSF data to another index version, decoding and re-encoding each object. The input index version is detected automatically.",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		in, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("unable to open %s for reading: %s", args[0], err)
+		}
+		defer in.Close()
+
+		out, err := os.Create(args[1])
+		if err != nil {
+			return fmt.Errorf("unable to open %s for writing: %s", args[1], err)
+		}
+		err = rsf.Convert(in, out, toVersion)
+		if err != nil {
+			out.Close()
+			return fmt.Errorf("error converting RSF data from %s: %s", args[0], err)
+		}
+		return out.Close()
+	},
+}
@@ -0,0 +1,66 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	rsf "github.com/rstudio/repository-snapshot-format"
+	"github.com/stretchr/testify/suite"
+)
+
+type RsfConvertCommandSuite struct {
+	suite.Suite
+}
+
+func TestRsfConvertCommandSuite(t *testing.T) {
+	suite.Run(t, &RsfConvertCommandSuite{})
+}
+
+func (s *RsfConvertCommandSuite) TestConvert() {
+	type file struct {
+		Name string `rsf:"name,skip,fixed:5"`
+		Size int    `rsf:"size"`
+	}
+	type record struct {
+		Name  string   `rsf:"name"`
+		Tags  []string `rsf:"tags"`
+		Files []file   `rsf:"files,index:name"`
+	}
+	rec := record{
+		Name:  "rsf",
+		Tags:  []string{"a", "b"},
+		Files: []file{{Name: "a.tgz", Size: 10}, {Name: "b.whl", Size: 7}},
+	}
+	dir := s.T().TempDir()
+	write := func(w rsf.Writer) {
+		_, err := w.WriteObject(rec)
+		s.Require().Nil(err)
+	}
+	v1 := &bytes.Buffer{}
+	write(rsf.NewWriter(v1))
+	v2 := &bytes.Buffer{}
+	write(rsf.NewWriterWithVersion(v2, rsf.Version2))
+	in := filepath.Join(dir, "in.rsf")
+	s.Require().Nil(os.WriteFile(in, v1.Bytes(), 0o644))
+	out := filepath.Join(dir, "out.rsf")
+
+	PrintCmd.SetArgs([]string{"convert", "--to-version", "2", in, out})
+	defer func() {
+		toVersion = rsf.Version2
+	}()
+	s.Require().Nil(PrintCmd.Execute())
+
+	converted, err := os.ReadFile(out)
+	s.Require().Nil(err)
+	s.Assert().Equal(v2.Bytes(), converted)
+
+	// The converted file can be printed.
+	printed := &bytes.Buffer{}
+	PrintCmd.SetOut(printed)
+	PrintCmd.SetArgs([]string{out})
+	s.Require().Nil(PrintCmd.Execute())
+	s.Assert().Contains(printed.String(), "files (indexed array(2)):")
+}
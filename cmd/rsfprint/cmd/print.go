@@ -4,31 +4,84 @@ package cmd
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 
 	rsf "github.com/rstudio/repository-snapshot-format"
 	"github.com/spf13/cobra"
 )
 
+var (
+	printJSON   bool
+	nonFinite   string
+	printHex    bool
+	printTable  bool
+	printSchema bool
+	printField  string
+)
+
+func init() {
+	PrintCmd.Flags().BoolVar(&printJSON, "json", false, "Print objects as JSON Lines.")
+	PrintCmd.Flags().StringVar(&nonFinite, "non-finite", "", "With --json, print NaN and infinite floats as this string instead of null.")
+	PrintCmd.Flags().BoolVar(&printHex, "hex", false, "Print the raw bytes of each field in hex following the field.")
+	PrintCmd.Flags().BoolVar(&printTable, "table", false, "Print arrays of structs with scalar fields as tables.")
+	PrintCmd.Flags().BoolVar(&printSchema, "schema", false, "Print only the index as a tree of fields, without reading any objects.")
+	PrintCmd.Flags().StringVar(&printField, "field", "", "Print only the value of this top-level scalar field of each object, one per line.")
+}
+
 var PrintCmd = &cobra.Command{
 	Use:   "rspm",
 	Short: "Posit Package Manager",
 	Long:  "Posit Package Manager administrative toolset.",
+	// Allow file arguments alongside the `convert` subcommand.
+	Args: cobra.ArbitraryArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		for _, f := range args {
-			_, err := os.Stat(f)
+		sizes := make([]int64, len(args))
+		for i, f := range args {
+			info, err := os.Stat(f)
 			if err != nil {
 				return fmt.Errorf("unable to read %s: %s", f, err)
 			}
+			sizes[i] = info.Size()
 		}
 
-		for _, f := range args {
+		for i, f := range args {
 			rsfFile, err := os.Open(f)
 			if err != nil {
 				return fmt.Errorf("unable to open %s for reading: %s", f, err)
 			}
+
+			// Files with a footer index begin with objects rather than
+			// an index, so they can't be read from the start.
+			footer, err := rsf.HasFooterIndex(rsfFile)
+			if err != nil {
+				return fmt.Errorf("unable to read %s: %s", f, err)
+			}
+			if footer {
+				return fmt.Errorf("%s has a footer index and cannot be printed", f)
+			}
 			buf := bufio.NewReader(rsfFile)
-			err = rsf.Print(cmd.OutOrStdout(), buf)
+
+			// Check that the file is RSF data before reading a bogus
+			// index size from it.
+			err = rsf.CheckFileSize(buf, sizes[i])
+			if err != nil {
+				return fmt.Errorf("%s %s", f, err)
+			}
+
+			if printSchema {
+				err = printIndex(cmd.OutOrStdout(), buf)
+			} else if printField != "" {
+				err = printFieldValues(cmd.OutOrStdout(), buf, printField)
+			} else if printJSON {
+				var opts []rsf.PrintOption
+				if nonFinite != "" {
+					opts = append(opts, rsf.NonFiniteFloats(nonFinite))
+				}
+				err = rsf.PrintJSON(cmd.OutOrStdout(), buf, opts...)
+			} else {
+				err = rsf.Print(cmd.OutOrStdout(), buf, rsf.HexBytes(printHex), rsf.Tables(printTable))
+			}
 			if err != nil {
 				return fmt.Errorf("error printing RSF data from %s: %s", f, err)
 			}
@@ -37,3 +90,44 @@ var PrintCmd = &cobra.Command{
 		return nil
 	},
 }
+
+// printIndex reads the index from `buf` and prints its fields, with the
+// subfields of arrays indented below them. See `rsf.Index.String`.
+func printIndex(w io.Writer, buf *bufio.Reader) error {
+	index, err := rsf.NewReader().ReadIndex(buf)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprint(w, index.String())
+	return err
+}
+
+// printFieldValues prints the value of the top-level field `field` of each
+// object in `buf`, one per line. See `rsf.Reader.ReadFieldString`.
+func printFieldValues(w io.Writer, buf *bufio.Reader, field string) error {
+	r := rsf.NewReader()
+	_, err := r.ReadIndex(buf)
+	if err != nil {
+		return err
+	}
+	for {
+		_, err = r.BeginObject(buf)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		val, err := r.ReadFieldString(buf, field)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, val)
+		if err != nil {
+			return err
+		}
+		err = r.SkipObject(buf)
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -2,8 +2,12 @@
 package cmd
 
 import (
+	"bytes"
+	"os"
+	"path/filepath"
 	"testing"
 
+	rsf "github.com/rstudio/repository-snapshot-format"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -14,3 +18,208 @@ type RsfPrintCommandSuite struct {
 func TestRsfPrintCommandSuite(t *testing.T) {
 	suite.Run(t, &RsfPrintCommandSuite{})
 }
+
+func (s *RsfPrintCommandSuite) TestPrintHex() {
+	type record struct {
+		Name string `rsf:"name"`
+		Age  int    `rsf:"age"`
+	}
+	data := &bytes.Buffer{}
+	w := rsf.NewWriterWithVersion(data, rsf.Version2)
+	_, err := w.WriteObject(record{Name: "rsf", Age: 3})
+	s.Require().Nil(err)
+	path := filepath.Join(s.T().TempDir(), "test.rsf")
+	s.Require().Nil(os.WriteFile(path, data.Bytes(), 0o644))
+
+	out := &bytes.Buffer{}
+	PrintCmd.SetOut(out)
+	PrintCmd.SetArgs([]string{"--hex", path})
+	defer func() {
+		printHex = false
+	}()
+	s.Require().Nil(PrintCmd.Execute())
+	s.Assert().Equal(`-----------------------------------------
+                Object[1]                
+-----------------------------------------
+name (string): rsf
+  hex: 03 00 00 00 72 73 66
+age (int): 3
+  hex: 06 00 00 00 00 00 00 00 00 00
+`, out.String())
+}
+
+func (s *RsfPrintCommandSuite) TestPrintTable() {
+	type file struct {
+		ID   int    `rsf:"id,skip"`
+		Name string `rsf:"name"`
+		Size int    `rsf:"size"`
+	}
+	type record struct {
+		Files []file `rsf:"files,index:id"`
+	}
+	data := &bytes.Buffer{}
+	w := rsf.NewWriterWithVersion(data, rsf.Version2)
+	_, err := w.WriteObject(record{Files: []file{{ID: 1, Name: "a.tar.gz", Size: 1024}, {ID: 20, Name: "b.whl", Size: 7}}})
+	s.Require().Nil(err)
+	path := filepath.Join(s.T().TempDir(), "test.rsf")
+	s.Require().Nil(os.WriteFile(path, data.Bytes(), 0o644))
+
+	out := &bytes.Buffer{}
+	PrintCmd.SetOut(out)
+	PrintCmd.SetArgs([]string{"--table", path})
+	defer func() {
+		printTable = false
+	}()
+	s.Require().Nil(PrintCmd.Execute())
+	s.Assert().Equal(`-----------------------------------------
+                Object[1]                
+-----------------------------------------
+files (indexed array(2)):
+    @key  name      size
+    1     a.tar.gz  1024
+    20    b.whl     7
+`, out.String())
+}
+
+func (s *RsfPrintCommandSuite) TestPrintSchema() {
+	// The shape of the complex test data in the rsf package.
+	type classifier struct {
+		Name   string   `rsf:"name"`
+		Type   int      `rsf:"type"`
+		Values []string `rsf:"values"`
+	}
+	type snapshot struct {
+		Description string `rsf:"description"`
+		Deleted     bool   `rsf:"deleted"`
+		Snapshot    string `rsf:"snapshot,skip,fixed:10"`
+		Version     string `rsf:"version"`
+		Summary     string `rsf:"summary"`
+		License     string `rsf:"license"`
+	}
+	type record struct {
+		HomePage    string       `rsf:"homepage"`
+		Classifiers []classifier `rsf:"classifiers"`
+		Snapshots   []snapshot   `rsf:"snapshots,index:snapshot"`
+		Popularity  int64        `rsf:"popularity"`
+	}
+	data := &bytes.Buffer{}
+	w := rsf.NewWriterWithVersion(data, rsf.Version2)
+	_, err := w.WriteObject(record{
+		HomePage:    "http://homepage.com",
+		Classifiers: []classifier{{Name: "License", Type: 2, Values: []string{"one"}}},
+		Snapshots:   []snapshot{{Description: "numpy", Snapshot: "2020-10-11", Version: "3.0.3"}},
+		Popularity:  25,
+	})
+	s.Require().Nil(err)
+	path := filepath.Join(s.T().TempDir(), "test.rsf")
+	s.Require().Nil(os.WriteFile(path, data.Bytes(), 0o644))
+
+	out := &bytes.Buffer{}
+	PrintCmd.SetOut(out)
+	PrintCmd.SetArgs([]string{"--schema", path})
+	defer func() {
+		printSchema = false
+	}()
+	s.Require().Nil(PrintCmd.Execute())
+	s.Assert().Equal(`homepage str
+classifiers arr[struct]
+  name str
+  type i64
+  values arr[string]
+snapshots arr[struct] index:string(10)
+  description str
+  deleted bool
+  version str
+  summary str
+  license str
+popularity i64
+`, out.String())
+}
+
+func (s *RsfPrintCommandSuite) TestPrintField() {
+	type record struct {
+		Name   string  `rsf:"name"`
+		Age    int     `rsf:"age"`
+		Rating float64 `rsf:"rating"`
+	}
+	data := &bytes.Buffer{}
+	w := rsf.NewWriterWithVersion(data, rsf.Version2)
+	for _, rec := range []record{{Name: "rsf", Age: 3, Rating: 1.5}, {Name: "rspm", Age: 55, Rating: 92.689}} {
+		_, err := w.WriteObject(rec)
+		s.Require().Nil(err)
+	}
+	path := filepath.Join(s.T().TempDir(), "test.rsf")
+	s.Require().Nil(os.WriteFile(path, data.Bytes(), 0o644))
+	defer func() {
+		printField = ""
+	}()
+
+	for field, expected := range map[string]string{
+		"name":   "rsf\nrspm\n",
+		"age":    "3\n55\n",
+		"rating": "1.500000\n92.689000\n",
+	} {
+		out := &bytes.Buffer{}
+		PrintCmd.SetOut(out)
+		PrintCmd.SetArgs([]string{"--field", field, path})
+		s.Require().Nil(PrintCmd.Execute())
+		s.Assert().Equal(expected, out.String())
+	}
+}
+
+func (s *RsfPrintCommandSuite) TestPrintNotRSF() {
+	path := filepath.Join(s.T().TempDir(), "test.txt")
+	s.Require().Nil(os.WriteFile(path, []byte("hello, world\nthis is not RSF\n"), 0o644))
+
+	for _, args := range [][]string{{path}, {"--json", path}, {"--schema", path}} {
+		PrintCmd.SetOut(&bytes.Buffer{})
+		PrintCmd.SetArgs(args)
+		err := PrintCmd.Execute()
+		s.Require().NotNil(err)
+		s.Assert().Equal(path+" does not appear to be an RSF file", err.Error())
+	}
+	printJSON = false
+	printSchema = false
+}
+
+func (s *RsfPrintCommandSuite) TestPrintFooterIndex() {
+	type record struct {
+		Name string `rsf:"name"`
+	}
+	data := &bytes.Buffer{}
+	w := rsf.NewWriterWithVersion(data, rsf.Version2, rsf.FooterIndex(true))
+	_, err := w.WriteObject(record{Name: "rsf"})
+	s.Require().Nil(err)
+	s.Require().Nil(w.Close())
+	path := filepath.Join(s.T().TempDir(), "test.rsf")
+	s.Require().Nil(os.WriteFile(path, data.Bytes(), 0o644))
+
+	for _, args := range [][]string{{path}, {"--json", path}} {
+		PrintCmd.SetOut(&bytes.Buffer{})
+		PrintCmd.SetArgs(args)
+		err = PrintCmd.Execute()
+		s.Require().NotNil(err)
+		s.Assert().Equal(path+" has a footer index and cannot be printed", err.Error())
+	}
+	printJSON = false
+}
+
+func (s *RsfPrintCommandSuite) TestPrintFooterIndexMarker() {
+	type record struct {
+		Name string `rsf:"name"`
+	}
+	data := &bytes.Buffer{}
+	w := rsf.NewWriterWithVersion(data, rsf.Version1)
+	_, err := w.WriteObject(record{Name: "abcdRSFI"})
+	s.Require().Nil(err)
+	s.Require().Nil(w.Close())
+	path := filepath.Join(s.T().TempDir(), "test.rsf")
+	s.Require().Nil(os.WriteFile(path, data.Bytes(), 0o644))
+
+	// Files that merely end with the footer index marker are printed.
+	out := &bytes.Buffer{}
+	PrintCmd.SetOut(out)
+	PrintCmd.SetArgs([]string{path})
+	s.Require().Nil(PrintCmd.Execute())
+	s.Assert().Contains(out.String(), "name (string): abcdRSFI\n")
+}
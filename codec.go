@@ -0,0 +1,112 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codec is the compression used for compressed string fields (see the
+// `compress` tag option). See `Compression`.
+type Codec int
+
+const (
+	// CodecGzip compresses strings with gzip. This is the default.
+	CodecGzip Codec = iota
+
+	// CodecZstd compresses strings with zstd, which usually compresses
+	// better and faster than gzip. It is recorded in the index header, so
+	// it requires Version3 or greater.
+	CodecZstd
+)
+
+// MaxDecompressedSize is the maximum size of a decompressed string field, in
+// bytes. Compressed strings can expand to many times their size, so readers
+// stop decompressing a value at this size and return `ErrDecompressedSize`
+// rather than allocating without bound for a corrupt or malicious file.
+const MaxDecompressedSize = 64 * 1024 * 1024
+
+var ErrDecompressedSize = errors.New("the decompressed string exceeds the maximum size")
+
+// codec compresses and decompresses the values of compressed string fields.
+type codec interface {
+	compress(val []byte) ([]byte, error)
+	decompress(bs []byte) ([]byte, error)
+}
+
+// codecs maps each `Codec` to its implementation.
+var codecs = map[Codec]codec{
+	CodecGzip: gzipCodec{},
+	CodecZstd: zstdCodec{},
+}
+
+type gzipCodec struct{}
+
+func (gzipCodec) compress(val []byte) ([]byte, error) {
+	compressed := &bytes.Buffer{}
+	gz := gzip.NewWriter(compressed)
+	_, err := gz.Write(val)
+	if err != nil {
+		return nil, err
+	}
+	err = gz.Close()
+	if err != nil {
+		return nil, err
+	}
+	return compressed.Bytes(), nil
+}
+
+func (gzipCodec) decompress(bs []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(bs))
+	if err != nil {
+		return nil, err
+	}
+	val, err := io.ReadAll(io.LimitReader(gz, MaxDecompressedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(val) > MaxDecompressedSize {
+		return nil, ErrDecompressedSize
+	}
+	return val, gz.Close()
+}
+
+// The zstd encoder and decoder are safe for concurrent use, and are costly to
+// create, so they are created once and shared. The decoder is limited to
+// `MaxDecompressedSize`, like gzip.
+var zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) {
+	return zstd.NewWriter(nil)
+})
+var zstdDecoder = sync.OnceValues(func() (*zstd.Decoder, error) {
+	return zstd.NewReader(nil,
+		zstd.WithDecoderMaxMemory(MaxDecompressedSize),
+		zstd.WithDecoderMaxWindow(MaxDecompressedSize),
+	)
+})
+
+type zstdCodec struct{}
+
+func (zstdCodec) compress(val []byte) ([]byte, error) {
+	enc, err := zstdEncoder()
+	if err != nil {
+		return nil, err
+	}
+	return enc.EncodeAll(val, nil), nil
+}
+
+func (zstdCodec) decompress(bs []byte) ([]byte, error) {
+	dec, err := zstdDecoder()
+	if err != nil {
+		return nil, err
+	}
+	val, err := dec.DecodeAll(bs, nil)
+	if errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded) {
+		return nil, ErrDecompressedSize
+	}
+	return val, err
+}
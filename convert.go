@@ -0,0 +1,328 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrConvertVersion = errors.New("files can only be converted to Version1, Version2, or Version3")
+
+// Convert reads the RSF data in `src`, which may use any index version, and
+// writes it to `dst` with the index format of `version`. Each object is
+// decoded and re-encoded, so the objects are written like `Rewrite` with no
+// transform.
+//
+// A `Version1` index does not describe the element type of arrays, or whether
+// struct arrays are indexed, so objects in `Version1` files cannot be decoded
+// with the index alone. When converting a `Version1` file, this metadata is
+// inferred from the arrays written in the objects: arrays of strings, ints,
+// floats, and bools are recognized by their sizes, and indexed struct arrays
+// by the sizes recorded in their array index. Index keys are assumed to be
+// fixed-length strings when they are printable, and ints or byte arrays
+// otherwise. Since the objects are read twice, once to infer the metadata and
+// again to convert them, `src` must be seekable. Arrays that are empty in
+// every object can't be inferred, and are written as empty arrays.
+//
+// Multi-schema files cannot be converted.
+func Convert(src io.ReadSeeker, dst io.Writer, version int) error {
+	if version < Version1 || version > Version3 {
+		return ErrConvertVersion
+	}
+
+	r := &rsfReader{}
+	buf := bufio.NewReader(src)
+	index, err := r.ReadIndex(buf)
+	if err != nil {
+		return fmt.Errorf("error reading index: %s", err)
+	}
+	if r.Schemas() != nil {
+		return errors.New("multi-schema files cannot be converted")
+	}
+
+	if r.indexVersion == 1 {
+		index, err = inferArrays(r, index, buf)
+		if err != nil {
+			return err
+		}
+
+		// Read the objects again with the inferred index.
+		_, err = src.Seek(0, io.SeekStart)
+		if err != nil {
+			return err
+		}
+		buf.Reset(src)
+		r = &rsfReader{}
+		_, err = r.ReadIndex(buf)
+		if err != nil {
+			return fmt.Errorf("error reading index: %s", err)
+		}
+		r.SetIndex(index)
+	}
+
+	return rewriteObjects(r, index, buf, NewWriterWithVersion(dst, version), func(obj map[string]any) map[string]any {
+		return obj
+	})
+}
+
+// arrayInferrer infers the array metadata missing from a `Version1` index
+// from the arrays written in each object. See `Convert`.
+type arrayInferrer struct {
+	r *rsfReader
+
+	// The arrays whose metadata has been inferred, and the number of arrays
+	// that remain.
+	resolved map[*IndexEntry]bool
+	pending  int
+}
+
+// inferArrays reads objects from `buf` until the metadata of each array in
+// `index` has been inferred, or the objects end, and returns a copy of
+// `index` including the inferred metadata.
+func inferArrays(r *rsfReader, index Index, buf *bufio.Reader) (Index, error) {
+	index = copyIndex(index)
+	in := &arrayInferrer{r: r, resolved: make(map[*IndexEntry]bool)}
+	in.pending = countArrays(index)
+
+	for i := 0; in.pending > 0; i++ {
+		sz, err := r.BeginObject(buf)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("error reading object %d: %s", i, err)
+		}
+		data, err := readBytes(buf, sz-sizeFieldLen)
+		if err != nil {
+			return nil, fmt.Errorf("error reading object %d: %s", i, unexpectedEOF(err))
+		}
+		r.pos += int64(len(data))
+
+		_, err = in.fields(index, data, true)
+		if err != nil {
+			return nil, fmt.Errorf("error inferring the arrays of object %d: %s", i, err)
+		}
+	}
+	return index, nil
+}
+
+// copyIndex returns a deep copy of `index`.
+func copyIndex(index Index) Index {
+	cp := make(Index, len(index))
+	copy(cp, index)
+	for i := range cp {
+		if cp[i].Subfields != nil {
+			cp[i].Subfields = copyIndex(cp[i].Subfields)
+		}
+	}
+	return cp
+}
+
+// countArrays returns the number of arrays in `index`, including nested
+// arrays.
+func countArrays(index Index) int {
+	var n int
+	for _, entry := range index {
+		if entry.FieldType == FieldTypeArray {
+			n += 1 + countArrays(entry.Subfields)
+		}
+	}
+	return n
+}
+
+// fields parses the fields described by `index` at the start of `data`, and
+// returns the number of bytes used. When `infer` is true, the metadata of
+// arrays is inferred; otherwise, arrays are skipped using their size fields.
+func (in *arrayInferrer) fields(index Index, data []byte, infer bool) (int, error) {
+	var pos int
+	for i := range index {
+		entry := &index[i]
+		var sz int
+		var err error
+		if entry.FieldType == FieldTypeArray {
+			sz, err = in.array(entry, data[pos:], infer)
+		} else {
+			sz, err = in.r.fieldLen(*entry, bufio.NewReader(bytes.NewReader(data[pos:])))
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+		}
+		if err != nil {
+			return 0, withField(entry.FieldName, err)
+		}
+		if sz > len(data)-pos {
+			return 0, withField(entry.FieldName, io.ErrUnexpectedEOF)
+		}
+		pos += sz
+	}
+	return pos, nil
+}
+
+// array parses the array described by `entry` at the start of `data`,
+// inferring its metadata if `infer` is true, and returns the array's size.
+func (in *arrayInferrer) array(entry *IndexEntry, data []byte, infer bool) (int, error) {
+	if len(data) < sizeFieldLen+sizeFieldLen {
+		return 0, io.ErrUnexpectedEOF
+	}
+	sz := int(binary.LittleEndian.Uint32(data))
+	n := int(binary.LittleEndian.Uint32(data[sizeFieldLen:]))
+	if sz < sizeFieldLen+sizeFieldLen || sz > len(data) {
+		return 0, fmt.Errorf("invalid array size %d", sz)
+	}
+	if !infer {
+		return sz, nil
+	}
+
+	body := data[sizeFieldLen+sizeFieldLen : sz]
+	if !in.resolved[entry] {
+		if !in.infer(entry, n, body) {
+			// The array can't be inferred from this object (e.g., it's
+			// empty), so its nested arrays can't be either.
+			return sz, nil
+		}
+		in.resolved[entry] = true
+		in.pending--
+	}
+
+	// Infer the nested arrays of struct elements.
+	if len(entry.Subfields) > 0 {
+		_, err := in.elements(entry, n, body, true)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return sz, nil
+}
+
+// infer infers the element type of the array described by `entry`, and
+// whether it's indexed, from the array's `n` elements in `body`. False is
+// returned if the array's metadata can't be inferred.
+func (in *arrayInferrer) infer(entry *IndexEntry, n int, body []byte) bool {
+	if n == 0 {
+		return false
+	}
+
+	// Struct arrays are indexed if their elements, read one after another,
+	// don't fill the array.
+	if len(entry.Subfields) > 0 {
+		entry.SubfieldType = int(reflect.Struct)
+		used, err := in.elements(entry, n, body, false)
+		if err == nil && used == len(body) {
+			return true
+		}
+		return in.inferIndexed(entry, n, body)
+	}
+
+	// Other arrays are recognized by the size of their elements.
+	switch {
+	case stringsFill(n, body):
+		entry.SubfieldType = int(reflect.String)
+	case n*sizeInt64 == len(body):
+		entry.SubfieldType = int(reflect.Int64)
+	case n*sizeFloat64 == len(body):
+		entry.SubfieldType = int(reflect.Float64)
+	case n == len(body):
+		entry.SubfieldType = int(reflect.Bool)
+	default:
+		return false
+	}
+	return true
+}
+
+// inferIndexed finds the size of the array index keys for which the sizes in
+// the array index of the struct array described by `entry` match the
+// elements in `body`.
+func (in *arrayInferrer) inferIndexed(entry *IndexEntry, n int, body []byte) bool {
+	for keySz := 1; n*(keySz+sizeFieldLen) <= len(body); keySz++ {
+		entry.Indexed = true
+		entry.IndexSize = keySz
+		used, err := in.elements(entry, n, body, false)
+		if err != nil || used != len(body) {
+			continue
+		}
+
+		// Use the keys to guess the index type.
+		printable := true
+		for i := 0; i < n && printable; i++ {
+			key := body[i*(keySz+sizeFieldLen) : i*(keySz+sizeFieldLen)+keySz]
+			printable = isPrintable(key)
+		}
+		switch {
+		case printable:
+			entry.IndexType = int(reflect.String)
+		case keySz == sizeInt64:
+			entry.IndexType = int(reflect.Int64)
+		default:
+			entry.IndexType = int(reflect.Array)
+		}
+		return true
+	}
+
+	entry.Indexed = false
+	entry.IndexSize = 0
+	return false
+}
+
+// elements parses the `n` elements of the struct array described by `entry`
+// in `body`, and returns the number of bytes used, including the array index.
+// For indexed arrays, the size of each element must match the size recorded
+// in the array index.
+func (in *arrayInferrer) elements(entry *IndexEntry, n int, body []byte, infer bool) (int, error) {
+	var pos int
+	if entry.Indexed {
+		pos = n * (entry.IndexSize + sizeFieldLen)
+		if pos > len(body) {
+			return 0, io.ErrUnexpectedEOF
+		}
+	}
+	for i := 0; i < n; i++ {
+		used, err := in.fields(entry.Subfields, body[pos:], infer)
+		if err != nil {
+			return 0, err
+		}
+		if entry.Indexed {
+			at := i*(entry.IndexSize+sizeFieldLen) + entry.IndexSize
+			if want := int(binary.LittleEndian.Uint32(body[at:])); used != want {
+				return 0, fmt.Errorf("element %d size %d does not match the array index size %d", i, used, want)
+			}
+		}
+		pos += used
+	}
+	return pos, nil
+}
+
+// stringsFill returns true if `body` consists of exactly `n` variable-length
+// strings.
+func stringsFill(n int, body []byte) bool {
+	var pos int
+	for i := 0; i < n; i++ {
+		if len(body)-pos < sizeFieldLen {
+			return false
+		}
+		sz := int(binary.LittleEndian.Uint32(body[pos:]))
+		if sz > len(body)-pos-sizeFieldLen {
+			return false
+		}
+		pos += sizeFieldLen + sz
+	}
+	return pos == len(body)
+}
+
+// isPrintable returns true if `key` is a printable UTF-8 string.
+func isPrintable(key []byte) bool {
+	if !utf8.Valid(key) {
+		return false
+	}
+	for _, c := range string(key) {
+		if !unicode.IsPrint(c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,76 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ConvertSuite struct {
+	suite.Suite
+}
+
+func TestConvertSuite(t *testing.T) {
+	suite.Run(t, &ConvertSuite{})
+}
+
+// printed returns the `Print` output for the RSF data in `data`.
+func (s *ConvertSuite) printed(data []byte) string {
+	out := &bytes.Buffer{}
+	s.Require().Nil(Print(out, bufio.NewReader(bytes.NewReader(data))))
+	return out.String()
+}
+
+func (s *ConvertSuite) TestConvertVersion1() {
+	v1 := &bytes.Buffer{}
+	w := NewWriter(v1)
+	for _, obj := range testComplexData {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+
+	// The array metadata is inferred, so the converted file prints like
+	// the Version2 fixture.
+	out := &bytes.Buffer{}
+	s.Require().Nil(Convert(bytes.NewReader(v1.Bytes()), out, Version2))
+	s.Assert().Equal(s.printed(getComplexData(&s.Suite).Bytes()), s.printed(out.Bytes()))
+
+	r := NewReader()
+	index, err := r.ReadIndex(bufio.NewReader(bytes.NewReader(out.Bytes())))
+	s.Require().Nil(err)
+	s.Assert().Equal(IndexEntry{
+		FieldName:    "snapshots",
+		FieldType:    FieldTypeArray,
+		Indexed:      true,
+		IndexType:    int(reflect.String),
+		IndexSize:    10,
+		SubfieldType: int(reflect.Struct),
+		Subfields:    index[5].Subfields,
+	}, index[5])
+	s.Assert().Equal(int(reflect.String), index[3].Subfields[2].SubfieldType)
+}
+
+func (s *ConvertSuite) TestConvertVersion2() {
+	data := getComplexData(&s.Suite).Bytes()
+
+	// Converting to the same version doesn't change the file.
+	out := &bytes.Buffer{}
+	s.Require().Nil(Convert(bytes.NewReader(data), out, Version2))
+	s.Assert().Equal(data, out.Bytes())
+
+	// Version3 files print the same objects.
+	v3 := &bytes.Buffer{}
+	s.Require().Nil(Convert(bytes.NewReader(data), v3, Version3))
+	s.Assert().Equal(IndexVersion3, v3.Bytes()[:3])
+	s.Assert().Equal(s.printed(data), s.printed(v3.Bytes()))
+}
+
+func (s *ConvertSuite) TestConvertInvalidVersion() {
+	data := getComplexData(&s.Suite).Bytes()
+	err := Convert(bytes.NewReader(data), &bytes.Buffer{}, 4)
+	s.Assert().ErrorIs(err, ErrConvertVersion)
+}
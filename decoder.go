@@ -0,0 +1,244 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"fmt"
+)
+
+// Token is a value returned by `Decoder.Token`: one of `StartObject`,
+// `EndObject`, `StartArray`, `EndArray`, or `Field`.
+type Token any
+
+// StartObject begins an object, or an element of an array of structs.
+type StartObject struct{}
+
+// EndObject ends an object, or an element of an array of structs.
+type EndObject struct{}
+
+// StartArray begins an array field with `Len` elements. For indexed arrays,
+// `Keys` holds the index key of each element.
+type StartArray struct {
+	Name string
+	Len  int
+	Keys []any
+}
+
+// EndArray ends an array field.
+type EndArray struct{}
+
+// Field is a scalar field, or an element of an array of scalars, in which
+// case `Name` is the name of the array. The `Value` has the type used by
+// `DecodeObject`.
+type Field struct {
+	Name  string
+	Type  int
+	Value any
+}
+
+// Decoder reads a file as a stream of tokens, so that very large files can
+// be processed without decoding whole objects into memory. See `Token`.
+type Decoder struct {
+	r       *rsfReader
+	buf     *bufio.Reader
+	indexed bool
+	objects int
+	stack   []decoderFrame
+
+	// Tokens to return before reading further, and the overflow string
+	// fields of the current object, which are returned at its end.
+	pending  []Token
+	overflow []Token
+}
+
+// decoderFrame is the state of an object, array element, or array being
+// decoded. Array frames have an `array` entry.
+type decoderFrame struct {
+	fields Index
+	next   int
+
+	array   *IndexEntry
+	element IndexEntry
+	len     int
+}
+
+// NewDecoder returns a decoder that reads a file, including its index, from
+// `buf`. The reader options apply to the decoder.
+func NewDecoder(buf *bufio.Reader, opts ...ReaderOption) *Decoder {
+	r := &rsfReader{}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return &Decoder{r: r, buf: buf}
+}
+
+// Token returns the next token. Each object is returned as a `StartObject`
+// token, followed by a token for each field in index order, and an
+// `EndObject` token. Array fields are returned as a `StartArray` token,
+// followed by a `Field` token for each scalar element or the tokens of an
+// object for each struct element, and an `EndArray` token. Overflow string
+// fields are returned just before the `EndObject` token of the top-level
+// object, since the strings follow the object's other fields.
+//
+// An `io.EOF` error is returned at the end of the objects. The decoder cannot
+// continue after any other error.
+func (d *Decoder) Token() (Token, error) {
+	if !d.indexed {
+		_, err := d.r.ReadIndex(d.buf)
+		if err != nil {
+			return nil, err
+		}
+		d.indexed = true
+	}
+
+	for {
+		if len(d.pending) > 0 {
+			t := d.pending[0]
+			d.pending = d.pending[1:]
+			return t, nil
+		}
+
+		if len(d.stack) == 0 {
+			return d.startObject()
+		}
+
+		top := &d.stack[len(d.stack)-1]
+		if top.array != nil {
+			return d.nextElement(top)
+		}
+
+		if top.next == len(top.fields) {
+			d.stack = d.stack[:len(d.stack)-1]
+			if len(d.stack) > 0 {
+				return EndObject{}, nil
+			}
+			err := d.endObject()
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		entry := top.fields[top.next]
+		top.next++
+		t, err := d.nextField(entry)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding field %s: %w", entry.FieldName, err)
+		}
+		if t != nil {
+			return t, nil
+		}
+	}
+}
+
+// startObject begins the next top-level object.
+func (d *Decoder) startObject() (Token, error) {
+	err := d.r.checkMaxObjects(d.buf, d.objects)
+	if err != nil {
+		return nil, err
+	}
+	_, err = d.r.BeginObject(d.buf)
+	if err != nil {
+		return nil, err
+	}
+	d.objects++
+	d.stack = append(d.stack, decoderFrame{fields: d.r.index})
+	return StartObject{}, nil
+}
+
+// endObject reads the overflow strings of the top-level object and discards
+// anything that remains in the object. The overflow string fields and the
+// `EndObject` token are queued.
+func (d *Decoder) endObject() error {
+	err := d.r.readOverflow(d.buf)
+	if err != nil {
+		return err
+	}
+	err = d.r.SkipObject(d.buf)
+	if err != nil {
+		return err
+	}
+	d.pending = append(d.overflow, EndObject{})
+	d.overflow = nil
+	return nil
+}
+
+// nextField reads the field described by `entry`. A nil token is returned
+// for fields that don't produce a token right away, like overflow strings
+// and the bool bitmap.
+func (d *Decoder) nextField(entry IndexEntry) (Token, error) {
+	switch entry.FieldType {
+	case FieldTypeBoolBitmap:
+		return nil, d.r.readBitmap(entry, d.buf)
+	case FieldTypeOverflowStr:
+		return nil, d.r.readOverflowRef(d.buf, func(s string) error {
+			d.overflow = append(d.overflow, Field{Name: entry.FieldName, Type: entry.FieldType, Value: s})
+			return nil
+		})
+	case FieldTypeArray, FieldTypePackedArray:
+		// Absent arrays produce no tokens.
+		absent, err := d.r.absent(entry)
+		if err != nil || absent {
+			return nil, err
+		}
+		return d.startArray(entry)
+	default:
+		val, err := d.r.decodeValue(entry, d.buf)
+		if err != nil {
+			return nil, err
+		}
+		return Field{Name: entry.FieldName, Type: entry.FieldType, Value: val}, nil
+	}
+}
+
+// startArray reads an array's size, length, and index, and begins the array.
+func (d *Decoder) startArray(entry IndexEntry) (Token, error) {
+	arraySz, err := d.r.ReadSizeField(d.buf)
+	if err != nil {
+		return nil, err
+	}
+	arrayLen, err := d.r.ReadSizeField(d.buf)
+	if err != nil {
+		return nil, err
+	}
+	err = checkArrayLen(entry, arraySz, arrayLen)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := d.r.readArrayKeys(entry, arrayLen, d.buf)
+	if err != nil {
+		return nil, err
+	}
+	element, err := arrayElement(entry, arrayLen)
+	if err != nil {
+		return nil, err
+	}
+
+	d.stack = append(d.stack, decoderFrame{array: &entry, element: element, len: arrayLen})
+	return StartArray{Name: entry.FieldName, Len: arrayLen, Keys: keys}, nil
+}
+
+// nextElement begins the next element of the array `top`, or ends the array.
+func (d *Decoder) nextElement(top *decoderFrame) (Token, error) {
+	if top.next == top.len {
+		d.stack = d.stack[:len(d.stack)-1]
+		return EndArray{}, nil
+	}
+	top.next++
+
+	if top.element.FieldType == 0 {
+		if emptyElements(*top.array) {
+			err := d.r.readEmptyElement(d.buf)
+			if err != nil {
+				return nil, fmt.Errorf("error decoding field %s: %w", top.array.FieldName, err)
+			}
+		}
+		d.stack = append(d.stack, decoderFrame{fields: top.array.Subfields})
+		return StartObject{}, nil
+	}
+	val, err := d.r.decodeValue(top.element, d.buf)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding field %s: %w", top.array.FieldName, err)
+	}
+	return Field{Name: top.array.FieldName, Type: top.element.FieldType, Value: val}, nil
+}
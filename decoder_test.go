@@ -0,0 +1,127 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type DecoderSuite struct {
+	suite.Suite
+}
+
+func TestDecoderSuite(t *testing.T) {
+	suite.Run(t, &DecoderSuite{})
+}
+
+func (s *DecoderSuite) tokens(d *Decoder) []Token {
+	tokens := make([]Token, 0)
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			return tokens
+		}
+		s.Require().Nil(err)
+		tokens = append(tokens, t)
+	}
+}
+
+func (s *DecoderSuite) TestToken() {
+	d := NewDecoder(bufio.NewReader(getComplexData(&s.Suite)))
+	tokens := s.tokens(d)
+
+	str := func(name, val string) Field {
+		return Field{Name: name, Type: FieldTypeVarStr, Value: val}
+	}
+	snapshot := func(description string, deleted bool, version, summary, license string) []Token {
+		return []Token{
+			StartObject{},
+			str("description", description),
+			Field{Name: "deleted", Type: FieldTypeBool, Value: deleted},
+			str("version", version),
+			str("summary", summary),
+			str("license", license),
+			EndObject{},
+		}
+	}
+
+	expected := []Token{
+		StartObject{},
+		str("homepage", "http://homepage.com"),
+		str("cname", "numpy"),
+		str("pname", "Numpy"),
+		StartArray{Name: "classifiers", Len: 2},
+		StartObject{},
+		str("name", "License"),
+		Field{Name: "type", Type: FieldTypeInt64, Value: int64(2)},
+		StartArray{Name: "values", Len: 3},
+		str("values", "one"),
+		str("values", "two"),
+		str("values", "three"),
+		EndArray{},
+		EndObject{},
+		StartObject{},
+		str("name", "Usage"),
+		Field{Name: "type", Type: FieldTypeInt64, Value: int64(1)},
+		StartArray{Name: "values", Len: 0},
+		EndArray{},
+		EndObject{},
+		EndArray{},
+		str("author", "an-author"),
+		StartArray{Name: "snapshots", Len: 3, Keys: []any{"2020-10-11", "2020-10-10", "2020-10-09"}},
+	}
+	expected = append(expected, snapshot("The description of numpy", false, "3.0.3", "numpy summary", "MIT")...)
+	expected = append(expected, snapshot("Older description of numpy", false, "3.0.2", "numpy summary", "MIT")...)
+	expected = append(expected, snapshot("", true, "", "", "")...)
+	expected = append(expected,
+		EndArray{},
+		Field{Name: "popularity", Type: FieldTypeInt64, Value: int64(55)},
+		EndObject{},
+	)
+
+	s.Require().Greater(len(tokens), len(expected))
+	s.Assert().Equal(expected, tokens[:len(expected)])
+
+	// The second object follows.
+	rest := tokens[len(expected):]
+	s.Assert().Equal(StartObject{}, rest[0])
+	s.Assert().Equal(str("homepage", "http://django-home.com"), rest[1])
+	s.Assert().Equal(EndObject{}, rest[len(rest)-1])
+
+	// The decoder stays at the end.
+	_, err := d.Token()
+	s.Assert().Equal(io.EOF, err)
+}
+
+func (s *DecoderSuite) TestTokenOverflow() {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	_, err := w.WriteObject(testOverflowData[0])
+	s.Require().Nil(err)
+
+	d := NewDecoder(bufio.NewReader(buf))
+	tokens := s.tokens(d)
+
+	// Overflow strings are returned at the end of the object.
+	s.Assert().Equal([]Token{
+		StartObject{},
+		Field{Name: "name", Type: FieldTypeVarStr, Value: "numpy"},
+		Field{Name: "downloads", Type: FieldTypeInt64, Value: int64(1000)},
+		Field{Name: "description", Type: FieldTypeOverflowStr, Value: testOverflowData[0].Description},
+		Field{Name: "notes", Type: FieldTypeOverflowStr, Value: "notes"},
+		EndObject{},
+	}, tokens)
+}
+
+func (s *DecoderSuite) TestTokenMaxObjects() {
+	d := NewDecoder(bufio.NewReader(getComplexData(&s.Suite)), MaxObjects(1))
+	var err error
+	for err == nil {
+		_, err = d.Token()
+	}
+	s.Assert().ErrorIs(err, ErrMaxObjects)
+}
@@ -0,0 +1,53 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// EqualFiles reports whether two RSF files have the same content: an equal
+// index (see `Index.Equal`) and the same sequence of objects, decoded with
+// `DecodeObject`. Unlike comparing the bytes of the files, this ignores
+// differences in layout, like the use of a string table, a verbose index, or
+// object padding. Objects are compared one at a time, so the files are not
+// held in memory.
+func EqualFiles(a, b io.Reader) (bool, error) {
+	bufA := bufio.NewReader(a)
+	bufB := bufio.NewReader(b)
+	ra := &rsfReader{}
+	rb := &rsfReader{}
+
+	indexA, err := ra.ReadIndex(bufA)
+	if err != nil {
+		return false, fmt.Errorf("error reading index for file a: %s", err)
+	}
+	indexB, err := rb.ReadIndex(bufB)
+	if err != nil {
+		return false, fmt.Errorf("error reading index for file b: %s", err)
+	}
+	if !indexA.Equal(indexB) {
+		return false, nil
+	}
+
+	for i := 0; ; i++ {
+		objA, errA := ra.DecodeObject(bufA)
+		if errA != nil && errA != io.EOF {
+			return false, fmt.Errorf("error reading object %d for file a: %s", i, errA)
+		}
+		objB, errB := rb.DecodeObject(bufB)
+		if errB != nil && errB != io.EOF {
+			return false, fmt.Errorf("error reading object %d for file b: %s", i, errB)
+		}
+
+		if errA == io.EOF || errB == io.EOF {
+			// The files are equal only if both end here.
+			return errA == errB, nil
+		}
+		if !reflect.DeepEqual(objA, objB) {
+			return false, nil
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type EqualSuite struct {
+	suite.Suite
+}
+
+func TestEqualSuite(t *testing.T) {
+	suite.Run(t, &EqualSuite{})
+}
+
+func (s *EqualSuite) write(data []FullPackageRecordPyPI, opts ...WriterOption) *bytes.Reader {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version3, opts...)
+	for _, obj := range data {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+	s.Require().Nil(w.Close())
+	return bytes.NewReader(buf.Bytes())
+}
+
+func (s *EqualSuite) TestEqualFiles() {
+	plain := s.write(testComplexData).Size()
+	compressed := s.write(testComplexData, StringTable(true)).Size()
+	s.Require().NotEqual(plain, compressed)
+
+	equal, err := EqualFiles(s.write(testComplexData), s.write(testComplexData, StringTable(true)))
+	s.Require().Nil(err)
+	s.Assert().True(equal)
+
+	equal, err = EqualFiles(s.write(testComplexData, VerboseIndex(true)), s.write(testComplexData, PadObjects(8)))
+	s.Require().Nil(err)
+	s.Assert().True(equal)
+}
+
+func (s *EqualSuite) TestNotEqualFiles() {
+	// Fewer objects.
+	equal, err := EqualFiles(s.write(testComplexData), s.write(testComplexData[:1]))
+	s.Require().Nil(err)
+	s.Assert().False(equal)
+	equal, err = EqualFiles(s.write(testComplexData[:1]), s.write(testComplexData))
+	s.Require().Nil(err)
+	s.Assert().False(equal)
+
+	// Different values.
+	changed := append([]FullPackageRecordPyPI{}, testComplexData...)
+	changed[1].Popularity = 56
+	equal, err = EqualFiles(s.write(testComplexData), s.write(changed))
+	s.Require().Nil(err)
+	s.Assert().False(equal)
+
+	// Different index.
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version3)
+	_, err = w.WriteObject(testNamedData)
+	s.Require().Nil(err)
+	equal, err = EqualFiles(s.write(testComplexData), buf)
+	s.Require().Nil(err)
+	s.Assert().False(equal)
+}
+
+func (s *EqualSuite) TestEqualFilesErrors() {
+	_, err := EqualFiles(bytes.NewReader([]byte{1, 2}), s.write(testComplexData))
+	s.Assert().ErrorContains(err, "error reading index for file a")
+}
@@ -0,0 +1,216 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"reflect"
+	"time"
+)
+
+var ErrMapFieldType = errors.New("arrays and packed bools cannot be mapped")
+var ErrMapOverflow = errors.New("objects with overflow strings cannot be mapped")
+var ErrMapStringTable = errors.New("files with a string table cannot be mapped")
+var ErrMapKeyTable = errors.New("files with an object key table cannot be mapped")
+var ErrMapMultiSchema = errors.New("multi-schema files cannot be mapped")
+var ErrMapColumnar = errors.New("columnar files cannot be mapped")
+
+// MapField copies the RSF data in `src` to `dst`, replacing the value of the
+// top-level field `field` in each object with the result of `fn`. The value
+// is passed to `fn` as decoded by `DecodeObject`, and the result must be
+// convertible to the field's type (e.g., a string for string fields, or any
+// integer for integer fields).
+//
+// Only the mapped field is decoded. The raw bytes of the index and of every
+// other field are copied as-is, so unmapped fields are preserved exactly. The
+// size of each object is updated for the new value.
+//
+// Array fields and packed bools cannot be mapped. Files with overflow strings,
+// a string table, an object key table, or several schemas are rejected,
+// since changing the size of a field would invalidate their offsets and
+// references. Columnar files, which have no objects, are also rejected.
+func MapField(src io.Reader, dst io.Writer, field string, fn func(any) any) error {
+	// Record the bytes read from `src` so that they can be copied.
+	rec := &byteRecorder{}
+	buf := bufio.NewReader(io.TeeReader(src, rec))
+
+	r := &rsfReader{}
+	w := &rsfWriter{}
+	index, err := r.ReadIndex(buf)
+	if err != nil {
+		return fmt.Errorf("error reading index: %s", err)
+	}
+	w.codec = r.codec()
+	if r.features&FeatureKeyTable != 0 {
+		return ErrMapKeyTable
+	}
+	if r.strings != nil {
+		return ErrMapStringTable
+	}
+	if r.schemas != nil {
+		return ErrMapMultiSchema
+	}
+	if r.features&FeatureColumnar != 0 {
+		return ErrMapColumnar
+	}
+
+	mapped := -1
+	for n, entry := range index {
+		if entry.FieldType == FieldTypeOverflowStr {
+			return ErrMapOverflow
+		}
+		if entry.FieldName == field && mapped < 0 {
+			mapped = n
+		}
+	}
+	if mapped < 0 {
+		return ErrNoSuchField
+	}
+	switch index[mapped].FieldType {
+	case FieldTypeArray, FieldTypePackedArray, FieldTypePackedBool, FieldTypeBoolBitmap:
+		return ErrMapFieldType
+	}
+
+	_, err = dst.Write(rec.bytes(0, r.pos))
+	if err != nil {
+		return err
+	}
+
+	obj := &bytes.Buffer{}
+	for i := 0; ; i++ {
+		rec.trim(r.pos)
+		_, err = r.BeginObject(buf)
+		if err == io.EOF {
+			// Copy the end of objects marker, if any, and the rest.
+			_, err = dst.Write(rec.bytes(rec.base, r.pos))
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(dst, buf)
+			return err
+		} else if err != nil {
+			return fmt.Errorf("error reading object %d: %s", i, err)
+		}
+
+		obj.Reset()
+		for n, entry := range index {
+			if n != mapped {
+				err = r.copyField(entry, buf, rec, obj)
+				if err != nil {
+					return fmt.Errorf("error reading object %d field %s: %s", i, entry.FieldName, err)
+				}
+				continue
+			}
+
+			var val any
+			val, err = r.decodeValue(entry, buf)
+			if err != nil {
+				return fmt.Errorf("error reading object %d field %s: %s", i, entry.FieldName, err)
+			}
+			err = w.writeValue(entry, fn(val), obj)
+			if err != nil {
+				return fmt.Errorf("error writing object %d field %s: %s", i, entry.FieldName, err)
+			}
+		}
+
+		// Copy anything following the fields, like padding.
+		start := r.pos
+		err = r.SkipObject(buf)
+		if err != nil {
+			return fmt.Errorf("error reading object %d: %s", i, err)
+		}
+		obj.Write(rec.bytes(start, r.pos))
+
+		_, err = w.WriteSizeField(0, obj.Len()+sizeFieldLen, dst)
+		if err != nil {
+			return err
+		}
+		_, err = dst.Write(obj.Bytes())
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// copyField advances past the field described by `entry`, copying its raw
+// bytes, as recorded by `rec`, to `out`.
+func (f *rsfReader) copyField(entry IndexEntry, buf *bufio.Reader, rec *byteRecorder, out io.Writer) error {
+	start := f.pos
+	err := f.advance(entry, buf)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(rec.bytes(start, f.pos))
+	return err
+}
+
+// writeValue writes `val` as the field described by `entry`. The value is
+// converted to the field's type like the values passed to `Rewrite`; a nil
+// value is written as the zero value.
+func (f *rsfWriter) writeValue(entry IndexEntry, val any, buf *bytes.Buffer) error {
+	var t reflect.Type
+	switch entry.FieldType {
+	case FieldTypeVarStr, FieldTypeCompressedStr, FieldTypeFixedStr:
+		t = reflect.TypeOf("")
+	case FieldTypeBool:
+		t = reflect.TypeOf(false)
+	case FieldTypeInt64, FieldTypeFixedInt64:
+		t = reflect.TypeOf(int64(0))
+	case FieldTypeFloat:
+		t = reflect.TypeOf(float64(0))
+	case FieldTypeBigEndianInt, FieldTypeBigEndianFloat:
+		t = bigEndianType(entry)
+	case FieldTypeIP:
+		t = netipAddrType
+	case FieldTypeDays:
+		t = timeType
+	case FieldTypeOpaque, FieldTypeBytes:
+		t = reflect.TypeOf([]byte{})
+	default:
+		return fmt.Errorf("unexpected index field type %d", entry.FieldType)
+	}
+
+	v := reflect.New(t).Elem()
+	if val != nil {
+		err := convert(v, val)
+		if err != nil {
+			return err
+		}
+	}
+
+	var err error
+	switch entry.FieldType {
+	case FieldTypeVarStr:
+		_, err = f.WriteStringField(0, v.String(), buf)
+	case FieldTypeCompressedStr:
+		_, err = f.WriteCompressedStringField(0, v.String(), buf)
+	case FieldTypeFixedStr:
+		_, err = f.WriteFixedStringField(0, entry.FieldSize, v.String(), buf)
+	case FieldTypeBool:
+		_, err = f.WriteBoolField(0, v.Bool(), buf)
+	case FieldTypeInt64:
+		_, err = f.WriteInt64Field(0, v.Int(), buf)
+	case FieldTypeFixedInt64:
+		_, err = f.WriteFixedInt64Field(0, v.Int(), buf)
+	case FieldTypeFloat:
+		_, err = f.WriteFloatField(0, v.Float(), buf)
+	case FieldTypeBigEndianInt, FieldTypeBigEndianFloat:
+		_, err = f.writeBigEndian(v, entry.FieldSize, buf)
+	case FieldTypeIP:
+		_, err = f.WriteIPField(0, v.Interface().(netip.Addr), buf)
+	case FieldTypeDays:
+		_, err = f.WriteDaysField(0, v.Interface().(time.Time), buf)
+	case FieldTypeOpaque:
+		_, err = f.WriteSizeField(0, v.Len(), buf)
+		if err == nil {
+			_, err = buf.Write(v.Bytes())
+		}
+	case FieldTypeBytes:
+		_, err = f.WriteBytesField(0, v.Bytes(), buf)
+	}
+	return err
+}
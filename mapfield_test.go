@@ -0,0 +1,99 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type MapFieldSuite struct {
+	suite.Suite
+}
+
+func TestMapFieldSuite(t *testing.T) {
+	suite.Run(t, &MapFieldSuite{})
+}
+
+func (s *MapFieldSuite) TestMapField() {
+	data := getComplexData(&s.Suite).Bytes()
+
+	var authors []any
+	out := &bytes.Buffer{}
+	err := MapField(bytes.NewReader(data), out, "author", func(v any) any {
+		authors = append(authors, v)
+		return "REDACTED"
+	})
+	s.Require().Nil(err)
+	s.Assert().Equal([]any{"an-author", "be-an-author"}, authors)
+
+	// The result matches the redacted data written from scratch, so the
+	// other fields are unchanged.
+	expected := &bytes.Buffer{}
+	w := NewWriterWithVersion(expected, Version2)
+	for _, obj := range testComplexData {
+		obj.Author = "REDACTED"
+		_, err = w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+	s.Assert().Equal(expected.Bytes(), out.Bytes())
+}
+
+func (s *MapFieldSuite) TestMapFieldConvert() {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version3, VerboseIndex(true))
+	for _, obj := range testComplexData {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+	s.Require().Nil(w.Close())
+
+	// Integers of any type can be written to integer fields.
+	out := &bytes.Buffer{}
+	err := MapField(bytes.NewReader(buf.Bytes()), out, "popularity", func(v any) any {
+		return int(v.(int64) + 1)
+	})
+	s.Require().Nil(err)
+
+	equal, err := EqualFiles(bytes.NewReader(buf.Bytes()), bytes.NewReader(out.Bytes()))
+	s.Require().Nil(err)
+	s.Assert().False(equal)
+
+	// Mapping back restores the original data.
+	restored := &bytes.Buffer{}
+	err = MapField(out, restored, "popularity", func(v any) any {
+		return v.(int64) - 1
+	})
+	s.Require().Nil(err)
+	s.Assert().Equal(buf.Bytes(), restored.Bytes())
+}
+
+func (s *MapFieldSuite) TestMapFieldErrors() {
+	data := getComplexData(&s.Suite).Bytes()
+	identity := func(v any) any { return v }
+
+	err := MapField(bytes.NewReader(data), &bytes.Buffer{}, "missing", identity)
+	s.Assert().ErrorIs(err, ErrNoSuchField)
+
+	err = MapField(bytes.NewReader(data), &bytes.Buffer{}, "snapshots", identity)
+	s.Assert().ErrorIs(err, ErrMapFieldType)
+
+	err = MapField(bytes.NewReader(data), &bytes.Buffer{}, "author", func(v any) any { return 1 })
+	s.Assert().EqualError(err, "error writing object 0 field author: cannot convert int to string")
+
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version3, StringTable(true))
+	_, err = w.WriteObject(testComplexData[0])
+	s.Require().Nil(err)
+	s.Require().Nil(w.Close())
+	err = MapField(buf, &bytes.Buffer{}, "author", identity)
+	s.Assert().ErrorIs(err, ErrMapStringTable)
+
+	buf = &bytes.Buffer{}
+	w = NewWriterWithVersion(buf, Version2)
+	_, err = w.WriteObject(testOverflowData[0])
+	s.Require().Nil(err)
+	err = MapField(buf, &bytes.Buffer{}, "name", identity)
+	s.Assert().ErrorIs(err, ErrMapOverflow)
+}
@@ -0,0 +1,270 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrDedupeFieldType = errors.New("arrays, bools, opaque fields, byte slices, and overflow strings cannot be used to dedupe")
+
+// DedupePolicy selects which of the objects sharing a key is kept by
+// `DedupeBy`.
+type DedupePolicy int
+
+const (
+	// KeepFirst keeps the first object with each key.
+	KeepFirst DedupePolicy = iota
+
+	// KeepLast keeps the last object with each key.
+	KeepLast
+)
+
+// MergeOption configures `MergeWithOptions`.
+type MergeOption func(*mergeOptions)
+
+type mergeOptions struct {
+	// The top-level field whose value identifies duplicate objects.
+	dedupeBy string
+	policy   DedupePolicy
+}
+
+// DedupeBy merges only one object for each value of the top-level field
+// `field`, which is decoded from each object as by `DecodeObject`. The
+// `policy` selects whether the first or the last object with a given key is
+// kept; the rest are skipped. The other fields are copied as raw bytes, as
+// usual. Array, bool, opaque, and overflow string fields cannot be used.
+//
+// With `KeepFirst`, objects are written as they are read. With `KeepLast`, the
+// objects are held in memory until every source has been read, and each kept
+// object is written in the position of its last occurrence.
+func DedupeBy(field string, policy DedupePolicy) MergeOption {
+	return func(o *mergeOptions) {
+		o.dedupeBy = field
+		o.policy = policy
+	}
+}
+
+// Merge combines multiple RSF files into a single file. Simply concatenating
+// RSF files does not work since each file begins with its own index. Instead,
+// the index from the first source is written to `dst` once, followed by the
+// objects from every source, in order. The redundant indexes of the remaining
+// sources are skipped.
+//
+// All sources must share an identical index. Sources that use a string table
+// or include an object key table cannot be merged since these tables are
+// specific to each file, and multi-schema and columnar files cannot be
+// merged. Sources written with `FooterIndex` cannot be merged either; seekable
+// sources are checked for a footer index (see `HasFooterIndex`) before they
+// are read. If a source's index does not match the index of the first source,
+// an error naming the offending source is returned.
+//
+// The objects of each source are copied up to the end of objects marker, so
+// anything following the objects, like a trailer, is not copied.
+func Merge(dst io.Writer, srcs ...io.Reader) error {
+	return MergeWithOptions(dst, srcs)
+}
+
+// MergeWithOptions merges the sources `srcs` into `dst` like `Merge`, with
+// options like `DedupeBy`.
+func MergeWithOptions(dst io.Writer, srcs []io.Reader, opts ...MergeOption) error {
+	o := &mergeOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	var first Index
+	var d *deduper
+	for i, src := range srcs {
+		if rs, ok := src.(io.ReadSeeker); ok {
+			footer, err := HasFooterIndex(rs)
+			if err != nil {
+				return fmt.Errorf("error reading source %d: %s", i, err)
+			}
+			if footer {
+				return fmt.Errorf("source %d has a footer index and cannot be merged", i)
+			}
+		}
+		buf := bufio.NewReader(src)
+
+		// Capture the raw index bytes as they are read so the first
+		// index can be copied verbatim to the destination.
+		raw := &bytes.Buffer{}
+		r := &rsfReader{}
+		idx, err := r.ReadIndex(io.TeeReader(buf, raw))
+		if err != nil {
+			return fmt.Errorf("error reading index for source %d: %s", i, err)
+		}
+		if r.strings != nil {
+			return fmt.Errorf("source %d uses a string table and cannot be merged", i)
+		}
+		if r.features&FeatureKeyTable != 0 {
+			return fmt.Errorf("source %d includes an object key table and cannot be merged", i)
+		}
+		if r.schemas != nil {
+			return fmt.Errorf("source %d has several schemas and cannot be merged", i)
+		}
+		if r.features&FeatureColumnar != 0 {
+			return fmt.Errorf("source %d is columnar and cannot be merged", i)
+		}
+
+		if i == 0 {
+			first = idx
+			_, err = io.Copy(dst, raw)
+			if err != nil {
+				return err
+			}
+		} else if !first.Equal(idx) {
+			return fmt.Errorf("index for source %d does not match the index for source 0", i)
+		}
+
+		if o.dedupeBy != "" {
+			if d == nil {
+				d, err = newDeduper(idx, o)
+				if err != nil {
+					return err
+				}
+			}
+			err = d.copyObjects(dst, r, buf)
+			if err != nil {
+				return fmt.Errorf("error copying objects for source %d: %s", i, err)
+			}
+			continue
+		}
+
+		err = copyObjects(dst, buf)
+		if err != nil {
+			return fmt.Errorf("error copying objects for source %d: %s", i, err)
+		}
+	}
+
+	if d != nil {
+		return d.flush(dst)
+	}
+	return nil
+}
+
+// copyObjects copies the objects of a source from `buf`, positioned after the
+// source's index, to `dst` as raw bytes. Copying stops at the end of objects
+// marker (or the end of the source), so that anything following the objects,
+// like a trailer, isn't copied into the middle of the merged objects.
+func copyObjects(dst io.Writer, buf *bufio.Reader) error {
+	for n := 0; ; n++ {
+		bs, err := buf.Peek(sizeFieldLen)
+		if err == io.EOF && len(bs) == 0 {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("error reading object %d: %s", n, unexpectedEOF(err))
+		}
+		sz := int64(binary.LittleEndian.Uint32(bs))
+		if sz == 0 {
+			return nil
+		} else if sz < sizeFieldLen {
+			return fmt.Errorf("error reading object %d: invalid object size %d", n, sz)
+		}
+		_, err = io.CopyN(dst, buf, sz)
+		if err != nil {
+			return fmt.Errorf("error reading object %d: %s", n, unexpectedEOF(err))
+		}
+	}
+}
+
+// deduper copies the objects of merged sources, skipping objects with
+// duplicate keys (see `DedupeBy`).
+type deduper struct {
+	entry  IndexEntry
+	policy DedupePolicy
+
+	// The keys seen so far. With `KeepLast`, the objects are held in
+	// `objects`, with the position of each key's object; objects replaced
+	// by a later duplicate are nil.
+	seen    map[any]int
+	objects [][]byte
+}
+
+func newDeduper(index Index, o *mergeOptions) (*deduper, error) {
+	for _, entry := range index {
+		if entry.FieldName != o.dedupeBy {
+			continue
+		}
+		switch entry.FieldType {
+		case FieldTypeArray, FieldTypePackedArray, FieldTypeBool, FieldTypePackedBool,
+			FieldTypeBoolBitmap, FieldTypeOpaque, FieldTypeBytes, FieldTypeOverflowStr:
+			return nil, withField(entry.FieldName, ErrDedupeFieldType)
+		}
+		return &deduper{
+			entry:  entry,
+			policy: o.policy,
+			seen:   make(map[any]int),
+		}, nil
+	}
+	return nil, withField(o.dedupeBy, ErrNoSuchField)
+}
+
+// copyObjects reads the objects of a source from `buf`, positioned after the
+// source's index, and copies or holds each object that is kept.
+func (d *deduper) copyObjects(dst io.Writer, r *rsfReader, buf *bufio.Reader) error {
+	// Record the bytes of each object as they are read so that they can be
+	// copied.
+	rec := &byteRecorder{base: r.pos}
+	tee := bufio.NewReader(io.TeeReader(buf, rec))
+	for n := 0; ; n++ {
+		start := r.pos
+		rec.trim(start)
+		_, err := r.BeginObject(tee)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("error reading object %d: %s", n, err)
+		}
+		err = r.AdvanceTo(tee, d.entry.FieldName)
+		if err != nil {
+			return fmt.Errorf("error reading object %d: %s", n, err)
+		}
+		key, err := r.decodeValue(d.entry, tee)
+		if err != nil {
+			return fmt.Errorf("error reading object %d field %s: %s", n, d.entry.FieldName, err)
+		}
+		err = r.SkipObject(tee)
+		if err != nil {
+			return fmt.Errorf("error reading object %d: %s", n, err)
+		}
+
+		pos, dup := d.seen[key]
+		if d.policy == KeepFirst {
+			if dup {
+				continue
+			}
+			d.seen[key] = 0
+			_, err = dst.Write(rec.bytes(start, r.pos))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if dup {
+			d.objects[pos] = nil
+		}
+		d.seen[key] = len(d.objects)
+		d.objects = append(d.objects, append([]byte{}, rec.bytes(start, r.pos)...))
+	}
+}
+
+// flush writes the objects held with `KeepLast`.
+func (d *deduper) flush(dst io.Writer) error {
+	for _, obj := range d.objects {
+		if obj == nil {
+			continue
+		}
+		_, err := dst.Write(obj)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
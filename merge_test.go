@@ -0,0 +1,188 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type MergeSuite struct {
+	suite.Suite
+}
+
+func TestMergeSuite(t *testing.T) {
+	suite.Run(t, &MergeSuite{})
+}
+
+func (s *MergeSuite) TestMerge() {
+	// Write two copies of the complex data.
+	srcs := make([]*bytes.Buffer, 2)
+	for i := range srcs {
+		srcs[i] = &bytes.Buffer{}
+		w := NewWriterWithVersion(srcs[i], Version2)
+		for _, obj := range testComplexData {
+			_, err := w.WriteObject(obj)
+			s.Require().Nil(err)
+		}
+	}
+	s.Require().Equal(888, srcs[0].Len())
+
+	dst := &bytes.Buffer{}
+	err := Merge(dst, bytes.NewReader(srcs[0].Bytes()), bytes.NewReader(srcs[1].Bytes()))
+	s.Require().Nil(err)
+
+	// The index is written only once. The index uses 3 bytes for the
+	// version and 266 bytes for the index itself.
+	s.Assert().Equal(888*2-269, dst.Len())
+
+	// Read the index and count the objects.
+	buf := bufio.NewReader(dst)
+	r := NewReader()
+	_, err = r.ReadIndex(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(int64(269), r.Pos())
+	var count int
+	for {
+		sz, err := r.ReadSizeField(buf)
+		if err == io.EOF {
+			break
+		}
+		s.Require().Nil(err)
+		err = r.Discard(sz-sizeFieldLen, buf)
+		s.Require().Nil(err)
+		count++
+	}
+	s.Assert().Equal(4, count)
+}
+
+func (s *MergeSuite) TestMergeMismatch() {
+	src1 := &bytes.Buffer{}
+	w := NewWriterWithVersion(src1, Version2)
+	_, err := w.WriteObject(testComplexData[0])
+	s.Require().Nil(err)
+
+	src2 := &bytes.Buffer{}
+	w = NewWriterWithVersion(src2, Version2)
+	_, err = w.WriteObject(struct {
+		Company string `rsf:"company"`
+	}{
+		Company: "posit",
+	})
+	s.Require().Nil(err)
+
+	err = Merge(&bytes.Buffer{}, bytes.NewReader(src1.Bytes()), bytes.NewReader(src1.Bytes()), src2)
+	s.Assert().EqualError(err, "index for source 2 does not match the index for source 0")
+}
+
+type mergePackage struct {
+	Name    string `rsf:"cname"`
+	Version int    `rsf:"version"`
+	Latest  bool   `rsf:"latest"`
+}
+
+func (s *MergeSuite) writePackages(pkgs ...mergePackage) *bytes.Reader {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	for _, pkg := range pkgs {
+		_, err := w.WriteObject(pkg)
+		s.Require().Nil(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func (s *MergeSuite) readPackages(data *bytes.Buffer) []mergePackage {
+	buf := bufio.NewReader(data)
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	pkgs := make([]mergePackage, 0)
+	for {
+		var pkg mergePackage
+		err = r.Unmarshal(buf, &pkg)
+		if err == io.EOF {
+			return pkgs
+		}
+		s.Require().Nil(err)
+		pkgs = append(pkgs, pkg)
+	}
+}
+
+func (s *MergeSuite) TestMergeDedupe() {
+	sources := func() []io.Reader {
+		return []io.Reader{
+			s.writePackages(
+				mergePackage{Name: "ggplot2", Version: 1},
+				mergePackage{Name: "dplyr", Version: 1},
+			),
+			s.writePackages(
+				mergePackage{Name: "dplyr", Version: 2, Latest: true},
+				mergePackage{Name: "shiny", Version: 2, Latest: true},
+				mergePackage{Name: "ggplot2", Version: 2, Latest: true},
+			),
+		}
+	}
+
+	dst := &bytes.Buffer{}
+	err := MergeWithOptions(dst, sources(), DedupeBy("cname", KeepFirst))
+	s.Require().Nil(err)
+	s.Assert().Equal([]mergePackage{
+		{Name: "ggplot2", Version: 1},
+		{Name: "dplyr", Version: 1},
+		{Name: "shiny", Version: 2, Latest: true},
+	}, s.readPackages(dst))
+
+	// With `KeepLast`, each object is written in the position of its last
+	// occurrence.
+	dst = &bytes.Buffer{}
+	err = MergeWithOptions(dst, sources(), DedupeBy("cname", KeepLast))
+	s.Require().Nil(err)
+	s.Assert().Equal([]mergePackage{
+		{Name: "dplyr", Version: 2, Latest: true},
+		{Name: "shiny", Version: 2, Latest: true},
+		{Name: "ggplot2", Version: 2, Latest: true},
+	}, s.readPackages(dst))
+}
+
+func (s *MergeSuite) TestMergeDedupeField() {
+	src := s.writePackages(mergePackage{Name: "ggplot2", Version: 1})
+	err := MergeWithOptions(&bytes.Buffer{}, []io.Reader{src}, DedupeBy("package", KeepFirst))
+	s.Assert().ErrorIs(err, ErrNoSuchField)
+
+	src = s.writePackages(mergePackage{Name: "ggplot2", Version: 1})
+	err = MergeWithOptions(&bytes.Buffer{}, []io.Reader{src}, DedupeBy("latest", KeepFirst))
+	s.Assert().ErrorIs(err, ErrDedupeFieldType)
+}
+
+func (s *MergeSuite) TestMergeTrailers() {
+	srcs := make([]io.Reader, 2)
+	for i, pkg := range []mergePackage{{Name: "a", Version: 1}, {Name: "b", Version: 2}} {
+		buf := &bytes.Buffer{}
+		w := NewWriterWithVersion(buf, Version3)
+		_, err := w.WriteObject(pkg)
+		s.Require().Nil(err)
+		w.WriteTrailer([]byte("meta"))
+		s.Require().Nil(w.Close())
+		srcs[i] = bytes.NewReader(buf.Bytes())
+	}
+
+	// The objects of every source are merged, and the trailers are not
+	// copied.
+	dst := &bytes.Buffer{}
+	s.Require().Nil(Merge(dst, srcs...))
+	_, err := ReadTrailer(bytes.NewReader(dst.Bytes()))
+	s.Assert().ErrorIs(err, ErrNoTrailer)
+	s.Assert().Equal([]mergePackage{{Name: "a", Version: 1}, {Name: "b", Version: 2}}, s.readPackages(dst))
+}
+
+func (s *MergeSuite) TestMergeTruncated() {
+	data := &bytes.Buffer{}
+	_, err := data.ReadFrom(s.writePackages(mergePackage{Name: "a"}))
+	s.Require().Nil(err)
+	truncated := bytes.NewReader(data.Bytes()[:data.Len()-2])
+	err = Merge(&bytes.Buffer{}, s.writePackages(mergePackage{Name: "b"}), truncated)
+	s.Assert().EqualError(err, "error copying objects for source 1: error reading object 0: unexpected EOF")
+}
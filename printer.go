@@ -3,13 +3,63 @@ package rsf
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"net/netip"
 	"reflect"
 	"strings"
+	"text/tabwriter"
+	"time"
 )
 
-func Print(w io.Writer, r *bufio.Reader) error {
+var ErrNotRSF = errors.New("does not appear to be an RSF file")
+
+// CheckFileSize peeks at the start of the data in `r`, which is read from a
+// file of `size` bytes, and returns `ErrNotRSF` if the data can't be RSF. Data
+// without a Version2 or Version3 index header is read as a Version1 index,
+// whose first four bytes are the index size, so a file that doesn't start with
+// an index header and whose first size field is larger than the file is not
+// RSF data. This catches most files that aren't RSF before a bogus index size
+// is read. Nothing is consumed from `r`.
+func CheckFileSize(r *bufio.Reader, size int64) error {
+	header, err := r.Peek(sizeFieldLen)
+	if err != nil {
+		if err == io.EOF {
+			return ErrNotRSF
+		}
+		return err
+	}
+	if bytes.Equal(header[:3], IndexVersion2) || bytes.Equal(header[:3], IndexVersion3) {
+		return nil
+	}
+	if int64(binary.LittleEndian.Uint32(header)) > size {
+		return ErrNotRSF
+	}
+	return nil
+}
+
+// Print prints the objects in RSF data, one field per line. See `HexBytes`
+// for an option that includes the raw bytes of each field, `Tables` for an
+// option that prints arrays of structs as tables, and `FileSize` for an
+// option that checks that the data is RSF before it is read. Data written with
+// `FooterIndex` cannot be printed, since it begins with objects rather than
+// an index (see `HasFooterIndex`).
+func Print(w io.Writer, r *bufio.Reader, opts ...PrintOption) error {
+	o := &printOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.fileSize > 0 {
+		err := CheckFileSize(r, o.fileSize)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Create a new reader since we need to read the RSF data.
 	reader := NewReader()
 
@@ -19,13 +69,27 @@ func Print(w io.Writer, r *bufio.Reader) error {
 		return fmt.Errorf("error reading index: %s", err)
 	}
 
+	// To print raw bytes, record the bytes read following the index.
+	var hex *byteRecorder
+	if o.hex {
+		hex = &byteRecorder{base: reader.Pos()}
+		r = bufio.NewReader(io.TeeReader(r, hex))
+	}
+
 	// Iterate the fields recursively and print the data.
 	var i int
 	for {
 		i++
 
-		// Read full object size
-		_, err = reader.ReadSizeField(r)
+		// Bytes preceding the object are no longer needed.
+		if hex != nil {
+			hex.trim(reader.Pos())
+		}
+
+		// Read full object size. A zero size marks the end of the objects
+		// and is reported as `io.EOF`. Any remaining data (like an object
+		// key table) is not printed.
+		_, err = reader.BeginObject(r)
 		if err != nil {
 			if err == io.EOF {
 				return nil
@@ -33,6 +97,11 @@ func Print(w io.Writer, r *bufio.Reader) error {
 			return err
 		}
 
+		// Objects in multi-schema files are printed with their own index.
+		if schemas := reader.Schemas(); schemas != nil {
+			idx = schemas[reader.SchemaID()]
+		}
+
 		// Add blank newline unless at first object
 		if i > 1 {
 			_, err = fmt.Fprintln(w, "")
@@ -52,7 +121,7 @@ func Print(w io.Writer, r *bufio.Reader) error {
 
 		// Print data for each field of the object.
 		for _, f := range idx {
-			err = printField("", f, w, r, reader, 0)
+			err = printField("", f, w, r, reader, 0, hex, o.table)
 			if err != nil {
 				if err == io.EOF {
 					return nil
@@ -60,12 +129,29 @@ func Print(w io.Writer, r *bufio.Reader) error {
 				return fmt.Errorf("error printing data: %s", err)
 			}
 		}
+
+		// Discard any data following the fields, like overflow strings.
+		err = reader.SkipObject(r)
+		if err != nil {
+			return fmt.Errorf("error printing data: %s", err)
+		}
 	}
 }
 
-func printField(parentKey string, f IndexEntry, w io.Writer, r *bufio.Reader, reader Reader, indent int) error {
+// printField prints the field `f`. When `hex` is set, the raw bytes of the
+// field follow the field (for arrays, the bytes of the array size, length, and
+// index follow the array, and the bytes of each element follow the element).
+// When `table` is set, arrays of structs with only scalar fields are printed
+// as tables.
+func printField(parentKey string, f IndexEntry, w io.Writer, r *bufio.Reader, reader Reader, indent int, hex *byteRecorder, table bool) error {
+	// The index depth is limited when it is read, but guard against deeply
+	// nested indexes supplied in other ways.
+	if indent > DefaultMaxDepth {
+		return ErrMaxDepth
+	}
 
 	pad := strings.Repeat(" ", indent*4)
+	start := reader.Pos()
 	switch f.FieldType {
 	case FieldTypeBool:
 		b, err := reader.ReadBoolField(r)
@@ -76,6 +162,22 @@ func printField(parentKey string, f IndexEntry, w io.Writer, r *bufio.Reader, re
 		if err != nil {
 			return err
 		}
+	case FieldTypeBoolBitmap:
+		// The bitmap is not printed, but it is retained for the packed
+		// bools that follow.
+		err := reader.(*rsfReader).readBitmap(f, r)
+		if err != nil {
+			return fmt.Errorf("error reading bool bitmap: %s", err)
+		}
+	case FieldTypePackedBool:
+		b, err := reader.(*rsfReader).readPackedBool(f)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s%s (bool): %t\n", pad, f.FieldName, b)
+		if err != nil {
+			return err
+		}
 	case FieldTypeInt64:
 		i, err := reader.ReadIntField(r)
 		if err != nil {
@@ -85,6 +187,33 @@ func printField(parentKey string, f IndexEntry, w io.Writer, r *bufio.Reader, re
 		if err != nil {
 			return err
 		}
+	case FieldTypeFixedInt64:
+		i, err := reader.ReadFixedIntField(r)
+		if err != nil {
+			return fmt.Errorf("error reading int: %s", err)
+		}
+		_, err = fmt.Fprintf(w, "%s%s (fixed int): %d\n", pad, f.FieldName, i)
+		if err != nil {
+			return err
+		}
+	case FieldTypeBigEndianInt:
+		i, err := reader.(*rsfReader).readFixedInt(f, r)
+		if err != nil {
+			return fmt.Errorf("error reading int: %s", err)
+		}
+		_, err = fmt.Fprintf(w, "%s%s (big-endian int): %d\n", pad, f.FieldName, i)
+		if err != nil {
+			return err
+		}
+	case FieldTypeBigEndianFloat:
+		fl, err := reader.(*rsfReader).readFloat(f, r)
+		if err != nil {
+			return fmt.Errorf("error reading float: %s", err)
+		}
+		_, err = fmt.Fprintf(w, "%s%s (big-endian float): %f\n", pad, f.FieldName, fl)
+		if err != nil {
+			return err
+		}
 	case FieldTypeFloat:
 		fl, err := reader.ReadFloatField(r)
 		if err != nil {
@@ -94,6 +223,50 @@ func printField(parentKey string, f IndexEntry, w io.Writer, r *bufio.Reader, re
 		if err != nil {
 			return err
 		}
+	case FieldTypeOpaque:
+		sz, err := reader.ReadSizeField(r)
+		if err != nil {
+			return fmt.Errorf("error reading opaque value size: %s", err)
+		}
+		s, err := reader.ReadFixedStringField(sz, r)
+		if err != nil {
+			return fmt.Errorf("error reading opaque value: %s", err)
+		}
+		_, err = fmt.Fprintf(w, "%s%s (opaque(%d)): %x\n", pad, f.FieldName, sz, s)
+		if err != nil {
+			return err
+		}
+	case FieldTypeBytes:
+		bs, err := reader.ReadBytesField(r)
+		if err != nil {
+			return fmt.Errorf("error reading bytes: %s", err)
+		}
+		_, err = fmt.Fprintf(w, "%s%s (bytes(%d)): %x\n", pad, f.FieldName, len(bs), bs)
+		if err != nil {
+			return err
+		}
+	case FieldTypeIP:
+		addr, err := reader.ReadIPField(r)
+		if err != nil {
+			return fmt.Errorf("error reading IP address: %s", err)
+		}
+		var s string
+		if addr.IsValid() {
+			s = addr.String()
+		}
+		_, err = fmt.Fprintf(w, "%s%s (ip): %s\n", pad, f.FieldName, s)
+		if err != nil {
+			return err
+		}
+	case FieldTypeDays:
+		t, err := reader.ReadDaysField(r)
+		if err != nil {
+			return fmt.Errorf("error reading date: %s", err)
+		}
+		_, err = fmt.Fprintf(w, "%s%s (date): %s\n", pad, f.FieldName, t.Format(time.DateOnly))
+		if err != nil {
+			return err
+		}
 	case FieldTypeFixedStr:
 		s, err := reader.ReadFixedStringField(f.FieldSize, r)
 		if err != nil {
@@ -103,6 +276,28 @@ func printField(parentKey string, f IndexEntry, w io.Writer, r *bufio.Reader, re
 		if err != nil {
 			return err
 		}
+	case FieldTypeCompressedStr:
+		s, err := reader.ReadCompressedStringField(r)
+		if err != nil {
+			return fmt.Errorf("error reading compressed string field %s: %s", f.FieldName, err)
+		}
+		_, err = fmt.Fprintf(w, "%s%s (compressed string): %s\n", pad, f.FieldName, s)
+		if err != nil {
+			return err
+		}
+	case FieldTypeOverflowStr:
+		offset, err := reader.ReadSizeField(r)
+		if err != nil {
+			return fmt.Errorf("error reading overflow string offset: %s", err)
+		}
+		length, err := reader.ReadSizeField(r)
+		if err != nil {
+			return fmt.Errorf("error reading overflow string length: %s", err)
+		}
+		_, err = fmt.Fprintf(w, "%s%s (overflow string): offset %d, length %d\n", pad, f.FieldName, offset, length)
+		if err != nil {
+			return err
+		}
 	case FieldTypeVarStr:
 		s, err := reader.ReadStringField(r)
 		if err != nil {
@@ -112,7 +307,16 @@ func printField(parentKey string, f IndexEntry, w io.Writer, r *bufio.Reader, re
 		if err != nil {
 			return err
 		}
-	case FieldTypeArray:
+	case FieldTypeArray, FieldTypePackedArray:
+		absent, err := reader.(*rsfReader).absent(f)
+		if err != nil {
+			return err
+		}
+		if absent {
+			_, err = fmt.Fprintf(w, "%s%s (array): absent\n", pad, f.FieldName)
+			return err
+		}
+
 		sz, err := reader.ReadSizeField(r)
 		if err != nil {
 			return fmt.Errorf("error reading array size: %s", err)
@@ -121,6 +325,10 @@ func printField(parentKey string, f IndexEntry, w io.Writer, r *bufio.Reader, re
 		if err != nil {
 			return fmt.Errorf("error reading array length: %s", err)
 		}
+		err = checkArrayLen(f, sz, arrayLen)
+		if err != nil {
+			return err
+		}
 
 		key := f.FieldName
 		if parentKey != "" {
@@ -148,9 +356,19 @@ func printField(parentKey string, f IndexEntry, w io.Writer, r *bufio.Reader, re
 						return fmt.Errorf("error reading index int64 value: %s", err)
 					}
 					indexValues = append(indexValues, intIndexVal)
+				case reflect.Array:
+					var bIndexVal string
+					bIndexVal, err = reader.ReadFixedStringField(indexSz, r)
+					if err != nil {
+						return fmt.Errorf("error reading index bytes value: %s", err)
+					}
+					indexValues = append(indexValues, []byte(bIndexVal))
 				}
 
-				// Discard index size
+				// Discard index size. Packed arrays don't include it.
+				if f.FieldType == FieldTypePackedArray {
+					continue
+				}
 				err = reader.Discard(4, r)
 				if err != nil {
 					return fmt.Errorf("error discarding index bytes: %s", err)
@@ -169,10 +387,18 @@ func printField(parentKey string, f IndexEntry, w io.Writer, r *bufio.Reader, re
 				return err
 			}
 		}
+		err = printHex(w, hex, pad, start, reader.Pos())
+		if err != nil {
+			return err
+		}
+
+		if table && arrayLen > 0 && isScalarStruct(f) {
+			return printTable(f, indexValues, arrayLen, w, r, reader, pad+strings.Repeat(" ", 4))
+		}
 
 	fields:
 		for i := 0; i < arrayLen; i++ {
-			if f.Subfields != nil {
+			if f.Subfields != nil || emptyElements(f) {
 				var indexVal string
 				if len(indexValues) > 0 {
 					switch t := indexValues[i].(type) {
@@ -180,21 +406,40 @@ func printField(parentKey string, f IndexEntry, w io.Writer, r *bufio.Reader, re
 						indexVal = fmt.Sprintf(" %s", t)
 					case int64:
 						indexVal = fmt.Sprintf(" %d", t)
+					case []byte:
+						indexVal = fmt.Sprintf(" %x", t)
 					}
 				}
 				_, err = fmt.Fprintf(w, "%s-%s\n", pad+strings.Repeat(" ", 4), indexVal)
-				for _, subfield := range f.Subfields {
-					err = printField(key, subfield, w, r, reader, indent+1)
+				if err != nil {
+					return err
+				}
+
+				if emptyElements(f) {
+					err = reader.(*rsfReader).readEmptyElement(r)
 					if err != nil {
-						if err == io.EOF {
-							return nil
-						}
+						return fmt.Errorf("error reading array %s element %d: %s", key, i, unexpectedEOF(err))
+					}
+				}
+
+				// Subfields, including nested arrays and their indexes, are
+				// printed one level deeper than the array. Since the array
+				// has already started, the end of the data is unexpected.
+				for _, subfield := range f.Subfields {
+					err = printField(key, subfield, w, r, reader, indent+1, hex, table)
+					if err == io.EOF {
+						return fmt.Errorf("error reading array %s element %d: %s", key, i, io.ErrUnexpectedEOF)
+					} else if err != nil {
 						return err
 					}
 				}
 			} else {
+				elStart := reader.Pos()
 				_, err = fmt.Fprintf(w, "%s-", pad+strings.Repeat(" ", 4))
 
+				// The elements of packed arrays of numbers are fixed-width.
+				packed := packedElement(f)
+
 				switch reflect.Kind(f.SubfieldType) {
 				case reflect.String:
 					var s string
@@ -218,7 +463,11 @@ func printField(parentKey string, f IndexEntry, w io.Writer, r *bufio.Reader, re
 					}
 				case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
 					var d int64
-					d, err = reader.ReadIntField(r)
+					if packed.FieldType != 0 {
+						d, err = reader.(*rsfReader).readFixedInt(packed, r)
+					} else {
+						d, err = reader.ReadIntField(r)
+					}
 					if err != nil {
 						return fmt.Errorf("error reading array int field: %s", err)
 					}
@@ -228,7 +477,11 @@ func printField(parentKey string, f IndexEntry, w io.Writer, r *bufio.Reader, re
 					}
 				case reflect.Float32, reflect.Float64:
 					var fl float64
-					fl, err = reader.ReadFloatField(r)
+					if packed.FieldType != 0 {
+						fl, err = reader.(*rsfReader).readFloat(packed, r)
+					} else {
+						fl, err = reader.ReadFloatField(r)
+					}
 					if err != nil {
 						return fmt.Errorf("error reading array float field: %s", err)
 					}
@@ -237,7 +490,13 @@ func printField(parentKey string, f IndexEntry, w io.Writer, r *bufio.Reader, re
 						return err
 					}
 				default:
-					_, err = fmt.Fprintf(w, " cannot print data for arrays of arrays\n")
+					// Elements of nested arrays, and of types written by a
+					// newer writer, are not printed.
+					elements := "arrays"
+					if kind := reflect.Kind(f.SubfieldType); kind != reflect.Array && kind != reflect.Slice {
+						elements = kind.String()
+					}
+					_, err = fmt.Fprintf(w, " cannot print data for arrays of %s\n", elements)
 					if err != nil {
 						return err
 					}
@@ -247,10 +506,102 @@ func printField(parentKey string, f IndexEntry, w io.Writer, r *bufio.Reader, re
 					}
 					break fields
 				}
+				err = printHex(w, hex, pad+strings.Repeat(" ", 4), elStart, reader.Pos())
+				if err != nil {
+					return err
+				}
 			}
 		}
+		return nil
 	default:
 		return fmt.Errorf("cannot print unknown field %s with type %d", f.FieldName, f.FieldType)
 	}
-	return nil
+	return printHex(w, hex, pad, start, reader.Pos())
+}
+
+// isScalarStruct returns true if `f` is an array of structs whose fields are
+// all scalars.
+func isScalarStruct(f IndexEntry) bool {
+	if len(f.Subfields) == 0 {
+		return false
+	}
+	for _, subfield := range f.Subfields {
+		if subfield.FieldType == FieldTypeArray || subfield.FieldType == FieldTypePackedArray {
+			return false
+		}
+	}
+	return true
+}
+
+// printTable prints the elements of the struct array `f` as a table with a
+// column for each field, preceded by a column of index keys for indexed
+// arrays. Columns are aligned, and each line is prefixed with `pad`.
+func printTable(f IndexEntry, indexValues []any, arrayLen int, w io.Writer, r *bufio.Reader, reader Reader, pad string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	// Print the header row.
+	var cols []string
+	if len(indexValues) > 0 {
+		cols = append(cols, IndexKeyField)
+	}
+	for _, subfield := range f.Subfields {
+		cols = append(cols, subfield.FieldName)
+	}
+	_, err := fmt.Fprintf(tw, "%s%s\n", pad, strings.Join(cols, "\t"))
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < arrayLen; i++ {
+		cols = cols[:0]
+		if len(indexValues) > 0 {
+			cols = append(cols, formatTableValue(indexValues[i]))
+		}
+		for _, subfield := range f.Subfields {
+			val, err := reader.(*rsfReader).decodeValue(subfield, r)
+			if err == io.EOF {
+				return fmt.Errorf("error reading array %s element %d: %s", f.FieldName, i, io.ErrUnexpectedEOF)
+			} else if err != nil {
+				return fmt.Errorf("error reading array %s field %s: %s", f.FieldName, subfield.FieldName, err)
+			}
+			cols = append(cols, formatTableValue(val))
+		}
+		_, err = fmt.Fprintf(tw, "%s%s\n", pad, strings.Join(cols, "\t"))
+		if err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+// formatTableValue formats a decoded value for `printTable` like the values
+// of other fields are printed.
+func formatTableValue(v any) string {
+	switch t := v.(type) {
+	case float64:
+		return fmt.Sprintf("%f", t)
+	case []byte:
+		return fmt.Sprintf("%x", t)
+	case netip.Addr:
+		if !t.IsValid() {
+			return ""
+		}
+		return t.String()
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+// printHex prints the bytes recorded by `rec` from the file position `start`
+// up to `end` in hex. Nothing is printed if `rec` is nil or there are no bytes.
+func printHex(w io.Writer, rec *byteRecorder, pad string, start, end int64) error {
+	if rec == nil {
+		return nil
+	}
+	bs := rec.bytes(start, end)
+	if len(bs) == 0 {
+		return nil
+	}
+	_, err := fmt.Fprintf(w, "%s  hex: % x\n", pad, bs)
+	return err
 }
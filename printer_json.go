@@ -0,0 +1,131 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+// PrintOption configures the output of a printer.
+type PrintOption func(*printOptions)
+
+type printOptions struct {
+	// The value printed in place of NaN and infinite floats.
+	nonFinite any
+
+	// When enabled, the raw bytes of each field are printed in hex.
+	hex bool
+
+	// When enabled, arrays of structs with scalar fields are printed as
+	// tables.
+	table bool
+
+	// The size of the RSF file, if known. See `FileSize`.
+	fileSize int64
+}
+
+// NonFiniteFloats sets the value that `PrintJSON` emits in place of NaN,
+// +Inf, and -Inf floats, since JSON cannot represent them. By default, these
+// values are printed as `null`.
+//
+// For example, `NonFiniteFloats("NaN")` prints a NaN float as the string
+// "NaN". Note that all non-finite floats are printed with the same value.
+func NonFiniteFloats(sentinel any) PrintOption {
+	return func(o *printOptions) {
+		o.nonFinite = sentinel
+	}
+}
+
+// HexBytes prints the raw bytes of each field in hex on the line following the
+// field. This is useful for diagnosing writer bugs. It is used by `Print`.
+func HexBytes(enabled bool) PrintOption {
+	return func(o *printOptions) {
+		o.hex = enabled
+	}
+}
+
+// Tables prints each array of structs whose fields are all scalars as an
+// aligned table, with a column for each field and a row for each element. The
+// index keys of indexed arrays are printed in the first column, labeled with
+// `IndexKeyField`. Arrays of structs that include nested arrays are printed
+// one field per line, as usual. It is used by `Print`. The raw bytes of table
+// rows are not printed with `HexBytes`.
+func Tables(enabled bool) PrintOption {
+	return func(o *printOptions) {
+		o.table = enabled
+	}
+}
+
+// FileSize sets the size of the file being printed. When set, the data is
+// checked with `CheckFileSize` before the index is read, so that printing a
+// file that isn't RSF data returns `ErrNotRSF` rather than a low-level read
+// error. It is used by `Print` and `PrintJSON`.
+func FileSize(size int64) PrintOption {
+	return func(o *printOptions) {
+		o.fileSize = size
+	}
+}
+
+// PrintJSON prints the objects in RSF data as JSON Lines, with one JSON object
+// per line. Arrays are printed as JSON arrays, and the index key of each element
+// of an indexed array is printed with the `IndexKeyField` key.
+func PrintJSON(w io.Writer, r *bufio.Reader, opts ...PrintOption) error {
+	o := &printOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.fileSize > 0 {
+		err := CheckFileSize(r, o.fileSize)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Create a new reader since we need to read the RSF data.
+	reader := NewReader()
+
+	_, err := reader.ReadIndex(r)
+	if err != nil {
+		return fmt.Errorf("error reading index: %s", err)
+	}
+
+	enc := json.NewEncoder(w)
+	for {
+		var obj map[string]any
+		obj, err = reader.DecodeObject(r)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("error reading data: %s", err)
+		}
+
+		err = enc.Encode(o.replaceNonFinite(obj))
+		if err != nil {
+			return fmt.Errorf("error printing data: %s", err)
+		}
+	}
+}
+
+// replaceNonFinite recursively replaces NaN and infinite floats in a decoded
+// value with the configured sentinel.
+func (o *printOptions) replaceNonFinite(v any) any {
+	switch t := v.(type) {
+	case float64:
+		if math.IsNaN(t) || math.IsInf(t, 0) {
+			return o.nonFinite
+		}
+	case map[string]any:
+		for k, el := range t {
+			t[k] = o.replaceNonFinite(el)
+		}
+	case []any:
+		for i, el := range t {
+			t[i] = o.replaceNonFinite(el)
+		}
+	}
+	return v
+}
@@ -0,0 +1,55 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PrinterJSONSuite struct {
+	suite.Suite
+}
+
+func TestPrinterJSONSuite(t *testing.T) {
+	suite.Run(t, &PrinterJSONSuite{})
+}
+
+func (s *PrinterJSONSuite) TestPrintJSON() {
+	out := &bytes.Buffer{}
+	err := PrintJSON(out, bufio.NewReader(getData(&s.Suite)))
+	s.Require().Nil(err)
+	s.Assert().Equal(`{"age":55,"company":"posit","list":[`+
+		`{"@key":"2020-10-01","name":"From 2020","verified":false},`+
+		`{"@key":"2021-03-21","name":"From 2021","verified":true},`+
+		`{"@key":"2022-12-15","name":"this is from 2022","verified":true}],`+
+		`"rating":92.689,"ready":true}`+"\n", out.String())
+}
+
+func (s *PrinterJSONSuite) TestPrintJSONNonFiniteFloats() {
+	// NaN and infinite floats are printed as null by default.
+	out := &bytes.Buffer{}
+	err := PrintJSON(out, bufio.NewReader(getFloatData(&s.Suite)))
+	s.Require().Nil(err)
+	s.Assert().Equal(`{"name":"nan","value":null,"values":[1.5,null]}`+"\n"+
+		`{"name":"inf","value":null,"values":[null]}`+"\n", out.String())
+
+	// Print a sentinel instead.
+	out.Reset()
+	err = PrintJSON(out, bufio.NewReader(getFloatData(&s.Suite)), NonFiniteFloats("NaN"))
+	s.Require().Nil(err)
+	s.Assert().Equal(`{"name":"nan","value":"NaN","values":[1.5,"NaN"]}`+"\n"+
+		`{"name":"inf","value":"NaN","values":["NaN"]}`+"\n", out.String())
+}
+
+func (s *PrinterJSONSuite) TestPrintNonFiniteFloats() {
+	out := &bytes.Buffer{}
+	err := Print(out, bufio.NewReader(getFloatData(&s.Suite)))
+	s.Require().Nil(err)
+	s.Assert().Contains(out.String(), "value (float): NaN\n")
+	s.Assert().Contains(out.String(), "    -NaN\n")
+	s.Assert().Contains(out.String(), "value (float): +Inf\n")
+	s.Assert().Contains(out.String(), "    --Inf\n")
+}
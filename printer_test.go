@@ -0,0 +1,243 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PrinterSuite struct {
+	suite.Suite
+}
+
+func TestPrinterSuite(t *testing.T) {
+	suite.Run(t, &PrinterSuite{})
+}
+
+type printFile struct {
+	Id   int    `rsf:"id,skip"`
+	Name string `rsf:"name"`
+}
+
+type printVersion struct {
+	Version string      `rsf:"version,skip,fixed:5"`
+	Tags    []string    `rsf:"tags"`
+	Files   []printFile `rsf:"files,index:id"`
+	Yanked  bool        `rsf:"yanked"`
+}
+
+type printPackage struct {
+	Name     string         `rsf:"name"`
+	Versions []printVersion `rsf:"versions,index:version"`
+	Stars    int            `rsf:"stars"`
+}
+
+var testPrintData = printPackage{
+	Name: "rsf",
+	Versions: []printVersion{
+		{
+			Version: "1.0.0",
+			Tags:    []string{"stable"},
+			Files: []printFile{
+				{Id: 7, Name: "rsf-1.0.0.tar.gz"},
+				{Id: 12, Name: "rsf-1.0.0.whl"},
+			},
+		},
+		{
+			Version: "1.1.0",
+			Yanked:  true,
+		},
+		{
+			Version: "2.0.0",
+			Files: []printFile{
+				{Id: 30, Name: "rsf-2.0.0.tar.gz"},
+			},
+		},
+	},
+	Stars: 42,
+}
+
+func (s *PrinterSuite) TestPrintNestedIndexedArrays() {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	_, err := w.WriteObject(testPrintData)
+	s.Require().Nil(err)
+
+	out := &bytes.Buffer{}
+	err = Print(out, bufio.NewReader(bytes.NewReader(buf.Bytes())))
+	s.Require().Nil(err)
+	s.Assert().Equal(`-----------------------------------------
+                Object[1]                
+-----------------------------------------
+name (string): rsf
+versions (indexed array(3)):
+    - 1.0.0
+    tags (array(1)):
+        -stable
+    files (indexed array(2)):
+        - 7
+        name (string): rsf-1.0.0.tar.gz
+        - 12
+        name (string): rsf-1.0.0.whl
+    yanked (bool): false
+    - 1.1.0
+    tags (array(0)):
+    files (array(0)):
+    yanked (bool): true
+    - 2.0.0
+    tags (array(0)):
+    files (indexed array(1)):
+        - 30
+        name (string): rsf-2.0.0.tar.gz
+    yanked (bool): false
+stars (int): 42
+`, out.String())
+}
+
+func (s *PrinterSuite) TestPrintNestedIndexedArraysTruncated() {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	_, err := w.WriteObject(testPrintData)
+	s.Require().Nil(err)
+
+	// End the data partway through the nested `files` array.
+	data := buf.Bytes()
+	end := bytes.Index(data, []byte("rsf-1.0.0.whl"))
+	out := &bytes.Buffer{}
+	err = Print(out, bufio.NewReader(bytes.NewReader(data[:end])))
+	s.Assert().ErrorContains(err, "error reading variable-length string field name")
+}
+
+func (s *PrinterSuite) TestPrintTables() {
+	out := &bytes.Buffer{}
+	err := Print(out, bufio.NewReader(getData(&s.Suite)), Tables(true))
+	s.Require().Nil(err)
+	s.Assert().Equal(`-----------------------------------------
+                Object[1]                
+-----------------------------------------
+company (string): posit
+ready (bool): true
+list (indexed array(3)):
+    @key        name               verified
+    2020-10-01  From 2020          false
+    2021-03-21  From 2021          true
+    2022-12-15  this is from 2022  true
+age (int): 55
+rating (float): 92.689000
+`, out.String())
+
+	// Arrays with nested arrays are printed one field per line.
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	_, err = w.WriteObject(testPrintData)
+	s.Require().Nil(err)
+	out.Reset()
+	err = Print(out, bufio.NewReader(buf), Tables(true))
+	s.Require().Nil(err)
+	s.Assert().Equal(`-----------------------------------------
+                Object[1]                
+-----------------------------------------
+name (string): rsf
+versions (indexed array(3)):
+    - 1.0.0
+    tags (array(1)):
+        -stable
+    files (indexed array(2)):
+        @key  name
+        7     rsf-1.0.0.tar.gz
+        12    rsf-1.0.0.whl
+    yanked (bool): false
+    - 1.1.0
+    tags (array(0)):
+    files (array(0)):
+    yanked (bool): true
+    - 2.0.0
+    tags (array(0)):
+    files (indexed array(1)):
+        @key  name
+        30    rsf-2.0.0.tar.gz
+    yanked (bool): false
+stars (int): 42
+`, out.String())
+}
+
+func (s *PrinterSuite) TestPrintHexBytes() {
+	type record struct {
+		Name  string   `rsf:"name"`
+		Ready bool     `rsf:"ready"`
+		Tags  []string `rsf:"tags"`
+		Age   int      `rsf:"age"`
+	}
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	_, err := w.WriteObject(record{Name: "rsf", Ready: true, Tags: []string{"a", "bc"}, Age: 3})
+	s.Require().Nil(err)
+	_, err = w.WriteObject(record{Name: "x", Age: -1})
+	s.Require().Nil(err)
+
+	out := &bytes.Buffer{}
+	err = Print(out, bufio.NewReader(buf), HexBytes(true))
+	s.Require().Nil(err)
+	s.Assert().Equal(`-----------------------------------------
+                Object[1]                
+-----------------------------------------
+name (string): rsf
+  hex: 03 00 00 00 72 73 66
+ready (bool): true
+  hex: 01
+tags (array(2)):
+  hex: 13 00 00 00 02 00 00 00
+    -a
+      hex: 01 00 00 00 61
+    -bc
+      hex: 02 00 00 00 62 63
+age (int): 3
+  hex: 06 00 00 00 00 00 00 00 00 00
+
+-----------------------------------------
+                Object[2]                
+-----------------------------------------
+name (string): x
+  hex: 01 00 00 00 78
+ready (bool): false
+  hex: 00
+tags (array(0)):
+  hex: 08 00 00 00 00 00 00 00
+age (int): -1
+  hex: 01 00 00 00 00 00 00 00 00 00
+`, out.String())
+}
+
+func (s *PrinterSuite) TestPrintFileSize() {
+	type record struct {
+		Name  string `rsf:"name"`
+		Stars int    `rsf:"stars"`
+	}
+
+	// RSF data of every index version passes the check.
+	for _, version := range []int{Version1, Version2, Version3} {
+		buf := &bytes.Buffer{}
+		w := NewWriterWithVersion(buf, version)
+		_, err := w.WriteObject(record{Name: "rsf", Stars: 42})
+		s.Require().Nil(err)
+		s.Require().Nil(w.Close())
+		size := int64(buf.Len())
+
+		out := &bytes.Buffer{}
+		err = Print(out, bufio.NewReader(buf), FileSize(size))
+		s.Assert().Nil(err)
+		s.Assert().Contains(out.String(), "stars (int): 42")
+	}
+
+	// Other files are reported as not RSF data rather than read.
+	text := []byte("this is not an RSF file\n")
+	err := Print(&bytes.Buffer{}, bufio.NewReader(bytes.NewReader(text)), FileSize(int64(len(text))))
+	s.Assert().ErrorIs(err, ErrNotRSF)
+	err = PrintJSON(&bytes.Buffer{}, bufio.NewReader(bytes.NewReader(text)), FileSize(int64(len(text))))
+	s.Assert().ErrorIs(err, ErrNotRSF)
+	err = CheckFileSize(bufio.NewReader(bytes.NewReader([]byte("rs"))), 2)
+	s.Assert().ErrorIs(err, ErrNotRSF)
+}
@@ -3,47 +3,260 @@ package rsf
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"math"
+	"net/netip"
+	"reflect"
 )
 
 type rsfReader struct {
-	pos int
+	pos int64
 
 	// When reading an RSF file based on a struct, the first entry
 	// is an index. See `ReadIndex`.
 	index        Index
 	indexVersion int
 
+	// Feature flags read from a Version3 index header.
+	features int
+
+	// The schema fingerprint read from the index header, if any. See
+	// `Fingerprint`.
+	fingerprint uint64
+
+	// The producer version read from the index header, if any. See
+	// `ProducerVersion`.
+	writerVersion string
+
+	// The string table, if the file includes one. When set, variable-length
+	// strings are read as indexes into this table.
+	strings []string
+
+	// The field name table, if the index includes one. See `FieldNameTable`.
+	fieldNames []string
+
 	// Saves the current position for advancing the reader.
 	at []string
+
+	// The positions of the start and end of the current object. See
+	// `BeginObject`.
+	objectStart int64
+	objectEnd   int64
+
+	// Set when `NextObject` has read the size field of an object whose
+	// fields have not been read.
+	begun bool
+
+	// Overflow strings to read at the end of the current object. See
+	// `readOverflow`.
+	overflow []overflowRef
+
+	// The bitmap of packed bool fields in the current object. See
+	// `PackBools`.
+	bitmap []byte
+
+	// Optional hook invoked for each field that is advanced past or read.
+	// See `OnField`.
+	onField func(path []string, entry IndexEntry)
+
+	// The maximum nesting depth of the index. See `MaxDepth`.
+	maxDepth int
+
+	// The maximum number of objects read by bulk helpers. See `MaxObjects`.
+	maxObjects int
+
+	// An optional logger for debug events. See `DebugLogger`.
+	logger *slog.Logger
+
+	// The struct field matching each index entry, by index and struct type.
+	// See `ReadObjectInto`.
+	plans map[planKey]*fieldPlan
+
+	// Values for struct fields that are absent from the index, by struct
+	// type. See `Defaults`.
+	defaults map[reflect.Type]reflect.Value
+
+	// The path of the element field receiving each array index key, by
+	// element type and field name.
+	keyPaths map[planKey][]int
+
+	// The indexes of a multi-schema file, and the id of the current
+	// object's index. See `NewWriterWithSchemas`.
+	schemas []Index
+	schema  int
+}
+
+// ReaderOption configures optional reader behavior.
+type ReaderOption func(*rsfReader)
+
+// OnField registers a hook that is invoked with the path and index entry of
+// each field as the reader advances past it (with `AdvanceTo` or
+// `AdvanceToNextElement`), advances to it (with `AdvanceTo`), or reads it (with
+// `Unmarshal`). The hook only observes; it does not change read behavior. This
+// is useful for tracking the use of deprecated fields.
+func OnField(fn func(path []string, entry IndexEntry)) ReaderOption {
+	return func(f *rsfReader) {
+		f.onField = fn
+	}
+}
+
+// Defaults registers `v`, a struct or a pointer to a struct, as the default
+// values of its type. When `Unmarshal` or `ReadObjectInto` reads an object or
+// array element into a struct of the same type, each field that is absent
+// from the file's index is set to its value in `v` rather than being left
+// unchanged. This lets callers reading older files declare non-zero defaults
+// for fields added later, e.g., `Defaults(record{Verified: true})`. Fields
+// that are in the index but hidden by a projection are left unchanged. Other
+// values are ignored. The option may be given once for each struct type.
+func Defaults(v any) ReaderOption {
+	return func(f *rsfReader) {
+		rv := reflect.ValueOf(v)
+		for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+			rv = rv.Elem()
+		}
+		if rv.Kind() != reflect.Struct {
+			return
+		}
+		if f.defaults == nil {
+			f.defaults = make(map[reflect.Type]reflect.Value)
+		}
+		f.defaults[rv.Type()] = rv
+	}
 }
 
-func NewReader() Reader {
-	return &rsfReader{}
+// DefaultMaxDepth is the default maximum nesting depth of arrays in an index.
+// See `MaxDepth`.
+const DefaultMaxDepth = 64
+
+var ErrMaxDepth = errors.New("the index exceeds the maximum nesting depth")
+
+// MaxDepth sets the maximum nesting depth of arrays in an index. Since arrays
+// are read recursively, a corrupt or malicious index with deeply nested arrays
+// could otherwise overflow the stack. `ReadIndex` returns `ErrMaxDepth` for
+// indexes that exceed the maximum. A depth of zero uses `DefaultMaxDepth`.
+func MaxDepth(depth int) ReaderOption {
+	return func(f *rsfReader) {
+		f.maxDepth = depth
+	}
+}
+
+var ErrMaxObjects = errors.New("the file exceeds the maximum number of objects")
+
+// MaxObjects limits the number of objects read by helpers that read all of the
+// remaining objects, like `DecodeAll` and `Keys`. When a file includes more
+// objects, `ErrMaxObjects` is returned before the first object over the limit
+// is read. This bounds the memory used to read untrusted files. A maximum of
+// zero (the default) does not limit the number of objects.
+func MaxObjects(n int) ReaderOption {
+	return func(f *rsfReader) {
+		f.maxObjects = n
+	}
 }
 
-func (f *rsfReader) Pos() int {
+// checkMaxObjects returns `ErrMaxObjects` if `n` objects have been read, the
+// maximum is reached, and another object follows. The next object's size
+// field is peeked without reading it.
+func (f *rsfReader) checkMaxObjects(buf *bufio.Reader, n int) error {
+	if f.maxObjects <= 0 || n < f.maxObjects {
+		return nil
+	}
+	bs, err := buf.Peek(sizeFieldLen)
+	if err != nil || binary.LittleEndian.Uint32(bs) == 0 {
+		// The objects have ended (or the error will be reported by the
+		// caller's next read).
+		return nil
+	}
+	return ErrMaxObjects
+}
+
+func NewReader(opts ...ReaderOption) Reader {
+	f := &rsfReader{}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// DebugLogger logs the reader's decisions to `l` at the debug level: each
+// `AdvanceTo` target, each field skipped while advancing (with the number of
+// bytes discarded), and each field read by `Unmarshal`, `DecodeObject`, and
+// related helpers (with its type). Each event includes the reader position.
+// This helps trace where a file that fails to decode diverges from the index.
+// Nothing is logged, or formatted, when no logger is set.
+func DebugLogger(l *slog.Logger) ReaderOption {
+	return func(f *rsfReader) {
+		f.logger = l
+	}
+}
+
+// notifyField invokes the `OnField` hook, if any, for the field named by
+// appending `entry.FieldName` to `parent`.
+func (f *rsfReader) notifyField(parent []string, entry IndexEntry) {
+	if f.onField == nil {
+		return
+	}
+	path := make([]string, 0, len(parent)+1)
+	path = append(path, parent...)
+	f.onField(append(path, entry.FieldName), entry)
+}
+
+func (f *rsfReader) Pos() int64 {
 	return f.pos
 }
 
+// At returns a copy of the field path that the reader was last advanced to,
+// like `[]string{"list", "name"}` following `AdvanceTo(buf, "list", "name")`.
+// The path is empty at the start of each object. This is useful for
+// diagnosing the position of the reader after a series of advances.
+func (f *rsfReader) At() []string {
+	return append([]string{}, f.at...)
+}
+
+// WriterVersion returns the version of the producer that wrote the file, as
+// recorded in the index header with `ProducerVersion`. An empty string is
+// returned if the file doesn't record a producer version.
+func (f *rsfReader) WriterVersion() string {
+	return f.writerVersion
+}
+
+// Features returns the feature flags recorded in the Version3 index header,
+// like `FeatureStringTable`, so that callers can check whether a feature is
+// used without probing the file. Earlier index versions predate the flags and
+// cannot use these features, so zero is returned for them.
+func (f *rsfReader) Features() uint32 {
+	return uint32(f.features)
+}
+
+// Seek seeks to the file position `pos`. Since `go vet` requires methods
+// named Seek with an int64 first parameter to match `io.Seeker`, `pos` is an
+// int; internally, positions are int64 so that files larger than 2 GiB can be
+// read on 32-bit platforms.
 func (f *rsfReader) Seek(pos int, r io.Seeker, fieldNames ...string) error {
-	i, err := r.Seek(int64(pos), 0)
-	f.pos = int(i)
+	return f.seek(int64(pos), r, fieldNames...)
+}
+
+func (f *rsfReader) seek(pos int64, r io.Seeker, fieldNames ...string) error {
+	i, err := r.Seek(pos, io.SeekStart)
+	f.pos = i
 	f.at = fieldNames
 	return err
 }
 
 func (f *rsfReader) Discard(sz int, r *bufio.Reader, fieldNames ...string) error {
 	i, err := r.Discard(sz)
-	if err != nil {
+	if err == io.EOF && i > 0 {
+		return io.ErrUnexpectedEOF
+	} else if err != nil {
 		return err
 	} else if i != sz {
 		return fmt.Errorf("unexpected discard size %d; expected %d", i, sz)
 	}
-	f.pos += i
+	f.pos += int64(i)
 	if len(fieldNames) > 0 {
 		f.at = fieldNames
 	}
@@ -55,10 +268,8 @@ func (f *rsfReader) ReadSizeField(r io.Reader) (int, error) {
 	i, err := io.ReadFull(r, bs)
 	if err != nil {
 		return 0, err
-	} else if i != sizeFieldLen {
-		return 0, fmt.Errorf("unexpected read size %d; expected %d", i, sizeFieldLen)
 	}
-	f.pos += i
+	f.pos += int64(i)
 	sz := binary.LittleEndian.Uint32(bs)
 	return int(sz), nil
 }
@@ -68,61 +279,61 @@ func (f *rsfReader) ReadIntField(r io.Reader) (int64, error) {
 	i, err := io.ReadFull(r, bs)
 	if err != nil {
 		return 0, err
-	} else if i != sizeInt64 {
-		return 0, fmt.Errorf("unexpected read size %d; expected %d", i, sizeInt64)
 	}
-	f.pos += i
+	f.pos += int64(i)
 	intVal, _ := binary.Varint(bs)
 	return intVal, nil
 }
 
+// ReadFixedIntField reads an int64 value written with `WriteFixedInt64Field`.
+func (f *rsfReader) ReadFixedIntField(r io.Reader) (int64, error) {
+	bs := make([]byte, sizeFixedInt64)
+	i, err := io.ReadFull(r, bs)
+	if err != nil {
+		return 0, err
+	}
+	f.pos += int64(i)
+	return int64(binary.LittleEndian.Uint64(bs)), nil
+}
+
 func (f *rsfReader) ReadFloatField(r io.Reader) (float64, error) {
 	bs := make([]byte, sizeFloat64)
 	i, err := io.ReadFull(r, bs)
 	if err != nil {
 		return 0, err
-	} else if i != sizeFloat64 {
-		return 0, fmt.Errorf("unexpected read size %d; expected %d", i, sizeFloat64)
 	}
-	f.pos += i
+	f.pos += int64(i)
 	return math.Float64frombits(binary.LittleEndian.Uint64(bs)), nil
 }
 
 func (f *rsfReader) ReadFixedStringField(sz int, r io.Reader) (string, error) {
 	// Read string field
-	bs := make([]byte, sz)
-	i, err := io.ReadFull(r, bs)
+	bs, err := readBytes(r, sz)
 	if err != nil {
 		return "", err
-	} else if i != sz {
-		return "", fmt.Errorf("unexpected read size %d; expected %d", i, sz)
 	}
-	f.pos += i
+	f.pos += int64(sz)
 
 	return string(bs), nil
 }
 
 func (f *rsfReader) ReadStringField(r io.Reader) (string, error) {
+	if f.strings != nil {
+		return f.readStringRef(r)
+	}
+
 	// read size
-	bs := make([]byte, sizeFieldLen)
-	i, err := io.ReadFull(r, bs)
+	sz, err := f.ReadSizeField(r)
 	if err != nil {
 		return "", err
-	} else if i != sizeFieldLen {
-		return "", fmt.Errorf("unexpected read size %d; expected %d", i, sizeFieldLen)
 	}
-	f.pos += i
 
-	sz := binary.LittleEndian.Uint32(bs)
 	// Read string field
-	bs = make([]byte, sz)
-	i, err = io.ReadFull(r, bs)
+	bs, err := readBytes(r, sz)
 	if err != nil {
-		return "", err
-	} else if i != int(sz) {
-		return "", fmt.Errorf("unexpected read size %d; expected %d", i, sz)
+		return "", unexpectedEOF(err)
 	}
-	f.pos += i
+	f.pos += int64(sz)
 
 	return string(bs), nil
 }
@@ -133,10 +344,159 @@ func (f *rsfReader) ReadBoolField(r io.Reader) (bool, error) {
 	i, err := io.ReadFull(r, bs)
 	if err != nil {
 		return false, err
-	} else if i != 1 {
-		return false, fmt.Errorf("unexpected read size %d; expected %d", i, 1)
 	}
-	f.pos += i
+	f.pos += int64(i)
 
 	return bs[0] == 1, nil
 }
+
+// ReadIPField reads an IP address written with `WriteIPField`. The zero
+// `netip.Addr` is returned for the zero address.
+func (f *rsfReader) ReadIPField(r io.Reader) (netip.Addr, error) {
+	sz := make([]byte, 1)
+	_, err := io.ReadFull(r, sz)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	f.pos++
+
+	switch sz[0] {
+	case 0:
+		return netip.Addr{}, nil
+	case 4, 16:
+	default:
+		return netip.Addr{}, fmt.Errorf("invalid IP address length %d", sz[0])
+	}
+
+	bs := make([]byte, sz[0])
+	_, err = io.ReadFull(r, bs)
+	if err != nil {
+		return netip.Addr{}, unexpectedEOF(err)
+	}
+	f.pos += int64(len(bs))
+
+	addr, _ := netip.AddrFromSlice(bs)
+	return addr, nil
+}
+
+func (f *rsfReader) ReadCompressedStringField(r io.Reader) (string, error) {
+	// read size
+	sz, err := f.ReadSizeField(r)
+	if err != nil {
+		return "", err
+	}
+
+	// Read compressed value
+	bs, err := readBytes(r, sz)
+	if err != nil {
+		return "", unexpectedEOF(err)
+	}
+	f.pos += int64(sz)
+
+	// Decompress value
+	val, err := codecs[f.codec()].decompress(bs)
+	if err != nil {
+		return "", err
+	}
+	return string(val), nil
+}
+
+// codec returns the codec of compressed strings recorded in the index header.
+func (f *rsfReader) codec() Codec {
+	if f.features&FeatureZstd != 0 {
+		return CodecZstd
+	}
+	return CodecGzip
+}
+
+// readOpaque reads an opaque value written by an `RSFMarshaler`, and returns
+// the encoded bytes.
+func (f *rsfReader) readOpaque(r io.Reader) ([]byte, error) {
+	sz, err := f.ReadSizeField(r)
+	if err != nil {
+		return nil, err
+	}
+	bs, err := readBytes(r, sz)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	f.pos += int64(sz)
+	return bs, nil
+}
+
+// maxPrealloc is the largest read for which `readBytes` allocates the full
+// buffer up front.
+const maxPrealloc = 64 * 1024
+
+// maxPreallocLen is the largest number of array elements allocated before the
+// elements are read.
+const maxPreallocLen = 1024
+
+// readBytes reads exactly `sz` bytes from `r`. Since sizes are read from the
+// data, a corrupt or malicious size could request a huge allocation. Reads
+// larger than `maxPrealloc` are buffered incrementally, so memory use is
+// bounded by the data actually available.
+func readBytes(r io.Reader, sz int) ([]byte, error) {
+	if sz < 0 {
+		return nil, fmt.Errorf("invalid read size %d", sz)
+	}
+
+	if sz <= maxPrealloc {
+		bs := make([]byte, sz)
+		_, err := io.ReadFull(r, bs)
+		if err != nil {
+			return nil, err
+		}
+		return bs, nil
+	}
+
+	buf := &bytes.Buffer{}
+	n, err := io.CopyN(buf, r, int64(sz))
+	if err == io.EOF && n > 0 {
+		return nil, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// unexpectedEOF converts an `io.EOF` error, returned when a reader ends after
+// the start of a field, to `io.ErrUnexpectedEOF`. An `io.EOF` error is only
+// returned by the Read* methods when the reader ends before a field.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// byteRecorder records the bytes read from an RSF file (e.g., with an
+// `io.TeeReader`) so that the bytes between two reader positions can be
+// retrieved. The `base` is the file position of the first recorded byte.
+type byteRecorder struct {
+	base int64
+	data []byte
+}
+
+func (b *byteRecorder) Write(p []byte) (int, error) {
+	b.data = append(b.data, p...)
+	return len(p), nil
+}
+
+// trim discards the recorded bytes before the file position `pos`.
+func (b *byteRecorder) trim(pos int64) {
+	if pos < b.base || pos-b.base > int64(len(b.data)) {
+		return
+	}
+	b.data = append(b.data[:0], b.data[pos-b.base:]...)
+	b.base = pos
+}
+
+// bytes returns the recorded bytes from the file position `start` up to
+// `end`, or nil if they weren't recorded.
+func (b *byteRecorder) bytes(start, end int64) []byte {
+	if end <= start || start < b.base || end-b.base > int64(len(b.data)) {
+		return nil
+	}
+	return b.data[start-b.base : end-b.base]
+}
@@ -0,0 +1,21 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"errors"
+)
+
+// ErrFieldAbsent is returned by `AdvanceTo` when the field is an optional
+// array that is absent from the current object. See `NilAsAbsent`.
+var ErrFieldAbsent = errors.New("field is absent from the object")
+
+// absent returns true if the field described by `entry` is an optional array
+// that is absent from the current object, according to the object's bitmap.
+// See writer_absent.go for the format.
+func (f *rsfReader) absent(entry IndexEntry) (bool, error) {
+	if !entry.Optional {
+		return false, nil
+	}
+	present, err := f.readBit(entry.PresenceBit, entry.FieldName)
+	return !present, err
+}
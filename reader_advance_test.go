@@ -19,20 +19,20 @@ func TestReaderMigrationSuite(t *testing.T) {
 }
 
 func (s *ReaderMigrationSuite) TestAdvanceFields() {
-	buf := bufio.NewReader(getData(s.Suite))
+	buf := bufio.NewReader(getData(&s.Suite))
 	r := NewReader()
 
 	// Read the index
 	_, err := r.ReadIndex(buf)
 	s.Assert().Nil(err)
-	s.Assert().Equal(117, r.Pos())
+	s.Assert().Equal(int64(117), r.Pos())
 
 	// Record should be 132 bytes in length
 	recordSz, err := r.ReadSizeField(buf)
 	s.Assert().Nil(err)
 	s.Assert().Equal(132, recordSz)
 	// Position increased by 4 (size field is 4 bytes)
-	s.Assert().Equal(121, r.Pos())
+	s.Assert().Equal(int64(121), r.Pos())
 
 	// Company
 	err = r.AdvanceTo(buf, "company")
@@ -41,7 +41,7 @@ func (s *ReaderMigrationSuite) TestAdvanceFields() {
 	s.Assert().Nil(err)
 	s.Assert().Equal("posit", company)
 	// Position increased by 9. Size field is 4 bytes + data is 5 bytes.
-	s.Assert().Equal(130, r.Pos())
+	s.Assert().Equal(int64(130), r.Pos())
 
 	// Skip the ready field and advance to "list"
 	// Array should be 100 bytes in size
@@ -52,17 +52,17 @@ func (s *ReaderMigrationSuite) TestAdvanceFields() {
 	s.Assert().Nil(err)
 	s.Assert().Equal(100, arraySz)
 	// Position increased by 4
-	s.Assert().Equal(135, r.Pos())
+	s.Assert().Equal(int64(135), r.Pos())
 	// Get expect array end position
-	arrayEndPos := arrayPos + arraySz
-	s.Assert().Equal(231, arrayEndPos)
+	arrayEndPos := arrayPos + int64(arraySz)
+	s.Assert().Equal(int64(231), arrayEndPos)
 
 	// Array should be 3 elements in length
 	arrayLen, err := r.ReadSizeField(buf)
 	s.Assert().Nil(err)
 	s.Assert().Equal(3, arrayLen)
 	// Position increased by 4
-	s.Assert().Equal(139, r.Pos())
+	s.Assert().Equal(int64(139), r.Pos())
 
 	// Array index. Read all three index entries
 	// Entry 1
@@ -92,7 +92,7 @@ func (s *ReaderMigrationSuite) TestAdvanceFields() {
 	// a 10-byte fixed-length string and a 4-byte size field.
 	// 3*14=42
 	// 136+42=178
-	s.Assert().Equal(181, r.Pos())
+	s.Assert().Equal(int64(181), r.Pos())
 
 	// Get the first array element's "Name" field
 	err = r.AdvanceTo(buf, "list", "name")
@@ -103,7 +103,7 @@ func (s *ReaderMigrationSuite) TestAdvanceFields() {
 	// Position increased by 4+9. String size uses 4 bytes and
 	// string value uses 9 bytes.
 	// 178+13=183
-	s.Assert().Equal(194, r.Pos())
+	s.Assert().Equal(int64(194), r.Pos())
 
 	// Skip the "Verified" field and advance to second array element's "Name" field
 	err = r.AdvanceToNextElement(buf)
@@ -117,7 +117,7 @@ func (s *ReaderMigrationSuite) TestAdvanceFields() {
 	// string value uses 9 bytes. Also, the skipped field "verified"
 	// uses 1 byte
 	// 191+13+1=205
-	s.Assert().Equal(208, r.Pos())
+	s.Assert().Equal(int64(208), r.Pos())
 
 	// Read the second array element's "Verified" field
 	err = r.AdvanceTo(buf, "list", "verified")
@@ -125,19 +125,19 @@ func (s *ReaderMigrationSuite) TestAdvanceFields() {
 	verified, err := r.ReadBoolField(buf)
 	s.Assert().Nil(err)
 	s.Assert().True(verified)
-	s.Assert().Equal(209, r.Pos())
+	s.Assert().Equal(int64(209), r.Pos())
 
 	// Skip the last array element's "Name" field and advance to "Verified".
 	// This tests skipping an array sub-element.
 	err = r.AdvanceToNextElement(buf)
 	s.Assert().Nil(err)
-	s.Assert().Equal(209, r.Pos())
+	s.Assert().Equal(int64(209), r.Pos())
 	err = r.AdvanceTo(buf, "list", "verified")
 	s.Assert().Nil(err)
 	// Last name field (skipped) read "this is from 2022"
 	// 17 bytes + 4 bytes (size) = 21
 	// 206 + 21 = 227
-	s.Assert().Equal(230, r.Pos())
+	s.Assert().Equal(int64(230), r.Pos())
 	verified, err = r.ReadBoolField(buf)
 	s.Assert().Nil(err)
 	s.Assert().True(verified)
@@ -157,20 +157,20 @@ func (s *ReaderMigrationSuite) TestAdvanceFields() {
 }
 
 func (s *ReaderMigrationSuite) TestAdvanceArray() {
-	buf := bufio.NewReader(getData(s.Suite))
+	buf := bufio.NewReader(getData(&s.Suite))
 	r := NewReader()
 
 	// Read the index
 	_, err := r.ReadIndex(buf)
 	s.Assert().Nil(err)
-	s.Assert().Equal(117, r.Pos())
+	s.Assert().Equal(int64(117), r.Pos())
 
 	// Record should be 132 bytes in length
 	recordSz, err := r.ReadSizeField(buf)
 	s.Assert().Nil(err)
 	s.Assert().Equal(132, recordSz)
 	// Position increased by 4 (size field is 4 bytes)
-	s.Assert().Equal(121, r.Pos())
+	s.Assert().Equal(int64(121), r.Pos())
 
 	// Company
 	err = r.AdvanceTo(buf, "company")
@@ -179,7 +179,7 @@ func (s *ReaderMigrationSuite) TestAdvanceArray() {
 	s.Assert().Nil(err)
 	s.Assert().Equal("posit", company)
 	// Position increased by 9. Size field is 4 bytes + data is 5 bytes.
-	s.Assert().Equal(130, r.Pos())
+	s.Assert().Equal(int64(130), r.Pos())
 
 	// Skip the "ready" field and the "list" array and advance
 	// to "age". This tests skipping both a regular field and an entire array.
@@ -188,7 +188,7 @@ func (s *ReaderMigrationSuite) TestAdvanceArray() {
 	age, err := r.ReadIntField(buf)
 	s.Assert().Nil(err)
 	s.Assert().Equal(int64(55), age)
-	s.Assert().Equal(241, r.Pos())
+	s.Assert().Equal(int64(241), r.Pos())
 
 	// Read the "rating" field.
 	err = r.AdvanceTo(buf, "rating")
@@ -205,37 +205,37 @@ func (s *ReaderMigrationSuite) TestAdvanceArray() {
 	tmp, err := os.CreateTemp("", "")
 	s.Assert().Nil(err)
 	defer os.Remove(tmp.Name())
-	buf = bufio.NewReader(getData(s.Suite))
+	buf = bufio.NewReader(getData(&s.Suite))
 	_, err = io.Copy(tmp, buf)
 
 	// Seek back to the last array element.
 	err = r.Seek(209, tmp)
 	s.Assert().Nil(err)
 	// Position set to 209
-	s.Assert().Equal(209, r.Pos())
+	s.Assert().Equal(int64(209), r.Pos())
 
 	// Read last array element's "Name" field again from the temp file.
 	name, err := r.ReadStringField(tmp)
 	s.Assert().Nil(err)
 	s.Assert().Equal("this is from 2022", name)
-	s.Assert().Equal(230, r.Pos())
+	s.Assert().Equal(int64(230), r.Pos())
 }
 
 func (s *ReaderMigrationSuite) TestAdvanceErrors() {
-	buf := bufio.NewReader(getData(s.Suite))
+	buf := bufio.NewReader(getData(&s.Suite))
 	r := NewReader()
 
 	// Read the index
 	_, err := r.ReadIndex(buf)
 	s.Assert().Nil(err)
-	s.Assert().Equal(117, r.Pos())
+	s.Assert().Equal(int64(117), r.Pos())
 
 	// Record should be 132 bytes in length
 	recordSz, err := r.ReadSizeField(buf)
 	s.Assert().Nil(err)
 	s.Assert().Equal(132, recordSz)
 	// Position increased by 4 (size field is 4 bytes)
-	s.Assert().Equal(121, r.Pos())
+	s.Assert().Equal(int64(121), r.Pos())
 
 	// Company
 	err = r.AdvanceTo(buf, "company")
@@ -244,9 +244,204 @@ func (s *ReaderMigrationSuite) TestAdvanceErrors() {
 	s.Assert().Nil(err)
 	s.Assert().Equal("posit", company)
 	// Position increased by 9. Size field is 4 bytes + data is 5 bytes.
-	s.Assert().Equal(130, r.Pos())
+	s.Assert().Equal(int64(130), r.Pos())
 
 	// Attempt to advance to field that doesn't exist
 	err = r.AdvanceTo(buf, "nothere")
 	s.Assert().ErrorIs(err, ErrNoSuchField)
 }
+
+func (s *ReaderMigrationSuite) TestAdvanceEmptyFieldNames() {
+	buf := bufio.NewReader(getData(&s.Suite))
+	r := NewReader()
+	index, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+
+	// Simulate a file whose "ready" and "list.verified" fields were written
+	// with empty names.
+	index[1].FieldName = ""
+	index[2].Subfields[1].FieldName = ""
+	r.SetIndex(index)
+
+	_, err = r.ReadSizeField(buf)
+	s.Require().Nil(err)
+
+	// Advance past "company" to the empty-named field.
+	err = r.AdvanceTo(buf, "")
+	s.Require().Nil(err)
+	s.Assert().Equal(int64(130), r.Pos())
+	ready, err := r.ReadBoolField(buf)
+	s.Require().Nil(err)
+	s.Assert().True(ready)
+
+	// Advancing from the empty-named field doesn't start over.
+	err = r.AdvanceTo(buf, "list")
+	s.Require().Nil(err)
+	s.Assert().Equal(int64(131), r.Pos())
+	_, err = r.ReadSizeField(buf)
+	s.Require().Nil(err)
+	_, err = r.ReadSizeField(buf)
+	s.Require().Nil(err)
+	err = r.Discard(3*14, buf)
+	s.Require().Nil(err)
+
+	// Read each element's empty-named field.
+	var verified []bool
+	for i := 0; i < 3; i++ {
+		err = r.AdvanceTo(buf, "list", "")
+		s.Require().Nil(err)
+		v, err := r.ReadBoolField(buf)
+		s.Require().Nil(err)
+		verified = append(verified, v)
+		err = r.AdvanceToNextElement(buf)
+		s.Require().Nil(err)
+	}
+	s.Assert().Equal([]bool{false, true, true}, verified)
+
+	err = r.AdvanceTo(buf, "age")
+	s.Require().Nil(err)
+	age, err := r.ReadIntField(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(int64(55), age)
+}
+
+func (s *ReaderMigrationSuite) TestAdvanceToPath() {
+	bufA := bufio.NewReader(getData(&s.Suite))
+	a := NewReader()
+	bufB := bufio.NewReader(getData(&s.Suite))
+	b := NewReader()
+	for _, rb := range []struct {
+		r   Reader
+		buf *bufio.Reader
+	}{{a, bufA}, {b, bufB}} {
+		_, err := rb.r.ReadIndex(rb.buf)
+		s.Require().Nil(err)
+		_, err = rb.r.BeginObject(rb.buf)
+		s.Require().Nil(err)
+
+		// Advance to the array and discard its size, length, and index.
+		s.Require().Nil(rb.r.AdvanceTo(rb.buf, "list"))
+		s.Require().Nil(rb.r.Discard(8+3*14, rb.buf))
+	}
+
+	// A dotted path resolves like the equivalent field names.
+	s.Require().Nil(a.AdvanceTo(bufA, "list", "name"))
+	s.Require().Nil(b.AdvanceToPath(bufB, "list.name"))
+	s.Assert().Equal(a.Pos(), b.Pos())
+	nameA, err := a.ReadStringField(bufA)
+	s.Require().Nil(err)
+	nameB, err := b.ReadStringField(bufB)
+	s.Require().Nil(err)
+	s.Assert().Equal("From 2020", nameA)
+	s.Assert().Equal(nameA, nameB)
+
+	// Empty segments are ignored.
+	s.Require().Nil(a.AdvanceTo(bufA, "list", "verified"))
+	s.Require().Nil(b.AdvanceToPath(bufB, "list..verified."))
+	s.Assert().Equal(a.Pos(), b.Pos())
+
+	// Errors.
+	s.Assert().ErrorIs(b.AdvanceToPath(bufB, ""), ErrNoSuchField)
+	s.Assert().ErrorIs(b.AdvanceToPath(bufB, "."), ErrNoSuchField)
+	s.Assert().ErrorIs(b.AdvanceToPath(bufB, "list.nothere"), ErrNoSuchField)
+}
+
+func (s *ReaderMigrationSuite) TestReadFieldPath() {
+	buf := bufio.NewReader(getData(&s.Suite))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	_, err = r.BeginObject(buf)
+	s.Require().Nil(err)
+
+	company, err := r.ReadFieldPath(buf, "company")
+	s.Require().Nil(err)
+	s.Assert().Equal("posit", company)
+
+	// Arrays are read like `DecodeObject` reads them.
+	list, err := r.ReadFieldPath(buf, "list")
+	s.Require().Nil(err)
+	s.Assert().Len(list, 3)
+
+	rating, err := r.ReadFieldPath(buf, "rating.")
+	s.Require().Nil(err)
+	s.Assert().Equal(92.689, rating)
+
+	_, err = r.ReadFieldPath(buf, "")
+	s.Assert().ErrorIs(err, ErrNoSuchField)
+}
+
+func (s *ReaderMigrationSuite) TestCurrentField() {
+	buf := bufio.NewReader(getData(&s.Suite))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	_, err = r.BeginObject(buf)
+	s.Require().Nil(err)
+
+	// Not yet at a field.
+	_, ok := r.CurrentField()
+	s.Assert().False(ok)
+
+	s.Require().Nil(r.AdvanceTo(buf, "company"))
+	entry, ok := r.CurrentField()
+	s.Require().True(ok)
+	s.Assert().Equal("company", entry.FieldName)
+	s.Assert().Equal(FieldTypeVarStr, entry.FieldType)
+	_, err = r.ReadStringField(buf)
+	s.Require().Nil(err)
+
+	// Advance to the array and discard its size, length, and index.
+	s.Require().Nil(r.AdvanceTo(buf, "list"))
+	entry, ok = r.CurrentField()
+	s.Require().True(ok)
+	s.Assert().Equal(FieldTypeArray, entry.FieldType)
+	s.Assert().True(entry.Indexed)
+	s.Require().Nil(r.Discard(8+3*14, buf))
+
+	s.Require().Nil(r.AdvanceTo(buf, "list", "name"))
+	entry, ok = r.CurrentField()
+	s.Require().True(ok)
+	s.Assert().Equal("name", entry.FieldName)
+	s.Assert().Equal(FieldTypeVarStr, entry.FieldType)
+	name, err := r.ReadStringField(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal("From 2020", name)
+
+	// At the end of an element, the reader is at the start of the next.
+	s.Require().Nil(r.AdvanceToNextElement(buf))
+	_, ok = r.CurrentField()
+	s.Assert().False(ok)
+}
+
+func (s *ReaderMigrationSuite) TestAt() {
+	buf := bufio.NewReader(getData(&s.Suite))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	_, err = r.BeginObject(buf)
+	s.Require().Nil(err)
+	s.Assert().Empty(r.At())
+
+	s.Require().Nil(r.AdvanceTo(buf, "company"))
+	s.Assert().Equal([]string{"company"}, r.At())
+	_, err = r.ReadStringField(buf)
+	s.Require().Nil(err)
+
+	// Advance to the array and discard its size, length, and index.
+	s.Require().Nil(r.AdvanceTo(buf, "list"))
+	s.Assert().Equal([]string{"list"}, r.At())
+	s.Require().Nil(r.Discard(8+3*14, buf))
+
+	s.Require().Nil(r.AdvanceTo(buf, "list", "name"))
+	at := r.At()
+	s.Assert().Equal([]string{"list", "name"}, at)
+
+	// The path is a copy.
+	at[1] = "verified"
+	s.Assert().Equal([]string{"list", "name"}, r.At())
+
+	// Ending the object clears the path.
+	s.Require().Nil(r.SkipObject(buf))
+	s.Assert().Empty(r.At())
+}
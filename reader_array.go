@@ -0,0 +1,522 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+var ErrInvalidArrayTarget = errors.New("array target must be a non-nil pointer to a slice")
+var ErrNotArray = errors.New("field is not an array")
+var ErrNotIndexedArray = errors.New("field is not an indexed array")
+
+// ErrStopIteration can be returned by the callback passed to
+// `ForEachElement` to stop iterating without an error.
+var ErrStopIteration = errors.New("stop iteration")
+
+// ReadArrayInto reads the array indicated by `fieldNames` into `dst`, which
+// must be a pointer to a slice (e.g., `*[]Snapshot`). The reader must be
+// positioned at the start of the array (e.g., with `AdvanceTo`). Elements are
+// read like `Unmarshal` reads array fields.
+//
+// The existing capacity of `*dst` is reused: the slice is truncated to zero
+// length and the elements are appended, so decoding many arrays into the same
+// slice doesn't allocate a new backing array for each one. Since the backing
+// array is overwritten, callers must not retain slices (or elements) returned
+// by previous calls.
+//
+// Since the `index` tag option is on the array field rather than the element
+// type, the element field that receives each index key of an indexed struct
+// array is found by elimination: it's the only element field tagged `skip`
+// that isn't in the index and whose type matches the index keys. If there is
+// no such field, or more than one, the keys are not assigned.
+//
+// When complete, the reader is positioned at the end of the array.
+func (f *rsfReader) ReadArrayInto(buf *bufio.Reader, dst any, fieldNames ...string) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return ErrInvalidArrayTarget
+	}
+	if len(fieldNames) == 0 {
+		return ErrNoSuchField
+	}
+	entries, pos, err := entrySet(f.index, fieldNames...)
+	if err != nil {
+		return err
+	}
+	entry := entries[pos]
+	if entry.FieldType != FieldTypeArray && entry.FieldType != FieldTypePackedArray {
+		return ErrNotArray
+	}
+
+	v := rv.Elem()
+	v.SetLen(0)
+	var indexField string
+	if entry.Indexed && v.Type().Elem().Kind() == reflect.Struct {
+		indexField, err = keyField(entry, v.Type().Elem())
+		if err != nil {
+			return err
+		}
+	}
+	err = f.readArray(fieldNames, entry, indexField, v, buf)
+	if err != nil {
+		return err
+	}
+	f.at = fieldNames
+	return nil
+}
+
+// keyField returns the name of the field of the element struct type `t` that
+// receives the index keys of the indexed array described by `entry`: the only
+// field tagged `skip` that isn't in the index and whose type matches the keys.
+// An empty name is returned if there is no such field, or more than one.
+func keyField(entry IndexEntry, t reflect.Type) (string, error) {
+	all, err := structFields(t, true)
+	if err != nil {
+		return "", err
+	}
+	written, err := structFields(t, false)
+	if err != nil {
+		return "", err
+	}
+	inIndex := make(map[string]bool, len(entry.Subfields))
+	for _, subfield := range entry.Subfields {
+		inIndex[subfield.FieldName] = true
+	}
+
+	var name string
+	for n, field := range all {
+		if _, ok := written[n]; ok || inIndex[n] {
+			continue
+		}
+		ft := t.FieldByIndex(field.path).Type
+		var match bool
+		switch reflect.Kind(entry.IndexType) {
+		case reflect.String:
+			match = ft.Kind() == reflect.String
+		case reflect.Int64:
+			switch ft.Kind() {
+			case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+				match = true
+			}
+		case reflect.Array:
+			match = isByteArray(ft) && ft.Len() == entry.IndexSize
+		}
+		if !match {
+			continue
+		}
+		if name != "" {
+			return "", nil
+		}
+		name = n
+	}
+	return name, nil
+}
+
+// SeekToIndexValue advances to the element of the indexed array indicated by
+// `fieldNames` whose index key equals `key`. String keys must match the fixed
+// index size. Integer keys may be any integer type. Fixed-size byte array keys
+// may be given as a `[]byte` or as a byte array (e.g., `[16]byte`). The reader
+// must be positioned at the start of the array (e.g., with `AdvanceTo`).
+//
+// Only the array index is read to find the element; the preceding elements
+// are discarded without reading their fields. If the element is found, the
+// reader is positioned at the start of the element, so subfields can be read
+// with `AdvanceTo`, and true is returned. Otherwise, the array is skipped and
+// false is returned.
+func (f *rsfReader) SeekToIndexValue(buf *bufio.Reader, key any, fieldNames ...string) (bool, error) {
+	if len(fieldNames) == 0 {
+		return false, ErrNoSuchField
+	}
+	entries, pos, err := entrySet(f.index, fieldNames...)
+	if err != nil {
+		return false, err
+	}
+	entry := entries[pos]
+	if !entry.Indexed {
+		return false, ErrNotIndexedArray
+	}
+	want, err := indexKey(entry, key)
+	if err != nil {
+		return false, err
+	}
+
+	// Read the array size and length.
+	start := f.pos
+	arraySz, err := f.ReadSizeField(buf)
+	if err != nil {
+		return false, err
+	}
+	arrayLen, err := f.ReadSizeField(buf)
+	if err != nil {
+		return false, err
+	}
+	err = checkArrayLen(entry, arraySz, arrayLen)
+	if err != nil {
+		return false, err
+	}
+
+	// Search the array index, recording the offset of each element from
+	// the start of the elements.
+	found := -1
+	var offset int
+	for i := 0; i < arrayLen; i++ {
+		var k any
+		k, err = f.readIndexKey(entry, buf)
+		if err != nil {
+			return false, err
+		}
+		elSz := entry.FieldSize
+		if entry.FieldType != FieldTypePackedArray {
+			elSz, err = f.ReadSizeField(buf)
+			if err != nil {
+				return false, err
+			}
+		}
+		if keyEqual(k, want) {
+			found = i
+			break
+		}
+		offset += elSz
+	}
+
+	if found < 0 {
+		f.at = fieldNames
+		return false, f.Discard(int(start+int64(arraySz)-f.pos), buf)
+	}
+
+	// Discard the rest of the array index and the preceding elements.
+	remaining := arrayLen - found - 1
+	if entry.FieldType == FieldTypePackedArray {
+		remaining *= entry.IndexSize
+	} else {
+		remaining *= entry.IndexSize + sizeFieldLen
+	}
+	at := make([]string, 0, len(fieldNames)+1)
+	at = append(at, fieldNames...)
+	return true, f.Discard(remaining+offset, buf, append(at, Top)...)
+}
+
+// ForEachElement calls `fn` for each element of the array indicated by
+// `fieldNames`, which must be an indexed array or a packed array, without
+// reading the elements into memory. The reader must be positioned at the start
+// of the array (e.g., with `AdvanceTo`).
+//
+// `fn` is called with the element number and its index key (nil for packed
+// arrays without an index), with the reader positioned at the start of the
+// element's fields. The callback may read as many of the element's fields as
+// it needs with `AdvanceTo` and the Read* methods; the unread remainder of the
+// element is then discarded. If `fn` returns `ErrStopIteration`, the rest of
+// the array is discarded and nil is returned. Other errors stop iteration and
+// are returned.
+//
+// When complete, the reader is positioned at the end of the array.
+func (f *rsfReader) ForEachElement(buf *bufio.Reader, fn func(i int, key any) error, fieldNames ...string) error {
+	if len(fieldNames) == 0 {
+		return ErrNoSuchField
+	}
+	idx, err := f.ReadArrayIndex(buf, fieldNames...)
+	if err != nil {
+		return err
+	}
+
+	for i := range idx.Sizes {
+		elStart := f.pos
+		f.at = idx.elementAt()
+		err = fn(i, idx.Keys[i])
+		if errors.Is(err, ErrStopIteration) {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		read := int(f.pos - elStart)
+		if read > idx.Sizes[i] {
+			return fmt.Errorf("array %s element %d: read %d bytes past the end of the element", idx.entry.FieldName, i, read-idx.Sizes[i])
+		}
+		err = f.Discard(idx.Sizes[i]-read, buf)
+		if err != nil {
+			return err
+		}
+	}
+
+	f.at = fieldNames
+	return f.Discard(int(idx.end-f.pos), buf)
+}
+
+// ArrayIndex describes the elements of an indexed or packed array. See
+// `ReadArrayIndex`.
+type ArrayIndex struct {
+	// The index key of each element. Keys are nil for packed arrays
+	// without an index.
+	Keys []any
+
+	// The size of each element in bytes.
+	Sizes []int
+
+	entry      IndexEntry
+	fieldNames []string
+
+	// The positions of the first element and the end of the array.
+	start int64
+	end   int64
+}
+
+// Len returns the number of elements in the array.
+func (idx *ArrayIndex) Len() int {
+	return len(idx.Sizes)
+}
+
+// elementAt returns the field path of the start of an element.
+func (idx *ArrayIndex) elementAt() []string {
+	at := make([]string, 0, len(idx.fieldNames)+1)
+	at = append(at, idx.fieldNames...)
+	return append(at, Top)
+}
+
+// ReadArrayIndex reads the size, length, and index of the array indicated by
+// `fieldNames`, which must be an indexed array or a packed array, and returns
+// the index key and size of each element. The reader must be positioned at the
+// start of the array (e.g., with `AdvanceTo`). When complete, the reader is
+// positioned at the start of the first element.
+//
+// With a seekable source, the keys can be used to choose the elements to read
+// with `ReadElementAt`, without reading the other elements.
+func (f *rsfReader) ReadArrayIndex(r io.Reader, fieldNames ...string) (*ArrayIndex, error) {
+	if len(fieldNames) == 0 {
+		return nil, ErrNoSuchField
+	}
+	entries, pos, err := entrySet(f.index, fieldNames...)
+	if err != nil {
+		return nil, err
+	}
+	entry := entries[pos]
+	if entry.FieldType != FieldTypePackedArray && !entry.Indexed {
+		return nil, ErrNotIndexedArray
+	}
+
+	// Read the array size and length.
+	start := f.pos
+	arraySz, err := f.ReadSizeField(r)
+	if err != nil {
+		return nil, err
+	}
+	arrayLen, err := f.ReadSizeField(r)
+	if err != nil {
+		return nil, err
+	}
+	err = checkArrayLen(entry, arraySz, arrayLen)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the array index, which records each element's key and size.
+	// Packed array elements are all the record size.
+	idx := &ArrayIndex{
+		Keys:       make([]any, arrayLen),
+		Sizes:      make([]int, arrayLen),
+		entry:      entry,
+		fieldNames: append([]string{}, fieldNames...),
+		end:        start + int64(arraySz),
+	}
+	for i := 0; i < arrayLen; i++ {
+		if entry.Indexed {
+			idx.Keys[i], err = f.readIndexKey(entry, r)
+			if err != nil {
+				return nil, err
+			}
+		}
+		idx.Sizes[i] = entry.FieldSize
+		if entry.FieldType != FieldTypePackedArray {
+			idx.Sizes[i], err = f.ReadSizeField(r)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	idx.start = f.pos
+	f.at = idx.elementAt()
+	return idx, nil
+}
+
+// ReadElementAt seeks to element `n` of the array described by `idx`, which
+// was read with `ReadArrayIndex`, and reads the element like `DecodeObject`
+// reads array elements: struct elements are returned as a map that includes
+// the element's index key (see `IndexKeyField`). The position of `r` must
+// match the reader position. Elements may be read in any order.
+//
+// When complete, the reader is positioned at the end of the element.
+func (f *rsfReader) ReadElementAt(r io.ReadSeeker, idx *ArrayIndex, n int) (any, error) {
+	if n < 0 || n >= idx.Len() {
+		return nil, fmt.Errorf("array element %d out of range; array length is %d", n, idx.Len())
+	}
+
+	elStart := idx.start
+	for i := 0; i < n; i++ {
+		elStart += int64(idx.Sizes[i])
+	}
+	elEnd := elStart + int64(idx.Sizes[n])
+	err := f.seek(elStart, r, idx.elementAt()...)
+	if err != nil {
+		return nil, err
+	}
+
+	elEntry, err := arrayElement(idx.entry, idx.Len())
+	if err != nil {
+		return nil, err
+	}
+
+	// Read no further than the end of the element.
+	buf := bufio.NewReader(io.LimitReader(r, int64(idx.Sizes[n])))
+	var el any
+	if elEntry.FieldType == 0 {
+		var m map[string]any
+		m, err = f.decodeStruct(idx.entry.Subfields, buf)
+		if err == nil && idx.entry.Indexed {
+			m[IndexKeyField] = idx.Keys[n]
+		}
+		el = m
+	} else {
+		el, err = f.decodeValue(elEntry, buf)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading array %s element %d: %w", idx.entry.FieldName, n, err)
+	}
+	return el, f.seek(elEnd, r, idx.elementAt()...)
+}
+
+// indexKey converts `key` to the type of the index keys of `entry`. See
+// `readIndexKey`.
+func indexKey(entry IndexEntry, key any) (any, error) {
+	v := reflect.ValueOf(key)
+	switch reflect.Kind(entry.IndexType) {
+	case reflect.String:
+		if v.Kind() == reflect.String {
+			return v.String(), nil
+		}
+	case reflect.Int64:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+			return v.Int(), nil
+		}
+	case reflect.Array:
+		if b, ok := key.([]byte); ok {
+			return b, nil
+		} else if v.IsValid() && isByteArray(v.Type()) {
+			b = make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			return b, nil
+		}
+	default:
+		return nil, ErrInvalidIndexFieldType
+	}
+	return nil, fmt.Errorf("cannot use %T as an index key for field %s", key, entry.FieldName)
+}
+
+// keyEqual returns true if two index keys are equal.
+func keyEqual(a, b any) bool {
+	if ab, ok := a.([]byte); ok {
+		bb, ok := b.([]byte)
+		return ok && bytes.Equal(ab, bb)
+	}
+	return a == b
+}
+
+// SkipArrayElement discards the array element at the current position of the
+// array indicated by `fieldNames`, without reading the element's fields. The
+// reader must be positioned at the start of the element: after the array index
+// is read (for the first element), or after `AdvanceToNextElement` or
+// `SkipArrayElement` (for later elements).
+//
+// Packed array elements are discarded using the record size. Otherwise, each
+// field is advanced past, which only reads the size fields of variable-length
+// values. When complete, the reader is positioned at the start of the next
+// element, so its subfields can be read with `AdvanceTo`.
+func (f *rsfReader) SkipArrayElement(buf *bufio.Reader, fieldNames ...string) error {
+	if len(fieldNames) == 0 {
+		return ErrNoSuchField
+	}
+	entries, pos, err := entrySet(f.index, fieldNames...)
+	if err != nil {
+		return err
+	}
+	entry := entries[pos]
+
+	at := make([]string, 0, len(fieldNames)+1)
+	at = append(at, fieldNames...)
+	at = append(at, Top)
+
+	switch {
+	case entry.FieldType == FieldTypePackedArray:
+		return f.Discard(entry.FieldSize, buf, at...)
+	case entry.FieldType != FieldTypeArray:
+		return ErrNotArray
+	case emptyElements(entry):
+		err = f.readEmptyElement(buf)
+		if err != nil {
+			return err
+		}
+	case len(entry.Subfields) > 0:
+		for _, subfield := range entry.Subfields {
+			f.notifyField(fieldNames, subfield)
+			err = f.advance(subfield, buf)
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		fieldType := elementFieldType(reflect.Kind(entry.SubfieldType))
+		if fieldType == 0 {
+			return fmt.Errorf("cannot skip array elements of type %s", reflect.Kind(entry.SubfieldType))
+		}
+		err = f.advance(IndexEntry{FieldType: fieldType}, buf)
+		if err != nil {
+			return err
+		}
+	}
+
+	f.at = at
+	return nil
+}
+
+// emptyElements returns true if the elements of the array described by `entry`
+// are structs with no fields, like structs whose fields are all tagged
+// `rsf:"-"`. Unless the array is indexed, each such element is written as a
+// size field (see `writeEmptyElement`).
+func emptyElements(entry IndexEntry) bool {
+	return entry.FieldType == FieldTypeArray && !entry.Indexed && len(entry.Subfields) == 0 &&
+		reflect.Kind(entry.SubfieldType) == reflect.Struct
+}
+
+// readEmptyElement reads an element of an array of structs with no fields,
+// discarding any bytes that the element's size field includes.
+func (f *rsfReader) readEmptyElement(buf *bufio.Reader) error {
+	sz, err := f.ReadSizeField(buf)
+	if err != nil {
+		return err
+	}
+	return f.Discard(sz, buf)
+}
+
+// elementFieldType returns the field type used to write array elements of kind
+// `kind`, or zero for struct elements and unsupported kinds.
+func elementFieldType(kind reflect.Kind) int {
+	switch kind {
+	case reflect.String:
+		return FieldTypeVarStr
+	case reflect.Bool:
+		return FieldTypeBool
+	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+		return FieldTypeInt64
+	case reflect.Float32, reflect.Float64:
+		return FieldTypeFloat
+	case reflect.Array, reflect.Slice:
+		return FieldTypeArray
+	default:
+		return 0
+	}
+}
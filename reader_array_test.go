@@ -0,0 +1,559 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ReaderArraySuite struct {
+	suite.Suite
+}
+
+func TestReaderArraySuite(t *testing.T) {
+	suite.Run(t, &ReaderArraySuite{})
+}
+
+func getComplexData(s *suite.Suite) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	for _, obj := range testComplexData {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+	return buf
+}
+
+func (s *ReaderArraySuite) TestReadArrayInto() {
+	buf := bufio.NewReader(getComplexData(&s.Suite))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+
+	var classifiers []Classifier
+	var snapshots []FullManifestSnapshotPyPI
+	var first *FullManifestSnapshotPyPI
+	for _, obj := range testComplexData {
+		_, err = r.BeginObject(buf)
+		s.Require().Nil(err)
+
+		err = r.AdvanceTo(buf, "classifiers")
+		s.Require().Nil(err)
+		err = r.ReadArrayInto(buf, &classifiers, "classifiers")
+		s.Require().Nil(err)
+		s.Assert().Equal(obj.Classifiers, classifiers)
+
+		err = r.AdvanceTo(buf, "snapshots")
+		s.Require().Nil(err)
+		err = r.ReadArrayInto(buf, &snapshots, "snapshots")
+		s.Require().Nil(err)
+		s.Require().Len(snapshots, len(obj.Snapshots))
+		for i, snapshot := range obj.Snapshots {
+			s.Assert().Equal(snapshot.Description, snapshots[i].Description)
+			s.Assert().Equal(snapshot.Deleted, snapshots[i].Deleted)
+			s.Assert().Equal(snapshot.Version, snapshots[i].Version)
+		}
+
+		// The second object has fewer snapshots, so the backing array
+		// is reused.
+		if first == nil {
+			first = &snapshots[0]
+		} else {
+			s.Assert().Same(first, &snapshots[0])
+		}
+
+		// Fields following the arrays can still be read.
+		err = r.AdvanceTo(buf, "popularity")
+		s.Require().Nil(err)
+		popularity, err := r.ReadIntField(buf)
+		s.Require().Nil(err)
+		s.Assert().Equal(obj.Popularity, popularity)
+
+		s.Require().Nil(r.SkipObject(buf))
+	}
+}
+
+func (s *ReaderArraySuite) TestReadArrayIntoNested() {
+	b := &bytes.Buffer{}
+	w := NewWriterWithVersion(b, Version2)
+	_, err := w.WriteObject(testNestedData)
+	s.Require().Nil(err)
+
+	buf := bufio.NewReader(b)
+	r := NewReader()
+	_, err = r.ReadIndex(buf)
+	s.Require().Nil(err)
+	_, err = r.BeginObject(buf)
+	s.Require().Nil(err)
+
+	// The barcodes are assigned to the `skip` field of each product, and
+	// the ids to the `skip` field of each variation.
+	var products []nestedProduct
+	err = r.AdvanceTo(buf, "products")
+	s.Require().Nil(err)
+	err = r.ReadArrayInto(buf, &products, "products")
+	s.Require().Nil(err)
+	s.Assert().Equal(testNestedData.Products, products)
+
+	err = r.AdvanceTo(buf, "age")
+	s.Require().Nil(err)
+	age, err := r.ReadIntField(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(int64(55), age)
+}
+
+func (s *ReaderArraySuite) TestReadArrayIntoErrors() {
+	buf := bufio.NewReader(getComplexData(&s.Suite))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	_, err = r.BeginObject(buf)
+	s.Require().Nil(err)
+
+	var snapshots []FullManifestSnapshotPyPI
+	s.Assert().ErrorIs(r.ReadArrayInto(buf, snapshots, "snapshots"), ErrInvalidArrayTarget)
+	s.Assert().ErrorIs(r.ReadArrayInto(buf, &snapshots), ErrNoSuchField)
+	s.Assert().ErrorIs(r.ReadArrayInto(buf, &snapshots, "missing"), ErrNoSuchField)
+	s.Assert().ErrorIs(r.ReadArrayInto(buf, &snapshots, "author"), ErrNotArray)
+}
+
+func benchmarkReadArrayInto(b *testing.B, reuse bool) {
+	data := &bytes.Buffer{}
+	w := NewWriterWithVersion(data, Version2)
+	for i := 0; i < 1000; i++ {
+		_, err := w.WriteObject(testComplexData[i%len(testComplexData)])
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf := bufio.NewReader(bytes.NewReader(data.Bytes()))
+		r := NewReader()
+		_, err := r.ReadIndex(buf)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		var snapshots []FullManifestSnapshotPyPI
+		for {
+			_, err = r.BeginObject(buf)
+			if err != nil {
+				break
+			}
+			if !reuse {
+				snapshots = nil
+			}
+			err = r.AdvanceTo(buf, "snapshots")
+			if err != nil {
+				b.Fatal(err)
+			}
+			err = r.ReadArrayInto(buf, &snapshots, "snapshots")
+			if err != nil {
+				b.Fatal(err)
+			}
+			err = r.SkipObject(buf)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkReadArrayInto(b *testing.B) {
+	benchmarkReadArrayInto(b, true)
+}
+
+func BenchmarkReadArrayIntoNoReuse(b *testing.B) {
+	benchmarkReadArrayInto(b, false)
+}
+
+type uuidFile struct {
+	ID   [16]byte `rsf:"id,skip"`
+	Name string   `rsf:"name"`
+	Size int64    `rsf:"size"`
+}
+
+type uuidRecord struct {
+	Name  string     `rsf:"name"`
+	Files []uuidFile `rsf:"files,index:id"`
+	Count int64      `rsf:"count"`
+}
+
+func uuid(b byte) [16]byte {
+	var id [16]byte
+	for i := range id {
+		id[i] = b + byte(i)
+	}
+	return id
+}
+
+var testUUIDData = uuidRecord{
+	Name: "uuids",
+	Files: []uuidFile{
+		{ID: uuid(0x10), Name: "one", Size: 1},
+		{ID: uuid(0x20), Name: "two", Size: 2},
+		{ID: uuid(0x30), Name: "three", Size: 3},
+	},
+	Count: 3,
+}
+
+func (s *ReaderArraySuite) getUUIDData() []byte {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	_, err := w.WriteObject(testUUIDData)
+	s.Require().Nil(err)
+	return buf.Bytes()
+}
+
+func (s *ReaderArraySuite) TestByteArrayIndex() {
+	data := s.getUUIDData()
+
+	r := NewReader()
+	buf := bufio.NewReader(bytes.NewReader(data))
+	index, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	s.Assert().True(index[1].Indexed)
+	s.Assert().Equal(16, index[1].IndexSize)
+	s.Assert().Equal("files arr[struct] index:bytes(16)", strings.Split(index.String(), "\n")[1])
+
+	// Unmarshal assigns the keys.
+	var rec uuidRecord
+	s.Require().Nil(r.Unmarshal(buf, &rec))
+	s.Assert().Equal(testUUIDData, rec)
+
+	// The printer shows the keys in hex.
+	out := &bytes.Buffer{}
+	err = Print(out, bufio.NewReader(bytes.NewReader(data)))
+	s.Require().Nil(err)
+	s.Assert().Contains(out.String(), "- 202122232425262728292a2b2c2d2e2f\n")
+}
+
+func (s *ReaderArraySuite) TestSeekToIndexValue() {
+	data := s.getUUIDData()
+
+	id := uuid(0x30)
+	for _, key := range []any{id, id[:]} {
+		r := NewReader()
+		buf := bufio.NewReader(bytes.NewReader(data))
+		_, err := r.ReadIndex(buf)
+		s.Require().Nil(err)
+		_, err = r.BeginObject(buf)
+		s.Require().Nil(err)
+
+		err = r.AdvanceTo(buf, "files")
+		s.Require().Nil(err)
+		found, err := r.SeekToIndexValue(buf, key, "files")
+		s.Require().Nil(err)
+		s.Require().True(found)
+
+		err = r.AdvanceTo(buf, "files", "size")
+		s.Require().Nil(err)
+		size, err := r.ReadIntField(buf)
+		s.Require().Nil(err)
+		s.Assert().Equal(int64(3), size)
+
+		// The fields following the array can be read.
+		err = r.AdvanceToNextElement(buf, "files")
+		s.Require().Nil(err)
+		err = r.AdvanceTo(buf, "count")
+		s.Require().Nil(err)
+		count, err := r.ReadIntField(buf)
+		s.Require().Nil(err)
+		s.Assert().Equal(int64(3), count)
+		s.Require().Nil(r.SkipObject(buf))
+	}
+}
+
+func (s *ReaderArraySuite) TestSeekToIndexValueNotFound() {
+	buf := bufio.NewReader(getComplexData(&s.Suite))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	_, err = r.BeginObject(buf)
+	s.Require().Nil(err)
+
+	err = r.AdvanceTo(buf, "snapshots")
+	s.Require().Nil(err)
+	found, err := r.SeekToIndexValue(buf, "2020-01-01", "snapshots")
+	s.Require().Nil(err)
+	s.Assert().False(found)
+
+	// The array was skipped.
+	err = r.AdvanceTo(buf, "popularity")
+	s.Require().Nil(err)
+	popularity, err := r.ReadIntField(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(int64(55), popularity)
+}
+
+func (s *ReaderArraySuite) TestSeekToIndexValueErrors() {
+	buf := bufio.NewReader(getComplexData(&s.Suite))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+
+	_, err = r.SeekToIndexValue(buf, "2020-10-10", "classifiers")
+	s.Assert().ErrorIs(err, ErrNotIndexedArray)
+	_, err = r.SeekToIndexValue(buf, 10, "snapshots")
+	s.Assert().EqualError(err, "cannot use int as an index key for field snapshots")
+}
+
+func (s *ReaderArraySuite) TestSkipArrayElement() {
+	buf := bufio.NewReader(getData(&s.Suite))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	_, err = r.BeginObject(buf)
+	s.Require().Nil(err)
+
+	// Read the array size, length, and index.
+	err = r.AdvanceTo(buf, "list")
+	s.Require().Nil(err)
+	_, err = r.ReadSizeField(buf)
+	s.Require().Nil(err)
+	arrayLen, err := r.ReadSizeField(buf)
+	s.Require().Nil(err)
+	s.Require().Equal(3, arrayLen)
+	for i := 0; i < arrayLen; i++ {
+		_, err = r.ReadFixedStringField(10, buf)
+		s.Require().Nil(err)
+		_, err = r.ReadSizeField(buf)
+		s.Require().Nil(err)
+	}
+	s.Assert().Equal(int64(181), r.Pos())
+
+	// Read the first element's name.
+	err = r.AdvanceTo(buf, "list", "name")
+	s.Require().Nil(err)
+	name, err := r.ReadStringField(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal("From 2020", name)
+	err = r.AdvanceToNextElement(buf)
+	s.Require().Nil(err)
+
+	// Skip the second element (14 bytes).
+	s.Assert().Equal(int64(195), r.Pos())
+	err = r.SkipArrayElement(buf, "list")
+	s.Require().Nil(err)
+	s.Assert().Equal(int64(209), r.Pos())
+
+	// Read the third element's name.
+	err = r.AdvanceTo(buf, "list", "name")
+	s.Require().Nil(err)
+	name, err = r.ReadStringField(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal("this is from 2022", name)
+
+	s.Assert().ErrorIs(r.SkipArrayElement(buf, "age"), ErrNotArray)
+}
+
+func (s *ReaderArraySuite) TestSkipPackedArrayElement() {
+	data := &bytes.Buffer{}
+	w := NewWriterWithVersion(data, Version2)
+	_, err := w.WriteObject(testPackedData)
+	s.Require().Nil(err)
+
+	buf := bufio.NewReader(data)
+	r := NewReader()
+	_, err = r.ReadIndex(buf)
+	s.Require().Nil(err)
+	_, err = r.BeginObject(buf)
+	s.Require().Nil(err)
+
+	// Read the array size and length, and discard the index.
+	err = r.AdvanceTo(buf, "points")
+	s.Require().Nil(err)
+	_, err = r.ReadSizeField(buf)
+	s.Require().Nil(err)
+	arrayLen, err := r.ReadSizeField(buf)
+	s.Require().Nil(err)
+	err = r.Discard(arrayLen*sizeInt64, buf)
+	s.Require().Nil(err)
+
+	// Skip the first element and read the second element's label.
+	err = r.SkipArrayElement(buf, "points")
+	s.Require().Nil(err)
+	err = r.AdvanceTo(buf, "points", "label")
+	s.Require().Nil(err)
+	label, err := r.ReadFixedStringField(4, buf)
+	s.Require().Nil(err)
+	s.Assert().Equal("nw  ", label)
+}
+
+func (s *ReaderArraySuite) TestForEachElement() {
+	buf := bufio.NewReader(bytes.NewReader(s.getUUIDData()))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	_, err = r.BeginObject(buf)
+	s.Require().Nil(err)
+
+	// Sum the sizes without reading the names or building a slice.
+	err = r.AdvanceTo(buf, "files")
+	s.Require().Nil(err)
+	var total int64
+	var keys []any
+	err = r.ForEachElement(buf, func(i int, key any) error {
+		keys = append(keys, key)
+		err := r.AdvanceTo(buf, "files", "size")
+		if err != nil {
+			return err
+		}
+		size, err := r.ReadIntField(buf)
+		total += size
+		return err
+	}, "files")
+	s.Require().Nil(err)
+	s.Assert().Equal(int64(6), total)
+	s.Require().Len(keys, 3)
+	s.Assert().Equal(testUUIDData.Files[2].ID[:], keys[2])
+
+	// The reader is positioned at the end of the array.
+	err = r.AdvanceTo(buf, "count")
+	s.Require().Nil(err)
+	count, err := r.ReadIntField(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(int64(3), count)
+}
+
+func (s *ReaderArraySuite) TestForEachElementStop() {
+	data := &bytes.Buffer{}
+	w := NewWriterWithVersion(data, Version2)
+	_, err := w.WriteObject(testPackedData)
+	s.Require().Nil(err)
+
+	buf := bufio.NewReader(data)
+	r := NewReader()
+	_, err = r.ReadIndex(buf)
+	s.Require().Nil(err)
+	_, err = r.BeginObject(buf)
+	s.Require().Nil(err)
+
+	// Stop at the first invalid point. Elements that aren't read are
+	// skipped.
+	err = r.AdvanceTo(buf, "points")
+	s.Require().Nil(err)
+	var invalid any
+	err = r.ForEachElement(buf, func(i int, key any) error {
+		err := r.AdvanceTo(buf, "points", "valid")
+		if err != nil {
+			return err
+		}
+		valid, err := r.ReadBoolField(buf)
+		if err != nil {
+			return err
+		}
+		if !valid {
+			invalid = key
+			return ErrStopIteration
+		}
+		return nil
+	}, "points")
+	s.Require().Nil(err)
+	s.Assert().Equal(int64(30), invalid)
+
+	err = r.AdvanceTo(buf, "sides")
+	s.Require().Nil(err)
+	sides, err := r.ReadIntField(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(int64(4), sides)
+}
+
+func (s *ReaderArraySuite) TestForEachElementErrors() {
+	buf := bufio.NewReader(getData(&s.Suite))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+
+	fn := func(i int, key any) error { return nil }
+	s.Assert().ErrorIs(r.ForEachElement(buf, fn), ErrNoSuchField)
+	s.Assert().ErrorIs(r.ForEachElement(buf, fn, "nothere"), ErrNoSuchField)
+	s.Assert().ErrorIs(r.ForEachElement(buf, fn, "company"), ErrNotIndexedArray)
+}
+
+// countingSeeker counts the bytes read from a seekable source.
+type countingSeeker struct {
+	io.ReadSeeker
+	n int
+}
+
+func (c *countingSeeker) Read(p []byte) (int, error) {
+	n, err := c.ReadSeeker.Read(p)
+	c.n += n
+	return n, err
+}
+
+func (s *ReaderArraySuite) TestReadElementAt() {
+	data := getComplexData(&s.Suite).Bytes()
+	buf := bufio.NewReader(bytes.NewReader(data))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	_, err = r.BeginObject(buf)
+	s.Require().Nil(err)
+	err = r.AdvanceTo(buf, "snapshots")
+	s.Require().Nil(err)
+
+	rs := &countingSeeker{ReadSeeker: bytes.NewReader(data)}
+	s.Require().Nil(r.Seek(int(r.Pos()), rs, "snapshots"))
+	idx, err := r.ReadArrayIndex(rs, "snapshots")
+	s.Require().Nil(err)
+	s.Require().Equal(3, idx.Len())
+	s.Assert().Equal([]any{"2020-10-11", "2020-10-10", "2020-10-09"}, idx.Keys)
+
+	// Read only the elements with matching keys.
+	rs.n = 0
+	var els []any
+	for i, key := range idx.Keys {
+		if key != "2020-10-11" {
+			continue
+		}
+		el, err := r.ReadElementAt(rs, idx, i)
+		s.Require().Nil(err)
+		els = append(els, el)
+	}
+	s.Assert().Equal([]any{map[string]any{
+		IndexKeyField: "2020-10-11",
+		"description": "The description of numpy",
+		"deleted":     false,
+		"version":     "3.0.3",
+		"summary":     "numpy summary",
+		"license":     "MIT",
+	}}, els)
+	s.Assert().Equal(idx.Sizes[0], rs.n)
+
+	// Elements can be read in any order, and fields following the element
+	// can be read with `AdvanceTo`.
+	el, err := r.ReadElementAt(rs, idx, 2)
+	s.Require().Nil(err)
+	s.Assert().Equal(true, el.(map[string]any)["deleted"])
+	el, err = r.ReadElementAt(rs, idx, 1)
+	s.Require().Nil(err)
+	s.Assert().Equal("3.0.2", el.(map[string]any)["version"])
+	err = r.AdvanceTo(bufio.NewReader(rs), "snapshots", "description")
+	s.Require().Nil(err)
+
+	_, err = r.ReadElementAt(rs, idx, 3)
+	s.Assert().EqualError(err, "array element 3 out of range; array length is 3")
+}
+
+func (s *ReaderArraySuite) TestReadArrayIndexNotIndexed() {
+	buf := bufio.NewReader(getComplexData(&s.Suite))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	_, err = r.BeginObject(buf)
+	s.Require().Nil(err)
+	err = r.AdvanceTo(buf, "classifiers")
+	s.Require().Nil(err)
+	_, err = r.ReadArrayIndex(buf, "classifiers")
+	s.Assert().ErrorIs(err, ErrNotIndexedArray)
+}
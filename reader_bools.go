@@ -0,0 +1,35 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"fmt"
+	"io"
+)
+
+// readBitmap reads the bitmap of packed bool fields described by `entry`. See
+// writer_bools.go for the format.
+func (f *rsfReader) readBitmap(entry IndexEntry, r io.Reader) error {
+	bs, err := readBytes(r, entry.FieldSize)
+	if err != nil {
+		return err
+	}
+	f.pos += int64(entry.FieldSize)
+	f.bitmap = bs
+	return nil
+}
+
+// readPackedBool returns the value of the packed bool field described by
+// `entry` from the current object's bitmap. Since packed bools are not
+// written in the object, nothing is read.
+func (f *rsfReader) readPackedBool(entry IndexEntry) (bool, error) {
+	return f.readBit(entry.FieldSize, entry.FieldName)
+}
+
+// readBit returns bit number `bit` of the current object's bitmap, which
+// belongs to the field named `name`.
+func (f *rsfReader) readBit(bit int, name string) (bool, error) {
+	if bit < 0 || bit/8 >= len(f.bitmap) {
+		return false, fmt.Errorf("bit %d of field %s is outside the bool bitmap", bit, name)
+	}
+	return f.bitmap[bit/8]&(1<<(bit%8)) != 0, nil
+}
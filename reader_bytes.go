@@ -0,0 +1,106 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var ErrPackedBoolBytes = errors.New("packed bools are not written as separate fields")
+
+// FieldBytes advances to the field indicated by `fieldNames` and returns the
+// field's raw encoded bytes, exactly as written: the size field and the
+// string for variable-length strings, the fixed bytes for fixed strings, the
+// encoded integer for integers, and so on. For arrays, the bytes include the
+// array's size, length, index, and elements. The reader is positioned just
+// after the field. This is useful for hashing or signing specific fields.
+//
+// Packed bools cannot be read, since they are written as bits of a bitmap.
+func (f *rsfReader) FieldBytes(buf *bufio.Reader, fieldNames ...string) ([]byte, error) {
+	entries, pos, err := entrySet(f.index, fieldNames...)
+	if err != nil {
+		return nil, err
+	}
+	if pos < 0 || pos >= len(entries) {
+		return nil, ErrNoSuchField
+	}
+	entry := entries[pos]
+
+	err = f.AdvanceTo(buf, fieldNames...)
+	if err != nil {
+		return nil, err
+	}
+
+	sz, err := f.fieldLen(entry, buf)
+	if err != nil {
+		return nil, err
+	}
+	bs, err := readBytes(buf, sz)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	f.pos += int64(sz)
+	return bs, nil
+}
+
+// fieldLen returns the number of bytes used to write the field described by
+// `entry`, which begins at the current position of `buf`, like `advance`.
+// Size fields are peeked rather than read.
+func (f *rsfReader) fieldLen(entry IndexEntry, buf *bufio.Reader) (int, error) {
+	// peekSize returns the size field at the current position.
+	peekSize := func() (int, error) {
+		bs, err := buf.Peek(sizeFieldLen)
+		if err != nil {
+			return 0, err
+		}
+		return int(binary.LittleEndian.Uint32(bs)), nil
+	}
+
+	switch entry.FieldType {
+	case FieldTypeFixedStr, FieldTypeBigEndianInt, FieldTypeBigEndianFloat:
+		return entry.FieldSize, nil
+	case FieldTypeArray, FieldTypePackedArray:
+		// Absent arrays are not written in the object.
+		absent, err := f.absent(entry)
+		if err != nil || absent {
+			return 0, err
+		}
+		return peekSize()
+	case FieldTypeCompressedStr, FieldTypeOpaque, FieldTypeBytes:
+		sz, err := peekSize()
+		return sizeFieldLen + sz, err
+	case FieldTypeVarStr:
+		if f.strings != nil {
+			return sizeFieldLen, nil
+		}
+		sz, err := peekSize()
+		return sizeFieldLen + sz, err
+	case FieldTypeBool:
+		return 1, nil
+	case FieldTypeInt64:
+		return sizeInt64, nil
+	case FieldTypeFloat:
+		return sizeFloat64, nil
+	case FieldTypeFixedInt64:
+		return sizeFixedInt64, nil
+	case FieldTypeDays:
+		return sizeInt32, nil
+	case FieldTypeOverflowStr:
+		return sizeFieldLen + sizeFieldLen, nil
+	case FieldTypeIP:
+		bs, err := buf.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		return 1 + int(bs[0]), nil
+	case FieldTypePackedBool, FieldTypeBoolBitmap:
+		return 0, withField(entry.FieldName, ErrPackedBoolBytes)
+	default:
+		if entry.FieldWidth > 0 {
+			return entry.FieldWidth, nil
+		}
+		return 0, fmt.Errorf("unexpected index field type %d", entry.FieldType)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ReaderBytesSuite struct {
+	suite.Suite
+}
+
+func TestReaderBytesSuite(t *testing.T) {
+	suite.Run(t, &ReaderBytesSuite{})
+}
+
+func (s *ReaderBytesSuite) TestFieldBytes() {
+	buf := bufio.NewReader(getData(&s.Suite))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	_, err = r.BeginObject(buf)
+	s.Require().Nil(err)
+
+	// The bytes match the bytes emitted by the writer.
+	w := &rsfWriter{}
+	company := &bytes.Buffer{}
+	_, err = w.WriteStringField(0, "posit", company)
+	s.Require().Nil(err)
+	bs, err := r.FieldBytes(buf, "company")
+	s.Require().Nil(err)
+	s.Assert().Equal(company.Bytes(), bs)
+
+	// The reader is positioned after the field.
+	s.Require().Nil(r.AdvanceTo(buf, "ready"))
+	ready, err := r.ReadBoolField(buf)
+	s.Require().Nil(err)
+	s.Assert().True(ready)
+
+	// Skip the array.
+	age := &bytes.Buffer{}
+	_, err = w.WriteInt64Field(0, 55, age)
+	s.Require().Nil(err)
+	bs, err = r.FieldBytes(buf, "age")
+	s.Require().Nil(err)
+	s.Assert().Equal(age.Bytes(), bs)
+
+	s.Require().Nil(r.AdvanceTo(buf, "rating"))
+	rating, err := r.ReadFloatField(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(92.689, rating)
+}
+
+func (s *ReaderBytesSuite) TestFieldBytesArray() {
+	data := getData(&s.Suite)
+	raw := data.Bytes()
+	buf := bufio.NewReader(bytes.NewReader(raw))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	_, err = r.BeginObject(buf)
+	s.Require().Nil(err)
+
+	// The array bytes include the array's size field.
+	err = r.AdvanceTo(buf, "list")
+	s.Require().Nil(err)
+	start := r.Pos()
+	bs, err := r.FieldBytes(buf, "list")
+	s.Require().Nil(err)
+	s.Assert().Equal(raw[start:r.Pos()], bs)
+
+	s.Require().Nil(r.AdvanceTo(buf, "age"))
+	age, err := r.ReadIntField(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(int64(55), age)
+
+	_, err = r.FieldBytes(buf, "author")
+	s.Assert().ErrorIs(err, ErrNoSuchField)
+}
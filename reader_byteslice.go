@@ -0,0 +1,38 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// ReadBytesField reads a byte slice written with `WriteBytesField`. An empty
+// slice is returned for an empty value.
+func (f *rsfReader) ReadBytesField(r io.Reader) ([]byte, error) {
+	sz, err := f.ReadSizeField(r)
+	if err != nil {
+		return nil, err
+	}
+
+	bs, err := readBytes(r, sz)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	f.pos += int64(sz)
+	return bs, nil
+}
+
+// setBytes assigns a byte slice to a slice of bytes. Since the writer does not
+// distinguish between nil and empty slices, empty values are assigned as nil.
+func setBytes(v reflect.Value, bs []byte) error {
+	if !isBytesType(v.Type()) {
+		return fmt.Errorf("cannot read bytes into %s", v.Type())
+	}
+	if len(bs) == 0 {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	v.SetBytes(bs)
+	return nil
+}
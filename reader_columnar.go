@@ -0,0 +1,100 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+var ErrNotColumnar = errors.New("file is not columnar")
+var ErrColumnarFile = errors.New("columnar files must be read with ReadColumn")
+
+// ReadColumn reads the column of the top-level field `field` from a columnar
+// file written with `WriteColumnar`. Only the index, the column sizes, and the
+// column itself are read; the other columns are skipped with a seek. Values
+// are read into `T` like `Unmarshal` reads fields, so a `float64` field can be
+// read as `[]float64` and an array field of strings as `[][]string`. If `T`
+// is an interface type, values have the types used by `DecodeObject`.
+func ReadColumn[T any](r io.ReadSeeker, field string) ([]T, error) {
+	_, err := r.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	// The index and column sizes are read without buffering so that nothing
+	// beyond them is read.
+	f := &rsfReader{}
+	index, err := f.ReadIndex(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading index: %s", err)
+	}
+	if f.features&FeatureColumnar == 0 {
+		return nil, ErrNotColumnar
+	}
+	rows, err := f.ReadSizeField(r)
+	if err != nil {
+		return nil, err
+	}
+	count, err := f.ReadSizeField(r)
+	if err != nil {
+		return nil, err
+	}
+	if count != len(index) {
+		return nil, fmt.Errorf("column count %d does not match the index field count %d", count, len(index))
+	}
+
+	// Find the column offset from the sizes of the preceding columns.
+	offset := f.pos + int64(count)*sizeFieldLen
+	var entry *IndexEntry
+	var sz int
+	for i := 0; i < count; i++ {
+		sz, err = f.ReadSizeField(r)
+		if err != nil {
+			return nil, err
+		}
+		if index[i].FieldName == field {
+			entry = &index[i]
+			break
+		}
+		offset += int64(sz)
+	}
+	if entry == nil {
+		return nil, ErrNoSuchField
+	}
+
+	_, err = r.Seek(offset, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	f.pos = offset
+	buf := bufio.NewReader(io.LimitReader(r, int64(sz)))
+
+	path := []string{field}
+	vals := make([]T, 0, min(rows, maxPreallocLen))
+	for i := 0; i < rows; i++ {
+		var val T
+		v := reflect.ValueOf(&val).Elem()
+		if v.Kind() == reflect.Interface {
+			var decoded any
+			decoded, err = f.decodeValue(*entry, buf)
+			if err == nil {
+				dv := reflect.ValueOf(decoded)
+				if !dv.Type().AssignableTo(v.Type()) {
+					err = fmt.Errorf("cannot read %T into %s", decoded, v.Type())
+				} else {
+					v.Set(dv)
+				}
+			}
+		} else {
+			err = f.readValue(path, *entry, "", v, buf)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading column %s row %d: %w", field, i, err)
+		}
+		vals = append(vals, val)
+	}
+	return vals, nil
+}
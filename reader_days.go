@@ -0,0 +1,32 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+)
+
+// ReadDaysField reads a date written with `WriteDaysField`. The date is
+// returned at midnight UTC.
+func (f *rsfReader) ReadDaysField(r io.Reader) (time.Time, error) {
+	bs := make([]byte, sizeInt32)
+	i, err := io.ReadFull(r, bs)
+	if err != nil {
+		return time.Time{}, err
+	}
+	f.pos += int64(i)
+	days := int64(int32(binary.LittleEndian.Uint32(bs)))
+	return time.Unix(days*secondsPerDay, 0).UTC(), nil
+}
+
+// setTime assigns a date to a `time.Time`.
+func setTime(v reflect.Value, t time.Time) error {
+	if v.Type() != timeType {
+		return fmt.Errorf("cannot read date into %s", v.Type())
+	}
+	v.Set(reflect.ValueOf(t))
+	return nil
+}
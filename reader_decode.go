@@ -0,0 +1,317 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// IndexKeyField is the map key used by `DecodeObject` to record the index key
+// of each element of an indexed array.
+const IndexKeyField = "@key"
+
+// SchemaIDField is the map key used by `DecodeObject` to record the schema id
+// of each object in a multi-schema file (see `NewWriterWithSchemas`).
+const SchemaIDField = "@schema"
+
+// DecodeObject reads the next object from `buf` into a generic map, without
+// requiring a Go struct. The reader must already be positioned at the start of
+// an object, and an index must have been read with `ReadIndex` or supplied with
+// `SetIndex`.
+//
+// Values are decoded as follows:
+//
+//   - Strings are decoded as `string`.
+//   - Booleans are decoded as `bool`.
+//   - Integers are decoded as `int64`.
+//   - Floats are decoded as `float64`.
+//   - IP addresses are decoded as `netip.Addr`.
+//   - Dates written with the `days` option are decoded as `time.Time` at
+//     midnight UTC.
+//   - Byte slices, and values written by an `RSFMarshaler`, are decoded as
+//     `[]byte`.
+//   - Arrays are decoded as `[]any`, and struct array elements are decoded as
+//     `map[string]any`. The index key of each element of an indexed array is
+//     recorded in the element map with the `IndexKeyField` key. Arrays that
+//     are absent from the object (see `NilAsAbsent`) are omitted.
+//
+// In a multi-schema file, each object is decoded with the index of its schema,
+// and the schema id is recorded with the `SchemaIDField` key as an `int`.
+//
+// When complete, the reader is positioned at the start of the next object. An
+// `io.EOF` error is returned at the end of the objects.
+func (f *rsfReader) DecodeObject(buf *bufio.Reader) (map[string]any, error) {
+	var obj map[string]any
+	err := f.readObject(buf, func() error {
+		var err error
+		obj, err = f.decodeStruct(f.index, buf)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if f.schemas != nil {
+		obj[SchemaIDField] = f.schema
+	}
+	return obj, nil
+}
+
+// ReadScalars reads the top-level scalar fields of the next object into a
+// generic map, without decoding arrays. Array fields and values written by an
+// `RSFMarshaler` are skipped. Scalar values are decoded like `DecodeObject`.
+// When complete, the reader is positioned at the start of the next object. An
+// `io.EOF` error is returned at the end of the objects.
+func (f *rsfReader) ReadScalars(buf *bufio.Reader) (map[string]any, error) {
+	obj := make(map[string]any)
+	err := f.readObject(buf, func() error {
+		for _, entry := range f.index {
+			var err error
+			switch entry.FieldType {
+			case FieldTypeArray, FieldTypePackedArray, FieldTypeOpaque, FieldTypeBoolBitmap:
+				err = f.advance(entry, buf)
+			case FieldTypeOverflowStr:
+				if entry.hidden {
+					err = f.advance(entry, buf)
+					break
+				}
+				name := entry.FieldName
+				err = f.readOverflowRef(buf, func(s string) error {
+					obj[name] = s
+					return nil
+				})
+			default:
+				if entry.hidden {
+					err = f.advance(entry, buf)
+					break
+				}
+				obj[entry.FieldName], err = f.decodeValue(entry, buf)
+			}
+			if err != nil {
+				return fmt.Errorf("error reading field %s: %w", entry.FieldName, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// DecodeAll reads each remaining object into a generic map, like
+// `DecodeObject`. The number of objects can be limited with `MaxObjects`.
+func (f *rsfReader) DecodeAll(buf *bufio.Reader) ([]map[string]any, error) {
+	return f.decodeObjects(buf, -1)
+}
+
+// DecodeN reads the next `n` objects into generic maps, like `DecodeObject`,
+// and stops without reading the rest of the file. This is useful for
+// previewing or validating the start of a large file. Fewer than `n` objects
+// are returned, without error, if the file ends first.
+func (f *rsfReader) DecodeN(buf *bufio.Reader, n int) ([]map[string]any, error) {
+	return f.decodeObjects(buf, max(n, 0))
+}
+
+// decodeObjects reads up to `n` objects into generic maps, or every remaining
+// object if `n` is negative.
+func (f *rsfReader) decodeObjects(buf *bufio.Reader, n int) ([]map[string]any, error) {
+	objs := make([]map[string]any, 0)
+	for n < 0 || len(objs) < n {
+		err := f.checkMaxObjects(buf, len(objs))
+		if err != nil {
+			return nil, err
+		}
+
+		var obj map[string]any
+		obj, err = f.DecodeObject(buf)
+		if err == io.EOF {
+			return objs, nil
+		} else if err != nil {
+			return nil, err
+		}
+		objs = append(objs, obj)
+	}
+	return objs, nil
+}
+
+// decodeStruct decodes the fields described by `index` into a map.
+func (f *rsfReader) decodeStruct(index Index, buf *bufio.Reader) (map[string]any, error) {
+	obj := make(map[string]any, len(index))
+	for _, entry := range index {
+		if entry.hidden {
+			err := f.advance(entry, buf)
+			if err != nil {
+				return nil, fmt.Errorf("error decoding field %s: %w", entry.FieldName, err)
+			}
+			continue
+		}
+
+		// Overflow strings are decoded after the object's other fields.
+		if entry.FieldType == FieldTypeOverflowStr {
+			name := entry.FieldName
+			err := f.readOverflowRef(buf, func(s string) error {
+				obj[name] = s
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("error decoding field %s: %w", entry.FieldName, err)
+			}
+			continue
+		}
+
+		// The bool bitmap is not a field.
+		if entry.FieldType == FieldTypeBoolBitmap {
+			err := f.readBitmap(entry, buf)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// Absent arrays are omitted from the map.
+		absent, err := f.absent(entry)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding field %s: %w", entry.FieldName, err)
+		}
+		if absent {
+			continue
+		}
+
+		val, err := f.decodeValue(entry, buf)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding field %s: %w", entry.FieldName, err)
+		}
+		obj[entry.FieldName] = val
+	}
+	return obj, nil
+}
+
+// decodeValue decodes the field described by `entry`.
+func (f *rsfReader) decodeValue(entry IndexEntry, buf *bufio.Reader) (any, error) {
+	if f.logger != nil {
+		f.logger.Debug("read field", "field", entry.FieldName, "type", typeTag(entry.FieldType), "pos", f.pos)
+	}
+
+	switch entry.FieldType {
+	case FieldTypeVarStr:
+		return f.ReadStringField(buf)
+	case FieldTypeCompressedStr:
+		return f.ReadCompressedStringField(buf)
+	case FieldTypeFixedStr:
+		return f.ReadFixedStringField(entry.FieldSize, buf)
+	case FieldTypeBool:
+		return f.ReadBoolField(buf)
+	case FieldTypePackedBool:
+		return f.readPackedBool(entry)
+	case FieldTypeInt64:
+		return f.ReadIntField(buf)
+	case FieldTypeFixedInt64, FieldTypeBigEndianInt:
+		return f.readFixedInt(entry, buf)
+	case FieldTypeFloat, FieldTypeBigEndianFloat:
+		return f.readFloat(entry, buf)
+	case FieldTypeIP:
+		return f.ReadIPField(buf)
+	case FieldTypeDays:
+		return f.ReadDaysField(buf)
+	case FieldTypeOpaque:
+		return f.readOpaque(buf)
+	case FieldTypeBytes:
+		return f.ReadBytesField(buf)
+	case FieldTypeArray, FieldTypePackedArray:
+		return f.decodeArray(entry, buf)
+	default:
+		return nil, fmt.Errorf("unexpected index field type %d", entry.FieldType)
+	}
+}
+
+// decodeArray decodes an array, including its index (if any).
+func (f *rsfReader) decodeArray(entry IndexEntry, buf *bufio.Reader) ([]any, error) {
+	// Read the array size and length.
+	arraySz, err := f.ReadSizeField(buf)
+	if err != nil {
+		return nil, err
+	}
+	arrayLen, err := f.ReadSizeField(buf)
+	if err != nil {
+		return nil, err
+	}
+	err = checkArrayLen(entry, arraySz, arrayLen)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := f.readArrayKeys(entry, arrayLen, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	elEntry, err := arrayElement(entry, arrayLen)
+	if err != nil {
+		return nil, err
+	}
+
+	els := make([]any, 0)
+	for i := 0; i < arrayLen; i++ {
+		var el any
+		if elEntry.FieldType == 0 {
+			if emptyElements(entry) {
+				err = f.readEmptyElement(buf)
+				if err != nil {
+					return nil, err
+				}
+			}
+			var m map[string]any
+			m, err = f.decodeStruct(entry.Subfields, buf)
+			if err != nil {
+				return nil, err
+			}
+			if keys != nil {
+				m[IndexKeyField] = keys[i]
+			}
+			el = m
+		} else {
+			el, err = f.decodeValue(elEntry, buf)
+			if err != nil {
+				return nil, err
+			}
+		}
+		els = append(els, el)
+	}
+	return els, nil
+}
+
+// arrayElement returns the entry used to decode the elements of the array
+// described by `entry`, or a zero entry for arrays of structs. An error is
+// returned if the elements of a non-empty array cannot be decoded.
+func arrayElement(entry IndexEntry, arrayLen int) (IndexEntry, error) {
+	if el := packedElement(entry); el.FieldType != 0 {
+		return el, nil
+	}
+
+	// Older indexes did not record the array element type, but struct
+	// arrays can be identified by their subfields.
+	kind := reflect.Kind(entry.SubfieldType)
+	if kind == reflect.Invalid && len(entry.Subfields) > 0 {
+		kind = reflect.Struct
+	}
+
+	switch kind {
+	case reflect.Struct:
+		return IndexEntry{}, nil
+	case reflect.String:
+		return IndexEntry{FieldType: FieldTypeVarStr}, nil
+	case reflect.Bool:
+		return IndexEntry{FieldType: FieldTypeBool}, nil
+	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+		return IndexEntry{FieldType: FieldTypeInt64}, nil
+	case reflect.Float32, reflect.Float64:
+		return IndexEntry{FieldType: FieldTypeFloat}, nil
+	default:
+		if arrayLen > 0 {
+			return IndexEntry{}, fmt.Errorf("cannot decode array elements of type %s", kind)
+		}
+		return IndexEntry{}, nil
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ReaderDecodeSuite struct {
+	suite.Suite
+}
+
+func TestReaderDecodeSuite(t *testing.T) {
+	suite.Run(t, &ReaderDecodeSuite{})
+}
+
+func (s *ReaderDecodeSuite) TestDecodeObject() {
+	buf := bufio.NewReader(getData(&s.Suite))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+
+	obj, err := r.DecodeObject(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(map[string]any{
+		"company": "posit",
+		"ready":   true,
+		"list": []any{
+			map[string]any{
+				IndexKeyField: "2020-10-01",
+				"name":        "From 2020",
+				"verified":    false,
+			},
+			map[string]any{
+				IndexKeyField: "2021-03-21",
+				"name":        "From 2021",
+				"verified":    true,
+			},
+			map[string]any{
+				IndexKeyField: "2022-12-15",
+				"name":        "this is from 2022",
+				"verified":    true,
+			},
+		},
+		"age":    int64(55),
+		"rating": 92.689,
+	}, obj)
+	s.Assert().Equal(int64(249), r.Pos())
+
+	// No more objects.
+	_, err = r.DecodeObject(buf)
+	s.Assert().ErrorIs(err, io.EOF)
+}
+
+func (s *ReaderDecodeSuite) TestReadScalars() {
+	buf := bufio.NewReader(getData(&s.Suite))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+
+	obj, err := r.ReadScalars(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(map[string]any{
+		"company": "posit",
+		"ready":   true,
+		"age":     int64(55),
+		"rating":  92.689,
+	}, obj)
+	s.Assert().Equal(int64(249), r.Pos())
+
+	// No more objects.
+	_, err = r.ReadScalars(buf)
+	s.Assert().ErrorIs(err, io.EOF)
+}
+
+type floatRecord struct {
+	Name   string    `rsf:"name"`
+	Value  float64   `rsf:"value"`
+	Values []float64 `rsf:"values"`
+}
+
+// getFloatData writes records with NaN and infinite float values.
+func getFloatData(s *suite.Suite) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	for _, rec := range []floatRecord{
+		{Name: "nan", Value: math.NaN(), Values: []float64{1.5, math.NaN()}},
+		{Name: "inf", Value: math.Inf(1), Values: []float64{math.Inf(-1)}},
+	} {
+		_, err := w.WriteObject(rec)
+		s.Require().Nil(err)
+	}
+	return buf
+}
+
+func (s *ReaderDecodeSuite) TestNonFiniteFloats() {
+	buf := bufio.NewReader(getFloatData(&s.Suite))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+
+	// Readers return the exact float values.
+	obj, err := r.DecodeObject(buf)
+	s.Require().Nil(err)
+	s.Assert().True(math.IsNaN(obj["value"].(float64)))
+	s.Assert().Equal(1.5, obj["values"].([]any)[0])
+	s.Assert().True(math.IsNaN(obj["values"].([]any)[1].(float64)))
+
+	var rec floatRecord
+	err = r.Unmarshal(buf, &rec)
+	s.Require().Nil(err)
+	s.Assert().Equal("inf", rec.Name)
+	s.Assert().True(math.IsInf(rec.Value, 1))
+	s.Assert().Len(rec.Values, 1)
+	s.Assert().True(math.IsInf(rec.Values[0], -1))
+}
+
+func (s *ReaderDecodeSuite) TestDecodeAll() {
+	buf := bufio.NewReader(getFloatData(&s.Suite))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+
+	objs, err := r.DecodeAll(buf)
+	s.Require().Nil(err)
+	s.Require().Len(objs, 2)
+	s.Assert().Equal("nan", objs[0]["name"])
+	s.Assert().Equal("inf", objs[1]["name"])
+}
+
+func (s *ReaderDecodeSuite) TestDecodeAllMaxObjects() {
+	// The limit is reached before the second object is read.
+	buf := bufio.NewReader(getFloatData(&s.Suite))
+	r := NewReader(MaxObjects(1))
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	_, err = r.DecodeAll(buf)
+	s.Assert().ErrorIs(err, ErrMaxObjects)
+
+	// A file with exactly the maximum number of objects can be read.
+	buf = bufio.NewReader(getFloatData(&s.Suite))
+	r = NewReader(MaxObjects(2))
+	_, err = r.ReadIndex(buf)
+	s.Require().Nil(err)
+	objs, err := r.DecodeAll(buf)
+	s.Require().Nil(err)
+	s.Assert().Len(objs, 2)
+
+	// Keys is also limited.
+	buf = bufio.NewReader(getFloatData(&s.Suite))
+	r = NewReader(MaxObjects(1))
+	_, err = r.ReadIndex(buf)
+	s.Require().Nil(err)
+	_, err = r.Keys(buf, "name")
+	s.Assert().ErrorIs(err, ErrMaxObjects)
+}
+
+func (s *ReaderDecodeSuite) TestDecodeN() {
+	buf := bufio.NewReader(getComplexData(&s.Suite))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+
+	objs, err := r.DecodeN(buf, 1)
+	s.Require().Nil(err)
+	s.Require().Len(objs, 1)
+	s.Assert().Equal("numpy", objs[0]["cname"])
+
+	// The reader is positioned at the next object.
+	objs, err = r.DecodeN(buf, 5)
+	s.Require().Nil(err)
+	s.Require().Len(objs, 1)
+	s.Assert().Equal("django", objs[0]["cname"])
+
+	objs, err = r.DecodeN(buf, 1)
+	s.Require().Nil(err)
+	s.Assert().Empty(objs)
+}
@@ -0,0 +1,63 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"encoding/binary"
+	"hash"
+	"hash/fnv"
+)
+
+// Fingerprint returns a 64-bit hash of the schema described by the index. The
+// hash covers the same properties compared by `Equal` (field names, types,
+// fixed sizes, array index metadata, presence bits, and subfields), so
+// indexes that are equal have the same fingerprint. Type tags, field widths,
+// and field name ids are not included. The fingerprint is stable across
+// releases, so it can be stored and compared with the fingerprint of files
+// written later.
+func (i Index) Fingerprint() uint64 {
+	h := fnv.New64a()
+	i.writeFingerprint(h)
+	return h.Sum64()
+}
+
+func (i Index) writeFingerprint(h hash.Hash64) {
+	bs := make([]byte, sizeFieldLen)
+	putInt := func(n int) {
+		binary.LittleEndian.PutUint32(bs, uint32(n))
+		_, _ = h.Write(bs)
+	}
+
+	// Include the field count so that subfields are not confused with the
+	// fields that follow their arrays.
+	putInt(len(i))
+	for _, entry := range i {
+		putInt(len(entry.FieldName))
+		_, _ = h.Write([]byte(entry.FieldName))
+		putInt(entry.FieldType)
+		putInt(entry.FieldSize)
+		if entry.Indexed {
+			putInt(1)
+		} else {
+			putInt(0)
+		}
+		putInt(entry.IndexSize)
+		putInt(entry.IndexType)
+		putInt(entry.SubfieldType)
+		// Presence bits are only included for optional arrays, so the
+		// fingerprints of other indexes are unchanged.
+		if entry.Optional {
+			putInt(entry.PresenceBit)
+		}
+		entry.Subfields.writeFingerprint(h)
+	}
+}
+
+// SchemaFingerprint returns the schema fingerprint recorded in the index
+// header (see `Fingerprint`). If the file doesn't record a fingerprint, it is
+// calculated from the index.
+func (f *rsfReader) SchemaFingerprint() uint64 {
+	if f.features&FeatureFingerprint != 0 {
+		return f.fingerprint
+	}
+	return f.index.Fingerprint()
+}
@@ -0,0 +1,106 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+var ErrNoFooterIndex = errors.New("footer index not found")
+
+// HasFooterIndex returns true if the RSF data in `r` was written with the
+// `FooterIndex` option. The data must end with the `FooterIndexMarker`,
+// preceded by the size of an index that can be read in full and that follows
+// the end of objects marker, so data that merely ends with the marker bytes is
+// not mistaken for a footer index. Such data must be read with
+// `ReadFooterIndex`, and cannot be read from the start like other data (e.g.,
+// by `Merge` or `Print`). The position of `r` is restored.
+func HasFooterIndex(r io.ReadSeeker) (bool, error) {
+	pos, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return false, err
+	}
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return false, err
+	}
+
+	found := footerIndexAt(r, pos, end)
+
+	_, err = r.Seek(pos, io.SeekStart)
+	if err != nil {
+		return false, err
+	}
+	return found, nil
+}
+
+// footerIndexAt returns true if the data in `r` between `pos` and `end` ends
+// with a footer index. See the format in writer_footer.go.
+func footerIndexAt(r io.ReadSeeker, pos, end int64) bool {
+	tailLen := int64(sizeFieldLen + len(FooterIndexMarker))
+	if end-pos < sizeFieldLen+tailLen {
+		return false
+	}
+	sz, marker, err := readTail(&rsfReader{}, r, end)
+	if err != nil || !bytes.Equal(marker, FooterIndexMarker) || sz <= 0 {
+		return false
+	}
+
+	// The end of objects marker precedes the index.
+	start := end - tailLen - int64(sz)
+	if start-sizeFieldLen < pos {
+		return false
+	}
+	_, err = r.Seek(start-sizeFieldLen, io.SeekStart)
+	if err != nil {
+		return false
+	}
+	objSz, err := (&rsfReader{}).ReadSizeField(r)
+	if err != nil || objSz != 0 {
+		return false
+	}
+
+	// The index must fill the recorded size exactly.
+	ir := &rsfReader{}
+	_, err = ir.ReadIndex(io.LimitReader(r, int64(sz)))
+	return err == nil && ir.pos == int64(sz)
+}
+
+// ReadFooterIndex reads the index written after the last object by a writer
+// with the `FooterIndex` option, then seeks `r` to the start of the file,
+// where the objects begin. Since `r` is seeked, create any `bufio.Reader` used
+// to read the objects after calling ReadFooterIndex. `ErrNoFooterIndex` is
+// returned if the file has no footer index.
+func (f *rsfReader) ReadFooterIndex(r io.ReadSeeker) (Index, error) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	sz, marker, err := readTail(&rsfReader{}, r, end)
+	if err != nil || !bytes.Equal(marker, FooterIndexMarker) {
+		return nil, ErrNoFooterIndex
+	}
+
+	// Seek to the start of the index.
+	start := end - sizeFieldLen - int64(len(FooterIndexMarker)) - int64(sz)
+	if start < 0 {
+		return nil, ErrNoFooterIndex
+	}
+	_, err = r.Seek(start, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	index, err := f.ReadIndex(io.LimitReader(r, int64(sz)))
+	if err != nil {
+		return nil, err
+	}
+
+	// The objects begin at the start of the file.
+	err = f.seek(0, r)
+	if err != nil {
+		return nil, err
+	}
+	return index, nil
+}
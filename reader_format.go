@@ -0,0 +1,69 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"time"
+)
+
+var ErrFieldStringType = errors.New("arrays cannot be read as strings")
+
+// ReadFieldString advances to the scalar field indicated by `fieldNames`,
+// reads it with the reader matching its index type, and formats it as a
+// string: strings as-is, ints, floats, and bools with `strconv` (floats with
+// six decimal places, like `Print`), dates as `YYYY-MM-DD`, IP addresses in
+// their standard form, and opaque values and byte slices in hex. This supports
+// displaying any field uniformly. Arrays cannot be read as strings.
+func (f *rsfReader) ReadFieldString(buf *bufio.Reader, fieldNames ...string) (string, error) {
+	entries, pos, err := entrySet(f.index, fieldNames...)
+	if err != nil {
+		return "", err
+	}
+	if pos < 0 || pos >= len(entries) {
+		return "", ErrNoSuchField
+	}
+	entry := entries[pos]
+	if entry.FieldType == FieldTypeArray || entry.FieldType == FieldTypePackedArray {
+		return "", withField(entry.FieldName, ErrFieldStringType)
+	}
+
+	err = f.AdvanceTo(buf, fieldNames...)
+	if err != nil {
+		return "", err
+	}
+	val, err := f.decodeValue(entry, buf)
+	if err != nil {
+		return "", fmt.Errorf("error reading field %s: %w", entry.FieldName, err)
+	}
+	return formatValue(val), nil
+}
+
+// formatValue formats a scalar value decoded by `decodeValue`.
+func formatValue(val any) string {
+	switch v := val.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', 6, 64)
+	case time.Time:
+		return v.Format(time.DateOnly)
+	case netip.Addr:
+		if !v.IsValid() {
+			return ""
+		}
+		return v.String()
+	case []byte:
+		return hex.EncodeToString(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
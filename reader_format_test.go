@@ -0,0 +1,49 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ReaderFormatSuite struct {
+	suite.Suite
+}
+
+func TestReaderFormatSuite(t *testing.T) {
+	suite.Run(t, &ReaderFormatSuite{})
+}
+
+func (s *ReaderFormatSuite) TestReadFieldString() {
+	buf := bufio.NewReader(getData(&s.Suite))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	_, err = r.BeginObject(buf)
+	s.Require().Nil(err)
+
+	company, err := r.ReadFieldString(buf, "company")
+	s.Require().Nil(err)
+	s.Assert().Equal("posit", company)
+
+	ready, err := r.ReadFieldString(buf, "ready")
+	s.Require().Nil(err)
+	s.Assert().Equal("true", ready)
+
+	// Arrays cannot be read as strings.
+	_, err = r.ReadFieldString(buf, "list")
+	s.Assert().ErrorIs(err, ErrFieldStringType)
+
+	age, err := r.ReadFieldString(buf, "age")
+	s.Require().Nil(err)
+	s.Assert().Equal("55", age)
+
+	rating, err := r.ReadFieldString(buf, "rating")
+	s.Require().Nil(err)
+	s.Assert().Equal("92.689000", rating)
+
+	_, err = r.ReadFieldString(buf, "missing")
+	s.Assert().ErrorIs(err, ErrNoSuchField)
+}
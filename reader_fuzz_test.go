@@ -0,0 +1,97 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+)
+
+// fuzzSeeds returns valid RSF data written with each index version and
+// several writer features. The fuzzer mutates these to find malformed input
+// that causes a panic.
+func fuzzSeeds(f *testing.F) [][]byte {
+	var seeds [][]byte
+	write := func(version int, objs []any, opts ...WriterOption) {
+		buf := &bytes.Buffer{}
+		w := NewWriterWithVersion(buf, version, opts...)
+		for _, obj := range objs {
+			_, err := w.WriteObject(obj)
+			if err != nil {
+				f.Fatal(err)
+			}
+		}
+		err := w.Close()
+		if err != nil {
+			f.Fatal(err)
+		}
+		seeds = append(seeds, buf.Bytes())
+	}
+
+	var complexData []any
+	for _, obj := range testComplexData {
+		complexData = append(complexData, obj)
+	}
+	for _, version := range []int{Version1, Version2, Version3} {
+		write(version, complexData)
+		write(version, []any{testUpgradedData})
+		write(version, []any{testPackedData})
+	}
+	write(Version3, complexData, StringTable(true))
+	write(Version3, complexData, ObjectKey("cname"))
+	return seeds
+}
+
+func FuzzReadIndex(f *testing.F) {
+	for _, seed := range fuzzSeeds(f) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := NewReader()
+		_, _ = r.ReadIndex(bufio.NewReader(bytes.NewReader(data)))
+	})
+}
+
+func FuzzDecode(f *testing.F) {
+	for _, seed := range fuzzSeeds(f) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Decode generically.
+		r := NewReader()
+		buf := bufio.NewReader(bytes.NewReader(data))
+		_, err := r.ReadIndex(buf)
+		if err != nil {
+			return
+		}
+		for {
+			_, err = r.DecodeObject(buf)
+			if err != nil {
+				break
+			}
+		}
+
+		// Decode with reflection.
+		r = NewReader()
+		buf = bufio.NewReader(bytes.NewReader(data))
+		_, err = r.ReadIndex(buf)
+		if err != nil {
+			return
+		}
+		for {
+			var rec FullPackageRecordPyPI
+			err = r.Unmarshal(buf, &rec)
+			if err != nil {
+				break
+			}
+		}
+
+		// Print.
+		_ = Print(io.Discard, bufio.NewReader(bytes.NewReader(data)))
+
+		// Look up an object key.
+		r = NewReader()
+		_, _ = r.FindObject(bytes.NewReader(data), "pkg")
+	})
+}
@@ -0,0 +1,180 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ReaderHookSuite struct {
+	suite.Suite
+}
+
+func TestReaderHookSuite(t *testing.T) {
+	suite.Run(t, &ReaderHookSuite{})
+}
+
+func (s *ReaderHookSuite) TestOnFieldUnmarshal() {
+	var fields []string
+	r := NewReader(OnField(func(path []string, entry IndexEntry) {
+		fields = append(fields, strings.Join(path, "."))
+	}))
+
+	buf := bufio.NewReader(getData(&s.Suite))
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+
+	var rec legacyRecord
+	err = r.Unmarshal(buf, &rec)
+	s.Require().Nil(err)
+	s.Assert().Equal([]string{
+		"company",
+		"ready",
+		"list",
+		"list.name",
+		"list.verified",
+		"list.name",
+		"list.verified",
+		"list.name",
+		"list.verified",
+		"age",
+		"rating",
+	}, fields)
+	s.Assert().Equal("this is from 2022", rec.List[2].Name)
+
+	// Reading into a struct that is missing fields still invokes the hook
+	// for each field.
+	fields = nil
+	buf = bufio.NewReader(getData(&s.Suite))
+	_, err = r.ReadIndex(buf)
+	s.Require().Nil(err)
+	var partial struct {
+		Rating float64 `rsf:"rating"`
+	}
+	err = r.Unmarshal(buf, &partial)
+	s.Require().Nil(err)
+	s.Assert().Equal(92.689, partial.Rating)
+	s.Assert().Equal([]string{
+		"company",
+		"ready",
+		"list",
+		"age",
+		"rating",
+	}, fields)
+}
+
+func (s *ReaderHookSuite) TestOnFieldAdvance() {
+	var fields []string
+	var types []int
+	r := NewReader(OnField(func(path []string, entry IndexEntry) {
+		fields = append(fields, strings.Join(path, "."))
+		types = append(types, entry.FieldType)
+	}))
+
+	buf := bufio.NewReader(getData(&s.Suite))
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	_, err = r.ReadSizeField(buf)
+	s.Require().Nil(err)
+
+	// Advance past "company", "ready", and "list" to "age".
+	err = r.AdvanceTo(buf, "age")
+	s.Require().Nil(err)
+	age, err := r.ReadIntField(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(int64(55), age)
+
+	// Advance past "rating" to the end of the object.
+	err = r.AdvanceToNextElement(buf)
+	s.Require().Nil(err)
+
+	s.Assert().Equal([]string{
+		"company",
+		"ready",
+		"list",
+		"age",
+		"rating",
+	}, fields)
+	s.Assert().Equal([]int{
+		FieldTypeVarStr,
+		FieldTypeBool,
+		FieldTypeArray,
+		FieldTypeInt64,
+		FieldTypeFloat,
+	}, types)
+}
+
+// debugLogger returns a logger that writes debug events, without times, to
+// `sb`.
+func debugLogger(sb *strings.Builder) *slog.Logger {
+	return slog.New(slog.NewTextHandler(sb, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
+
+func (s *ReaderHookSuite) TestDebugLoggerAdvance() {
+	sb := &strings.Builder{}
+	r := NewReader(DebugLogger(debugLogger(sb)))
+
+	buf := bufio.NewReader(getData(&s.Suite))
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	_, err = r.BeginObject(buf)
+	s.Require().Nil(err)
+	s.Require().Nil(r.AdvanceTo(buf, "age"))
+
+	s.Assert().Equal(`level=DEBUG msg="advance to" field=age pos=121
+level=DEBUG msg="skip field" field=company type=str bytes=9 pos=130
+level=DEBUG msg="skip field" field=ready type=bool bytes=1 pos=131
+level=DEBUG msg="skip field" field=list type=arr bytes=100 pos=231
+`, sb.String())
+}
+
+func (s *ReaderHookSuite) TestDebugLoggerDecode() {
+	sb := &strings.Builder{}
+	r := NewReader(DebugLogger(debugLogger(sb)))
+
+	buf := bufio.NewReader(getData(&s.Suite))
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	_, err = r.DecodeObject(buf)
+	s.Require().Nil(err)
+
+	s.Assert().Equal(`level=DEBUG msg="read field" field=company type=str pos=121
+level=DEBUG msg="read field" field=ready type=bool pos=130
+level=DEBUG msg="read field" field=list type=arr pos=131
+level=DEBUG msg="read field" field=name type=str pos=181
+level=DEBUG msg="read field" field=verified type=bool pos=194
+level=DEBUG msg="read field" field=name type=str pos=195
+level=DEBUG msg="read field" field=verified type=bool pos=208
+level=DEBUG msg="read field" field=name type=str pos=209
+level=DEBUG msg="read field" field=verified type=bool pos=230
+level=DEBUG msg="read field" field=age type=i64 pos=231
+level=DEBUG msg="read field" field=rating type=f64 pos=241
+`, sb.String())
+}
+
+func (s *ReaderHookSuite) TestDebugLoggerUnmarshal() {
+	sb := &strings.Builder{}
+	r := NewReader(DebugLogger(debugLogger(sb)))
+
+	buf := bufio.NewReader(getData(&s.Suite))
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	var rec legacyRecord
+	s.Require().Nil(r.Unmarshal(buf, &rec))
+
+	lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+	s.Assert().Contains(lines, `level=DEBUG msg="read field" field=list.name type=str pos=181`)
+	s.Assert().Contains(lines, `level=DEBUG msg="read field" field=rating type=f64 pos=241`)
+}
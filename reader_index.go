@@ -8,11 +8,17 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"reflect"
+	"strings"
 )
 
 type Index []IndexEntry
 
-const Top = ""
+// Top marks the position before the first field of an object or array
+// element in the field paths used by `AdvanceTo` and related methods. It is
+// not an empty string, since some files written by buggy producers include
+// fields with empty names, which must not be confused with `Top`.
+const Top = "\x00"
 
 type IndexEntry struct {
 	FieldName    string
@@ -23,28 +29,139 @@ type IndexEntry struct {
 	IndexType    int
 	SubfieldType int
 	Subfields    Index
+
+	// The type tag (like "str" or "i64") read from a verbose index. The
+	// reader relies on `FieldType`; the tag is informational. See
+	// `VerboseIndex`.
+	TypeTag string
+
+	// The byte width of the field, if recorded with `FieldWidths`. Zero if
+	// the width varies or was not recorded. This allows skipping fields of
+	// unknown types.
+	FieldWidth int
+
+	// The id of the field name in the index's field name table. Zero if the
+	// index has no field name table. See `FieldNameTable`.
+	FieldID int
+
+	// Whether the array may be absent from an object, and the number of the
+	// bit that records its presence in the object's bitmap. See
+	// `NilAsAbsent`.
+	Optional    bool
+	PresenceBit int
+
+	// Whether the field is hidden by the reader's projection. Hidden fields
+	// are skipped. See `SetProjection`.
+	hidden bool
 }
 
 func (f *rsfReader) SetIndex(newIndex Index) {
 	f.index = newIndex
+	f.plans = nil
 }
 
 func (f *rsfReader) ReadIndex(r io.Reader) (Index, error) {
+	sz, err := f.readIndexHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Position when done reading index will be the current reader position +
+	// the index size, minus the size field length, since we've already read it.
+	finalPos := f.pos + int64(sz) - sizeFieldLen
+
+	// Limit reads to the index so that a corrupt size within the index
+	// can't read into the objects that follow.
+	ir := io.LimitReader(r, int64(sz-sizeFieldLen))
+
+	// The field name table, if present, precedes the index entries.
+	if f.features&FeatureFieldNameTable != 0 {
+		err = f.readFieldNameTable(ir, finalPos)
+		if err != nil {
+			return nil, fmt.Errorf("error reading field name table: %s", indexReadError(err, sz))
+		}
+	}
+
+	f.index, err = f.readIndexEntries(ir, finalPos, 0, 0)
+	if err != nil {
+		return nil, indexReadError(err, sz)
+	}
+
+	// The indexes of the other schemas, if any, follow the index.
+	if f.features&FeatureMultiSchema != 0 {
+		err = f.readSchemas(r)
+		if err != nil {
+			return nil, fmt.Errorf("error reading schemas: %s", err)
+		}
+	}
+
+	// The string table, if present, follows the index.
+	if f.features&FeatureStringTable != 0 {
+		err = f.readStringTable(r)
+		if err != nil {
+			return nil, fmt.Errorf("error reading string table: %s", err)
+		}
+	}
+
+	return f.index, nil
+}
+
+// readIndexHeader reads the index version header (if any) and the index size,
+// resetting the state recorded from any previous index. It returns the index
+// size.
+func (f *rsfReader) readIndexHeader(r io.Reader) (int, error) {
 	var err error
 
 	// Peek at the first three bytes to see if an index version is included
 	header := make([]byte, 3)
 	n, err := r.Read(header)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 	if n != 3 {
-		return nil, fmt.Errorf("unexpected index header read length %d", n)
+		return 0, fmt.Errorf("unexpected index header read length %d", n)
 	}
 
 	// If the first three bytes equal an index version, then record the
 	// index version.
-	if bytes.Equal(header, IndexVersion2) {
+	f.features = 0
+	f.fingerprint = 0
+	f.writerVersion = ""
+	f.strings = nil
+	f.fieldNames = nil
+	f.objectEnd = 0
+	f.begun = false
+	f.plans = nil
+	f.schemas = nil
+	f.schema = 0
+	if bytes.Equal(header, IndexVersion3) {
+		f.indexVersion = 3
+		f.pos += 3
+
+		// Version3 includes feature flags.
+		f.features, err = f.ReadSizeField(r)
+		if err != nil {
+			return 0, err
+		}
+
+		// The schema fingerprint, if present, follows the feature flags.
+		if f.features&FeatureFingerprint != 0 {
+			var fp int64
+			fp, err = f.ReadFixedIntField(r)
+			if err != nil {
+				return 0, err
+			}
+			f.fingerprint = uint64(fp)
+		}
+
+		// The producer version, if present, follows the fingerprint.
+		if f.features&FeatureProducerVersion != 0 {
+			f.writerVersion, err = f.ReadStringField(r)
+			if err != nil {
+				return 0, err
+			}
+		}
+	} else if bytes.Equal(header, IndexVersion2) {
 		f.indexVersion = 2
 		f.pos += 3
 	} else {
@@ -56,7 +173,7 @@ func (f *rsfReader) ReadIndex(r io.Reader) (Index, error) {
 		// If an index version was found, simply read the full size field.
 		sz, err = f.ReadSizeField(r)
 		if err != nil {
-			return nil, err
+			return 0, err
 		}
 	} else {
 		// If an index version was not found, we need to read one more byte to get
@@ -65,10 +182,10 @@ func (f *rsfReader) ReadIndex(r io.Reader) (Index, error) {
 		lastByte := make([]byte, 1)
 		n, err = r.Read(lastByte)
 		if err != nil {
-			return nil, err
+			return 0, err
 		}
 		if n != 1 {
-			return nil, fmt.Errorf("unexpected index size supplemental read length %d", n)
+			return 0, fmt.Errorf("unexpected index size supplemental read length %d", n)
 		}
 
 		// Manually increment pos
@@ -77,15 +194,57 @@ func (f *rsfReader) ReadIndex(r io.Reader) (Index, error) {
 		sz = int(binary.LittleEndian.Uint32(size))
 	}
 
-	// Position when done reading index will be the current reader position +
-	// the index size, minus the size field length, since we've already read it.
-	f.index, err = f.readIndexEntries(r, f.pos+sz-sizeFieldLen, 0)
-	return f.index, err
+	if sz < sizeFieldLen {
+		return 0, fmt.Errorf("invalid index size %d", sz)
+	}
+
+	return sz, nil
+}
+
+// SkipIndex reads the index version header and the index size from `r`,
+// then seeks past the index without parsing it, leaving `r` at the first
+// object. This avoids parsing the index when it is already known (see
+// `SetIndex`). If the file includes a string table, which follows the index,
+// it is read since it is needed to read strings.
+func (f *rsfReader) SkipIndex(r io.ReadSeeker) error {
+	sz, err := f.readIndexHeader(r)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Seek(int64(sz-sizeFieldLen), io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	f.pos += int64(sz - sizeFieldLen)
+
+	if f.features&FeatureMultiSchema != 0 {
+		err = f.readSchemas(r)
+		if err != nil {
+			return fmt.Errorf("error reading schemas: %s", err)
+		}
+	}
+
+	if f.features&FeatureStringTable != 0 {
+		err = f.readStringTable(r)
+		if err != nil {
+			return fmt.Errorf("error reading string table: %s", err)
+		}
+	}
+	return nil
 }
 
-func (f *rsfReader) readIndexEntries(r io.Reader, finalPos, limit int) (Index, error) {
+func (f *rsfReader) readIndexEntries(r io.Reader, finalPos int64, limit, depth int) (Index, error) {
 	var err error
 
+	maxDepth := f.maxDepth
+	if maxDepth == 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	if depth > maxDepth {
+		return nil, ErrMaxDepth
+	}
+
 	entries := make([]IndexEntry, 0)
 	var pass int
 	for {
@@ -94,16 +253,21 @@ func (f *rsfReader) readIndexEntries(r io.Reader, finalPos, limit int) (Index, e
 		if limit != 0 && pass == limit {
 			break
 		}
-		pass++
 
 		// When we've completed reading the index, the file position is at the index size (sz).
 		if f.pos == finalPos {
+			// All subfields must fit in the index.
+			if limit != 0 {
+				return nil, fmt.Errorf("the index ended after %d of %d subfields", pass, limit)
+			}
 			break
 		}
+		pass++
 
 		// Read the field name.
 		var fieldName string
-		fieldName, err = f.ReadStringField(r)
+		var fieldID int
+		fieldName, fieldID, err = f.readIndexName(r)
 		if err != nil {
 			return nil, err
 		}
@@ -115,12 +279,33 @@ func (f *rsfReader) readIndexEntries(r io.Reader, finalPos, limit int) (Index, e
 			return nil, err
 		}
 
+		// A verbose index includes a type tag.
+		var typeTag string
+		if f.features&FeatureVerboseIndex != 0 {
+			typeTag, err = f.ReadStringField(r)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// Field widths may also be included.
+		var fieldWidth int
+		if f.features&FeatureFieldWidths != 0 {
+			fieldWidth, err = f.ReadSizeField(r)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		// For arrays, read the count of the number of subfields.
 		var subfieldCount int
 		var indexed bool
 		var arrayFieldType int
 		var indexSize, indexType int
-		if fieldType == FieldTypeArray {
+		var fieldSize int
+		var optional bool
+		var presenceBit int
+		if fieldType == FieldTypeArray || fieldType == FieldTypePackedArray {
 
 			// Older indexes didn't include the following two fields
 			if f.indexVersion >= 2 {
@@ -146,6 +331,23 @@ func (f *rsfReader) readIndexEntries(r io.Reader, finalPos, limit int) (Index, e
 					return nil, err
 				}
 
+				// Packed arrays include the record size.
+				if fieldType == FieldTypePackedArray {
+					fieldSize, err = f.ReadSizeField(r)
+					if err != nil {
+						return nil, err
+					}
+				}
+			}
+
+			// Top-level arrays record their presence bit when nil
+			// values are absent.
+			if f.features&FeatureNilAsAbsent != 0 && depth == 0 {
+				optional = true
+				presenceBit, err = f.ReadSizeField(r)
+				if err != nil {
+					return nil, err
+				}
 			}
 
 			subfieldCount, err = f.ReadSizeField(r)
@@ -154,13 +356,35 @@ func (f *rsfReader) readIndexEntries(r io.Reader, finalPos, limit int) (Index, e
 			}
 		}
 
+		// Packed bools include the bit number, and the bool bitmap includes
+		// its size.
+		if fieldType == FieldTypePackedBool || fieldType == FieldTypeBoolBitmap {
+			fieldSize, err = f.ReadSizeField(r)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// Big-endian numbers include their width.
+		if fieldType == FieldTypeBigEndianInt || fieldType == FieldTypeBigEndianFloat {
+			fieldSize, err = f.ReadSizeField(r)
+			if err != nil {
+				return nil, err
+			}
+			if fieldSize != sizeInt32 && fieldSize != sizeFixedInt64 {
+				return nil, fmt.Errorf("invalid big-endian field size %d for field %s", fieldSize, fieldName)
+			}
+		}
+
 		// For fixed-length strings, read the string size.
-		var fieldSize int
 		if fieldType == FieldTypeFixedStr {
 			fieldSize, err = f.ReadSizeField(r)
 			if err != nil {
 				return nil, err
 			}
+			if fieldSize == 0 {
+				return nil, fmt.Errorf("invalid fixed-length string size for field %s", fieldName)
+			}
 		}
 
 		// If there's a bad index, we may read past the expected size. This is a serious error.
@@ -168,11 +392,25 @@ func (f *rsfReader) readIndexEntries(r io.Reader, finalPos, limit int) (Index, e
 			return nil, fmt.Errorf("unexpected index position %d; index max pos reported is %d", f.pos, finalPos)
 		}
 
+		// Each index entry includes at least a name size and a type, so a
+		// corrupt subfield count can be detected before reading subfields.
+		if int64(subfieldCount) > (finalPos-f.pos)/minIndexEntrySize {
+			return nil, fmt.Errorf("subfield count %d for field %s exceeds the remaining index size", subfieldCount, fieldName)
+		}
+
+		// The array index keys must have a valid type and size.
+		if indexed {
+			err = checkIndexKey(fieldName, indexType, indexSize)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		// For arrays, recursively read the array subfields into a new array of entries.
 		var subfields []IndexEntry
 		if subfieldCount > 0 {
 			// Enumerate the subfields
-			subfields, err = f.readIndexEntries(r, finalPos, subfieldCount)
+			subfields, err = f.readIndexEntries(r, finalPos, subfieldCount, depth+1)
 			if err != nil {
 				return nil, err
 			}
@@ -188,25 +426,148 @@ func (f *rsfReader) readIndexEntries(r io.Reader, finalPos, limit int) (Index, e
 			Indexed:      indexed,
 			IndexSize:    indexSize,
 			IndexType:    indexType,
+			TypeTag:      typeTag,
+			FieldWidth:   fieldWidth,
+			FieldID:      fieldID,
+			Optional:     optional,
+			PresenceBit:  presenceBit,
 		})
 	}
 
 	return entries, nil
 }
 
+// checkIndexKey returns an error if the array index keys of the indexed array
+// `fieldName` have an invalid type or a size that doesn't match their type.
+// Since every key in the array index is read with this size, a wrong size
+// would misread the elements of the array.
+func checkIndexKey(fieldName string, indexType, indexSize int) error {
+	switch reflect.Kind(indexType) {
+	case reflect.String, reflect.Array:
+		if indexSize == 0 {
+			return fmt.Errorf("invalid index size %d for array %s", indexSize, fieldName)
+		}
+	case reflect.Int64:
+		if indexSize != sizeInt64 {
+			return fmt.Errorf("invalid index size %d for array %s; integer keys are %d bytes", indexSize, fieldName, sizeInt64)
+		}
+	default:
+		return fmt.Errorf("invalid index type %d for array %s", indexType, fieldName)
+	}
+	return nil
+}
+
+// checkKeyField returns an error if the field `name` of the elements of the
+// indexed array `entry`, which receives the array index keys, is a fixed-length
+// string written in each element (i.e., it isn't tagged `skip`) with a size
+// other than the size of the keys in the array index. The index entry doesn't
+// record which field is the key, so this can only be checked when the field is
+// known, as when reading into a struct.
+func checkKeyField(entry IndexEntry, name string) error {
+	if !entry.Indexed || name == "" || reflect.Kind(entry.IndexType) != reflect.String {
+		return nil
+	}
+	for _, subfield := range entry.Subfields {
+		if subfield.FieldName == name && subfield.FieldType == FieldTypeFixedStr && subfield.FieldSize != entry.IndexSize {
+			return fmt.Errorf("index size %d for array %s does not match the size %d of the index field %s",
+				entry.IndexSize, entry.FieldName, subfield.FieldSize, name)
+		}
+	}
+	return nil
+}
+
+// indexReadError describes an error reading the index. Since reads are
+// limited to the index, reaching the end of the reader means that an entry
+// extends past the end of the index of size `sz`.
+func indexReadError(err error, sz int) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("index entry extends past the end of the index (size %d)", sz)
+	}
+	return err
+}
+
+// readFieldNameTable reads the field name table at the start of the index.
+// See `writeFieldNameTable` for the table format.
+func (f *rsfReader) readFieldNameTable(r io.Reader, finalPos int64) error {
+	count, err := f.ReadSizeField(r)
+	if err != nil {
+		return err
+	}
+
+	// Each name includes at least a size field.
+	if int64(count) > (finalPos-f.pos)/sizeFieldLen {
+		return fmt.Errorf("field name count %d exceeds the remaining index size", count)
+	}
+
+	names := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		var name string
+		name, err = f.ReadStringField(r)
+		if err != nil {
+			return err
+		}
+		names = append(names, name)
+	}
+	f.fieldNames = names
+	return nil
+}
+
+// readIndexName reads the field name of an index entry. When the index
+// includes a field name table, the entry holds a name id, which is resolved
+// to the name in the table.
+func (f *rsfReader) readIndexName(r io.Reader) (string, int, error) {
+	if f.features&FeatureFieldNameTable == 0 {
+		name, err := f.ReadStringField(r)
+		return name, 0, err
+	}
+
+	id, err := f.ReadSizeField(r)
+	if err != nil {
+		return "", 0, err
+	}
+	if id < 0 || id >= len(f.fieldNames) {
+		return "", 0, fmt.Errorf("field name id %d out of range; table has %d names", id, len(f.fieldNames))
+	}
+	return f.fieldNames[id], id, nil
+}
+
 func (f *rsfReader) advance(advField IndexEntry, buf *bufio.Reader) error {
+	if f.logger != nil {
+		start := f.pos
+		defer func() {
+			f.logger.Debug("skip field", "field", advField.FieldName, "type", typeTag(advField.FieldType), "bytes", f.pos-start, "pos", f.pos)
+		}()
+	}
+
 	var err error
 	switch advField.FieldType {
 	case FieldTypeFixedStr:
 		err = f.Discard(advField.FieldSize, buf)
-	case FieldTypeArray:
+	case FieldTypeArray, FieldTypePackedArray:
+		// Absent arrays are not written in the object.
+		var absent bool
+		absent, err = f.absent(advField)
+		if err != nil || absent {
+			break
+		}
 		var sz int
 		sz, err = f.ReadSizeField(buf)
 		if err != nil {
 			return err
 		}
 		err = f.Discard(sz-sizeFieldLen, buf)
+	case FieldTypeCompressedStr, FieldTypeOpaque, FieldTypeBytes:
+		var sz int
+		sz, err = f.ReadSizeField(buf)
+		if err != nil {
+			return err
+		}
+		err = f.Discard(sz, buf)
 	case FieldTypeVarStr:
+		if f.strings != nil {
+			err = f.Discard(sizeFieldLen, buf)
+			break
+		}
 		var sz int
 		sz, err = f.ReadSizeField(buf)
 		if err != nil {
@@ -219,16 +580,135 @@ func (f *rsfReader) advance(advField IndexEntry, buf *bufio.Reader) error {
 		err = f.Discard(sizeInt64, buf)
 	case FieldTypeFloat:
 		err = f.Discard(sizeFloat64, buf)
+	case FieldTypeFixedInt64:
+		err = f.Discard(sizeFixedInt64, buf)
+	case FieldTypeBigEndianInt, FieldTypeBigEndianFloat:
+		err = f.Discard(advField.FieldSize, buf)
+	case FieldTypeDays:
+		err = f.Discard(sizeInt32, buf)
+	case FieldTypeBoolBitmap:
+		// The bitmap is retained for reading the packed bools that follow.
+		err = f.readBitmap(advField, buf)
+	case FieldTypePackedBool:
+		// Packed bools are not written in the object.
+	case FieldTypeOverflowStr:
+		err = f.Discard(sizeFieldLen+sizeFieldLen, buf)
+	case FieldTypeIP:
+		_, err = f.ReadIPField(buf)
 	default:
+		// Fields of unknown types can be skipped if their width is known.
+		if advField.FieldWidth > 0 {
+			err = f.Discard(advField.FieldWidth, buf)
+			break
+		}
 		return fmt.Errorf("unexpected index field type %d", advField.FieldType)
 	}
 
 	return err
 }
 
+// minIndexEntrySize is the smallest possible size of an index entry: the
+// field name size and the field type.
+const minIndexEntrySize = sizeFieldLen + sizeFieldLen
+
+// minSize returns the smallest number of bytes that can be used to write a
+// field described by `entry`.
+func minSize(entry IndexEntry) int {
+	switch entry.FieldType {
+	case FieldTypeVarStr, FieldTypeCompressedStr, FieldTypeOpaque, FieldTypeBytes:
+		return sizeFieldLen
+	case FieldTypeFixedStr, FieldTypeBoolBitmap, FieldTypeBigEndianInt, FieldTypeBigEndianFloat:
+		return entry.FieldSize
+	case FieldTypePackedBool:
+		return 0
+	case FieldTypeBool, FieldTypeIP:
+		return 1
+	case FieldTypeInt64:
+		return sizeInt64
+	case FieldTypeFloat:
+		return sizeFloat64
+	case FieldTypeFixedInt64:
+		return sizeFixedInt64
+	case FieldTypeDays:
+		return sizeInt32
+	case FieldTypeArray, FieldTypePackedArray, FieldTypeOverflowStr:
+		return sizeFieldLen + sizeFieldLen
+	default:
+		return entry.FieldWidth
+	}
+}
+
+// checkArrayLen returns an error if an array described by `entry` cannot
+// include `arrayLen` elements in `arraySz` bytes. This guards against corrupt
+// array lengths before elements are allocated or read.
+//
+// Sizes and lengths are read as uint32 values, which wrap to negative values
+// when converted to `int` on 32-bit platforms, so negative values are
+// rejected, and the arithmetic is done with int64 values.
+func checkArrayLen(entry IndexEntry, arraySz, arrayLen int) error {
+	if arraySz < sizeFieldLen+sizeFieldLen {
+		return fmt.Errorf("invalid array size %d", arraySz)
+	}
+	if arrayLen < 0 {
+		return fmt.Errorf("invalid array length %d", arrayLen)
+	}
+
+	// Find the smallest possible size of each element, including its entry
+	// in the array index.
+	var elSz int64
+	if entry.Indexed {
+		elSz += int64(entry.IndexSize)
+		if entry.FieldType != FieldTypePackedArray {
+			elSz += sizeFieldLen
+		}
+	}
+	if packedElement(entry).FieldType != 0 {
+		elSz += int64(entry.FieldSize)
+	} else if len(entry.Subfields) > 0 {
+		for _, subfield := range entry.Subfields {
+			elSz += int64(minSize(subfield))
+		}
+	} else {
+		switch reflect.Kind(entry.SubfieldType) {
+		case reflect.Struct:
+			// Elements of structs with no fields are written as a size
+			// field.
+			if emptyElements(entry) {
+				elSz += sizeFieldLen
+			}
+		case reflect.String:
+			elSz += sizeFieldLen
+		case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+			elSz += sizeInt64
+		case reflect.Float32, reflect.Float64:
+			elSz += sizeFloat64
+		case reflect.Array, reflect.Slice:
+			elSz += sizeFieldLen + sizeFieldLen
+		default:
+			elSz++
+		}
+	}
+
+	// Elements with no serialized fields use no space, so the length can't
+	// be verified. Since such arrays aren't useful, require at least one byte
+	// per element to bound the work done for a corrupt length.
+	if elSz == 0 {
+		elSz = 1
+	}
+
+	if int64(arrayLen) > (int64(arraySz)-sizeFieldLen-sizeFieldLen)/elSz {
+		return fmt.Errorf("array length %d exceeds array size %d", arrayLen, arraySz)
+	}
+	return nil
+}
+
 var ErrNoSuchField = errors.New("field not found")
 
 func (f *rsfReader) AdvanceTo(buf *bufio.Reader, fieldNames ...string) error {
+	if f.logger != nil {
+		f.logger.Debug("advance to", "field", strings.Join(fieldNames, rsfPathSep), "pos", f.pos)
+	}
+
 	at := f.at
 	if len(fieldNames) < len(at) {
 		at = f.at[:len(fieldNames)]
@@ -241,20 +721,41 @@ func (f *rsfReader) AdvanceTo(buf *bufio.Reader, fieldNames ...string) error {
 		return err
 	}
 
-	_, toPos, err := entrySet(f.index, fieldNames...)
+	to, toPos, err := entrySet(f.index, fieldNames...)
 	if err != nil {
 		return err
 	}
 
+	var parent []string
+	if len(fieldNames) > 0 {
+		parent = fieldNames[:len(fieldNames)-1]
+	}
+
 	for i := fromPos + 1; i < toPos; i++ {
+		f.notifyField(parent, from[i])
 		err = f.advance(from[i], buf)
 		if err != nil {
 			return err
 		}
 	}
 
+	if toPos >= 0 && toPos < len(to) {
+		f.notifyField(parent, to[toPos])
+	}
+
 	f.at = fieldNames
 
+	// Absent arrays can't be read, but the reader may advance past them.
+	if toPos >= 0 && toPos < len(to) {
+		absent, err := f.absent(to[toPos])
+		if err != nil {
+			return err
+		}
+		if absent {
+			return withField(strings.Join(fieldNames, rsfPathSep), ErrFieldAbsent)
+		}
+	}
+
 	return nil
 
 }
@@ -265,7 +766,13 @@ func (f *rsfReader) AdvanceToNextElement(buf *bufio.Reader, fieldNames ...string
 		return err
 	}
 
+	var parent []string
+	if len(f.at) > 0 {
+		parent = f.at[:len(f.at)-1]
+	}
+
 	for i := fromPos + 1; i < len(from); i++ {
+		f.notifyField(parent, from[i])
 		err = f.advance(from[i], buf)
 		if err != nil {
 			return err
@@ -284,6 +791,18 @@ func (f *rsfReader) AdvanceToNextElement(buf *bufio.Reader, fieldNames ...string
 
 }
 
+// CurrentField returns the index entry of the field at the reader's current
+// position, as set by `AdvanceTo` and the like, so that generic code can
+// choose which `Read*` method to call. False is returned if the reader is not
+// positioned at a field, like at the start of an object or array element.
+func (f *rsfReader) CurrentField() (IndexEntry, bool) {
+	entries, pos, err := entrySet(f.index, f.at...)
+	if err != nil || pos < 0 || pos >= len(entries) {
+		return IndexEntry{}, false
+	}
+	return entries[pos], true
+}
+
 func entrySet(index Index, fieldNames ...string) (Index, int, error) {
 	var atPos int
 
@@ -297,7 +816,7 @@ func entrySet(index Index, fieldNames ...string) (Index, int, error) {
 	for _, field := range fieldNames {
 		var found bool
 		for pos, entry := range next {
-			if entry.FieldName == field || field == Top {
+			if entry.FieldName == field && !entry.hidden || field == Top {
 				found = true
 				at = next
 				if field == Top {
@@ -315,3 +834,87 @@ func entrySet(index Index, fieldNames ...string) (Index, int, error) {
 	}
 	return at, atPos, nil
 }
+
+// Equal returns true if both indexes describe the same fields in the same
+// order, including field types, sizes, array index metadata, presence bits,
+// and subfields. Type tags and field widths are not compared since they are
+// redundant for known field types.
+func (i Index) Equal(other Index) bool {
+	if len(i) != len(other) {
+		return false
+	}
+	for n := range i {
+		if !i[n].Equal(other[n]) {
+			return false
+		}
+	}
+	return true
+}
+
+// String describes the fields of the index, one per line, for documentation
+// and inspection. Each line includes the field name and type tag (see
+// `VerboseIndex`), along with fixed sizes, array element types, array index
+// metadata, and the presence bits of optional arrays. Subfields are indented
+// below their arrays. For example:
+//
+//	company str
+//	date fstr(10)
+//	snapshots arr[struct] index:string(10)
+//	  description str
+func (i Index) String() string {
+	var sb strings.Builder
+	i.writeString(&sb, 0)
+	return sb.String()
+}
+
+func (i Index) writeString(sb *strings.Builder, indent int) {
+	for _, entry := range i {
+		sb.WriteString(strings.Repeat("  ", indent))
+		sb.WriteString(entry.FieldName)
+		sb.WriteString(" ")
+		sb.WriteString(typeTag(entry.FieldType))
+		switch entry.FieldType {
+		case FieldTypeFixedStr, FieldTypePackedArray, FieldTypePackedBool, FieldTypeBoolBitmap,
+			FieldTypeBigEndianInt, FieldTypeBigEndianFloat:
+			fmt.Fprintf(sb, "(%d)", entry.FieldSize)
+		}
+		if entry.FieldType == FieldTypeArray || entry.FieldType == FieldTypePackedArray {
+			kind := reflect.Kind(entry.SubfieldType)
+			if kind == reflect.Invalid && len(entry.Subfields) > 0 {
+				kind = reflect.Struct
+			}
+			if kind != reflect.Invalid {
+				fmt.Fprintf(sb, "[%s]", kind)
+			}
+		}
+		if entry.Indexed {
+			switch reflect.Kind(entry.IndexType) {
+			case reflect.String:
+				fmt.Fprintf(sb, " index:string(%d)", entry.IndexSize)
+			case reflect.Array:
+				fmt.Fprintf(sb, " index:bytes(%d)", entry.IndexSize)
+			default:
+				fmt.Fprintf(sb, " index:%s", reflect.Kind(entry.IndexType))
+			}
+		}
+		if entry.Optional {
+			fmt.Fprintf(sb, " optional(%d)", entry.PresenceBit)
+		}
+		sb.WriteString("\n")
+		entry.Subfields.writeString(sb, indent+1)
+	}
+}
+
+// Equal returns true if both index entries (and their subfields) match.
+func (e IndexEntry) Equal(other IndexEntry) bool {
+	return e.FieldName == other.FieldName &&
+		e.FieldType == other.FieldType &&
+		e.FieldSize == other.FieldSize &&
+		e.Indexed == other.Indexed &&
+		e.IndexSize == other.IndexSize &&
+		e.IndexType == other.IndexType &&
+		e.SubfieldType == other.SubfieldType &&
+		e.Optional == other.Optional &&
+		e.PresenceBit == other.PresenceBit &&
+		e.Subfields.Equal(other.Subfields)
+}
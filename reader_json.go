@@ -0,0 +1,272 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"net/netip"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// ObjectToJSON reads the next object and returns it as a JSON object. The
+// JSON is written directly as the object is read, without decoding the object
+// into a map first, so it is much cheaper than `DecodeObject` followed by
+// `json.Marshal`. Values are encoded as `PrintJSON` encodes them, except that
+// fields are written in index order, and NaN and infinite floats are written
+// as `null`. Overflow strings follow the object's other fields.
+//
+// When complete, the reader is positioned at the start of the next object. An
+// `io.EOF` error is returned at the end of the objects.
+func (f *rsfReader) ObjectToJSON(buf *bufio.Reader) ([]byte, error) {
+	bs := make([]byte, 0, 256)
+	bs = append(bs, '{')
+	overflow := func(name string) func(s string) error {
+		return func(s string) error {
+			bs = appendJSONKey(bs, name)
+			bs = appendJSONString(bs, s)
+			return nil
+		}
+	}
+
+	err := f.readObject(buf, func() error {
+		var err error
+		bs, err = f.appendJSONFields(bs, f.index, buf, overflow)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(bs, '}'), nil
+}
+
+// appendJSONFields appends the fields described by `index` to the JSON object
+// in `bs`. Overflow strings are set with the functions returned by `overflow`
+// when the overflow region is read.
+func (f *rsfReader) appendJSONFields(bs []byte, index Index, buf *bufio.Reader, overflow func(name string) func(s string) error) ([]byte, error) {
+	for _, entry := range index {
+		if entry.hidden {
+			err := f.advance(entry, buf)
+			if err != nil {
+				return nil, fmt.Errorf("error decoding field %s: %w", entry.FieldName, err)
+			}
+			continue
+		}
+
+		var err error
+		switch entry.FieldType {
+		case FieldTypeOverflowStr:
+			err = f.readOverflowRef(buf, overflow(entry.FieldName))
+		case FieldTypeBoolBitmap:
+			// The bool bitmap is not a field.
+			err = f.readBitmap(entry, buf)
+		case FieldTypeArray, FieldTypePackedArray:
+			// Absent arrays are omitted from the object.
+			var absent bool
+			absent, err = f.absent(entry)
+			if err != nil || absent {
+				break
+			}
+			bs = appendJSONKey(bs, entry.FieldName)
+			bs, err = f.appendJSONArray(bs, entry, buf)
+		default:
+			var val any
+			val, err = f.decodeValue(entry, buf)
+			if err == nil {
+				bs = appendJSONKey(bs, entry.FieldName)
+				bs, err = appendJSONValue(bs, val)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error decoding field %s: %w", entry.FieldName, err)
+		}
+	}
+	return bs, nil
+}
+
+// appendJSONArray appends an array, including the index key of each element
+// of an indexed array, to `bs`.
+func (f *rsfReader) appendJSONArray(bs []byte, entry IndexEntry, buf *bufio.Reader) ([]byte, error) {
+	arraySz, err := f.ReadSizeField(buf)
+	if err != nil {
+		return nil, err
+	}
+	arrayLen, err := f.ReadSizeField(buf)
+	if err != nil {
+		return nil, err
+	}
+	err = checkArrayLen(entry, arraySz, arrayLen)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := f.readArrayKeys(entry, arrayLen, buf)
+	if err != nil {
+		return nil, err
+	}
+	elEntry, err := arrayElement(entry, arrayLen)
+	if err != nil {
+		return nil, err
+	}
+
+	bs = append(bs, '[')
+	for i := 0; i < arrayLen; i++ {
+		if i > 0 {
+			bs = append(bs, ',')
+		}
+		if elEntry.FieldType != 0 {
+			var val any
+			val, err = f.decodeValue(elEntry, buf)
+			if err != nil {
+				return nil, err
+			}
+			bs, err = appendJSONValue(bs, val)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if emptyElements(entry) {
+			err = f.readEmptyElement(buf)
+			if err != nil {
+				return nil, err
+			}
+		}
+		bs = append(bs, '{')
+		if keys != nil {
+			bs = appendJSONKey(bs, IndexKeyField)
+			bs, err = appendJSONValue(bs, keys[i])
+			if err != nil {
+				return nil, err
+			}
+		}
+		bs, err = f.appendJSONFields(bs, entry.Subfields, buf, nil)
+		if err != nil {
+			return nil, err
+		}
+		bs = append(bs, '}')
+	}
+	return append(bs, ']'), nil
+}
+
+// appendJSONKey appends the object key `name` to `bs`, preceded by a comma
+// unless it is the first key of the object.
+func appendJSONKey(bs []byte, name string) []byte {
+	if bs[len(bs)-1] != '{' {
+		bs = append(bs, ',')
+	}
+	bs = appendJSONString(bs, name)
+	return append(bs, ':')
+}
+
+// appendJSONValue appends a scalar value, as decoded by `decodeValue`, to
+// `bs`.
+func appendJSONValue(bs []byte, val any) ([]byte, error) {
+	switch v := val.(type) {
+	case string:
+		return appendJSONString(bs, v), nil
+	case bool:
+		return strconv.AppendBool(bs, v), nil
+	case int64:
+		return strconv.AppendInt(bs, v, 10), nil
+	case float64:
+		return appendJSONFloat(bs, v), nil
+	case netip.Addr:
+		bs = append(bs, '"')
+		if v.IsValid() {
+			bs = v.AppendTo(bs)
+		}
+		return append(bs, '"'), nil
+	case time.Time:
+		return v.AppendFormat(append(bs, '"'), time.RFC3339Nano+`"`), nil
+	case []byte:
+		enc := make([]byte, base64.StdEncoding.EncodedLen(len(v)))
+		base64.StdEncoding.Encode(enc, v)
+		bs = append(bs, '"')
+		bs = append(bs, enc...)
+		return append(bs, '"'), nil
+	default:
+		return nil, fmt.Errorf("cannot encode %T as JSON", val)
+	}
+}
+
+// appendJSONFloat appends a float formatted like `encoding/json` formats it.
+// NaN and infinite floats are appended as `null`.
+func appendJSONFloat(bs []byte, fl float64) []byte {
+	if math.IsNaN(fl) || math.IsInf(fl, 0) {
+		return append(bs, "null"...)
+	}
+
+	// Use exponents for very small and very large values.
+	format := byte('f')
+	if abs := math.Abs(fl); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	bs = strconv.AppendFloat(bs, fl, format, -1, 64)
+	if format == 'e' {
+		// Clean up e-09 to e-9.
+		n := len(bs)
+		if n >= 4 && bs[n-4] == 'e' && bs[n-3] == '-' && bs[n-2] == '0' {
+			bs[n-2] = bs[n-1]
+			bs = bs[:n-1]
+		}
+	}
+	return bs
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString appends a quoted JSON string to `bs`, escaped like
+// `encoding/json` escapes it. Invalid UTF-8 is replaced with U+FFFD.
+func appendJSONString(bs []byte, s string) []byte {
+	bs = append(bs, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			bs = append(bs, s[start:i]...)
+			switch b {
+			case '"', '\\':
+				bs = append(bs, '\\', b)
+			case '\n':
+				bs = append(bs, '\\', 'n')
+			case '\r':
+				bs = append(bs, '\\', 'r')
+			case '\t':
+				bs = append(bs, '\\', 't')
+			default:
+				bs = append(bs, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			bs = append(bs, s[start:i]...)
+			bs = append(bs, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+
+		// U+2028 and U+2029 are valid JSON but not valid JavaScript.
+		if r == '\u2028' || r == '\u2029' {
+			bs = append(bs, s[start:i]...)
+			bs = append(bs, '\\', 'u', '2', '0', '2', hexDigits[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	bs = append(bs, s[start:]...)
+	return append(bs, '"')
+}
@@ -0,0 +1,167 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ReaderJSONSuite struct {
+	suite.Suite
+}
+
+func TestReaderJSONSuite(t *testing.T) {
+	suite.Run(t, &ReaderJSONSuite{})
+}
+
+// assertSameJSON asserts that each object in `data` is encoded by
+// `ObjectToJSON` with the same values that `json.Marshal` encodes for the
+// object decoded with `DecodeObject`.
+func (s *ReaderJSONSuite) assertSameJSON(data []byte, objects int) {
+	jbuf := bufio.NewReader(bytes.NewReader(data))
+	jr := NewReader()
+	_, err := jr.ReadIndex(jbuf)
+	s.Require().Nil(err)
+
+	dbuf := bufio.NewReader(bytes.NewReader(data))
+	dr := NewReader()
+	_, err = dr.ReadIndex(dbuf)
+	s.Require().Nil(err)
+
+	for i := 0; i < objects; i++ {
+		got, err := jr.ObjectToJSON(jbuf)
+		s.Require().Nil(err)
+		s.Require().True(json.Valid(got), string(got))
+
+		obj, err := dr.DecodeObject(dbuf)
+		s.Require().Nil(err)
+		want, err := json.Marshal(obj)
+		s.Require().Nil(err)
+		s.Assert().JSONEq(string(want), string(got))
+	}
+
+	_, err = jr.ObjectToJSON(jbuf)
+	s.Assert().ErrorIs(err, io.EOF)
+}
+
+func (s *ReaderJSONSuite) TestObjectToJSON() {
+	s.assertSameJSON(getComplexData(&s.Suite).Bytes(), len(testComplexData))
+}
+
+func (s *ReaderJSONSuite) TestObjectToJSONOverflow() {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	for _, obj := range testOverflowData {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+	s.assertSameJSON(buf.Bytes(), len(testOverflowData))
+}
+
+func (s *ReaderJSONSuite) TestObjectToJSONEscaping() {
+	type record struct {
+		Name  string     `rsf:"name"`
+		Notes string     `rsf:"notes"`
+		Addr  netip.Addr `rsf:"addr"`
+		Score float64    `rsf:"score"`
+		Tiny  float64    `rsf:"tiny"`
+	}
+	notes := "line one\nline \"two\"\r\n\ttabbed \\ <b>&</b> \x01 \xff \u2028 \u00e9"
+
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	_, err := w.WriteObject(record{
+		Name:  "numpy",
+		Notes: notes,
+		Addr:  netip.MustParseAddr("192.168.0.1"),
+		Score: 1.5,
+		Tiny:  0.00000001,
+	})
+	s.Require().Nil(err)
+
+	rbuf := bufio.NewReader(bytes.NewReader(buf.Bytes()))
+	r := NewReader()
+	_, err = r.ReadIndex(rbuf)
+	s.Require().Nil(err)
+	got, err := r.ObjectToJSON(rbuf)
+	s.Require().Nil(err)
+	s.Require().True(json.Valid(got))
+
+	// Strings are escaped exactly like `encoding/json` escapes them.
+	escaped, err := json.Marshal(notes)
+	s.Require().Nil(err)
+	s.Assert().Equal(`{"name":"numpy","notes":`+string(escaped)+`,"addr":"192.168.0.1","score":1.5,"tiny":1e-8}`, string(got))
+
+	var obj map[string]any
+	err = json.Unmarshal(got, &obj)
+	s.Require().Nil(err)
+	s.Assert().Equal("line one\nline \"two\"\r\n\ttabbed \\ <b>&</b> \x01 \ufffd \u2028 \u00e9", obj["notes"])
+}
+
+func (s *ReaderJSONSuite) TestObjectToJSONNonFinite() {
+	type record struct {
+		Values []float64 `rsf:"values"`
+	}
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	_, err := w.WriteObject(record{Values: []float64{math.NaN(), math.Inf(1), 2}})
+	s.Require().Nil(err)
+
+	rbuf := bufio.NewReader(bytes.NewReader(buf.Bytes()))
+	r := NewReader()
+	_, err = r.ReadIndex(rbuf)
+	s.Require().Nil(err)
+	got, err := r.ObjectToJSON(rbuf)
+	s.Require().Nil(err)
+	s.Assert().Equal(`{"values":[null,null,2]}`, string(got))
+}
+
+func BenchmarkObjectToJSON(b *testing.B) {
+	benchmarkObjectJSON(b, func(r Reader, buf *bufio.Reader) ([]byte, error) {
+		return r.ObjectToJSON(buf)
+	})
+}
+
+func BenchmarkDecodeObjectMarshal(b *testing.B) {
+	benchmarkObjectJSON(b, func(r Reader, buf *bufio.Reader) ([]byte, error) {
+		obj, err := r.DecodeObject(buf)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(obj)
+	})
+}
+
+func benchmarkObjectJSON(b *testing.B, toJSON func(r Reader, buf *bufio.Reader) ([]byte, error)) {
+	data := &bytes.Buffer{}
+	w := NewWriterWithVersion(data, Version2)
+	for _, obj := range testComplexData {
+		_, err := w.WriteObject(obj)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf := bufio.NewReader(bytes.NewReader(data.Bytes()))
+		r := NewReader()
+		_, err := r.ReadIndex(buf)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for range testComplexData {
+			_, err = toJSON(r, buf)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+var ErrNoKeyTable = errors.New("object key table not found")
+
+// FindObject uses the object key table written after the last object (see
+// `ObjectKey`) to seek directly to the object whose key equals `key`. If the
+// object is found, `r` is positioned at the object's size field and true is
+// returned. Otherwise, the position of `r` is unchanged and false is returned.
+func (f *rsfReader) FindObject(r io.ReadSeeker, key any) (bool, error) {
+	var want any
+	switch k := key.(type) {
+	case string:
+		want = k
+	case int, int64, int32, int16, int8:
+		want = reflect.ValueOf(k).Int()
+	default:
+		return false, fmt.Errorf("invalid object key type %T", key)
+	}
+
+	// Save the current position so it can be restored if the key isn't found.
+	start, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return false, err
+	}
+
+	entries, err := readKeyTable(r)
+	if err != nil {
+		return false, err
+	}
+
+	for _, entry := range entries {
+		if entry.key == want {
+			return true, f.seek(entry.offset, r)
+		}
+	}
+
+	_, err = r.Seek(start, io.SeekStart)
+	return false, err
+}
+
+// Keys reads the value of the top-level field `field` from each remaining
+// object, skipping the rest of each object without decoding it. This is
+// useful for building a table of contents of a file. The reader must be
+// positioned at the start of an object. Values are returned with the types
+// used by `DecodeObject`.
+func (f *rsfReader) Keys(buf *bufio.Reader, field string) ([]any, error) {
+	var entry *IndexEntry
+	for i := range f.index {
+		if f.index[i].FieldName == field && !f.index[i].hidden {
+			entry = &f.index[i]
+			break
+		}
+	}
+	if entry == nil {
+		return nil, ErrNoSuchField
+	}
+
+	keys := make([]any, 0)
+	for {
+		err := f.checkMaxObjects(buf, len(keys))
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = f.BeginObject(buf)
+		if err == io.EOF {
+			return keys, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		err = f.AdvanceTo(buf, field)
+		if err != nil {
+			return nil, err
+		}
+		key, err := f.decodeValue(*entry, buf)
+		if err != nil {
+			return nil, fmt.Errorf("error reading field %s: %w", field, err)
+		}
+		keys = append(keys, key)
+
+		err = f.SkipObject(buf)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// ReadObjectOffsets reads an offset index written by `NewIndexedWriter` and
+// returns the offset of each object in the data file by key. String keys are
+// returned as `string` and integer keys as `int64`. Seek the data file to an
+// offset with `Seek` to read the object.
+func ReadObjectOffsets(r io.ReadSeeker) (map[any]int64, error) {
+	entries, err := readKeyTable(r)
+	if err != nil {
+		return nil, err
+	}
+	offsets := make(map[any]int64, len(entries))
+	for _, entry := range entries {
+		offsets[entry.key] = entry.offset
+	}
+	return offsets, nil
+}
+
+// readKeyTable reads the object key table from the end of `r`.
+func readKeyTable(r io.ReadSeeker) ([]objectKeyEntry, error) {
+	// Read the key table size and marker.
+	_, err := r.Seek(-(sizeFieldLen + int64(len(KeyTableMarker))), io.SeekEnd)
+	if err != nil {
+		return nil, ErrNoKeyTable
+	}
+	tr := &rsfReader{}
+	sz, err := tr.ReadSizeField(r)
+	if err != nil {
+		return nil, err
+	}
+	marker := make([]byte, len(KeyTableMarker))
+	_, err = io.ReadFull(r, marker)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(marker, KeyTableMarker) {
+		return nil, ErrNoKeyTable
+	}
+
+	// Seek to the start of the key table.
+	_, err = r.Seek(-(int64(sz) + sizeFieldLen + int64(len(KeyTableMarker))), io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	keyType, err := tr.ReadSizeField(r)
+	if err != nil {
+		return nil, err
+	}
+	count, err := tr.ReadSizeField(r)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]objectKeyEntry, 0)
+	bs := make([]byte, sizeOffset)
+	for i := 0; i < count; i++ {
+		var key any
+		switch reflect.Kind(keyType) {
+		case reflect.String:
+			key, err = tr.ReadStringField(r)
+		case reflect.Int64:
+			key, err = tr.ReadIntField(r)
+		default:
+			err = fmt.Errorf("invalid object key type %d", keyType)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = io.ReadFull(r, bs)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, objectKeyEntry{
+			key:    key,
+			offset: int64(binary.LittleEndian.Uint64(bs)),
+		})
+	}
+	return entries, nil
+}
@@ -0,0 +1,193 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ReaderKeysSuite struct {
+	suite.Suite
+}
+
+func TestReaderKeysSuite(t *testing.T) {
+	suite.Run(t, &ReaderKeysSuite{})
+}
+
+func (s *ReaderKeysSuite) TestFindObject() {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version3, ObjectKey("cname"))
+	numpySz, err := w.WriteObject(testComplexData[0])
+	s.Require().Nil(err)
+	_, err = w.WriteObject(testComplexData[1])
+	s.Require().Nil(err)
+	s.Require().Nil(w.Close())
+
+	f := bytes.NewReader(buf.Bytes())
+	r := NewReader()
+	_, err = r.ReadIndex(f)
+	s.Require().Nil(err)
+	indexPos := r.Pos()
+
+	// Look up an object that doesn't exist. The position doesn't change.
+	found, err := r.FindObject(f, "pandas")
+	s.Require().Nil(err)
+	s.Assert().False(found)
+	pos, err := f.Seek(0, io.SeekCurrent)
+	s.Require().Nil(err)
+	s.Assert().Equal(int64(indexPos), pos)
+
+	// Find the second object without reading the first.
+	found, err = r.FindObject(f, "django")
+	s.Require().Nil(err)
+	s.Assert().True(found)
+	s.Assert().Equal(int64(numpySz), r.Pos())
+
+	var rec FullPackageRecordPyPI
+	err = r.Unmarshal(bufio.NewReader(f), &rec)
+	s.Require().Nil(err)
+	s.Assert().Equal("django", rec.CanonicalName)
+
+	// Find the first object.
+	found, err = r.FindObject(f, "numpy")
+	s.Require().Nil(err)
+	s.Assert().True(found)
+	s.Assert().Equal(indexPos, r.Pos())
+
+	// The key table is not printed.
+	pbuf := &bytes.Buffer{}
+	err = Print(pbuf, bufio.NewReader(bytes.NewReader(buf.Bytes())))
+	s.Require().Nil(err)
+	s.Assert().Contains(pbuf.String(), "Object[2]")
+	s.Assert().NotContains(pbuf.String(), "Object[3]")
+}
+
+func (s *ReaderKeysSuite) TestFindObjectIntKey() {
+	type record struct {
+		Id   int    `rsf:"id"`
+		Name string `rsf:"name"`
+	}
+
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version3, StringTable(true), ObjectKey("id"))
+	for i, name := range []string{"one", "two", "three"} {
+		_, err := w.WriteObject(record{Id: i + 1, Name: name})
+		s.Require().Nil(err)
+	}
+	s.Require().Nil(w.Close())
+
+	f := bytes.NewReader(buf.Bytes())
+	r := NewReader()
+	_, err := r.ReadIndex(f)
+	s.Require().Nil(err)
+
+	found, err := r.FindObject(f, 3)
+	s.Require().Nil(err)
+	s.Assert().True(found)
+
+	var rec record
+	err = r.Unmarshal(bufio.NewReader(f), &rec)
+	s.Require().Nil(err)
+	s.Assert().Equal(record{Id: 3, Name: "three"}, rec)
+}
+
+func (s *ReaderKeysSuite) TestFindObjectErrors() {
+	// No key table
+	f := bytes.NewReader(getData(&s.Suite).Bytes())
+	r := NewReader()
+	_, err := r.FindObject(f, "posit")
+	s.Assert().ErrorIs(err, ErrNoKeyTable)
+
+	// Key tables require Version3
+	w := NewWriterWithVersion(&bytes.Buffer{}, Version2, ObjectKey("cname"))
+	_, err = w.WriteObject(testComplexData[0])
+	s.Assert().ErrorIs(err, ErrKeyTableVersion)
+
+	// Missing key field
+	w = NewWriterWithVersion(&bytes.Buffer{}, Version3, ObjectKey("missing"))
+	_, err = w.WriteObject(testComplexData[0])
+	s.Assert().EqualError(err, "object key field missing not found")
+}
+
+func (s *ReaderKeysSuite) TestIndexedWriter() {
+	data := &bytes.Buffer{}
+	idx := &bytes.Buffer{}
+	w := NewIndexedWriter(data, idx, "cname", Version2)
+	for _, obj := range testComplexData {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+	s.Require().Nil(w.Close())
+
+	// The data file is unchanged.
+	plain := &bytes.Buffer{}
+	w = NewWriterWithVersion(plain, Version2)
+	for _, obj := range testComplexData {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+	s.Require().Nil(w.Close())
+	s.Assert().Equal(plain.Bytes(), data.Bytes())
+
+	offsets, err := ReadObjectOffsets(bytes.NewReader(idx.Bytes()))
+	s.Require().Nil(err)
+	s.Assert().Len(offsets, len(testComplexData))
+
+	f := bytes.NewReader(data.Bytes())
+	r := NewReader()
+	_, err = r.ReadIndex(f)
+	s.Require().Nil(err)
+	for i := len(testComplexData) - 1; i >= 0; i-- {
+		name := testComplexData[i].CanonicalName
+		offset, ok := offsets[name]
+		s.Require().True(ok)
+		s.Require().Nil(r.Seek(int(offset), f))
+
+		var rec FullPackageRecordPyPI
+		s.Require().Nil(r.Unmarshal(bufio.NewReader(f), &rec))
+		s.Assert().Equal(name, rec.CanonicalName)
+	}
+
+	// The offset index is required.
+	_, err = ReadObjectOffsets(bytes.NewReader(data.Bytes()))
+	s.Assert().ErrorIs(err, ErrNoKeyTable)
+}
+
+func (s *ReaderKeysSuite) TestKeys() {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	for _, company := range []string{"posit", "rstudio", "acme"} {
+		rec := testUpgradedData
+		rec.Company = company
+		_, err := w.WriteObject(rec)
+		s.Require().Nil(err)
+	}
+
+	b := bufio.NewReader(bytes.NewReader(buf.Bytes()))
+	r := NewReader()
+	_, err := r.ReadIndex(b)
+	s.Require().Nil(err)
+	keys, err := r.Keys(b, "company")
+	s.Require().Nil(err)
+	s.Assert().Equal([]any{"posit", "rstudio", "acme"}, keys)
+	s.Assert().Equal(int64(buf.Len()), r.Pos())
+
+	// Other field types.
+	b = bufio.NewReader(bytes.NewReader(buf.Bytes()))
+	_, err = r.ReadIndex(b)
+	s.Require().Nil(err)
+	keys, err = r.Keys(b, "zip")
+	s.Require().Nil(err)
+	s.Assert().Equal([]any{int64(75043), int64(75043), int64(75043)}, keys)
+
+	// Only top-level fields can be read.
+	b = bufio.NewReader(bytes.NewReader(buf.Bytes()))
+	_, err = r.ReadIndex(b)
+	s.Require().Nil(err)
+	_, err = r.Keys(b, "name")
+	s.Assert().ErrorIs(err, ErrNoSuchField)
+}
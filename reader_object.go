@@ -0,0 +1,153 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrObjectNotConsumed = errors.New("the object was not read exactly")
+
+// BeginObject reads the size field at the start of an object and returns the
+// object size, which includes the size field. The reader records where the
+// object ends so that `RemainingInObject` can report the bytes left to read.
+//
+// A zero size marks the end of the objects, so an `io.EOF` error is returned
+// when a zero size is read.
+func (f *rsfReader) BeginObject(r io.Reader) (int, error) {
+	if f.features&FeatureColumnar != 0 {
+		return 0, ErrColumnarFile
+	}
+	start := f.pos
+	f.begun = false
+	sz, err := f.ReadSizeField(r)
+	if err != nil {
+		return 0, err
+	}
+	if sz == 0 {
+		f.objectEnd = 0
+		return 0, io.EOF
+	}
+
+	f.objectStart = start
+	f.objectEnd = start + int64(sz)
+	f.overflow = nil
+	f.bitmap = nil
+	f.at = nil
+
+	// Objects in multi-schema files begin with the id of their index.
+	if f.schemas != nil {
+		err = f.beginSchema(r)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return sz, nil
+}
+
+// NextObject discards the unread remainder of the current object, if any,
+// and begins the next object like `BeginObject`, resetting the per-object
+// reader state. This allows a single reader to read object after object
+// without reading the index again. Since the object's size field has been
+// read, the next call to `Unmarshal`, `DecodeObject`, or `ReadScalars` reads
+// the object's fields without reading its size field again. An `io.EOF` error
+// is returned at the end of the objects.
+//
+//	for {
+//		err := r.NextObject(buf)
+//		if err == io.EOF {
+//			break
+//		}
+//		...
+//		err = r.Unmarshal(buf, &rec)
+//	}
+func (f *rsfReader) NextObject(buf *bufio.Reader) error {
+	err := f.SkipObject(buf)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.BeginObject(buf)
+	if err != nil {
+		return err
+	}
+	f.begun = true
+	return nil
+}
+
+// RemainingInObject returns the number of bytes remaining in the object
+// started with `BeginObject`, based on the reader position. It returns zero
+// at the end of the object, and a negative value if the reader has read past
+// the end of the object. Zero is returned if no object has been started.
+func (f *rsfReader) RemainingInObject() int {
+	if f.objectEnd == 0 {
+		return 0
+	}
+	return int(f.objectEnd - f.pos)
+}
+
+// SkipObject discards the unread remainder of the object started with
+// `BeginObject`, positioning the reader at the start of the next object. An
+// error is returned if the reader has read past the end of the object.
+func (f *rsfReader) SkipObject(buf *bufio.Reader) error {
+	remaining := f.RemainingInObject()
+	if remaining < 0 {
+		return fmt.Errorf("read %d bytes past the end of the object", -remaining)
+	} else if remaining > 0 {
+		err := f.Discard(remaining, buf)
+		if err != nil {
+			return err
+		}
+	}
+
+	f.at = nil
+	return nil
+}
+
+// EndObjectStrict verifies that exactly the bytes of the object started with
+// `BeginObject` have been read, and ends the object. Unlike `SkipObject`,
+// nothing is discarded: if fewer bytes were read (e.g., a field was skipped
+// without advancing past it), or more (e.g., a read continued into the next
+// object), an error wrapping `ErrObjectNotConsumed` reports the difference.
+// This catches consumers that read fields out of order during development,
+// rather than silently misreading the objects that follow. Any padding (see
+// `PadObjects`) or overflow region must be read or discarded first.
+func (f *rsfReader) EndObjectStrict() error {
+	if f.objectEnd == 0 {
+		return errors.New("no object has been started")
+	}
+
+	remaining := f.RemainingInObject()
+	if remaining > 0 {
+		return fmt.Errorf("%w: %d bytes remain unread", ErrObjectNotConsumed, remaining)
+	} else if remaining < 0 {
+		return fmt.Errorf("%w: read %d bytes past the end of the object", ErrObjectNotConsumed, -remaining)
+	}
+
+	f.at = nil
+	return nil
+}
+
+// SkipToObject skips the next `n` objects by reading the size field of each
+// and discarding the rest of the object, leaving the reader at the size field
+// of the object that follows. After `ReadIndex`, this positions the reader at
+// object `n`, counting from zero. Unlike `FindObject` and `ReadObjectOffsets`,
+// which use an object key table, this requires no table and no seeking, but
+// it reads every preceding object. An `io.EOF` error is returned if the
+// objects end before object `n`.
+func (f *rsfReader) SkipToObject(buf *bufio.Reader, n int) error {
+	for i := 0; i < n; i++ {
+		_, err := f.BeginObject(buf)
+		if err != nil {
+			return err
+		}
+		err = f.SkipObject(buf)
+		if err != nil {
+			return fmt.Errorf("error skipping object %d: %w", i, unexpectedEOF(err))
+		}
+	}
+	f.objectEnd = 0
+	return nil
+}
@@ -0,0 +1,226 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ReaderObjectSuite struct {
+	suite.Suite
+}
+
+func TestReaderObjectSuite(t *testing.T) {
+	suite.Run(t, &ReaderObjectSuite{})
+}
+
+func (s *ReaderObjectSuite) TestRemainingInObject() {
+	buf := bufio.NewReader(getData(&s.Suite))
+	r := NewReader()
+
+	// No object started.
+	s.Assert().Equal(0, r.RemainingInObject())
+
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+
+	// Record should be 132 bytes in length. The size field has been read.
+	sz, err := r.BeginObject(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(132, sz)
+	s.Assert().Equal(128, r.RemainingInObject())
+
+	// Reading `company` consumes 9 bytes.
+	err = r.AdvanceTo(buf, "company")
+	s.Require().Nil(err)
+	_, err = r.ReadStringField(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(119, r.RemainingInObject())
+
+	// Skip the `ready` and `list` fields, which consume 1+100 bytes.
+	err = r.AdvanceTo(buf, "age")
+	s.Require().Nil(err)
+	s.Assert().Equal(18, r.RemainingInObject())
+	_, err = r.ReadIntField(buf)
+	s.Require().Nil(err)
+	err = r.AdvanceTo(buf, "rating")
+	s.Require().Nil(err)
+	_, err = r.ReadFloatField(buf)
+	s.Require().Nil(err)
+
+	// At the end of the object.
+	s.Assert().Equal(0, r.RemainingInObject())
+
+	// No more objects.
+	_, err = r.BeginObject(buf)
+	s.Assert().ErrorIs(err, io.EOF)
+}
+
+func (s *ReaderObjectSuite) TestEndObjectStrict() {
+	buf := bufio.NewReader(getData(&s.Suite))
+	r := NewReader()
+	s.Assert().EqualError(r.EndObjectStrict(), "no object has been started")
+
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	_, err = r.BeginObject(buf)
+	s.Require().Nil(err)
+
+	// Skip the `rating` field by not reading it. The 8 bytes of the float
+	// are reported.
+	s.Require().Nil(r.AdvanceTo(buf, "age"))
+	_, err = r.ReadIntField(buf)
+	s.Require().Nil(err)
+	err = r.EndObjectStrict()
+	s.Assert().ErrorIs(err, ErrObjectNotConsumed)
+	s.Assert().EqualError(err, "the object was not read exactly: 8 bytes remain unread")
+
+	// Nothing was discarded, so the object can be finished.
+	_, err = r.ReadFloatField(buf)
+	s.Require().Nil(err)
+	s.Assert().Nil(r.EndObjectStrict())
+
+	// Reading into the next object is reported as well.
+	buf = bufio.NewReader(getComplexData(&s.Suite))
+	_, err = r.ReadIndex(buf)
+	s.Require().Nil(err)
+	_, err = r.BeginObject(buf)
+	s.Require().Nil(err)
+	s.Require().Nil(r.SkipObject(buf))
+	_, err = r.ReadIntField(buf)
+	s.Require().Nil(err)
+	err = r.EndObjectStrict()
+	s.Assert().ErrorIs(err, ErrObjectNotConsumed)
+	s.Assert().EqualError(err, "the object was not read exactly: read 10 bytes past the end of the object")
+}
+
+func (s *ReaderObjectSuite) TestSkipToObject() {
+	data := getComplexData(&s.Suite).Bytes()
+	r := NewReader()
+	buf := bufio.NewReader(bytes.NewReader(data))
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+
+	// Skip to the second object and read its name.
+	s.Require().Nil(r.SkipToObject(buf, 1))
+	s.Assert().Equal(0, r.RemainingInObject())
+	_, err = r.BeginObject(buf)
+	s.Require().Nil(err)
+	s.Require().Nil(r.AdvanceTo(buf, "cname"))
+	cname, err := r.ReadStringField(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal("django", cname)
+
+	// Skipping no objects leaves the reader at the first object.
+	buf = bufio.NewReader(bytes.NewReader(data))
+	_, err = r.ReadIndex(buf)
+	s.Require().Nil(err)
+	s.Require().Nil(r.SkipToObject(buf, 0))
+	var rec FullPackageRecordPyPI
+	s.Require().Nil(r.Unmarshal(buf, &rec))
+	s.Assert().Equal("numpy", rec.CanonicalName)
+
+	// Both objects can be skipped, but there is no third object.
+	buf = bufio.NewReader(bytes.NewReader(data))
+	_, err = r.ReadIndex(buf)
+	s.Require().Nil(err)
+	s.Require().Nil(r.SkipToObject(buf, 2))
+	s.Assert().ErrorIs(r.SkipToObject(buf, 1), io.EOF)
+
+	// Objects that are cut short are an error.
+	buf = bufio.NewReader(bytes.NewReader(data[:len(data)-10]))
+	_, err = r.ReadIndex(buf)
+	s.Require().Nil(err)
+	s.Assert().ErrorIs(r.SkipToObject(buf, 2), io.ErrUnexpectedEOF)
+}
+
+func (s *ReaderObjectSuite) TestNextObject() {
+	buf := bufio.NewReader(getComplexData(&s.Suite))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+
+	// Read part of each object. The unread remainder of each object is
+	// skipped, and `AdvanceTo` starts from the beginning of each object.
+	var authors []string
+	for {
+		err = r.NextObject(buf)
+		if err == io.EOF {
+			break
+		}
+		s.Require().Nil(err)
+		s.Require().Nil(r.AdvanceTo(buf, "author"))
+		author, err := r.ReadStringField(buf)
+		s.Require().Nil(err)
+		authors = append(authors, author)
+	}
+	s.Assert().Equal([]string{testComplexData[0].Author, testComplexData[1].Author}, authors)
+}
+
+func (s *ReaderObjectSuite) TestNextObjectUnmarshal() {
+	buf := bufio.NewReader(getComplexData(&s.Suite))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+
+	var recs []FullPackageRecordPyPI
+	for {
+		err = r.NextObject(buf)
+		if err == io.EOF {
+			break
+		}
+		s.Require().Nil(err)
+
+		// After `NextObject`, `Unmarshal` doesn't read the size field again.
+		var rec FullPackageRecordPyPI
+		s.Require().Nil(r.Unmarshal(buf, &rec))
+		recs = append(recs, rec)
+	}
+	s.Require().Len(recs, len(testComplexData))
+	for i, rec := range recs {
+		s.Assert().Equal(testComplexData[i].CanonicalName, rec.CanonicalName)
+		s.Assert().Equal(testComplexData[i].Classifiers, rec.Classifiers)
+		s.Assert().Equal(testComplexData[i].Popularity, rec.Popularity)
+		s.Assert().Len(rec.Snapshots, len(testComplexData[i].Snapshots))
+	}
+}
+
+func BenchmarkNextObjectUnmarshal(b *testing.B) {
+	data := &bytes.Buffer{}
+	w := NewWriterWithVersion(data, Version2)
+	for i := 0; i < 1000; i++ {
+		_, err := w.WriteObject(testComplexData[i%len(testComplexData)])
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf := bufio.NewReader(bytes.NewReader(data.Bytes()))
+		r := NewReader()
+		_, err := r.ReadIndex(buf)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		var rec FullPackageRecordPyPI
+		for {
+			err = r.NextObject(buf)
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				b.Fatal(err)
+			}
+			err = r.Unmarshal(buf, &rec)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
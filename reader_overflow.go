@@ -0,0 +1,109 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// overflowRef is a pointer to a string in the overflow region of the current
+// object that is resolved when the object's other fields have been read.
+type overflowRef struct {
+	offset int
+	length int
+	set    func(s string) error
+}
+
+// ReadOverflowStringField reads a string field tagged `overflow`. The field
+// holds a pointer to the string in the object's overflow region, so the
+// string is read by seeking to it and then seeking back to the end of the
+// field. The object must have been started with `BeginObject`, and the
+// position of `r` must match the reader position.
+func (f *rsfReader) ReadOverflowStringField(r io.ReadSeeker) (string, error) {
+	offset, err := f.ReadSizeField(r)
+	if err != nil {
+		return "", err
+	}
+	length, err := f.ReadSizeField(r)
+	if err != nil {
+		return "", err
+	}
+	err = f.checkOverflow(offset, length)
+	if err != nil {
+		return "", err
+	}
+
+	pos := f.pos
+	_, err = r.Seek(f.objectStart+int64(offset), io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+	bs, err := readBytes(r, length)
+	if err != nil {
+		return "", err
+	}
+	_, err = r.Seek(pos, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
+
+// readOverflowRef reads a pointer to a string in the overflow region. The
+// string is passed to `set` when the overflow region is read. See
+// `readOverflow`.
+func (f *rsfReader) readOverflowRef(r io.Reader, set func(s string) error) error {
+	offset, err := f.ReadSizeField(r)
+	if err != nil {
+		return err
+	}
+	length, err := f.ReadSizeField(r)
+	if err != nil {
+		return err
+	}
+	err = f.checkOverflow(offset, length)
+	if err != nil {
+		return err
+	}
+	f.overflow = append(f.overflow, overflowRef{offset: offset, length: length, set: set})
+	return nil
+}
+
+// readOverflow reads the strings referenced with `readOverflowRef` from the
+// overflow region. The reader must be positioned after the object's other
+// fields.
+func (f *rsfReader) readOverflow(buf *bufio.Reader) error {
+	for _, ref := range f.overflow {
+		skip := int(f.objectStart + int64(ref.offset) - f.pos)
+		if skip < 0 {
+			return fmt.Errorf("overflow offset %d precedes the reader position", ref.offset)
+		}
+		err := f.Discard(skip, buf)
+		if err != nil {
+			return err
+		}
+		s, err := f.ReadFixedStringField(ref.length, buf)
+		if err != nil {
+			return err
+		}
+		err = ref.set(s)
+		if err != nil {
+			return err
+		}
+	}
+	f.overflow = nil
+	return nil
+}
+
+// checkOverflow returns an error if a string with the given offset and length
+// is not within the current object.
+func (f *rsfReader) checkOverflow(offset, length int) error {
+	if f.objectEnd == 0 {
+		return fmt.Errorf("overflow strings can only be read in an object started with BeginObject")
+	}
+	if offset < sizeFieldLen || f.objectStart+int64(offset)+int64(length) > f.objectEnd {
+		return fmt.Errorf("overflow string offset %d and length %d exceed the object size %d", offset, length, f.objectEnd-f.objectStart)
+	}
+	return nil
+}
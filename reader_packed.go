@@ -0,0 +1,251 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+)
+
+var ErrNotPackedArray = errors.New("field is not a packed array")
+
+// SeekToArrayElement seeks to the start of element `n` of the packed array
+// indicated by `fieldNames`. Since each element of a packed array is the same
+// size, the element offset is computed from the record size without reading
+// the preceding elements. The reader must be positioned at the start of the
+// array (e.g., with `AdvanceTo` or `Seek`), and the position of `r` must match
+// the reader position.
+//
+// When complete, the reader is positioned at the start of the element, so
+// subfields can be read with `AdvanceTo`.
+func (f *rsfReader) SeekToArrayElement(r io.ReadSeeker, n int, fieldNames ...string) error {
+	if len(fieldNames) == 0 {
+		return ErrNoSuchField
+	}
+	entries, pos, err := entrySet(f.index, fieldNames...)
+	if err != nil {
+		return err
+	}
+	entry := entries[pos]
+	if entry.FieldType != FieldTypePackedArray {
+		return ErrNotPackedArray
+	}
+
+	// Read the array size and length.
+	_, err = f.ReadSizeField(r)
+	if err != nil {
+		return err
+	}
+	arrayLen, err := f.ReadSizeField(r)
+	if err != nil {
+		return err
+	}
+	if entry.FieldSize <= 0 {
+		return fmt.Errorf("invalid packed record size %d", entry.FieldSize)
+	}
+	if n < 0 || n >= arrayLen {
+		return fmt.Errorf("array element %d out of range; array length is %d", n, arrayLen)
+	}
+
+	// The elements follow the array index, if any.
+	base := f.pos
+	if entry.Indexed {
+		base += int64(arrayLen) * int64(entry.IndexSize)
+	}
+
+	at := make([]string, 0, len(fieldNames)+1)
+	at = append(at, fieldNames...)
+	return f.seek(base+int64(n)*int64(entry.FieldSize), r, append(at, Top)...)
+}
+
+// ReadPackedInt32Slice reads a packed array of `int32` values (see
+// `writePackedNumbers`). The elements are read all at once.
+func (f *rsfReader) ReadPackedInt32Slice(r io.Reader) ([]int32, error) {
+	bs, n, err := f.readPackedBlock(sizeInt32, r)
+	if err != nil {
+		return nil, err
+	}
+	vals := make([]int32, n)
+	for i := range vals {
+		vals[i] = int32(binary.LittleEndian.Uint32(bs[i*sizeInt32:]))
+	}
+	return vals, nil
+}
+
+// ReadPackedInt64Slice reads a packed array of `int64` or `int` values. The
+// elements are read all at once.
+func (f *rsfReader) ReadPackedInt64Slice(r io.Reader) ([]int64, error) {
+	bs, n, err := f.readPackedBlock(sizeFixedInt64, r)
+	if err != nil {
+		return nil, err
+	}
+	vals := make([]int64, n)
+	for i := range vals {
+		vals[i] = int64(binary.LittleEndian.Uint64(bs[i*sizeFixedInt64:]))
+	}
+	return vals, nil
+}
+
+// ReadPackedFloat32Slice reads a packed array of `float32` values. The
+// elements are read all at once.
+func (f *rsfReader) ReadPackedFloat32Slice(r io.Reader) ([]float32, error) {
+	bs, n, err := f.readPackedBlock(sizeFloat32, r)
+	if err != nil {
+		return nil, err
+	}
+	vals := make([]float32, n)
+	for i := range vals {
+		vals[i] = math.Float32frombits(binary.LittleEndian.Uint32(bs[i*sizeFloat32:]))
+	}
+	return vals, nil
+}
+
+// ReadPackedFloat64Slice reads a packed array of `float64` values. The
+// elements are read all at once.
+func (f *rsfReader) ReadPackedFloat64Slice(r io.Reader) ([]float64, error) {
+	bs, n, err := f.readPackedBlock(sizeFloat64, r)
+	if err != nil {
+		return nil, err
+	}
+	vals := make([]float64, n)
+	for i := range vals {
+		vals[i] = math.Float64frombits(binary.LittleEndian.Uint64(bs[i*sizeFloat64:]))
+	}
+	return vals, nil
+}
+
+// readPackedBlock reads the size and length of a packed array of numbers with
+// elements of `width` bytes, followed by the elements, and returns the element
+// bytes and the array length.
+func (f *rsfReader) readPackedBlock(width int, r io.Reader) ([]byte, int, error) {
+	arraySz, err := f.ReadSizeField(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	arrayLen, err := f.ReadSizeField(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	blockSz := arraySz - sizeFieldLen - sizeFieldLen
+	if arrayLen < 0 || blockSz < 0 || blockSz/width != arrayLen || blockSz%width != 0 {
+		return nil, 0, fmt.Errorf("array size %d does not match %d elements of %d bytes", arraySz, arrayLen, width)
+	}
+	bs, err := readBytes(r, blockSz)
+	if err != nil {
+		return nil, 0, unexpectedEOF(err)
+	}
+	f.pos += int64(blockSz)
+	return bs, arrayLen, nil
+}
+
+// packedElement returns the entry used to read each element of the packed
+// array of numbers described by `entry`, or a zero entry if `entry` is not a
+// packed array of numbers.
+func packedElement(entry IndexEntry) IndexEntry {
+	if entry.FieldType != FieldTypePackedArray || entry.FieldSize != sizeInt32 && entry.FieldSize != sizeFixedInt64 {
+		return IndexEntry{}
+	}
+	switch reflect.Kind(entry.SubfieldType) {
+	case reflect.Int, reflect.Int64, reflect.Int32:
+		return IndexEntry{FieldType: FieldTypeFixedInt64, FieldSize: entry.FieldSize}
+	case reflect.Float32, reflect.Float64:
+		return IndexEntry{FieldType: FieldTypeFloat, FieldSize: entry.FieldSize}
+	default:
+		return IndexEntry{}
+	}
+}
+
+// readFixedInt reads a fixed-width integer described by `entry`. Elements of
+// packed arrays of `int32` values are 4 bytes wide, and big-endian integers
+// are the width of the field size.
+func (f *rsfReader) readFixedInt(entry IndexEntry, r io.Reader) (int64, error) {
+	if entry.FieldSize != sizeInt32 && entry.FieldType != FieldTypeBigEndianInt {
+		return f.ReadFixedIntField(r)
+	}
+	bs, err := f.readNumber(entry, r)
+	if err != nil {
+		return 0, err
+	}
+	if len(bs) == sizeInt32 {
+		return int64(int32(byteOrder(entry).Uint32(bs))), nil
+	}
+	return int64(byteOrder(entry).Uint64(bs)), nil
+}
+
+// readFloat reads a float described by `entry`. Elements of packed arrays of
+// `float32` values are 4 bytes wide, and big-endian floats are the width of the
+// field size.
+func (f *rsfReader) readFloat(entry IndexEntry, r io.Reader) (float64, error) {
+	if entry.FieldSize != sizeFloat32 && entry.FieldType != FieldTypeBigEndianFloat {
+		return f.ReadFloatField(r)
+	}
+	bs, err := f.readNumber(entry, r)
+	if err != nil {
+		return 0, err
+	}
+	if len(bs) == sizeFloat32 {
+		return float64(math.Float32frombits(byteOrder(entry).Uint32(bs))), nil
+	}
+	return math.Float64frombits(byteOrder(entry).Uint64(bs)), nil
+}
+
+// byteOrder returns the byte order of the number described by `entry`. Fields
+// tagged `be` are big-endian, and all other numbers are little-endian.
+func byteOrder(entry IndexEntry) binary.ByteOrder {
+	switch entry.FieldType {
+	case FieldTypeBigEndianInt, FieldTypeBigEndianFloat:
+		return binary.BigEndian
+	default:
+		return binary.LittleEndian
+	}
+}
+
+// readNumber reads the bytes of a number of the width of the field size of
+// `entry`.
+func (f *rsfReader) readNumber(entry IndexEntry, r io.Reader) ([]byte, error) {
+	bs := make([]byte, entry.FieldSize)
+	i, err := io.ReadFull(r, bs)
+	if err != nil {
+		return nil, err
+	}
+	f.pos += int64(i)
+	return bs, nil
+}
+
+// readPackedNumbers reads the `arrayLen` elements of a packed array of numbers,
+// described by the element entry `el`, into the slice or array `v`. The
+// elements are read all at once.
+func (f *rsfReader) readPackedNumbers(el IndexEntry, arrayLen int, v reflect.Value, r io.Reader) error {
+	bs, err := readBytes(r, arrayLen*el.FieldSize)
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	f.pos += int64(len(bs))
+
+	if v.Kind() == reflect.Slice && v.Cap() < arrayLen {
+		v.Set(reflect.MakeSlice(v.Type(), arrayLen, arrayLen))
+	} else if v.Kind() == reflect.Slice {
+		v.SetLen(arrayLen)
+	}
+
+	for i := 0; i < arrayLen; i++ {
+		b := bs[i*el.FieldSize:]
+		switch {
+		case el.FieldType == FieldTypeFixedInt64 && el.FieldSize == sizeInt32:
+			err = setInt(v.Index(i), int64(int32(binary.LittleEndian.Uint32(b))))
+		case el.FieldType == FieldTypeFixedInt64:
+			err = setInt(v.Index(i), int64(binary.LittleEndian.Uint64(b)))
+		case el.FieldSize == sizeFloat32:
+			err = setFloat(v.Index(i), float64(math.Float32frombits(binary.LittleEndian.Uint32(b))))
+		default:
+			err = setFloat(v.Index(i), math.Float64frombits(binary.LittleEndian.Uint64(b)))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// splitPath splits a dotted field path (e.g., "list.name") into field names.
+// Empty segments, like those from a trailing dot, are ignored.
+func splitPath(path string) []string {
+	fieldNames := make([]string, 0)
+	for _, name := range strings.Split(path, rsfPathSep) {
+		if name != "" {
+			fieldNames = append(fieldNames, name)
+		}
+	}
+	return fieldNames
+}
+
+// AdvanceToPath advances the reader to the field indicated by the dotted
+// field path `path`, like `AdvanceTo`. For example, "list.name" is equivalent
+// to `AdvanceTo(buf, "list", "name")`. Empty segments are ignored, so
+// "list..name" and "list.name." are also equivalent.
+func (f *rsfReader) AdvanceToPath(buf *bufio.Reader, path string) error {
+	fieldNames := splitPath(path)
+	if len(fieldNames) == 0 {
+		return ErrNoSuchField
+	}
+	return f.AdvanceTo(buf, fieldNames...)
+}
+
+// ReadFieldPath advances to the field indicated by the dotted field path
+// `path` (see `AdvanceToPath`) and reads its value. Values are returned with the
+// types used by `DecodeObject`.
+func (f *rsfReader) ReadFieldPath(buf *bufio.Reader, path string) (any, error) {
+	fieldNames := splitPath(path)
+	if len(fieldNames) == 0 {
+		return nil, ErrNoSuchField
+	}
+	entries, pos, err := entrySet(f.index, fieldNames...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = f.AdvanceTo(buf, fieldNames...)
+	if err != nil {
+		return nil, err
+	}
+	val, err := f.decodeValue(entries[pos], buf)
+	if err != nil {
+		return nil, fmt.Errorf("error reading field %s: %w", strings.Join(fieldNames, rsfPathSep), err)
+	}
+	return val, nil
+}
@@ -0,0 +1,83 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"fmt"
+)
+
+// SetProjection restricts the reader to `projection`, a partial index of the
+// fields known to the consumer, like a plugin that understands only some of a
+// file's fields. The file's full index, read with `ReadIndex` or supplied with
+// `SetIndex`, is still used to skip the other fields, but they are hidden:
+// `AdvanceTo` and related methods return `ErrNoSuchField` for them, and
+// `DecodeObject`, `ReadScalars`, `ObjectToJSON`, `Unmarshal`, and `Keys` skip
+// them.
+//
+// Each projected field must match the type and size of the field of the same
+// name in the file's index. For arrays of structs, the projection may list a
+// subset of the subfields; an array with no subfields in the projection
+// exposes all of its subfields. A nil projection exposes all fields. The
+// projection is cleared when an index is read or set.
+func (f *rsfReader) SetProjection(projection Index) error {
+	index, err := project(f.index, projection, "")
+	if err != nil {
+		return err
+	}
+	f.index = index
+	f.plans = nil
+	return nil
+}
+
+// project returns a copy of `index` with the fields that are not in
+// `projection` hidden. The `prefix` is the path of the enclosing array, if
+// any.
+func project(index, projection Index, prefix string) (Index, error) {
+	path := func(name string) string {
+		if prefix == "" {
+			return name
+		}
+		return prefix + rsfPathSep + name
+	}
+
+	projected := make(map[string]IndexEntry, len(projection))
+	for _, entry := range projection {
+		projected[entry.FieldName] = entry
+	}
+
+	out := make(Index, len(index))
+	for n, entry := range index {
+		p, ok := projected[entry.FieldName]
+		delete(projected, entry.FieldName)
+
+		// The bool bitmap is needed to read any packed bools.
+		entry.hidden = !ok && projection != nil && entry.FieldType != FieldTypeBoolBitmap
+		if ok {
+			if p.FieldType != entry.FieldType {
+				return nil, withField(path(p.FieldName), fmt.Errorf("type %s does not match the file type %s", typeTag(p.FieldType), typeTag(entry.FieldType)))
+			}
+			if p.FieldSize != entry.FieldSize {
+				return nil, withField(path(p.FieldName), fmt.Errorf("size %d does not match the file size %d", p.FieldSize, entry.FieldSize))
+			}
+		}
+
+		if len(entry.Subfields) > 0 {
+			var subfields Index
+			if ok && len(p.Subfields) > 0 {
+				subfields = p.Subfields
+			}
+			var err error
+			entry.Subfields, err = project(entry.Subfields, subfields, path(entry.FieldName))
+			if err != nil {
+				return nil, err
+			}
+		}
+		out[n] = entry
+	}
+
+	for _, entry := range projection {
+		if _, ok := projected[entry.FieldName]; ok {
+			return nil, withField(path(entry.FieldName), ErrNoSuchField)
+		}
+	}
+	return out, nil
+}
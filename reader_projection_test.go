@@ -0,0 +1,136 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ReaderProjectionSuite struct {
+	suite.Suite
+}
+
+func TestReaderProjectionSuite(t *testing.T) {
+	suite.Run(t, &ReaderProjectionSuite{})
+}
+
+// pluginIndex is the partial index of a plugin that knows only the `company`
+// and `rating` fields.
+var pluginIndex = Index{
+	{FieldName: "company", FieldType: FieldTypeVarStr},
+	{FieldName: "rating", FieldType: FieldTypeFloat},
+}
+
+func (s *ReaderProjectionSuite) TestAdvance() {
+	buf := bufio.NewReader(getData(&s.Suite))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	s.Require().Nil(r.SetProjection(pluginIndex))
+	_, err = r.BeginObject(buf)
+	s.Require().Nil(err)
+
+	s.Require().Nil(r.AdvanceTo(buf, "company"))
+	company, err := r.ReadStringField(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal("posit", company)
+
+	// Hidden fields cannot be read.
+	s.Assert().ErrorIs(r.AdvanceTo(buf, "list"), ErrNoSuchField)
+	s.Assert().ErrorIs(r.AdvanceTo(buf, "age"), ErrNoSuchField)
+
+	// The `list` array and the other fields are skipped using the file's
+	// index.
+	s.Require().Nil(r.AdvanceTo(buf, "rating"))
+	rating, err := r.ReadFloatField(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(92.689, rating)
+}
+
+func (s *ReaderProjectionSuite) TestDecode() {
+	buf := bufio.NewReader(getData(&s.Suite))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	s.Require().Nil(r.SetProjection(pluginIndex))
+
+	obj, err := r.DecodeObject(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(map[string]any{
+		"company": "posit",
+		"rating":  92.689,
+	}, obj)
+}
+
+func (s *ReaderProjectionSuite) TestUnmarshal() {
+	buf := bufio.NewReader(getData(&s.Suite))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	s.Require().Nil(r.SetProjection(pluginIndex))
+
+	// Fields that the plugin doesn't know are not set, even when the
+	// struct includes them.
+	var rec struct {
+		Company string  `rsf:"company"`
+		Age     int     `rsf:"age"`
+		Rating  float64 `rsf:"rating"`
+	}
+	s.Require().Nil(r.Unmarshal(buf, &rec))
+	s.Assert().Equal("posit", rec.Company)
+	s.Assert().Equal(0, rec.Age)
+	s.Assert().Equal(92.689, rec.Rating)
+}
+
+func (s *ReaderProjectionSuite) TestSubfields() {
+	buf := bufio.NewReader(getData(&s.Suite))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	s.Require().Nil(r.SetProjection(Index{
+		{
+			FieldName:    "list",
+			FieldType:    FieldTypeArray,
+			SubfieldType: 25,
+			Subfields: Index{
+				{FieldName: "verified", FieldType: FieldTypeBool},
+			},
+		},
+	}))
+
+	obj, err := r.DecodeObject(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(map[string]any{
+		"list": []any{
+			map[string]any{IndexKeyField: "2020-10-01", "verified": false},
+			map[string]any{IndexKeyField: "2021-03-21", "verified": true},
+			map[string]any{IndexKeyField: "2022-12-15", "verified": true},
+		},
+	}, obj)
+}
+
+func (s *ReaderProjectionSuite) TestInvalid() {
+	buf := bufio.NewReader(getData(&s.Suite))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+
+	err = r.SetProjection(Index{{FieldName: "rating", FieldType: FieldTypeInt64}})
+	s.Assert().EqualError(err, "field rating: type i64 does not match the file type f64")
+	err = r.SetProjection(Index{{FieldName: "nothere", FieldType: FieldTypeVarStr}})
+	s.Assert().ErrorIs(err, ErrNoSuchField)
+	err = r.SetProjection(Index{{
+		FieldName: "list",
+		FieldType: FieldTypeArray,
+		Subfields: Index{{FieldName: "nothere", FieldType: FieldTypeVarStr}},
+	}})
+	s.Assert().EqualError(err, "field list.nothere: field not found")
+
+	// A nil projection exposes all fields.
+	s.Require().Nil(r.SetProjection(nil))
+	obj, err := r.DecodeObject(buf)
+	s.Require().Nil(err)
+	s.Assert().Len(obj, 5)
+}
@@ -0,0 +1,69 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"fmt"
+	"io"
+)
+
+// SchemaID returns the schema id of the current object in a multi-schema file
+// written by `NewWriterWithSchemas`, which is available once the object has
+// begun (e.g., with `BeginObject` or `NextObject`). The reader uses the index
+// of the object's schema until the next object begins, so callers can
+// dispatch on the id and then read the object with `Unmarshal` or the like.
+// `DecodeObject` also records the id with the `SchemaIDField` key. Zero is
+// returned for other files.
+func (f *rsfReader) SchemaID() int {
+	return f.schema
+}
+
+// Schemas returns the indexes of a multi-schema file, by schema id, or nil for
+// other files.
+func (f *rsfReader) Schemas() []Index {
+	return f.schemas
+}
+
+// readSchemas reads the indexes that follow the first index in a multi-schema
+// file.
+func (f *rsfReader) readSchemas(r io.Reader) error {
+	count, err := f.ReadSizeField(r)
+	if err != nil {
+		return err
+	}
+	if count < 1 {
+		return fmt.Errorf("invalid schema count %d", count)
+	}
+
+	f.schemas = []Index{f.index}
+	for i := 1; i < count; i++ {
+		sz, err := f.ReadSizeField(r)
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if sz < sizeFieldLen {
+			return fmt.Errorf("invalid index size %d for schema %d", sz, i)
+		}
+		finalPos := f.pos + int64(sz) - sizeFieldLen
+		index, err := f.readIndexEntries(io.LimitReader(r, int64(sz-sizeFieldLen)), finalPos, 0, 0)
+		if err != nil {
+			return fmt.Errorf("schema %d: %s", i, indexReadError(err, sz))
+		}
+		f.schemas = append(f.schemas, index)
+	}
+	return nil
+}
+
+// beginSchema reads the schema id at the start of an object in a multi-schema
+// file, and selects the index of the schema.
+func (f *rsfReader) beginSchema(r io.Reader) error {
+	id, err := f.ReadSizeField(r)
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	if id >= len(f.schemas) {
+		return fmt.Errorf("invalid schema id %d", id)
+	}
+	f.schema = id
+	f.index = f.schemas[id]
+	return nil
+}
@@ -0,0 +1,55 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// FieldSizeStats reads every object in the RSF data in `r` and returns the
+// total number of bytes used to encode each top-level field, by field name,
+// across all objects. The size of an array includes its size fields, its
+// array index, and all of its elements. Bytes that follow the fields of an
+// object, like overflow strings and padding, and strings stored in a string
+// table, are not attributed to any field.
+//
+// This is useful for finding the fields that dominate the size of a file,
+// which are candidates for compression or overflow strings.
+func FieldSizeStats(r *bufio.Reader) (map[string]int64, error) {
+	reader := &rsfReader{}
+	index, err := reader.ReadIndex(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading index: %s", err)
+	}
+
+	stats := make(map[string]int64)
+	for i := 0; ; i++ {
+		_, err = reader.BeginObject(r)
+		if err == io.EOF {
+			return stats, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("error reading object %d: %s", i, err)
+		}
+
+		// Objects in multi-schema files are measured with their own index.
+		fields := index
+		if schemas := reader.Schemas(); schemas != nil {
+			fields = schemas[reader.SchemaID()]
+		}
+
+		for _, entry := range fields {
+			start := reader.pos
+			err = reader.advance(entry, r)
+			if err != nil {
+				return nil, fmt.Errorf("error reading object %d: %s", i, withField(entry.FieldName, unexpectedEOF(err)))
+			}
+			stats[entry.FieldName] += reader.pos - start
+		}
+
+		err = reader.SkipObject(r)
+		if err != nil {
+			return nil, fmt.Errorf("error reading object %d: %s", i, err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ReaderStatsSuite struct {
+	suite.Suite
+}
+
+func TestReaderStatsSuite(t *testing.T) {
+	suite.Run(t, &ReaderStatsSuite{})
+}
+
+func (s *ReaderStatsSuite) TestFieldSizeStats() {
+	data := getComplexData(&s.Suite).Bytes()
+
+	stats, err := FieldSizeStats(bufio.NewReader(bytes.NewReader(data)))
+	s.Require().Nil(err)
+	s.Assert().Len(stats, 7)
+
+	// Scalar fields are the sum of their encoded sizes.
+	s.Assert().Equal(int64(sizeInt64*len(testComplexData)), stats["popularity"])
+	var cname int64
+	for _, obj := range testComplexData {
+		cname += int64(sizeFieldLen + len(obj.CanonicalName))
+	}
+	s.Assert().Equal(cname, stats["cname"])
+
+	// Arrays are the sum of their array sizes.
+	r := NewReader()
+	buf := bufio.NewReader(bytes.NewReader(data))
+	_, err = r.ReadIndex(buf)
+	s.Require().Nil(err)
+	var snapshots int64
+	for range testComplexData {
+		_, err = r.BeginObject(buf)
+		s.Require().Nil(err)
+		s.Require().Nil(r.AdvanceTo(buf, "snapshots"))
+		sz, err := r.ReadSizeField(buf)
+		s.Require().Nil(err)
+		snapshots += int64(sz)
+		s.Require().Nil(r.SkipObject(buf))
+	}
+	s.Assert().Equal(snapshots, stats["snapshots"])
+
+	// The fields account for all the bytes of the objects except their size
+	// fields, and the snapshots dominate the objects.
+	var total int64
+	for _, sz := range stats {
+		total += sz
+	}
+	s.Assert().Equal(r.Pos()-s.objectsStart(data), total+int64(sizeFieldLen*len(testComplexData)))
+	s.Assert().Greater(float64(stats["snapshots"])/float64(total), 0.5)
+}
+
+func (s *ReaderStatsSuite) TestFieldSizeStatsTruncated() {
+	data := getComplexData(&s.Suite).Bytes()
+	_, err := FieldSizeStats(bufio.NewReader(bytes.NewReader(data[:len(data)-20])))
+	s.Assert().NotNil(err)
+}
+
+// objectsStart returns the position of the first object in `data`.
+func (s *ReaderStatsSuite) objectsStart(data []byte) int64 {
+	r := NewReader()
+	_, err := r.ReadIndex(bufio.NewReader(bytes.NewReader(data)))
+	s.Require().Nil(err)
+	return r.Pos()
+}
@@ -0,0 +1,47 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"fmt"
+	"io"
+)
+
+// readStringTable reads the string table that follows the index. See
+// writer_strings.go for the table format.
+func (f *rsfReader) readStringTable(r io.Reader) error {
+	_, err := f.ReadSizeField(r)
+	if err != nil {
+		return err
+	}
+	count, err := f.ReadSizeField(r)
+	if err != nil {
+		return err
+	}
+
+	// Read the strings before assigning `f.strings` since `ReadStringField`
+	// resolves table indexes once the table is set.
+	strings := make([]string, 0)
+	for i := 0; i < count; i++ {
+		var s string
+		s, err = f.ReadStringField(r)
+		if err != nil {
+			return err
+		}
+		strings = append(strings, s)
+	}
+	f.strings = strings
+	return nil
+}
+
+// readStringRef reads a 4-byte string table index and returns the
+// referenced string.
+func (f *rsfReader) readStringRef(r io.Reader) (string, error) {
+	id, err := f.ReadSizeField(r)
+	if err != nil {
+		return "", err
+	}
+	if id < 0 || id >= len(f.strings) {
+		return "", fmt.Errorf("string table index %d out of range; table has %d strings", id, len(f.strings))
+	}
+	return f.strings[id], nil
+}
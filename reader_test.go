@@ -4,7 +4,10 @@ package rsf
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"io"
+	"math"
+	"net/netip"
 	"os"
 	"reflect"
 	"testing"
@@ -26,7 +29,7 @@ func (s *ReaderSuite) TestNewReader() {
 
 // This method returns the same data used by `TestWriteObjectWithArrayIndex`
 // in `writer_test.go`.
-func getData(s suite.Suite) *bytes.Buffer {
+func getData(s *suite.Suite) *bytes.Buffer {
 	buf := &bytes.Buffer{}
 	w := NewWriterWithVersion(buf, Version2)
 
@@ -81,13 +84,13 @@ func getData(s suite.Suite) *bytes.Buffer {
 }
 
 func (s *ReaderSuite) TestRead() {
-	buf := bufio.NewReader(getData(s.Suite))
+	buf := bufio.NewReader(getData(&s.Suite))
 	r := NewReader()
 
 	// Read the index
 	index, err := r.ReadIndex(buf)
 	s.Assert().Nil(err)
-	s.Assert().Equal(117, r.Pos())
+	s.Assert().Equal(int64(117), r.Pos())
 
 	// Check the index
 	s.Assert().Equal(Index{
@@ -142,7 +145,7 @@ func (s *ReaderSuite) TestRead() {
 	s.Assert().Nil(err)
 	s.Assert().Equal(132, recordSz)
 	// Position increased by 4 (size field is 4 bytes)
-	s.Assert().Equal(121, r.Pos())
+	s.Assert().Equal(int64(121), r.Pos())
 
 	// Company
 	err = r.AdvanceTo(buf, "company")
@@ -151,7 +154,7 @@ func (s *ReaderSuite) TestRead() {
 	s.Assert().Nil(err)
 	s.Assert().Equal("posit", company)
 	// Position increased by 9. Size field is 4 bytes + data is 5 bytes.
-	s.Assert().Equal(130, r.Pos())
+	s.Assert().Equal(int64(130), r.Pos())
 
 	// Ready
 	err = r.AdvanceTo(buf, "ready")
@@ -160,7 +163,7 @@ func (s *ReaderSuite) TestRead() {
 	s.Assert().Nil(err)
 	s.Assert().True(ready)
 	// Position increased by 1
-	s.Assert().Equal(131, r.Pos())
+	s.Assert().Equal(int64(131), r.Pos())
 
 	// Array should be 100 bytes in size
 	err = r.AdvanceTo(buf, "list")
@@ -169,14 +172,14 @@ func (s *ReaderSuite) TestRead() {
 	s.Assert().Nil(err)
 	s.Assert().Equal(100, arraySz)
 	// Position increased by 4
-	s.Assert().Equal(135, r.Pos())
+	s.Assert().Equal(int64(135), r.Pos())
 
 	// Array should be 3 elements in length
 	arrayLen, err := r.ReadSizeField(buf)
 	s.Assert().Nil(err)
 	s.Assert().Equal(3, arrayLen)
 	// Position increased by 4
-	s.Assert().Equal(139, r.Pos())
+	s.Assert().Equal(int64(139), r.Pos())
 
 	// Array index. Read all three index entries
 	// Entry 1
@@ -206,13 +209,13 @@ func (s *ReaderSuite) TestRead() {
 	// a 10-byte fixed-length string and a 4-byte size field.
 	// 3*14=42
 	// 136+42=170
-	s.Assert().Equal(181, r.Pos())
+	s.Assert().Equal(int64(181), r.Pos())
 
 	// Discard 28 bytes (14+14) to move to the last array element.
 	err = r.Discard(28, buf)
 	s.Assert().Nil(err)
 	// Position increased by 28 to 178+28=206.
-	s.Assert().Equal(209, r.Pos())
+	s.Assert().Equal(int64(209), r.Pos())
 
 	// Read last array element's "Name" field.
 	err = r.AdvanceTo(buf, "list", "name")
@@ -223,7 +226,7 @@ func (s *ReaderSuite) TestRead() {
 	// Position increased by 4+17. String size uses 4 bytes and
 	// string value uses 17 bytes.
 	// 206+21=227
-	s.Assert().Equal(230, r.Pos())
+	s.Assert().Equal(int64(230), r.Pos())
 
 	// Read last array element's "Verified" field.
 	err = r.AdvanceTo(buf, "list", "verified")
@@ -232,7 +235,7 @@ func (s *ReaderSuite) TestRead() {
 	s.Assert().Nil(err)
 	s.Assert().True(verified)
 	// Position increased by 1.
-	s.Assert().Equal(231, r.Pos())
+	s.Assert().Equal(int64(231), r.Pos())
 
 	// Read age field
 	err = r.AdvanceTo(buf, "age")
@@ -256,14 +259,14 @@ func (s *ReaderSuite) TestRead() {
 	tmp, err := os.CreateTemp("", "")
 	s.Assert().Nil(err)
 	defer os.Remove(tmp.Name())
-	buf = bufio.NewReader(getData(s.Suite))
+	buf = bufio.NewReader(getData(&s.Suite))
 	_, err = io.Copy(tmp, buf)
 
 	// Seek back to the last array element.
 	err = r.Seek(209, tmp)
 	s.Assert().Nil(err)
 	// Position set to 209
-	s.Assert().Equal(209, r.Pos())
+	s.Assert().Equal(int64(209), r.Pos())
 
 	// Read last array element's "Name" field again from the temp file.
 	name, err = r.ReadStringField(tmp)
@@ -272,5 +275,389 @@ func (s *ReaderSuite) TestRead() {
 	// Position increased by 4+17. String size uses 4 bytes and
 	// string value uses 17 bytes.
 	// 209+21=230
-	s.Assert().Equal(230, r.Pos())
+	s.Assert().Equal(int64(230), r.Pos())
+}
+
+func (s *ReaderSuite) TestReadMalformed() {
+	r := NewReader()
+
+	// A string size much larger than the available data returns an error
+	// without allocating the full size.
+	_, err := r.ReadStringField(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff, 'a', 'b'}))
+	s.Assert().ErrorIs(err, io.ErrUnexpectedEOF)
+
+	// Negative fixed string sizes are rejected.
+	_, err = r.ReadFixedStringField(-1, bytes.NewReader([]byte{'a'}))
+	s.Assert().EqualError(err, "invalid read size -1")
+
+	// An index size smaller than the size field is rejected.
+	_, err = r.ReadIndex(bytes.NewReader([]byte{0x00, 0x08, 0x32, 0x02, 0x00, 0x00, 0x00}))
+	s.Assert().EqualError(err, "invalid index size 2")
+
+	// A subfield count that can't fit in the index is rejected.
+	buf := &bytes.Buffer{}
+	buf.Write(IndexVersion2)
+	w := NewWriter(buf)
+	_, err = w.WriteSizeField(0, 30, buf)
+	s.Require().Nil(err)
+	_, err = w.WriteStringField(0, "list", buf)
+	s.Require().Nil(err)
+	_, err = w.WriteSizeField(0, FieldTypeArray, buf)
+	s.Require().Nil(err)
+	_, err = w.WriteBoolField(0, false, buf)
+	s.Require().Nil(err)
+	_, err = w.WriteSizeField(0, int(reflect.Struct), buf)
+	s.Require().Nil(err)
+	_, err = w.WriteSizeField(0, 1000000, buf)
+	s.Require().Nil(err)
+	_, err = r.ReadIndex(buf)
+	s.Assert().EqualError(err, "subfield count 1000000 for field list exceeds the remaining index size")
+
+	// A subfield count that exceeds the subfields in the index is rejected,
+	// rather than reading the following top-level fields as subfields.
+	entries := &bytes.Buffer{}
+	_, err = w.WriteStringField(0, "list", entries)
+	s.Require().Nil(err)
+	_, err = w.WriteSizeField(0, FieldTypeArray, entries)
+	s.Require().Nil(err)
+	_, err = w.WriteBoolField(0, false, entries)
+	s.Require().Nil(err)
+	_, err = w.WriteSizeField(0, int(reflect.Struct), entries)
+	s.Require().Nil(err)
+	_, err = w.WriteSizeField(0, 3, entries)
+	s.Require().Nil(err)
+	for _, name := range []string{"date", "name"} {
+		_, err = w.WriteStringField(0, name, entries)
+		s.Require().Nil(err)
+		_, err = w.WriteSizeField(0, FieldTypeVarStr, entries)
+		s.Require().Nil(err)
+	}
+	buf.Reset()
+	buf.Write(IndexVersion2)
+	_, err = w.WriteSizeField(0, entries.Len()+sizeFieldLen, buf)
+	s.Require().Nil(err)
+	buf.Write(entries.Bytes())
+	r = NewReader()
+	_, err = r.ReadIndex(buf)
+	s.Assert().EqualError(err, "the index ended after 2 of 3 subfields")
+
+	// A field name that extends past the end of the index is rejected, even
+	// if more data follows the index.
+	buf.Reset()
+	buf.Write(IndexVersion2)
+	_, err = w.WriteSizeField(0, 12, buf)
+	s.Require().Nil(err)
+	_, err = w.WriteSizeField(0, 100, buf)
+	s.Require().Nil(err)
+	_, err = w.WriteSizeField(0, FieldTypeVarStr, buf)
+	s.Require().Nil(err)
+	buf.Write(make([]byte, 200))
+	r = NewReader()
+	_, err = r.ReadIndex(buf)
+	s.Assert().EqualError(err, "index entry extends past the end of the index (size 12)")
+
+	// An array length that can't fit in the array size is rejected.
+	data := getData(&s.Suite).Bytes()
+	// The `list` array length is at position 135.
+	data[135] = 0xff
+	r = NewReader()
+	b := bufio.NewReader(bytes.NewReader(data))
+	_, err = r.ReadIndex(b)
+	s.Require().Nil(err)
+	_, err = r.DecodeObject(b)
+	s.Assert().EqualError(err, "error decoding field list: array length 255 exceeds array size 100")
+}
+
+func (s *ReaderSuite) TestReadShort() {
+	w := NewWriter(nil)
+	write := func(fn func(buf *bytes.Buffer) (int, error)) io.Reader {
+		buf := &bytes.Buffer{}
+		_, err := fn(buf)
+		s.Require().Nil(err)
+		// End the reader one byte short of the full field.
+		return io.LimitReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()-1))
+	}
+
+	r := NewReader()
+	_, err := r.ReadSizeField(write(func(buf *bytes.Buffer) (int, error) {
+		return w.WriteSizeField(0, 4567, buf)
+	}))
+	s.Assert().ErrorIs(err, io.ErrUnexpectedEOF)
+
+	_, err = r.ReadIntField(write(func(buf *bytes.Buffer) (int, error) {
+		return w.WriteInt64Field(0, 4567, buf)
+	}))
+	s.Assert().ErrorIs(err, io.ErrUnexpectedEOF)
+
+	_, err = r.ReadFloatField(write(func(buf *bytes.Buffer) (int, error) {
+		return w.WriteFloatField(0, 45.67, buf)
+	}))
+	s.Assert().ErrorIs(err, io.ErrUnexpectedEOF)
+
+	_, err = r.ReadFixedStringField(15, write(func(buf *bytes.Buffer) (int, error) {
+		return w.WriteFixedStringField(0, 15, "package-manager", buf)
+	}))
+	s.Assert().ErrorIs(err, io.ErrUnexpectedEOF)
+
+	_, err = r.ReadStringField(write(func(buf *bytes.Buffer) (int, error) {
+		return w.WriteStringField(0, "p", buf)
+	}))
+	s.Assert().ErrorIs(err, io.ErrUnexpectedEOF)
+
+	_, err = r.ReadCompressedStringField(write(func(buf *bytes.Buffer) (int, error) {
+		return w.WriteCompressedStringField(0, "package-manager", buf)
+	}))
+	s.Assert().ErrorIs(err, io.ErrUnexpectedEOF)
+
+	_, err = r.ReadIPField(write(func(buf *bytes.Buffer) (int, error) {
+		return w.WriteIPField(0, netip.MustParseAddr("192.168.1.1"), buf)
+	}))
+	s.Assert().ErrorIs(err, io.ErrUnexpectedEOF)
+
+	// A boolean is a single byte, so a reader that ends one byte short ends
+	// before the field.
+	_, err = r.ReadBoolField(write(func(buf *bytes.Buffer) (int, error) {
+		return w.WriteBoolField(0, true, buf)
+	}))
+	s.Assert().ErrorIs(err, io.EOF)
+
+	// Objects that are cut short by a limit return an error.
+	data := getData(&s.Suite).Bytes()
+	r = NewReader()
+	b := bufio.NewReader(io.LimitReader(bytes.NewReader(data), int64(len(data)-10)))
+	_, err = r.ReadIndex(b)
+	s.Require().Nil(err)
+	var objErr error
+	for objErr == nil {
+		_, objErr = r.DecodeObject(b)
+	}
+	s.Assert().ErrorIs(objErr, io.ErrUnexpectedEOF)
+}
+
+// nestedIndex returns a Version2 index with `depth` nested arrays.
+func nestedIndex(s *suite.Suite, depth int) []byte {
+	w := NewWriter(nil)
+	entries := &bytes.Buffer{}
+	for i := 0; i < depth; i++ {
+		_, err := w.WriteStringField(0, "list", entries)
+		s.Require().Nil(err)
+		_, err = w.WriteSizeField(0, FieldTypeArray, entries)
+		s.Require().Nil(err)
+		_, err = w.WriteBoolField(0, false, entries)
+		s.Require().Nil(err)
+		_, err = w.WriteSizeField(0, int(reflect.Struct), entries)
+		s.Require().Nil(err)
+		_, err = w.WriteSizeField(0, 1, entries)
+		s.Require().Nil(err)
+	}
+	_, err := w.WriteStringField(0, "name", entries)
+	s.Require().Nil(err)
+	_, err = w.WriteSizeField(0, FieldTypeVarStr, entries)
+	s.Require().Nil(err)
+
+	buf := &bytes.Buffer{}
+	buf.Write(IndexVersion2)
+	_, err = w.WriteSizeField(0, entries.Len()+sizeFieldLen, buf)
+	s.Require().Nil(err)
+	buf.Write(entries.Bytes())
+	return buf.Bytes()
+}
+
+func (s *ReaderSuite) TestReadIndexMaxDepth() {
+	// The default depth allows moderately nested arrays.
+	index, err := NewReader().ReadIndex(bytes.NewReader(nestedIndex(&s.Suite, 10)))
+	s.Require().Nil(err)
+	s.Assert().Len(index, 1)
+
+	// Excessive nesting is rejected.
+	_, err = NewReader().ReadIndex(bytes.NewReader(nestedIndex(&s.Suite, 10000)))
+	s.Assert().ErrorIs(err, ErrMaxDepth)
+	err = Print(&bytes.Buffer{}, bufio.NewReader(bytes.NewReader(nestedIndex(&s.Suite, 10000))))
+	s.Assert().EqualError(err, "error reading index: the index exceeds the maximum nesting depth")
+
+	// The depth is configurable.
+	_, err = NewReader(MaxDepth(5)).ReadIndex(bytes.NewReader(nestedIndex(&s.Suite, 10)))
+	s.Assert().ErrorIs(err, ErrMaxDepth)
+	_, err = NewReader(MaxDepth(10)).ReadIndex(bytes.NewReader(nestedIndex(&s.Suite, 10)))
+	s.Assert().Nil(err)
+}
+
+// indexedIndex returns a Version2 index with an indexed struct array whose
+// keys have the type `indexType` and the size `indexSize`.
+func indexedIndex(s *suite.Suite, indexType reflect.Kind, indexSize int) []byte {
+	w := NewWriter(nil)
+	entries := &bytes.Buffer{}
+	_, err := w.WriteStringField(0, "list", entries)
+	s.Require().Nil(err)
+	_, err = w.WriteSizeField(0, FieldTypeArray, entries)
+	s.Require().Nil(err)
+	_, err = w.WriteBoolField(0, true, entries)
+	s.Require().Nil(err)
+	_, err = w.WriteSizeField(0, int(indexType), entries)
+	s.Require().Nil(err)
+	_, err = w.WriteSizeField(0, indexSize, entries)
+	s.Require().Nil(err)
+	_, err = w.WriteSizeField(0, int(reflect.Struct), entries)
+	s.Require().Nil(err)
+	_, err = w.WriteSizeField(0, 1, entries)
+	s.Require().Nil(err)
+	_, err = w.WriteStringField(0, "name", entries)
+	s.Require().Nil(err)
+	_, err = w.WriteSizeField(0, FieldTypeVarStr, entries)
+	s.Require().Nil(err)
+
+	buf := &bytes.Buffer{}
+	buf.Write(IndexVersion2)
+	_, err = w.WriteSizeField(0, entries.Len()+sizeFieldLen, buf)
+	s.Require().Nil(err)
+	buf.Write(entries.Bytes())
+	return buf.Bytes()
+}
+
+func (s *ReaderSuite) TestReadIndexKeySizes() {
+	for _, valid := range []struct {
+		kind reflect.Kind
+		size int
+	}{
+		{reflect.String, 10},
+		{reflect.Int64, sizeInt64},
+		{reflect.Array, 16},
+	} {
+		index, err := NewReader().ReadIndex(bytes.NewReader(indexedIndex(&s.Suite, valid.kind, valid.size)))
+		s.Require().Nil(err)
+		s.Assert().Equal(valid.size, index[0].IndexSize)
+	}
+
+	// Keys must have a size that matches their type.
+	_, err := NewReader().ReadIndex(bytes.NewReader(indexedIndex(&s.Suite, reflect.String, 0)))
+	s.Assert().EqualError(err, "invalid index size 0 for array list")
+	_, err = NewReader().ReadIndex(bytes.NewReader(indexedIndex(&s.Suite, reflect.Int64, 4)))
+	s.Assert().EqualError(err, "invalid index size 4 for array list; integer keys are 10 bytes")
+	_, err = NewReader().ReadIndex(bytes.NewReader(indexedIndex(&s.Suite, reflect.Float64, 8)))
+	s.Assert().EqualError(err, "invalid index type 14 for array list")
+}
+
+func (s *ReaderSuite) TestFeatures() {
+	features := func(version int, opts ...WriterOption) uint32 {
+		buf := &bytes.Buffer{}
+		w := NewWriterWithVersion(buf, version, opts...)
+		_, err := w.WriteObject(testNamedData)
+		s.Require().Nil(err)
+		s.Require().Nil(w.Close())
+
+		r := NewReader()
+		_, err = r.ReadIndex(bufio.NewReader(buf))
+		s.Require().Nil(err)
+		return r.Features()
+	}
+
+	// Each enabled feature sets its bit.
+	s.Assert().Equal(uint32(0), features(Version3))
+	s.Assert().Equal(uint32(FeatureStringTable), features(Version3, StringTable(true)))
+	s.Assert().Equal(uint32(FeatureFingerprint|FeatureZstd), features(Version3, Fingerprint(true), Compression(CodecZstd)))
+
+	// Version2 files have no feature flags.
+	s.Assert().Equal(uint32(0), features(Version2))
+}
+
+func (s *ReaderSuite) TestSkipIndex() {
+	data := getData(&s.Suite).Bytes()
+	index, err := NewReader().ReadIndex(bytes.NewReader(data))
+	s.Require().Nil(err)
+
+	// Skip the index, then use the known index to read the object.
+	r := NewReader()
+	rs := bytes.NewReader(data)
+	s.Require().Nil(r.SkipIndex(rs))
+	s.Assert().Equal(int64(117), r.Pos())
+	r.SetIndex(index)
+
+	buf := bufio.NewReader(rs)
+	sz, err := r.ReadSizeField(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(len(data)-117, sz)
+	s.Require().Nil(r.AdvanceTo(buf, "age"))
+	age, err := r.ReadIntField(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(int64(55), age)
+
+	// The string table, which follows the index, is read.
+	out := &bytes.Buffer{}
+	w := NewWriterWithVersion(out, Version3, StringTable(true))
+	_, err = w.WriteObject(testNamedData)
+	s.Require().Nil(err)
+	s.Require().Nil(w.Close())
+	index, err = NewReader().ReadIndex(bytes.NewReader(out.Bytes()))
+	s.Require().Nil(err)
+
+	r = NewReader()
+	rs = bytes.NewReader(out.Bytes())
+	s.Require().Nil(r.SkipIndex(rs))
+	r.SetIndex(index)
+	var rec namedRecord
+	s.Require().Nil(r.Unmarshal(bufio.NewReader(rs), &rec))
+	s.Assert().Equal(testNamedData.Name, rec.Name)
+	s.Assert().Equal(testNamedData.Depends, rec.Depends)
+}
+
+// offsetSeeker records the position of the last seek.
+type offsetSeeker struct {
+	pos int64
+}
+
+func (o *offsetSeeker) Seek(offset int64, whence int) (int64, error) {
+	o.pos = offset
+	return offset, nil
+}
+
+func (s *ReaderSuite) TestLargePositions() {
+	// Positions past `math.MaxInt32` don't overflow, even on 32-bit
+	// platforms.
+	start := int64(math.MaxInt32 - 2)
+	r := &rsfReader{pos: start}
+	buf := bufio.NewReader(getData(&s.Suite))
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(start+117, r.Pos())
+
+	sz, err := r.BeginObject(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(start+121, r.Pos())
+	s.Assert().Equal(sz-sizeFieldLen, r.RemainingInObject())
+	s.Require().Nil(r.AdvanceTo(buf, "age"))
+	s.Assert().Equal(start+231, r.Pos())
+	s.Require().Nil(r.SkipObject(buf))
+	s.Assert().Equal(start+249, r.Pos())
+	s.Assert().Greater(r.Pos(), int64(math.MaxInt32))
+
+	// Internal seeks use int64 positions.
+	seeker := &offsetSeeker{}
+	large := int64(math.MaxUint32) + 10
+	s.Require().Nil(r.seek(large, seeker))
+	s.Assert().Equal(large, seeker.pos)
+	s.Assert().Equal(large, r.Pos())
+}
+
+func (s *ReaderSuite) TestLargeArrayLengths() {
+	entry := IndexEntry{FieldType: FieldTypeArray, SubfieldType: int(reflect.String)}
+
+	// A length above the int32 range is rejected by the array size rather
+	// than overflowing.
+	err := checkArrayLen(entry, math.MaxInt32, math.MaxInt32)
+	s.Assert().EqualError(err, fmt.Sprintf("array length %d exceeds array size %d", math.MaxInt32, math.MaxInt32))
+	large := int64(math.MaxUint32)
+	err = checkArrayLen(entry, math.MaxInt32, int(large))
+	s.Assert().NotNil(err)
+
+	// On 32-bit platforms, lengths above `math.MaxInt32` wrap to negative
+	// values when read.
+	length := uint32(math.MaxInt32) + 1
+	wrapped := int(int32(length))
+	err = checkArrayLen(entry, math.MaxInt32, wrapped)
+	s.Assert().EqualError(err, "invalid array length -2147483648")
+	err = checkArrayLen(entry, wrapped, 1)
+	s.Assert().EqualError(err, "invalid array size -2147483648")
+
+	// Lengths that fit in the array size are accepted.
+	s.Assert().Nil(checkArrayLen(entry, math.MaxInt32, (math.MaxInt32-8)/4))
 }
@@ -0,0 +1,70 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+var ErrNoTrailer = errors.New("trailer not found")
+
+// ReadTrailer reads the trailer written with `WriteTrailer` from the end of
+// `r`. If the file includes an object key table, the trailer is found before
+// the key table. `ErrNoTrailer` is returned if the file has no trailer.
+func ReadTrailer(r io.ReadSeeker) ([]byte, error) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	// Skip the object key table, if any.
+	tr := &rsfReader{}
+	sz, marker, err := readTail(tr, r, end)
+	if err != nil {
+		return nil, ErrNoTrailer
+	}
+	if bytes.Equal(marker, KeyTableMarker) {
+		end -= int64(sz) + sizeFieldLen + int64(len(KeyTableMarker))
+		sz, marker, err = readTail(tr, r, end)
+		if err != nil {
+			return nil, ErrNoTrailer
+		}
+	}
+	if !bytes.Equal(marker, TrailerMarker) {
+		return nil, ErrNoTrailer
+	}
+
+	// Seek to the start of the trailer.
+	start := end - sizeFieldLen - int64(len(TrailerMarker)) - int64(sz)
+	if start < 0 {
+		return nil, ErrNoTrailer
+	}
+	_, err = r.Seek(start, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	bs, err := readBytes(r, sz)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return bs, nil
+}
+
+// readTail reads the size field and marker that precede offset `end` of `r`.
+func readTail(tr *rsfReader, r io.ReadSeeker, end int64) (int, []byte, error) {
+	_, err := r.Seek(end-sizeFieldLen-int64(len(TrailerMarker)), io.SeekStart)
+	if err != nil {
+		return 0, nil, err
+	}
+	sz, err := tr.ReadSizeField(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	marker := make([]byte, len(TrailerMarker))
+	_, err = io.ReadFull(r, marker)
+	if err != nil {
+		return 0, nil, err
+	}
+	return sz, marker, nil
+}
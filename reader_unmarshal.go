@@ -0,0 +1,647 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"reflect"
+	"strings"
+)
+
+var ErrInvalidUnmarshalTarget = errors.New("unmarshal target must be a non-nil pointer to a struct")
+
+// Unmarshal reads the next object from `buf` into `v`, which must be a
+// pointer to a struct. The reader must already be positioned at the start of
+// an object (at the object's size field), and an index must have been read
+// with `ReadIndex` or supplied with `SetIndex`.
+//
+// Fields are bound by their `rsf` struct tag names rather than by position,
+// and binding is tolerant of schema differences:
+//
+//   - Fields in the destination struct that are not present in the index are
+//     left unchanged (usually at their zero value) without error, or set to
+//     the defaults registered with `Defaults`. This supports reading older
+//     files into newer structs.
+//   - Fields in the index that are not present in the destination struct are
+//     skipped. This supports reading newer files into older structs.
+//
+// For indexed struct arrays, each element's index key is assigned to the
+// element field named by the array's `index` tag option, even when that field
+// is tagged `skip`.
+//
+// When complete, the reader is positioned at the start of the next object.
+func (f *rsfReader) Unmarshal(buf *bufio.Reader, v any) error {
+	return f.ReadObjectInto(buf, v)
+}
+
+// ReadObjectInto reads the next object from `buf` into `v`, which must be a
+// pointer to a struct, like `Unmarshal`. The struct field matching each index
+// entry is found the first time an object is read into a struct type, and is
+// reused for later objects and array elements, so each object is read in a
+// single pass over the index without looking up fields by name. This matters
+// when reading many objects into the same type. The fields are found again
+// after an index is read or set.
+func (f *rsfReader) ReadObjectInto(buf *bufio.Reader, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return ErrInvalidUnmarshalTarget
+	}
+
+	return f.readObject(buf, func() error {
+		return f.readStruct(nil, f.index, rv.Elem(), buf)
+	})
+}
+
+// planKey identifies the fields of an index, the top-level index or an array's
+// subfields, read into a struct type, or a field of a struct type.
+type planKey struct {
+	index *IndexEntry
+	len   int
+	typ   reflect.Type
+	field string
+}
+
+// readObject reads an object's size field (unless it was read by
+// `NextObject`), calls `read` to read the object's fields, and then discards
+// any unread bytes remaining in the object. An `io.EOF` error is returned at
+// the end of the objects.
+func (f *rsfReader) readObject(buf *bufio.Reader, read func() error) error {
+	var err error
+	if f.begun {
+		f.begun = false
+	} else {
+		_, err = f.BeginObject(buf)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = read()
+	if err != nil {
+		return err
+	}
+
+	// Read any strings from the overflow region.
+	err = f.readOverflow(buf)
+	if err != nil {
+		return err
+	}
+
+	// Discard anything that remains in the object.
+	return f.SkipObject(buf)
+}
+
+// readStruct reads the fields described by `index` into the struct `v`.
+// Fields are read in index order; each is assigned to the struct field with a
+// matching `rsf` tag name, or skipped when no such field exists. The `parent`
+// path is the path of the struct's enclosing array, if any.
+func (f *rsfReader) readStruct(parent []string, index Index, v reflect.Value, buf *bufio.Reader) error {
+	key := planKey{len: len(index), typ: v.Type()}
+	if len(index) > 0 {
+		key.index = &index[0]
+	}
+	plan, ok := f.plans[key]
+	if !ok {
+		fields, err := structFields(v.Type(), false)
+		if err != nil {
+			return err
+		}
+		plan = planFields(index, fields)
+		if f.plans == nil {
+			f.plans = make(map[planKey]*fieldPlan)
+		}
+		f.plans[key] = plan
+	}
+	err := f.readFields(parent, index, plan.fields, v, buf)
+	if err != nil {
+		return err
+	}
+
+	// Set fields that are absent from the index to their defaults.
+	if def, ok := f.defaults[v.Type()]; ok {
+		for _, path := range plan.missing {
+			v.FieldByIndex(path).Set(def.FieldByIndex(path))
+		}
+	}
+	return nil
+}
+
+// keyPath returns the path of the field `name` of the struct type `t`, which
+// receives an array element's index key. Paths are cached by type.
+func (f *rsfReader) keyPath(t reflect.Type, name string) ([]int, error) {
+	key := planKey{typ: t, field: name}
+	if path, ok := f.keyPaths[key]; ok {
+		return path, nil
+	}
+	fields, err := structFields(t, true)
+	if err != nil {
+		return nil, err
+	}
+	if f.keyPaths == nil {
+		f.keyPaths = make(map[planKey][]int)
+	}
+	f.keyPaths[key] = fields[name].path
+	return fields[name].path, nil
+}
+
+// fieldPlan describes how the fields of an index are read into a struct type.
+type fieldPlan struct {
+	// The struct field matching each index entry, or nil for entries that
+	// are skipped.
+	fields []*structField
+
+	// The paths of the struct fields that are absent from the index, which
+	// are set to their defaults. See `Defaults`.
+	missing [][]int
+}
+
+// planFields returns the plan for reading the fields described by `index`
+// into a struct with the fields `fields`.
+func planFields(index Index, fields map[string]structField) *fieldPlan {
+	plan := &fieldPlan{fields: make([]*structField, len(index))}
+	found := make(map[string]bool, len(index))
+	for n, entry := range index {
+		found[entry.FieldName] = true
+		field, ok := fields[entry.FieldName]
+		if ok && !entry.hidden {
+			plan.fields[n] = &field
+		}
+	}
+	for name, field := range fields {
+		if !found[name] {
+			plan.missing = append(plan.missing, field.path)
+		}
+	}
+	return plan
+}
+
+// readFields reads the fields described by `index` into the struct `v`,
+// assigning each to the matching struct field in `plan` (see `planFields`).
+func (f *rsfReader) readFields(parent []string, index Index, plan []*structField, v reflect.Value, buf *bufio.Reader) error {
+	for n, entry := range index {
+		f.notifyField(parent, entry)
+		field := plan[n]
+		if field == nil {
+			err := f.advance(entry, buf)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		err := f.readValue(append(parent[:len(parent):len(parent)], entry.FieldName), entry, field.index, v.FieldByIndex(field.path), buf)
+		if err != nil {
+			return fmt.Errorf("error reading field %s: %w", entry.FieldName, err)
+		}
+	}
+	return nil
+}
+
+// structField describes a destination struct field.
+type structField struct {
+	// The field's index path. See `reflect.Value.FieldByIndex`.
+	path []int
+
+	// For indexed arrays, the name of the field used as the array index.
+	index string
+}
+
+// structFields maps each serialized field name of the struct type `t` to the
+// field. Nested (non-array) structs are flattened into their parent, matching
+// how `writeStruct` serializes them. When a name is used more than once, the
+// first occurrence wins. When `withSkipped` is true, fields tagged `skip` are
+// also included.
+func structFields(t reflect.Type, withSkipped bool) (map[string]structField, error) {
+	fields := make(map[string]structField)
+	err := collectStructFields(t, nil, withSkipped, fields)
+	return fields, err
+}
+
+func collectStructFields(t reflect.Type, parent []int, withSkipped bool, fields map[string]structField) error {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get(tagName) == rsfIgnore || !t.Field(i).IsExported() {
+			continue
+		}
+
+		tg := &tag{}
+		skip, err := getTagInfo(t, i, tg, &tag{}, nil)
+		if err != nil {
+			return err
+		}
+		if skip && !withSkipped {
+			continue
+		}
+
+		path := append(append([]int{}, parent...), i)
+		fieldType := t.Field(i).Type
+		if fieldType.Kind() == reflect.Struct && !isIPType(fieldType) && !isTimeType(fieldType) && !isMarshalerType(fieldType) {
+			err = collectStructFields(t.Field(i).Type, path, withSkipped, fields)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, ok := fields[tg.name]; !ok {
+			fields[tg.name] = structField{path: path, index: tg.index}
+		}
+	}
+	return nil
+}
+
+// readValue reads the field described by `entry` into `v`. The `path` is the
+// full path of the field. For indexed arrays, `indexField` names the element
+// field that receives each element's index key.
+func (f *rsfReader) readValue(path []string, entry IndexEntry, indexField string, v reflect.Value, buf *bufio.Reader) error {
+	if f.logger != nil {
+		f.logger.Debug("read field", "field", strings.Join(path, rsfPathSep), "type", typeTag(entry.FieldType), "pos", f.pos)
+	}
+
+	switch entry.FieldType {
+	case FieldTypeVarStr:
+		s, err := f.ReadStringField(buf)
+		if err != nil {
+			return err
+		}
+		return setString(v, s)
+	case FieldTypeCompressedStr:
+		s, err := f.ReadCompressedStringField(buf)
+		if err != nil {
+			return err
+		}
+		return setString(v, s)
+	case FieldTypeFixedStr:
+		s, err := f.ReadFixedStringField(entry.FieldSize, buf)
+		if err != nil {
+			return err
+		}
+		return setString(v, s)
+	case FieldTypeOverflowStr:
+		if v.Kind() != reflect.String {
+			return fmt.Errorf("cannot read string into %s", v.Type())
+		}
+		return f.readOverflowRef(buf, func(s string) error {
+			return setString(v, s)
+		})
+	case FieldTypeBool:
+		b, err := f.ReadBoolField(buf)
+		if err != nil {
+			return err
+		}
+		return setBool(v, b)
+	case FieldTypePackedBool:
+		b, err := f.readPackedBool(entry)
+		if err != nil {
+			return err
+		}
+		return setBool(v, b)
+	case FieldTypeInt64:
+		i, err := f.ReadIntField(buf)
+		if err != nil {
+			return err
+		}
+		return setInt(v, i)
+	case FieldTypeFixedInt64, FieldTypeBigEndianInt:
+		i, err := f.readFixedInt(entry, buf)
+		if err != nil {
+			return err
+		}
+		return setInt(v, i)
+	case FieldTypeFloat, FieldTypeBigEndianFloat:
+		fl, err := f.readFloat(entry, buf)
+		if err != nil {
+			return err
+		}
+		return setFloat(v, fl)
+	case FieldTypeIP:
+		addr, err := f.ReadIPField(buf)
+		if err != nil {
+			return err
+		}
+		return setIP(v, addr)
+	case FieldTypeDays:
+		t, err := f.ReadDaysField(buf)
+		if err != nil {
+			return err
+		}
+		return setTime(v, t)
+	case FieldTypeOpaque:
+		bs, err := f.readOpaque(buf)
+		if err != nil {
+			return err
+		}
+		return setOpaque(v, bs)
+	case FieldTypeBytes:
+		bs, err := f.ReadBytesField(buf)
+		if err != nil {
+			return err
+		}
+		return setBytes(v, bs)
+	case FieldTypeArray, FieldTypePackedArray:
+		// Absent arrays are read as nil slices and maps.
+		absent, err := f.absent(entry)
+		if err != nil {
+			return err
+		}
+		if absent {
+			if v.Kind() == reflect.Slice || v.Kind() == reflect.Map {
+				v.Set(reflect.Zero(v.Type()))
+			}
+			return nil
+		}
+		return f.readArray(path, entry, indexField, v, buf)
+	default:
+		return fmt.Errorf("unexpected index field type %d", entry.FieldType)
+	}
+}
+
+// readArray reads an array, including its index (if any), into the slice or
+// array `v`. For indexed struct arrays, each element's index key is assigned to
+// the element field named `indexField`, if any. This populates index fields
+// tagged `skip`, which are only written in the array index.
+func (f *rsfReader) readArray(path []string, entry IndexEntry, indexField string, v reflect.Value, buf *bufio.Reader) error {
+	if v.Kind() == reflect.Map {
+		return f.readMap(path, entry, v, buf)
+	}
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return fmt.Errorf("cannot read array into %s", v.Type())
+	}
+
+	// Read the array size and length.
+	arraySz, err := f.ReadSizeField(buf)
+	if err != nil {
+		return err
+	}
+	arrayLen, err := f.ReadSizeField(buf)
+	if err != nil {
+		return err
+	}
+	err = checkArrayLen(entry, arraySz, arrayLen)
+	if err != nil {
+		return err
+	}
+
+	// Read the array index, if included.
+	err = checkKeyField(entry, indexField)
+	if err != nil {
+		return err
+	}
+	keys, err := f.readArrayKeys(entry, arrayLen, buf)
+	if err != nil {
+		return err
+	}
+
+	// Empty slices with spare capacity are reused (see `ReadArrayInto`).
+	// Otherwise, empty arrays are read as nil slices since the writer does
+	// not distinguish between nil and empty slices, unless the array is
+	// optional (see `NilAsAbsent`).
+	reuse := v.Kind() == reflect.Slice && v.Len() == 0 && v.Cap() > 0
+	if v.Kind() == reflect.Slice && !reuse && arrayLen == 0 && !entry.Optional {
+		v.Set(reflect.Zero(v.Type()))
+	} else if v.Kind() == reflect.Slice && !reuse {
+		// The array length is read from the data, so limit the initial
+		// allocation. The slice grows as elements are read.
+		v.Set(reflect.MakeSlice(v.Type(), 0, min(arrayLen, maxPreallocLen)))
+	} else if v.Kind() == reflect.Array && v.Len() < arrayLen {
+		return fmt.Errorf("array length %d exceeds destination length %d", arrayLen, v.Len())
+	}
+
+	// Older indexes did not record the array element type, so fall back to
+	// the destination element type when needed.
+	kind := reflect.Kind(entry.SubfieldType)
+	if kind == reflect.Invalid {
+		kind = v.Type().Elem().Kind()
+	}
+
+	// The elements of packed arrays of numbers are read all at once.
+	if packed := packedElement(entry); packed.FieldType != 0 {
+		return f.readPackedNumbers(packed, arrayLen, v, buf)
+	}
+
+	// Find the element field that receives the index key.
+	var keyPath []int
+	if keys != nil && indexField != "" && kind == reflect.Struct && v.Type().Elem().Kind() == reflect.Struct {
+		keyPath, err = f.keyPath(v.Type().Elem(), indexField)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < arrayLen; i++ {
+		if v.Kind() == reflect.Slice {
+			v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+		}
+		if emptyElements(entry) {
+			err = f.readEmptyElement(buf)
+			if err != nil {
+				return err
+			}
+		}
+		err = f.readElement(path, kind, entry.Subfields, v.Index(i), buf)
+		if err != nil {
+			return err
+		}
+
+		if keyPath != nil {
+			err = setKey(v.Index(i).FieldByIndex(keyPath), keys[i])
+			if err != nil {
+				return fmt.Errorf("error reading index field %s: %w", indexField, err)
+			}
+		}
+	}
+	return nil
+}
+
+// readMap reads an array of key/value structs, written for a map field in
+// canonical form, into the map `v`. See `Canonical`.
+func (f *rsfReader) readMap(path []string, entry IndexEntry, v reflect.Value, buf *bufio.Reader) error {
+	entries := reflect.New(mapEntriesSliceType(v.Type())).Elem()
+	err := f.readArray(path, entry, "", entries, buf)
+	if err != nil {
+		return err
+	}
+
+	// Like slices, empty maps are read as nil maps, unless the array is
+	// optional.
+	if entries.Len() == 0 && !entry.Optional {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	v.Set(reflect.MakeMapWithSize(v.Type(), entries.Len()))
+	for i := 0; i < entries.Len(); i++ {
+		v.SetMapIndex(entries.Index(i).Field(0), entries.Index(i).Field(1))
+	}
+	return nil
+}
+
+// readArrayKeys reads the index of an indexed array with `arrayLen` elements
+// and returns the index key of each element. Nothing is read if the array is
+// not indexed.
+func (f *rsfReader) readArrayKeys(entry IndexEntry, arrayLen int, buf *bufio.Reader) ([]any, error) {
+	if !entry.Indexed {
+		return nil, nil
+	}
+
+	keys := make([]any, 0)
+	for i := 0; i < arrayLen; i++ {
+		key, err := f.readIndexKey(entry, buf)
+		if err != nil {
+			return nil, err
+		}
+
+		// Read the element size. Packed arrays don't include it.
+		if entry.FieldType != FieldTypePackedArray {
+			_, err = f.ReadSizeField(buf)
+			if err != nil {
+				return nil, err
+			}
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// readIndexKey reads a single array index key. Keys are strings, int64s, or,
+// for fixed-size byte array keys, byte slices.
+func (f *rsfReader) readIndexKey(entry IndexEntry, r io.Reader) (any, error) {
+	switch reflect.Kind(entry.IndexType) {
+	case reflect.String:
+		return f.ReadFixedStringField(entry.IndexSize, r)
+	case reflect.Int64:
+		return f.ReadIntField(r)
+	case reflect.Array:
+		s, err := f.ReadFixedStringField(entry.IndexSize, r)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(s), nil
+	default:
+		return nil, ErrInvalidIndexFieldType
+	}
+}
+
+// readElement reads a single array element of type `kind` into `v`. The
+// `path` is the path of the array.
+func (f *rsfReader) readElement(path []string, kind reflect.Kind, subfields Index, v reflect.Value, buf *bufio.Reader) error {
+	switch kind {
+	case reflect.Struct:
+		if v.Kind() != reflect.Struct {
+			return fmt.Errorf("cannot read struct into %s", v.Type())
+		}
+		return f.readStruct(path, subfields, v, buf)
+	case reflect.String:
+		return f.readValue(path, IndexEntry{FieldType: FieldTypeVarStr}, "", v, buf)
+	case reflect.Bool:
+		return f.readValue(path, IndexEntry{FieldType: FieldTypeBool}, "", v, buf)
+	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+		return f.readValue(path, IndexEntry{FieldType: FieldTypeInt64}, "", v, buf)
+	case reflect.Float32, reflect.Float64:
+		return f.readValue(path, IndexEntry{FieldType: FieldTypeFloat}, "", v, buf)
+	case reflect.Array, reflect.Slice:
+		return f.readArray(path, IndexEntry{FieldType: FieldTypeArray}, "", v, buf)
+	default:
+		return fmt.Errorf("unknown array element type %s", kind)
+	}
+}
+
+// setKey assigns an array index key, which is a string, an int64, or a byte
+// slice.
+func setKey(v reflect.Value, key any) error {
+	switch k := key.(type) {
+	case string:
+		return setString(v, k)
+	case int64:
+		return setInt(v, k)
+	case []byte:
+		if !isByteArray(v.Type()) || v.Len() != len(k) {
+			return fmt.Errorf("cannot read [%d]byte into %s", len(k), v.Type())
+		}
+		reflect.Copy(v, reflect.ValueOf(k))
+		return nil
+	default:
+		return ErrInvalidIndexFieldType
+	}
+}
+
+func setString(v reflect.Value, s string) error {
+	if v.Kind() != reflect.String {
+		return fmt.Errorf("cannot read string into %s", v.Type())
+	}
+	v.SetString(s)
+	return nil
+}
+
+func setBool(v reflect.Value, b bool) error {
+	if v.Kind() != reflect.Bool {
+		return fmt.Errorf("cannot read bool into %s", v.Type())
+	}
+	v.SetBool(b)
+	return nil
+}
+
+func setInt(v reflect.Value, i int64) error {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+		if v.OverflowInt(i) {
+			return fmt.Errorf("value %d overflows %s", i, v.Type())
+		}
+		v.SetInt(i)
+		return nil
+	default:
+		return fmt.Errorf("cannot read int into %s", v.Type())
+	}
+}
+
+// setOpaque reads an opaque value into `v` with its `RSFUnmarshaler`
+// implementation. Pointer fields are allocated as needed, and an empty value
+// is assigned to a pointer field as nil. See `writeMarshaler`.
+func setOpaque(v reflect.Value, bs []byte) error {
+	if v.Kind() == reflect.Pointer && v.Type().Implements(unmarshalerType) {
+		if len(bs) == 0 {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return v.Interface().(RSFUnmarshaler).UnmarshalRSF(NewReader(), bytes.NewReader(bs))
+	}
+	if !v.CanAddr() || !v.Addr().Type().Implements(unmarshalerType) {
+		return fmt.Errorf("cannot read opaque value into %s", v.Type())
+	}
+	return v.Addr().Interface().(RSFUnmarshaler).UnmarshalRSF(NewReader(), bytes.NewReader(bs))
+}
+
+// setIP assigns an IP address to a `netip.Addr` or `net.IP`. The zero address
+// is assigned as a nil `net.IP`.
+func setIP(v reflect.Value, addr netip.Addr) error {
+	switch v.Type() {
+	case netipAddrType:
+		v.Set(reflect.ValueOf(addr))
+		return nil
+	case netIPType:
+		var ip net.IP
+		if addr.IsValid() {
+			ip = addr.AsSlice()
+		}
+		v.Set(reflect.ValueOf(ip))
+		return nil
+	default:
+		return fmt.Errorf("cannot read IP address into %s", v.Type())
+	}
+}
+
+func setFloat(v reflect.Value, fl float64) error {
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(fl)
+		return nil
+	default:
+		return fmt.Errorf("cannot read float into %s", v.Type())
+	}
+}
@@ -0,0 +1,604 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ReaderUnmarshalSuite struct {
+	suite.Suite
+}
+
+func TestReaderUnmarshalSuite(t *testing.T) {
+	suite.Run(t, &ReaderUnmarshalSuite{})
+}
+
+type legacySnap struct {
+	Date     string `rsf:"date,skip,fixed:10"`
+	Name     string `rsf:"name"`
+	Verified bool   `rsf:"verified"`
+	Skip     string `rsf:"-"`
+}
+
+type legacyRecord struct {
+	Skip    string       `rsf:"-"`
+	Company string       `rsf:"company"`
+	Ready   bool         `rsf:"ready"`
+	List    []legacySnap `rsf:"list,index:date"`
+	Age     int          `rsf:"age"`
+	Rating  float64      `rsf:"rating"`
+}
+
+type upgradedSnap struct {
+	Guid     string `rsf:"guid,fixed:36"`
+	Date     string `rsf:"date,skip,fixed:10"`
+	Name     string `rsf:"name"`
+	Project  string `rsf:"project"`
+	Verified bool   `rsf:"verified"`
+	Skip     string `rsf:"-"`
+	SkipAlso string `rsf:"-"`
+	Trust    bool   `rsf:"trust"`
+}
+
+type upgradedProduct struct {
+	Barcode int32   `rsf:"barcode,skip"`
+	Name    string  `rsf:"name"`
+	Price   float32 `rsf:"price"`
+}
+
+type upgradedRecord struct {
+	Location string            `rsf:"location"`
+	Skip     string            `rsf:"-"`
+	Company  string            `rsf:"company"`
+	Products []upgradedProduct `rsf:"products,index:barcode"`
+	Ready    bool              `rsf:"ready"`
+	Portable bool              `rsf:"portable"`
+	List     []upgradedSnap    `rsf:"list,index:date"`
+	Income   float64           `rsf:"income"`
+	Age      int               `rsf:"age"`
+	Rating   float64           `rsf:"rating"`
+	Zip      int               `rsf:"zip"`
+}
+
+var testUpgradedData = upgradedRecord{
+	Location: "Albuquerque",
+	Company:  "posit",
+	Ready:    true,
+	Portable: true,
+	Income:   56999.98,
+	Age:      55,
+	Rating:   92.689,
+	Zip:      75043,
+	List: []upgradedSnap{
+		{
+			Guid:     "199d22ca-719f-40e6-a108-1f2147564168",
+			Date:     "2020-10-01",
+			Name:     "From 2020",
+			Project:  "albatross",
+			Verified: false,
+			Trust:    true,
+		},
+		{
+			Guid:     "eba30155-b31c-4287-a7a1-1018010859c1",
+			Date:     "2021-03-21",
+			Name:     "From 2021",
+			Project:  "bluebird",
+			Verified: true,
+		},
+	},
+	Products: []upgradedProduct{
+		{
+			Barcode: 123456789,
+			Name:    "shovel",
+			Price:   32.99,
+		},
+	},
+}
+
+type nestedVariation struct {
+	Id          int8   `rsf:"id,skip"`
+	Description string `rsf:"description"`
+}
+
+type nestedProduct struct {
+	Barcode    string            `rsf:"barcode,skip,fixed:12"`
+	Name       string            `rsf:"name"`
+	Price      float32           `rsf:"price"`
+	Variations []nestedVariation `rsf:"variations,index:id"`
+}
+
+type nestedRecord struct {
+	Company  string          `rsf:"company"`
+	Products []nestedProduct `rsf:"products,index:barcode"`
+	Age      int             `rsf:"age"`
+}
+
+// testNestedData mirrors the products and variations of the downgrade test,
+// where the elements of an indexed array contain another indexed array.
+var testNestedData = nestedRecord{
+	Company: "posit",
+	Products: []nestedProduct{
+		{
+			Barcode: "012345678901",
+			Name:    "shovel",
+			Price:   32.99,
+			Variations: []nestedVariation{
+				{
+					Id:          9,
+					Description: "variation one",
+				},
+				{
+					Id:          11,
+					Description: "variation two",
+				},
+			},
+		},
+		{
+			Barcode: "987654321098",
+			Name:    "rake",
+			Price:   15.44,
+		},
+	},
+	Age: 55,
+}
+
+func (s *ReaderUnmarshalSuite) TestUnmarshalIntoUpgradedStruct() {
+	buf := bufio.NewReader(getData(&s.Suite))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+
+	// The legacy file does not include many of the upgraded struct's
+	// fields. These are left at their zero values.
+	var rec upgradedRecord
+	err = r.Unmarshal(buf, &rec)
+	s.Require().Nil(err)
+	s.Assert().Equal(upgradedRecord{
+		Company: "posit",
+		Ready:   true,
+		Age:     55,
+		Rating:  92.689,
+		List: []upgradedSnap{
+			{
+				Date: "2020-10-01",
+				Name: "From 2020",
+			},
+			{
+				Date:     "2021-03-21",
+				Name:     "From 2021",
+				Verified: true,
+			},
+			{
+				Date:     "2022-12-15",
+				Name:     "this is from 2022",
+				Verified: true,
+			},
+		},
+	}, rec)
+	s.Assert().Equal(int64(249), r.Pos())
+
+	// Verify at EOF.
+	err = r.Unmarshal(buf, &rec)
+	s.Assert().ErrorIs(err, io.EOF)
+}
+
+func (s *ReaderUnmarshalSuite) TestUnmarshalDefaults() {
+	buf := bufio.NewReader(getData(&s.Suite))
+	r := NewReader(
+		Defaults(upgradedRecord{Company: "ignored", Zip: 12345}),
+		Defaults(&upgradedSnap{Verified: true, Trust: true, Project: "unknown"}),
+	)
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+
+	// Fields absent from the legacy file are set to their defaults, in the
+	// object and in each array element. Fields in the file keep their
+	// values, even when they are the zero value.
+	var rec upgradedRecord
+	err = r.Unmarshal(buf, &rec)
+	s.Require().Nil(err)
+	s.Assert().Equal(upgradedRecord{
+		Company: "posit",
+		Ready:   true,
+		Age:     55,
+		Rating:  92.689,
+		Zip:     12345,
+		List: []upgradedSnap{
+			{
+				Date:    "2020-10-01",
+				Name:    "From 2020",
+				Project: "unknown",
+				Trust:   true,
+			},
+			{
+				Date:     "2021-03-21",
+				Name:     "From 2021",
+				Project:  "unknown",
+				Verified: true,
+				Trust:    true,
+			},
+			{
+				Date:     "2022-12-15",
+				Name:     "this is from 2022",
+				Project:  "unknown",
+				Verified: true,
+				Trust:    true,
+			},
+		},
+	}, rec)
+}
+
+func (s *ReaderUnmarshalSuite) TestUnmarshalIntoLegacyStruct() {
+	b := &bytes.Buffer{}
+	w := NewWriterWithVersion(b, Version2)
+	_, err := w.WriteObject(testUpgradedData)
+	s.Require().Nil(err)
+	_, err = w.WriteObject(testUpgradedData)
+	s.Require().Nil(err)
+
+	buf := bufio.NewReader(b)
+	r := NewReader()
+	_, err = r.ReadIndex(buf)
+	s.Require().Nil(err)
+
+	// Fields not included in the legacy struct are skipped.
+	for i := 0; i < 2; i++ {
+		var rec legacyRecord
+		err = r.Unmarshal(buf, &rec)
+		s.Require().Nil(err)
+		s.Assert().Equal(legacyRecord{
+			Company: "posit",
+			Ready:   true,
+			Age:     55,
+			Rating:  92.689,
+			List: []legacySnap{
+				{
+					Date: "2020-10-01",
+					Name: "From 2020",
+				},
+				{
+					Date:     "2021-03-21",
+					Name:     "From 2021",
+					Verified: true,
+				},
+			},
+		}, rec)
+	}
+
+	// Verify at EOF.
+	var rec legacyRecord
+	err = r.Unmarshal(buf, &rec)
+	s.Assert().ErrorIs(err, io.EOF)
+}
+
+func (s *ReaderUnmarshalSuite) TestUnmarshalComplex() {
+	b := &bytes.Buffer{}
+	w := NewWriterWithVersion(b, Version2)
+	for _, obj := range testComplexData {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+
+	buf := bufio.NewReader(b)
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+
+	for _, expected := range testComplexData {
+		var rec FullPackageRecordPyPI
+		err = r.Unmarshal(buf, &rec)
+		s.Require().Nil(err)
+
+		// Ignored fields are not read. The skipped snapshot dates are
+		// read from the array index.
+		expected.Snapshots = append([]FullManifestSnapshotPyPI{}, expected.Snapshots...)
+		for i := range expected.Snapshots {
+			expected.Snapshots[i].CanonicalName = ""
+			expected.Snapshots[i].ProjectName = ""
+		}
+		s.Assert().Equal(expected, rec)
+	}
+}
+
+func (s *ReaderUnmarshalSuite) TestUnmarshalIndexKeys() {
+	buf := bufio.NewReader(getData(&s.Suite))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+
+	// The `date` field is tagged `skip`, so it is only written in the array
+	// index. It is still populated for each element.
+	var rec legacyRecord
+	err = r.Unmarshal(buf, &rec)
+	s.Require().Nil(err)
+	s.Assert().Len(rec.List, 3)
+	s.Assert().Equal("2020-10-01", rec.List[0].Date)
+	s.Assert().Equal("2021-03-21", rec.List[1].Date)
+	s.Assert().Equal("2022-12-15", rec.List[2].Date)
+	s.Assert().Equal("this is from 2022", rec.List[2].Name)
+
+	// Integer index keys are populated as well.
+	b := &bytes.Buffer{}
+	w := NewWriterWithVersion(b, Version2)
+	_, err = w.WriteObject(testPackedData)
+	s.Require().Nil(err)
+	buf = bufio.NewReader(b)
+	_, err = r.ReadIndex(buf)
+	s.Require().Nil(err)
+	var shape packedShape
+	err = r.Unmarshal(buf, &shape)
+	s.Require().Nil(err)
+	s.Assert().Equal(testPackedData, shape)
+}
+
+func (s *ReaderUnmarshalSuite) TestUnmarshalNestedIndexedArrays() {
+	b := &bytes.Buffer{}
+	w := NewWriterWithVersion(b, Version2)
+	_, err := w.WriteObject(testNestedData)
+	s.Require().Nil(err)
+
+	// The nested variations are reconstructed, including the ids that are
+	// only written in their array index.
+	buf := bufio.NewReader(b)
+	r := NewReader()
+	_, err = r.ReadIndex(buf)
+	s.Require().Nil(err)
+	var rec nestedRecord
+	err = r.Unmarshal(buf, &rec)
+	s.Require().Nil(err)
+	s.Assert().Equal(testNestedData, rec)
+}
+
+func (s *ReaderUnmarshalSuite) TestUnmarshalKeyFieldSize() {
+	// The index field isn't tagged `skip`, so it's written in each element
+	// as well as in the array index.
+	type snap struct {
+		Date string `rsf:"date,fixed:10"`
+		Name string `rsf:"name"`
+	}
+	type record struct {
+		List []snap `rsf:"list,index:date"`
+	}
+	rec := record{List: []snap{{Date: "2020-10-01", Name: "From 2020"}}}
+	b := &bytes.Buffer{}
+	w := NewWriterWithVersion(b, Version2)
+	_, err := w.WriteObject(rec)
+	s.Require().Nil(err)
+
+	var out record
+	r := NewReader()
+	buf := bufio.NewReader(bytes.NewReader(b.Bytes()))
+	_, err = r.ReadIndex(buf)
+	s.Require().Nil(err)
+	s.Require().Nil(r.Unmarshal(buf, &out))
+	s.Assert().Equal(rec, out)
+
+	// An index whose key size disagrees with the size of the index field
+	// is rejected.
+	data := b.Bytes()
+	header := []byte{0x01, byte(reflect.String), 0x00, 0x00, 0x00, 0x0a, 0x00, 0x00, 0x00}
+	at := bytes.Index(data, header)
+	s.Require().True(at > 0)
+	data[at+5] = 0x08
+	r = NewReader()
+	buf = bufio.NewReader(bytes.NewReader(data))
+	_, err = r.ReadIndex(buf)
+	s.Require().Nil(err)
+	err = r.Unmarshal(buf, &out)
+	s.Assert().ErrorContains(err, "index size 8 for array list does not match the size 10 of the index field date")
+}
+
+func (s *ReaderUnmarshalSuite) TestUnmarshalFieldOrder() {
+	// The destination struct declares the fields in a different order than
+	// the index of the file written with `getData`.
+	type reorderedSnap struct {
+		Verified bool   `rsf:"verified"`
+		Name     string `rsf:"name"`
+		Date     string `rsf:"date,skip,fixed:10"`
+	}
+	type reorderedRecord struct {
+		Rating  float64         `rsf:"rating"`
+		List    []reorderedSnap `rsf:"list,index:date"`
+		Age     int             `rsf:"age"`
+		Ready   bool            `rsf:"ready"`
+		Company string          `rsf:"company"`
+	}
+
+	buf := bufio.NewReader(getData(&s.Suite))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+
+	var rec reorderedRecord
+	err = r.Unmarshal(buf, &rec)
+	s.Require().Nil(err)
+	s.Assert().Equal(reorderedRecord{
+		Rating: 92.689,
+		List: []reorderedSnap{
+			{Name: "From 2020", Date: "2020-10-01"},
+			{Verified: true, Name: "From 2021", Date: "2021-03-21"},
+			{Verified: true, Name: "this is from 2022", Date: "2022-12-15"},
+		},
+		Age:     55,
+		Ready:   true,
+		Company: "posit",
+	}, rec)
+	s.Assert().Equal(int64(249), r.Pos())
+}
+
+func (s *ReaderUnmarshalSuite) TestUnmarshalInvalidTarget() {
+	buf := bufio.NewReader(getData(&s.Suite))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+
+	var rec legacyRecord
+	s.Assert().ErrorIs(r.Unmarshal(buf, rec), ErrInvalidUnmarshalTarget)
+	s.Assert().ErrorIs(r.Unmarshal(buf, nil), ErrInvalidUnmarshalTarget)
+	s.Assert().ErrorIs(r.Unmarshal(buf, &[]string{}), ErrInvalidUnmarshalTarget)
+}
+
+func (s *ReaderUnmarshalSuite) TestReadObjectInto() {
+	data := &bytes.Buffer{}
+	w := NewWriterWithVersion(data, Version2)
+	second := testUpgradedData
+	second.Company = "rstudio"
+	second.Zip = 98101
+	for _, obj := range []upgradedRecord{testUpgradedData, second} {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+
+	buf := bufio.NewReader(data)
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+
+	// The fields found for the first object are reused for the second.
+	var rec upgradedRecord
+	s.Require().Nil(r.ReadObjectInto(buf, &rec))
+	s.Assert().Equal(testUpgradedData, rec)
+
+	// A different struct type gets its own fields.
+	var legacy legacyRecord
+	s.Require().Nil(r.ReadObjectInto(buf, &legacy))
+	s.Assert().Equal("rstudio", legacy.Company)
+	s.Assert().Equal(55, legacy.Age)
+	s.Assert().Len(legacy.List, 2)
+
+	s.Assert().ErrorIs(r.ReadObjectInto(buf, &rec), io.EOF)
+	s.Assert().ErrorIs(r.ReadObjectInto(buf, rec), ErrInvalidUnmarshalTarget)
+}
+
+func (s *ReaderUnmarshalSuite) TestReadObjectIntoNewIndex() {
+	buf := bufio.NewReader(getData(&s.Suite))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+
+	// Find the fields for an index without the `age` field. Once the file's
+	// index is set, `age` must be read.
+	var rec legacyRecord
+	index := r.(*rsfReader).index
+	s.Require().Nil(r.SetProjection(Index{index[0], index[1], index[2], index[4]}))
+	s.Require().Nil(r.ReadObjectInto(buf, &rec))
+	s.Assert().Equal(0, rec.Age)
+
+	buf = bufio.NewReader(getData(&s.Suite))
+	_, err = r.ReadIndex(buf)
+	s.Require().Nil(err)
+	s.Require().Nil(r.ReadObjectInto(buf, &rec))
+	s.Assert().Equal(55, rec.Age)
+}
+
+// getUpgradedData returns 1000 objects of the upgraded struct.
+func getUpgradedData(b *testing.B) []byte {
+	data := &bytes.Buffer{}
+	w := NewWriterWithVersion(data, Version2)
+	for i := 0; i < 1000; i++ {
+		_, err := w.WriteObject(testUpgradedData)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	return data.Bytes()
+}
+
+func BenchmarkReadObjectInto(b *testing.B) {
+	data := getUpgradedData(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf := bufio.NewReader(bytes.NewReader(data))
+		r := NewReader()
+		_, err := r.ReadIndex(buf)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		var rec upgradedRecord
+		for {
+			err = r.ReadObjectInto(buf, &rec)
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkReadObjectAdvanceTo reads the same objects as
+// `BenchmarkReadObjectInto`, navigating to each field by name.
+func BenchmarkReadObjectAdvanceTo(b *testing.B) {
+	data := getUpgradedData(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf := bufio.NewReader(bytes.NewReader(data))
+		r := NewReader()
+		_, err := r.ReadIndex(buf)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		var rec upgradedRecord
+		for {
+			_, err = r.BeginObject(buf)
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				b.Fatal(err)
+			}
+
+			err = readUpgradedRecord(r, buf, &rec)
+			if err != nil {
+				b.Fatal(err)
+			}
+			err = r.SkipObject(buf)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// readUpgradedRecord reads each field of an upgraded record with `AdvanceTo`.
+func readUpgradedRecord(r Reader, buf *bufio.Reader, rec *upgradedRecord) error {
+	var err error
+	read := func(name string, fn func() error) {
+		if err == nil {
+			err = r.AdvanceTo(buf, name)
+		}
+		if err == nil {
+			err = fn()
+		}
+	}
+	read("location", func() (err error) { rec.Location, err = r.ReadStringField(buf); return })
+	read("company", func() (err error) { rec.Company, err = r.ReadStringField(buf); return })
+	read("products", func() error { return r.ReadArrayInto(buf, &rec.Products, "products") })
+	read("ready", func() (err error) { rec.Ready, err = r.ReadBoolField(buf); return })
+	read("portable", func() (err error) { rec.Portable, err = r.ReadBoolField(buf); return })
+	read("list", func() error { return r.ReadArrayInto(buf, &rec.List, "list") })
+	read("income", func() (err error) { rec.Income, err = r.ReadFloatField(buf); return })
+	read("age", func() error {
+		age, err := r.ReadIntField(buf)
+		rec.Age = int(age)
+		return err
+	})
+	read("rating", func() (err error) { rec.Rating, err = r.ReadFloatField(buf); return })
+	read("zip", func() error {
+		zip, err := r.ReadIntField(buf)
+		rec.Zip = int(zip)
+		return err
+	})
+	return err
+}
@@ -0,0 +1,103 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrRepairOverflow = errors.New("objects with overflow strings cannot be repaired")
+var ErrRepairKeyTable = errors.New("files with an object key table cannot be repaired")
+var ErrRepairMultiSchema = errors.New("multi-schema files cannot be repaired")
+var ErrRepairColumnar = errors.New("columnar files cannot be repaired")
+
+// Repair copies the RSF data in `src` to `dst`, correcting object size fields
+// that don't match the size of the object. Some early writers recorded object
+// sizes that were off by four bytes, which prevents skipping objects.
+//
+// The size of each object is recomputed by advancing past each of the fields
+// described by the index, and each object is written with the corrected size.
+// The index, and any data following the last object, are copied as-is. The
+// number of objects with corrected sizes is returned.
+//
+// Since only the fields described by the index are walked, objects must not
+// include data following their fields, like overflow strings or padding (see
+// `PadObjects`). Files with an object key table are also rejected, since
+// correcting object sizes would invalidate the recorded offsets, as are
+// multi-schema files and columnar files, which have no objects.
+func Repair(src io.Reader, dst io.Writer) (int, error) {
+	// Record the bytes read from `src` so that they can be copied.
+	rec := &byteRecorder{}
+	buf := bufio.NewReader(io.TeeReader(src, rec))
+
+	r := &rsfReader{}
+	w := &rsfWriter{}
+	index, err := r.ReadIndex(buf)
+	if err != nil {
+		return 0, fmt.Errorf("error reading index: %s", err)
+	}
+	if r.features&FeatureKeyTable != 0 {
+		return 0, ErrRepairKeyTable
+	}
+	if r.schemas != nil {
+		return 0, ErrRepairMultiSchema
+	}
+	if r.features&FeatureColumnar != 0 {
+		return 0, ErrRepairColumnar
+	}
+	for _, entry := range index {
+		if entry.FieldType == FieldTypeOverflowStr {
+			return 0, ErrRepairOverflow
+		}
+	}
+	_, err = dst.Write(rec.bytes(0, r.pos))
+	if err != nil {
+		return 0, err
+	}
+
+	var fixed int
+	for i := 0; ; i++ {
+		rec.trim(r.pos)
+		start := r.pos
+		var sz int
+		sz, err = r.ReadSizeField(buf)
+		if err == io.EOF {
+			return fixed, nil
+		} else if err != nil {
+			return fixed, fmt.Errorf("error reading object %d: %s", i, err)
+		}
+
+		// A zero size marks the end of the objects. Copy the rest.
+		if sz == 0 {
+			_, err = w.WriteSizeField(0, 0, dst)
+			if err != nil {
+				return fixed, err
+			}
+			_, err = io.Copy(dst, buf)
+			return fixed, err
+		}
+
+		bodyStart := r.pos
+		for _, entry := range index {
+			err = r.advance(entry, buf)
+			if err != nil {
+				return fixed, fmt.Errorf("error reading object %d field %s: %s", i, entry.FieldName, err)
+			}
+		}
+
+		actual := int(r.pos - start)
+		if actual != sz {
+			fixed++
+		}
+		_, err = w.WriteSizeField(0, actual, dst)
+		if err != nil {
+			return fixed, err
+		}
+		_, err = dst.Write(rec.bytes(bodyStart, r.pos))
+		if err != nil {
+			return fixed, err
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type RepairSuite struct {
+	suite.Suite
+}
+
+func TestRepairSuite(t *testing.T) {
+	suite.Run(t, &RepairSuite{})
+}
+
+func (s *RepairSuite) TestRepair() {
+	data := getComplexData(&s.Suite).Bytes()
+
+	// Find the object size fields.
+	r := NewReader()
+	_, err := r.ReadIndex(bytes.NewReader(data))
+	s.Require().Nil(err)
+	first := r.Pos()
+	firstSz := binary.LittleEndian.Uint32(data[first:])
+	second := first + int64(firstSz)
+	secondSz := binary.LittleEndian.Uint32(data[second:])
+	s.Require().Equal(int64(len(data)), second+int64(secondSz))
+
+	// Record object sizes that are off by four bytes.
+	broken := bytes.Clone(data)
+	binary.LittleEndian.PutUint32(broken[first:], firstSz+4)
+	binary.LittleEndian.PutUint32(broken[second:], secondSz-4)
+
+	// The objects can't be read.
+	r = NewReader()
+	buf := bufio.NewReader(bytes.NewReader(broken))
+	_, err = r.ReadIndex(buf)
+	s.Require().Nil(err)
+	_, err = r.DecodeAll(buf)
+	s.Assert().NotNil(err)
+
+	// Repairing restores the original data.
+	repaired := &bytes.Buffer{}
+	fixed, err := Repair(bytes.NewReader(broken), repaired)
+	s.Require().Nil(err)
+	s.Assert().Equal(2, fixed)
+	s.Assert().Equal(data, repaired.Bytes())
+
+	r = NewReader()
+	buf = bufio.NewReader(repaired)
+	_, err = r.ReadIndex(buf)
+	s.Require().Nil(err)
+	objs, err := r.DecodeAll(buf)
+	s.Require().Nil(err)
+	s.Assert().Len(objs, 2)
+
+	// Valid files are copied as-is.
+	copied := &bytes.Buffer{}
+	fixed, err = Repair(bytes.NewReader(data), copied)
+	s.Require().Nil(err)
+	s.Assert().Equal(0, fixed)
+	s.Assert().Equal(data, copied.Bytes())
+}
+
+func (s *RepairSuite) TestRepairUnsupported() {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	for _, obj := range testOverflowData {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+	_, err := Repair(bytes.NewReader(buf.Bytes()), &bytes.Buffer{})
+	s.Assert().ErrorIs(err, ErrRepairOverflow)
+
+	buf = &bytes.Buffer{}
+	w = NewWriterWithVersion(buf, Version3, ObjectKey("name"))
+	_, err = w.WriteObject(testNamedData)
+	s.Require().Nil(err)
+	s.Require().Nil(w.Close())
+	_, err = Repair(bytes.NewReader(buf.Bytes()), &bytes.Buffer{})
+	s.Assert().ErrorIs(err, ErrRepairKeyTable)
+}
@@ -0,0 +1,357 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// Rewrite reads each object from `src`, applies `transform`, and writes the
+// transformed objects to `dst` with a new index. This supports migrating a
+// file to a compatible schema (e.g., adding or removing fields) without
+// writing a struct for each schema.
+//
+// Objects are passed to `transform` as decoded by `DecodeObject`. The new
+// index is built from the first transformed object:
+//
+//   - Fields from the source index keep their source type, including
+//     fixed-length strings, compression, and array indexes. Source fields are
+//     written in their source order.
+//   - Added fields follow the source fields, sorted by name. Their types are
+//     determined from their values, which must be strings, bools, integers,
+//     floats, or slices of these types.
+//
+// All transformed objects must be compatible with the new index. If
+// `transform` returns nil, the object is dropped. The destination is written
+// with `Version2`. Multi-schema files cannot be rewritten.
+func Rewrite(src *bufio.Reader, dst io.Writer, transform func(map[string]any) map[string]any) error {
+	r := NewReader()
+	index, err := r.ReadIndex(src)
+	if err != nil {
+		return fmt.Errorf("error reading index: %s", err)
+	}
+	if r.Schemas() != nil {
+		return errors.New("multi-schema files cannot be rewritten")
+	}
+
+	return rewriteObjects(r, index, src, NewWriterWithVersion(dst, Version2), transform)
+}
+
+// rewriteObjects reads each remaining object from `src` with `r`, applies
+// `transform`, and writes the transformed objects with `w`, building the new
+// index from `index` as described for `Rewrite`. The writer is closed when
+// complete.
+func rewriteObjects(r Reader, index Index, src *bufio.Reader, w Writer, transform func(map[string]any) map[string]any) error {
+	var schema *mapSchema
+	for i := 0; ; i++ {
+		obj, err := r.DecodeObject(src)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("error reading object %d: %s", i, err)
+		}
+
+		obj = transform(obj)
+		if obj == nil {
+			continue
+		}
+
+		if schema == nil {
+			schema, err = newMapSchema(index, obj, nil)
+			if err != nil {
+				return fmt.Errorf("error building index: %s", err)
+			}
+		}
+
+		v := reflect.New(schema.typ).Elem()
+		err = schema.set(v, obj)
+		if err != nil {
+			return fmt.Errorf("error converting object %d: %s", i, err)
+		}
+		_, err = w.WriteObject(v.Interface())
+		if err != nil {
+			return fmt.Errorf("error writing object %d: %s", i, err)
+		}
+	}
+
+	return w.Close()
+}
+
+// mapSchema describes a struct type, built at runtime, that is used to write
+// generic maps with `WriteObject`.
+type mapSchema struct {
+	typ reflect.Type
+
+	// Maps each map key to its struct field index.
+	fields map[string]int
+
+	// The element schemas of struct arrays, by map key.
+	elems map[string]*mapSchema
+}
+
+// newMapSchema builds a schema for the fields of `index` that are present in
+// the `sample` map, followed by any fields that are only present in `sample`.
+// If `sample` is nil, all fields in `index` are included. For the elements of
+// indexed arrays, `key` describes the array index and adds an `IndexKeyField`
+// field used as the array index key.
+func newMapSchema(index Index, sample map[string]any, key *IndexEntry) (*mapSchema, error) {
+	s := &mapSchema{
+		fields: make(map[string]int),
+		elems:  make(map[string]*mapSchema),
+	}
+
+	var structFields []reflect.StructField
+	add := func(name string, t reflect.Type, tag string, elem *mapSchema) {
+		s.fields[name] = len(structFields)
+		if elem != nil {
+			s.elems[name] = elem
+		}
+		structFields = append(structFields, reflect.StructField{
+			Name: fmt.Sprintf("F%d", len(structFields)),
+			Type: t,
+			Tag:  reflect.StructTag(fmt.Sprintf(`%s:"%s"`, tagName, tag)),
+		})
+	}
+
+	// The index key is only written in the array index, so it is skipped
+	// when writing the element.
+	if key != nil {
+		switch reflect.Kind(key.IndexType) {
+		case reflect.String:
+			add(IndexKeyField, reflect.TypeOf(""), fmt.Sprintf("%s%s%s%s%s%s%d", IndexKeyField, rsfDelim, rsfSkip, rsfDelim, rsfFixed, rsfSep, key.IndexSize), nil)
+		case reflect.Int64:
+			add(IndexKeyField, reflect.TypeOf(int64(0)), IndexKeyField+rsfDelim+rsfSkip, nil)
+		case reflect.Array:
+			add(IndexKeyField, reflect.ArrayOf(key.IndexSize, reflect.TypeOf(byte(0))), IndexKeyField+rsfDelim+rsfSkip, nil)
+		default:
+			return nil, ErrInvalidIndexFieldType
+		}
+	}
+
+	for _, entry := range index {
+		if _, ok := sample[entry.FieldName]; sample != nil && !ok {
+			continue
+		}
+		t, tag, elem, err := entryType(entry, sample[entry.FieldName])
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %s", entry.FieldName, err)
+		}
+		add(entry.FieldName, t, tag, elem)
+	}
+
+	// Add any new fields.
+	var added []string
+	for name := range sample {
+		if _, ok := s.fields[name]; !ok {
+			added = append(added, name)
+		}
+	}
+	sort.Strings(added)
+	for _, name := range added {
+		t, err := valueType(sample[name])
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %s", name, err)
+		}
+		add(name, t, name, nil)
+	}
+
+	s.typ = reflect.StructOf(structFields)
+	return s, nil
+}
+
+// entryType returns the struct field type and `rsf` tag used to write the
+// field described by `entry`. For struct arrays, the element schema is also
+// returned. The `sample` value is used to find the fields of struct array
+// elements.
+func entryType(entry IndexEntry, sample any) (reflect.Type, string, *mapSchema, error) {
+	switch entry.FieldType {
+	case FieldTypeVarStr:
+		return reflect.TypeOf(""), entry.FieldName, nil, nil
+	case FieldTypeCompressedStr:
+		return reflect.TypeOf(""), entry.FieldName + rsfDelim + rsfCompress, nil, nil
+	case FieldTypeOverflowStr:
+		return reflect.TypeOf(""), entry.FieldName + rsfDelim + rsfOverflow, nil, nil
+	case FieldTypeFixedStr:
+		return reflect.TypeOf(""), fmt.Sprintf("%s%s%s%s%d", entry.FieldName, rsfDelim, rsfFixed, rsfSep, entry.FieldSize), nil, nil
+	case FieldTypeBool, FieldTypePackedBool:
+		return reflect.TypeOf(false), entry.FieldName, nil, nil
+	case FieldTypeInt64:
+		return reflect.TypeOf(int64(0)), entry.FieldName, nil, nil
+	case FieldTypeFixedInt64:
+		return reflect.TypeOf(int64(0)), entry.FieldName + rsfDelim + rsfFixedInt, nil, nil
+	case FieldTypeFloat:
+		return reflect.TypeOf(float64(0)), entry.FieldName, nil, nil
+	case FieldTypeBigEndianInt, FieldTypeBigEndianFloat:
+		return bigEndianType(entry), entry.FieldName + rsfDelim + rsfBigEndian, nil, nil
+	case FieldTypeIP:
+		return netipAddrType, entry.FieldName, nil, nil
+	case FieldTypeDays:
+		return timeType, entry.FieldName + rsfDelim + rsfDays, nil, nil
+	case FieldTypeOpaque:
+		return reflect.TypeOf(opaqueBytes(nil)), entry.FieldName, nil, nil
+	case FieldTypeBytes:
+		return reflect.TypeOf([]byte(nil)), entry.FieldName, nil, nil
+	case FieldTypeArray, FieldTypePackedArray:
+	default:
+		return nil, "", nil, fmt.Errorf("unexpected index field type %d", entry.FieldType)
+	}
+
+	tag := entry.FieldName
+	if entry.FieldType == FieldTypePackedArray {
+		tag += rsfDelim + rsfPacked
+	}
+
+	kind := reflect.Kind(entry.SubfieldType)
+	if kind == reflect.Invalid && len(entry.Subfields) > 0 {
+		kind = reflect.Struct
+	}
+	switch kind {
+	case reflect.String:
+		return reflect.TypeOf([]string{}), tag, nil, nil
+	case reflect.Bool:
+		return reflect.TypeOf([]bool{}), tag, nil, nil
+	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+		// Packed arrays keep their element width.
+		if entry.FieldType == FieldTypePackedArray && entry.FieldSize == sizeInt32 {
+			return reflect.TypeOf([]int32{}), tag, nil, nil
+		}
+		return reflect.TypeOf([]int64{}), tag, nil, nil
+	case reflect.Float32, reflect.Float64:
+		if entry.FieldType == FieldTypePackedArray && entry.FieldSize == sizeFloat32 {
+			return reflect.TypeOf([]float32{}), tag, nil, nil
+		}
+		return reflect.TypeOf([]float64{}), tag, nil, nil
+	case reflect.Struct:
+	default:
+		return nil, "", nil, fmt.Errorf("cannot rewrite arrays of %s", kind)
+	}
+
+	// Use the first element, if any, to find the element fields.
+	var elSample map[string]any
+	if els, ok := sample.([]any); ok && len(els) > 0 {
+		elSample, _ = els[0].(map[string]any)
+	}
+
+	var key *IndexEntry
+	if entry.Indexed {
+		key = &entry
+		tag += rsfDelim + rsfIndex + rsfSep + IndexKeyField
+	}
+	elem, err := newMapSchema(entry.Subfields, elSample, key)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return reflect.SliceOf(elem.typ), tag, elem, nil
+}
+
+// valueType returns the type used to write a value added by a transform.
+func valueType(v any) (reflect.Type, error) {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return nil, fmt.Errorf("cannot determine the type of a nil value")
+	}
+	el := t
+	if t.Kind() == reflect.Slice {
+		el = t.Elem()
+	}
+	switch el.Kind() {
+	case reflect.String, reflect.Bool, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+		return t, nil
+	default:
+		return nil, fmt.Errorf("cannot write values of type %s", t)
+	}
+}
+
+// set assigns the values in `obj` to the struct `v`, which must be of the
+// schema type. Fields missing from `obj` are left at their zero value.
+func (s *mapSchema) set(v reflect.Value, obj map[string]any) error {
+	for key, val := range obj {
+		i, ok := s.fields[key]
+		if !ok {
+			return fmt.Errorf("field %s is not in the index", key)
+		}
+		err := s.setField(key, v.Field(i), val)
+		if err != nil {
+			return fmt.Errorf("field %s: %s", key, err)
+		}
+	}
+	return nil
+}
+
+func (s *mapSchema) setField(key string, field reflect.Value, val any) error {
+	if val == nil {
+		return nil
+	}
+
+	// Struct arrays are converted element by element.
+	if elem, ok := s.elems[key]; ok {
+		els, ok := val.([]any)
+		if !ok {
+			return fmt.Errorf("cannot convert %T to an array", val)
+		}
+		slice := reflect.MakeSlice(field.Type(), len(els), len(els))
+		for i, el := range els {
+			m, ok := el.(map[string]any)
+			if !ok {
+				return fmt.Errorf("cannot convert element %T to a struct", el)
+			}
+			err := elem.set(slice.Index(i), m)
+			if err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+
+	// Other arrays may be decoded as []any.
+	if els, ok := val.([]any); ok && field.Kind() == reflect.Slice {
+		slice := reflect.MakeSlice(field.Type(), len(els), len(els))
+		for i, el := range els {
+			err := convert(slice.Index(i), el)
+			if err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+
+	return convert(field, val)
+}
+
+// convert assigns `val` to `v`, converting between numeric types.
+func convert(v reflect.Value, val any) error {
+	rv := reflect.ValueOf(val)
+	if rv.Type().AssignableTo(v.Type()) {
+		v.Set(rv)
+		return nil
+	}
+
+	// Fixed-size byte array keys are decoded as byte slices.
+	if b, ok := val.([]byte); ok && isByteArray(v.Type()) && v.Len() == len(b) {
+		reflect.Copy(v, rv)
+		return nil
+	}
+
+	// Only allow conversions within the same kind of value (e.g., int to
+	// int64), not from numbers to strings.
+	numeric := func(k reflect.Kind) bool {
+		switch k {
+		case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8,
+			reflect.Float32, reflect.Float64:
+			return true
+		}
+		return false
+	}
+	if rv.Kind() == v.Kind() || numeric(rv.Kind()) && numeric(v.Kind()) {
+		v.Set(rv.Convert(v.Type()))
+		return nil
+	}
+	return fmt.Errorf("cannot convert %T to %s", val, v.Type())
+}
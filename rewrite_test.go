@@ -0,0 +1,128 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type RewriteSuite struct {
+	suite.Suite
+}
+
+func TestRewriteSuite(t *testing.T) {
+	suite.Run(t, &RewriteSuite{})
+}
+
+type migratedSnapshot struct {
+	Description string `rsf:"description"`
+	Deleted     bool   `rsf:"deleted"`
+	Snapshot    string `rsf:"snapshot,skip,fixed:10"`
+	Version     string `rsf:"version"`
+	License     string `rsf:"license"`
+}
+
+type migratedRecord struct {
+	HomePage      string             `rsf:"homepage"`
+	CanonicalName string             `rsf:"cname"`
+	ProjectName   string             `rsf:"pname"`
+	Classifiers   []Classifier       `rsf:"classifiers"`
+	Author        string             `rsf:"author"`
+	Snapshots     []migratedSnapshot `rsf:"snapshots,index:snapshot"`
+	Popularity    int64              `rsf:"popularity"`
+	Migrated      bool               `rsf:"migrated"`
+}
+
+func (s *RewriteSuite) TestRewrite() {
+	src := &bytes.Buffer{}
+	w := NewWriterWithVersion(src, Version2)
+	for _, obj := range testComplexData {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+
+	// Drop the snapshot `summary` field and add a `migrated` field.
+	dst := &bytes.Buffer{}
+	err := Rewrite(bufio.NewReader(src), dst, func(obj map[string]any) map[string]any {
+		for _, snapshot := range obj["snapshots"].([]any) {
+			delete(snapshot.(map[string]any), "summary")
+		}
+		obj["migrated"] = true
+		return obj
+	})
+	s.Require().Nil(err)
+
+	buf := bufio.NewReader(dst)
+	r := NewReader()
+	index, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+
+	// The index no longer includes `summary`, and `migrated` is last.
+	s.Assert().Equal(IndexEntry{FieldName: "migrated", FieldType: FieldTypeBool}, index[len(index)-1])
+	snapshots, _, err := entrySet(index, "snapshots", Top)
+	s.Require().Nil(err)
+	for _, entry := range snapshots {
+		s.Assert().NotEqual("summary", entry.FieldName)
+	}
+
+	for _, obj := range testComplexData {
+		expected := migratedRecord{
+			HomePage:      obj.HomePage,
+			CanonicalName: obj.CanonicalName,
+			ProjectName:   obj.ProjectName,
+			Classifiers:   obj.Classifiers,
+			Author:        obj.Author,
+			Popularity:    obj.Popularity,
+			Migrated:      true,
+		}
+		for _, snapshot := range obj.Snapshots {
+			expected.Snapshots = append(expected.Snapshots, migratedSnapshot{
+				Description: snapshot.Description,
+				Deleted:     snapshot.Deleted,
+				Snapshot:    snapshot.Snapshot,
+				Version:     snapshot.Version,
+				License:     snapshot.License,
+			})
+		}
+
+		var rec migratedRecord
+		err = r.Unmarshal(buf, &rec)
+		s.Require().Nil(err)
+		s.Assert().Equal(expected, rec)
+	}
+
+	var rec migratedRecord
+	err = r.Unmarshal(buf, &rec)
+	s.Assert().ErrorIs(err, io.EOF)
+}
+
+func (s *RewriteSuite) TestRewriteErrors() {
+	src := &bytes.Buffer{}
+	w := NewWriterWithVersion(src, Version2)
+	for _, obj := range testComplexData {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+
+	// Added fields must be written consistently.
+	var i int
+	err := Rewrite(bufio.NewReader(bytes.NewReader(src.Bytes())), io.Discard, func(obj map[string]any) map[string]any {
+		i++
+		if i == 2 {
+			obj["added"] = "later"
+		}
+		return obj
+	})
+	s.Assert().EqualError(err, "error converting object 1: field added is not in the index")
+
+	// Values must have a supported type.
+	err = Rewrite(bufio.NewReader(bytes.NewReader(src.Bytes())), io.Discard, func(obj map[string]any) map[string]any {
+		obj["added"] = map[string]any{}
+		return obj
+	})
+	s.Assert().EqualError(err, "error building index: field added: cannot write values of type map[string]interface {}")
+}
@@ -4,56 +4,243 @@ package rsf
 import (
 	"bufio"
 	"io"
+	"net/netip"
+	"time"
 )
 
 type Writer interface {
 	// WriteObject uses reflection and `rsf` struct tag annotations to write an object.
+	// Objects are buffered to find their size, unless the destination is an
+	// `io.WriteSeeker` that can also be truncated (with a `Truncate(int64)
+	// error` method, like `*os.File`), in which case the size is written after
+	// the object. Other destinations, like a `bytes.Buffer`, are buffered.
 	WriteObject(v any) (int, error)
 
+	// WriteObjectWith writes an object like `WriteObject`, using the
+	// provided options.
+	WriteObjectWith(v any, opts WriteOptions) (int, error)
+
 	// WriteSizeField writes a 4-byte field that indicates a size (usually the
 	// size in bytes of an object or value, or an array length).
 	WriteSizeField(pos int, val int, r io.Writer) (int, error)
 
 	// WriteFixedStringField writes a string of a fixed length. An error is returned
-	// if the string size does not match the provided `sz` parameter.
+	// if the string size does not match the provided `sz` parameter, unless
+	// `TruncateFixed` is enabled and the string is too long.
 	WriteFixedStringField(pos, sz int, val string, r io.Writer) (int, error)
 
 	// WriteStringField writes a variable length string. The string value will be
 	// prepended with a 4-byte size field that indicates the string length.
 	WriteStringField(pos int, val string, r io.Writer) (int, error)
 
+	// WriteStringFromReader writes a variable-length string of `length`
+	// bytes copied from `src`, prepended with a 4-byte size field.
+	WriteStringFromReader(pos int, length int, src io.Reader, dst io.Writer) (int, error)
+
 	// WriteBoolField writes a 1-byte (0 or 1) boolean value.
 	WriteBoolField(pos int, val bool, r io.Writer) (int, error)
 
 	// WriteInt64Field write a 10-byte signed int64 value.
 	WriteInt64Field(pos int, val int64, r io.Writer) (int, error)
 
+	// WriteFixedInt64Field writes an 8-byte little-endian int64 value.
+	WriteFixedInt64Field(pos int, val int64, r io.Writer) (int, error)
+
 	// WriteFloatField write an 8-byte float64 value
 	WriteFloatField(pos int, val float64, r io.Writer) (int, error)
+
+	// WriteCompressedStringField writes a compressed string, using gzip
+	// unless another codec is selected with `Compression`. The compressed
+	// value will be prepended with a 4-byte size field that indicates the
+	// compressed length.
+	WriteCompressedStringField(pos int, val string, r io.Writer) (int, error)
+
+	// WriteIPField writes an IP address as a 1-byte length followed by the
+	// 4-byte (IPv4) or 16-byte (IPv6) address.
+	WriteIPField(pos int, val netip.Addr, r io.Writer) (int, error)
+
+	// WriteDaysField writes the date of a time as a 4-byte number of days
+	// since 1970-01-01.
+	WriteDaysField(pos int, val time.Time, r io.Writer) (int, error)
+
+	// WriteBytesField writes a byte slice. The bytes will be prepended with
+	// a 4-byte size field that indicates their length.
+	WriteBytesField(pos int, val []byte, r io.Writer) (int, error)
+
+	// WriteColumnar writes a slice of structs in columns, with the values of
+	// each top-level field stored together. See `ReadColumn`.
+	WriteColumnar(records any) error
+
+	// WriteTrailer sets a metadata blob that is written after the last
+	// object when the writer is closed. See `ReadTrailer`.
+	WriteTrailer(meta []byte)
+
+	// Close writes any data buffered by the writer. Writers that use a string
+	// table buffer all objects until Close is called. Close does not close the
+	// underlying io.Writer.
+	Close() error
 }
 
 // Reader - The Reader interface provides Read* methods analogous to the Write*
-// methods in the Writer interface. No `ReadObject` method is provided since
-// reading is likely to be customized per use case.
+// methods in the Writer interface. The `Unmarshal` method is analogous to
+// `WriteObject`, but reading is often customized per use case with `AdvanceTo`
+// and the Read* methods.
+//
+// The Read* methods return `io.EOF` only when the underlying reader ends
+// before a field. If it ends partway through a field, `io.ErrUnexpectedEOF` is
+// returned. Errors from `DecodeObject`, `Unmarshal`, and `Keys` wrap these
+// errors, so they can be checked with `errors.Is`. When reading from a bounded
+// reader, like an `io.LimitReader` serving a range of a file, the limit must
+// include each complete object to be decoded.
 type Reader interface {
 	ReadSizeField(r io.Reader) (int, error)
 	ReadFixedStringField(sz int, r io.Reader) (string, error)
 	ReadStringField(r io.Reader) (string, error)
 	ReadBoolField(r io.Reader) (bool, error)
 	ReadIntField(r io.Reader) (int64, error)
+	ReadFixedIntField(r io.Reader) (int64, error)
 	ReadFloatField(r io.Reader) (float64, error)
+	ReadCompressedStringField(r io.Reader) (string, error)
+	ReadOverflowStringField(r io.ReadSeeker) (string, error)
+	ReadIPField(r io.Reader) (netip.Addr, error)
+	ReadDaysField(r io.Reader) (time.Time, error)
+	ReadBytesField(r io.Reader) ([]byte, error)
 
 	// AdvanceTo advances the reader to the field indicated by `fieldNames`.
+	// If the field is an array that is absent from the object (see
+	// `NilAsAbsent`), an error wrapping `ErrFieldAbsent` is returned, and
+	// the reader may advance to the following fields.
 	AdvanceTo(buf *bufio.Reader, fieldNames ...string) error
 
+	// AdvanceToPath advances the reader to the field indicated by a dotted
+	// field path, like "list.name".
+	AdvanceToPath(buf *bufio.Reader, path string) error
+
+	// ReadFieldPath advances to the field indicated by a dotted field path
+	// and reads its value.
+	ReadFieldPath(buf *bufio.Reader, path string) (any, error)
+
+	// FieldBytes advances to the field indicated by `fieldNames` and
+	// returns its raw encoded bytes.
+	FieldBytes(buf *bufio.Reader, fieldNames ...string) ([]byte, error)
+
+	// ReadFieldString advances to the scalar field indicated by
+	// `fieldNames` and reads its value formatted as a string.
+	ReadFieldString(buf *bufio.Reader, fieldNames ...string) (string, error)
+
 	// AdvanceToNextElement advances the reader to the end of the current
 	// struct.
 	AdvanceToNextElement(buf *bufio.Reader, fieldNames ...string) error
 
+	// CurrentField returns the index entry of the field at the reader's
+	// current position, and false if the reader is not at a field.
+	CurrentField() (IndexEntry, bool)
+
 	// ReadIndex reads the object index at the top of an RSF file
 	ReadIndex(r io.Reader) (Index, error)
 	SetIndex(i Index)
 
+	// SetProjection restricts the reader to a partial index of the fields
+	// known to the consumer, while the full index is used to skip the other
+	// fields.
+	SetProjection(projection Index) error
+
+	// SkipIndex seeks past the index without parsing it, leaving the reader
+	// at the first object. Use `SetIndex` to provide the index.
+	SkipIndex(r io.ReadSeeker) error
+
+	// ReadFooterIndex reads the index written after the objects by a writer
+	// with `FooterIndex`, leaving `r` at the first object.
+	ReadFooterIndex(r io.ReadSeeker) (Index, error)
+
+	// SchemaFingerprint returns the schema fingerprint of the index. See
+	// `Index.Fingerprint`.
+	SchemaFingerprint() uint64
+
+	// WriterVersion returns the producer version recorded in the index
+	// header, or an empty string. See `ProducerVersion`.
+	WriterVersion() string
+
+	// Features returns the feature flags recorded in the index header, like
+	// `FeatureStringTable`.
+	Features() uint32
+
+	// FindObject seeks to the object with the given key using the object
+	// key table. See `ObjectKey`.
+	FindObject(r io.ReadSeeker, key any) (bool, error)
+
+	// SeekToArrayElement seeks to element `n` of the packed array indicated
+	// by `fieldNames`. The reader must be positioned at the start of the array.
+	SeekToArrayElement(r io.ReadSeeker, n int, fieldNames ...string) error
+
+	// ReadPackedInt32Slice and the like read a packed array of numbers all
+	// at once. The reader must be positioned at the start of the array.
+	ReadPackedInt32Slice(r io.Reader) ([]int32, error)
+	ReadPackedInt64Slice(r io.Reader) ([]int64, error)
+	ReadPackedFloat32Slice(r io.Reader) ([]float32, error)
+	ReadPackedFloat64Slice(r io.Reader) ([]float64, error)
+
+	// ReadArrayInto reads the array indicated by `fieldNames` into `dst`, a
+	// pointer to a slice, reusing the slice's existing capacity.
+	ReadArrayInto(buf *bufio.Reader, dst any, fieldNames ...string) error
+
+	// SeekToIndexValue advances to the element of the indexed array
+	// indicated by `fieldNames` with the index key `key`. The reader must be
+	// positioned at the start of the array.
+	SeekToIndexValue(buf *bufio.Reader, key any, fieldNames ...string) (bool, error)
+
+	// ForEachElement calls `fn` for each element of the indexed or packed
+	// array indicated by `fieldNames`, positioned at the element's fields.
+	ForEachElement(buf *bufio.Reader, fn func(i int, key any) error, fieldNames ...string) error
+
+	// ReadArrayIndex reads the index of the indexed or packed array
+	// indicated by `fieldNames`. The reader must be positioned at the start
+	// of the array.
+	ReadArrayIndex(r io.Reader, fieldNames ...string) (*ArrayIndex, error)
+
+	// ReadElementAt seeks to and reads element `n` of an array whose index
+	// was read with `ReadArrayIndex`.
+	ReadElementAt(r io.ReadSeeker, idx *ArrayIndex, n int) (any, error)
+
+	// SkipArrayElement discards the array element at the current position
+	// of the array indicated by `fieldNames`.
+	SkipArrayElement(buf *bufio.Reader, fieldNames ...string) error
+
+	// BeginObject reads the size field at the start of an object and returns
+	// the object size. An `io.EOF` error is returned at the end of the objects.
+	BeginObject(r io.Reader) (int, error)
+
+	// NextObject discards the remainder of the current object and begins
+	// the next object, resetting the per-object reader state.
+	NextObject(buf *bufio.Reader) error
+
+	// RemainingInObject returns the number of bytes remaining in the object
+	// started with `BeginObject`.
+	RemainingInObject() int
+
+	// SkipObject discards the remainder of the object started with
+	// `BeginObject`.
+	SkipObject(buf *bufio.Reader) error
+
+	// SkipToObject skips the next `n` objects, leaving the reader at the
+	// start of the object that follows.
+	SkipToObject(buf *bufio.Reader, n int) error
+
+	// EndObjectStrict returns an error unless exactly the bytes of the
+	// object started with `BeginObject` have been read.
+	EndObjectStrict() error
+
+	// SchemaID returns the schema id of the current object in a
+	// multi-schema file.
+	SchemaID() int
+
+	// Schemas returns the indexes of a multi-schema file, by schema id.
+	Schemas() []Index
+
+	// Keys reads the value of the top-level field `field` from each
+	// remaining object.
+	Keys(buf *bufio.Reader, field string) ([]any, error)
+
 	// Seek is used to seek a file position.
 	Seek(pos int, r io.Seeker, fieldNames ...string) error
 
@@ -61,7 +248,37 @@ type Reader interface {
 	Discard(sz int, r *bufio.Reader, fieldNames ...string) error
 
 	// Pos returns the current position in the read buffer.
-	Pos() int
+	Pos() int64
+
+	// At returns a copy of the field path that the reader was last advanced
+	// to.
+	At() []string
+
+	// ReadObjectInto reads the next object into a struct like `Unmarshal`,
+	// reusing the fields found for the struct type.
+	ReadObjectInto(buf *bufio.Reader, v any) error
+
+	// DecodeObject reads the next object into a generic map.
+	DecodeObject(buf *bufio.Reader) (map[string]any, error)
+
+	// DecodeAll reads each remaining object into a generic map.
+	DecodeAll(buf *bufio.Reader) ([]map[string]any, error)
+
+	// DecodeN reads the next `n` objects into generic maps, stopping early
+	// if the file ends.
+	DecodeN(buf *bufio.Reader, n int) ([]map[string]any, error)
+
+	// ReadScalars reads the top-level scalar fields of the next object into
+	// a generic map, skipping arrays.
+	ReadScalars(buf *bufio.Reader) (map[string]any, error)
+
+	// ObjectToJSON reads the next object and returns it as a JSON object,
+	// without decoding it into a map.
+	ObjectToJSON(buf *bufio.Reader) ([]byte, error)
+
+	// Unmarshal uses reflection and `rsf` struct tag annotations to read the
+	// next object into `v`, which must be a pointer to a struct.
+	Unmarshal(buf *bufio.Reader, v any) error
 }
 
 // General constants
@@ -69,6 +286,12 @@ const (
 	sizeFieldLen = 4
 	sizeFloat64  = 8
 	sizeInt64    = 10
+
+	sizeFixedInt64 = 8
+
+	// Element widths of packed arrays of 32-bit numbers
+	sizeInt32   = 4
+	sizeFloat32 = 4
 )
 
 // Constants used by `rsf` struct tags
@@ -86,6 +309,8 @@ const (
 	rsfDelim = ","
 	// Separates a struct tag parameter that uses the name:value format.
 	rsfSep = ":"
+	// Separates the names in a field path (e.g., "snapshots.snapshot").
+	rsfPathSep = "."
 
 	//
 	// Parameters:
@@ -98,6 +323,23 @@ const (
 	rsfFixed = "fixed"
 	// Denotes that a field is used to index an array.
 	rsfIndex = "index"
+	// Denotes a variable-length string field that is compressed.
+	rsfCompress = "compress"
+	// Denotes an array of fixed-size structs that is written packed.
+	rsfPacked = "packed"
+	// Denotes a string field that is written after the object's other
+	// fields.
+	rsfOverflow = "overflow"
+	// Denotes an integer field that is written as a fixed 8-byte value.
+	rsfFixedInt = "fixedint"
+	// Denotes a numeric field that is written as a fixed-width big-endian
+	// value.
+	rsfBigEndian = "be"
+	// Denotes a `time.Time` field that is written as a number of days.
+	rsfDays = "days"
+	// Denotes a bool field that is packed in the object's bitmap, so that
+	// false values use no bytes.
+	rsfFalseOmit = "falseomit"
 )
 
 // A struct used to record and pass information about `rsf` struct tags
@@ -108,4 +350,22 @@ type tag struct {
 	indexSz   int
 	indexVal  any
 	indexType int
+	compress  bool
+	packed    bool
+	overflow  bool
+	fixedInt  bool
+	bigEndian int
+	days      bool
+	falseOmit bool
+
+	// The field path, the path prefix for subfields, and the fixed size
+	// overrides by path. See `WriteOptions`.
+	path      string
+	prefix    string
+	overrides map[string]int
+
+	// The index functions by array path, and, for arrays, the function
+	// that computes each element's index key. See `WriteOptions`.
+	indexFuncs map[string]func(elem any) any
+	indexFunc  func(elem any) any
 }
@@ -2,10 +2,16 @@
 package rsf
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
+	"log"
 	"math"
+	"net/netip"
+	"reflect"
+	"strings"
+	"unicode/utf8"
 )
 
 // IndexVersion2 is the first recorded index version. It consists of:
@@ -14,15 +20,311 @@ import (
 //   - ASCII character "2".
 var IndexVersion2 = []byte{0x00, 0x08, 0x32}
 
+// IndexVersion3 adds a 4-byte feature flags field following the index
+// version. It consists of:
+//   - NULL
+//   - backspace
+//   - ASCII character "3".
+var IndexVersion3 = []byte{0x00, 0x08, 0x33}
+
 var (
 	Version1 = 1
 	Version2 = 2
+	Version3 = 3
+)
+
+// Feature flags recorded in the Version3 index header. Each optional feature
+// that changes how a file is read sets a bit, and readers report the flags of
+// a file with `Features`.
+const (
+	// Variable-length strings are written to a string table that follows
+	// the index. See `StringTable`.
+	FeatureStringTable = 1 << 0
+	// An object key table is written after the last object. See `ObjectKey`.
+	FeatureKeyTable = 1 << 1
+	// Each index entry includes a type tag. See `VerboseIndex`.
+	FeatureVerboseIndex = 1 << 2
+	// Each index entry includes the byte width of the field. See
+	// `FieldWidths`.
+	FeatureFieldWidths = 1 << 3
+	// Index entries reference field names by id in a field name table. See
+	// `FieldNameTable`.
+	FeatureFieldNameTable = 1 << 4
+	// Top-level bool fields are packed in a bitmap. See `PackBools`.
+	FeaturePackedBools = 1 << 5
+	// The index header includes a schema fingerprint. See `Fingerprint`.
+	FeatureFingerprint = 1 << 6
+	// The index header includes the version of the producer. See
+	// `ProducerVersion`.
+	FeatureProducerVersion = 1 << 7
+	// Records are written in columns rather than as objects. See
+	// `WriteColumnar`.
+	FeatureColumnar = 1 << 8
+	// Compressed strings use zstd rather than gzip. See `Compression`.
+	FeatureZstd = 1 << 9
+	// Several indexes follow the first, and each object begins with the id
+	// of its index. See `NewWriterWithSchemas`.
+	FeatureMultiSchema = 1 << 10
+	// Top-level arrays may be absent from an object, with their presence
+	// recorded in the bitmap. See `NilAsAbsent`.
+	FeatureNilAsAbsent = 1 << 11
 )
 
 type rsfWriter struct {
 	writer  io.Writer
 	version int
 	pos     int
+
+	// When enabled, variable-length strings are interned in a string table.
+	// See `StringTable`.
+	stringTable bool
+	strings     map[string]int
+	stringList  []string
+
+	// Writers that must write data after the index (like the string table)
+	// buffer the index and objects until `Close` is called.
+	header  *bytes.Buffer
+	pending *bytes.Buffer
+
+	// The total number of bytes written (or buffered) by `WriteObject`.
+	written int64
+
+	// When set, the value of this top-level field is recorded with each
+	// object's offset. See `ObjectKey`.
+	objectKey string
+	keys      []objectKeyEntry
+
+	// When set, the key table is written to this writer instead of after the
+	// last object. See `NewIndexedWriter`.
+	keyWriter io.Writer
+
+	// When enabled, index entries include type tags. See `VerboseIndex`.
+	verboseIndex bool
+
+	// When enabled, index entries include field widths. See `FieldWidths`.
+	fieldWidths bool
+
+	// When enabled, field names are interned in a field name table. See
+	// `FieldNameTable`.
+	fieldNameTable bool
+	fieldNames     map[string]int
+	fieldNameList  []string
+
+	// When enabled, fixed-length strings that are too long are truncated.
+	// See `TruncateFixed`.
+	truncateFixed bool
+
+	// An optional logger for warnings. See `WarningLogger`.
+	logger *log.Logger
+
+	// When enabled, top-level bool fields are packed in a bitmap. The
+	// number of packed fields is counted when the index is written, and
+	// the bitmap of the current object is filled as its fields are
+	// written. See `PackBools`.
+	packBools bool
+	boolCount int
+	bitmap    []byte
+	nextBit   int
+
+	// When enabled, nil top-level slices and maps are omitted, with their
+	// presence recorded in the bitmap. See `NilAsAbsent`.
+	nilAsAbsent bool
+
+	// When set, each object is padded to a multiple of this size. See
+	// `PadObjects`.
+	padTo int
+
+	// When enabled, the schema fingerprint is written in the index header.
+	// See `Fingerprint`.
+	fingerprint bool
+
+	// When enabled, strings that contain NUL bytes are rejected. See
+	// `RejectNulStrings`.
+	rejectNul bool
+
+	// When set, the producer version is written in the index header. See
+	// `ProducerVersion`.
+	producerVersion string
+
+	// When set, the trailer is written after the last object. See
+	// `WriteTrailer`.
+	trailer []byte
+
+	// Strings written to the current object's overflow region.
+	overflow overflowRegion
+
+	// When set, the index includes overflow strings, so objects are never
+	// streamed. See `streamObject`.
+	overflowFields bool
+
+	// When set, objects are converted to the schema of this index before
+	// they are written. See `NewWriterWithIndex`.
+	canonicalIndex Index
+	schema         *mapSchema
+
+	// When set, records were written in columns. See `WriteColumnar`.
+	columnar bool
+
+	// The codec for compressed strings. See `Compression`.
+	codec Codec
+
+	// When set, objects may be of any of these struct types, and each
+	// object records the id of its type. See `NewWriterWithSchemas`.
+	schemas []reflect.Type
+
+	// When enabled, objects are written in canonical form, and map fields
+	// are supported. See `Canonical`.
+	canonicalForm bool
+
+	// When enabled, the index is buffered and written after the last
+	// object. See `FooterIndex`.
+	footerIndex bool
+	footer      *bytes.Buffer
+}
+
+// WriterOption configures optional writer behavior.
+type WriterOption func(*rsfWriter)
+
+// StringTable enables the Version3 string table. When enabled, each unique
+// variable-length string value is written only once to a table that follows
+// the index, and each string field is written as a 4-byte table index in
+// place of the inline string. This can greatly reduce the size of files with
+// many repeated values.
+//
+// Since the string table follows the index, all objects are buffered in memory
+// until `Close` is called. The string table requires Version3 or greater.
+func StringTable(enabled bool) WriterOption {
+	return func(f *rsfWriter) {
+		f.stringTable = enabled
+	}
+}
+
+// ObjectKey records the value of the top-level field named `field` (a string
+// or integer field) along with the offset of each object. When the writer is
+// closed, these are written to a key table after the last object, which
+// allows `FindObject` to seek directly to an object by its key. The object key
+// table requires Version3 or greater.
+func ObjectKey(field string) WriterOption {
+	return func(f *rsfWriter) {
+		f.objectKey = field
+	}
+}
+
+// VerboseIndex writes a short ASCII type tag (like "str" or "i64") with each
+// index entry, following the numeric field type. The tags are redundant, but
+// they help tools written in other languages interpret the index without
+// mapping the numeric field types. See `IndexEntry.TypeTag`. The verbose
+// index requires Version3 or greater.
+func VerboseIndex(enabled bool) WriterOption {
+	return func(f *rsfWriter) {
+		f.verboseIndex = enabled
+	}
+}
+
+// FieldWidths records the byte width of each field in the index, following
+// the field type (and type tag, if any). Fields without a fixed width, like
+// arrays and variable-length strings, are recorded with a zero width. Since
+// the widths don't depend on understanding the field type, a reader can skip
+// fields of types added by newer writers by their declared width. See
+// `IndexEntry.FieldWidth`. Field widths require Version3 or greater.
+func FieldWidths(enabled bool) WriterOption {
+	return func(f *rsfWriter) {
+		f.fieldWidths = enabled
+	}
+}
+
+// FieldNameTable writes each unique field name once to a table at the start
+// of the index. Index entries then reference their names by a 4-byte id in
+// place of the name string, which shrinks indexes where the same names are
+// repeated in many nested structs. See `IndexEntry.FieldID`. The field name
+// table requires Version3 or greater.
+func FieldNameTable(enabled bool) WriterOption {
+	return func(f *rsfWriter) {
+		f.fieldNameTable = enabled
+	}
+}
+
+// TruncateFixed truncates fixed-length strings that are longer than their
+// fixed size instead of returning an error. Strings are truncated on a rune
+// boundary. If that leaves the string short of its fixed size, it is padded
+// with spaces. Strings that are shorter than their fixed size still return
+// an error. A warning is logged for each truncated string when a logger is
+// provided with `WarningLogger`.
+func TruncateFixed(enabled bool) WriterOption {
+	return func(f *rsfWriter) {
+		f.truncateFixed = enabled
+	}
+}
+
+// WarningLogger logs warnings, like those for strings truncated with
+// `TruncateFixed`, to `l`.
+func WarningLogger(l *log.Logger) WriterOption {
+	return func(f *rsfWriter) {
+		f.logger = l
+	}
+}
+
+// PackBools packs the top-level bool fields of each object into a bitmap at
+// the start of the object, using one bit per field instead of one byte. Bools
+// in array elements are not packed. Packed bools require Version3 or greater.
+func PackBools(enabled bool) WriterOption {
+	return func(f *rsfWriter) {
+		f.packBools = enabled
+	}
+}
+
+// Compression selects the codec used to compress string fields with the
+// `compress` tag option. The codec is recorded in the index header, and
+// readers decompress with the recorded codec. Codecs other than the default
+// `CodecGzip` require Version3 or greater.
+func Compression(codec Codec) WriterOption {
+	return func(f *rsfWriter) {
+		f.codec = codec
+	}
+}
+
+// PadObjects pads each object with zero bytes so that its size, including
+// its size field, is a multiple of `blockSize` (e.g., 4096). The padding is
+// included in the object size, so readers that finish each object with
+// `SkipObject` (including `Unmarshal` and `DecodeObject`) skip it. Readers that
+// read objects field-by-field must discard the padding themselves. Padding is
+// disabled when `blockSize` is zero or less.
+func PadObjects(blockSize int) WriterOption {
+	return func(f *rsfWriter) {
+		f.padTo = blockSize
+	}
+}
+
+// Fingerprint writes the schema fingerprint (see `Index.Fingerprint`) in the
+// index header, following the feature flags. Readers can then compare the
+// schema of a file to an expected schema with `SchemaFingerprint` without
+// comparing full indexes. The fingerprint requires Version3 or greater.
+func Fingerprint(enabled bool) WriterOption {
+	return func(f *rsfWriter) {
+		f.fingerprint = enabled
+	}
+}
+
+// ProducerVersion writes `version`, the version of the program or library
+// that writes the file (like "v1.4.0"), in the index header, following the
+// schema fingerprint (if any). Readers can report it with `WriterVersion` to
+// correlate problems with files to the producers that wrote them. The version
+// is only metadata; it does not affect how the file is read. The producer
+// version requires Version3 or greater.
+func ProducerVersion(version string) WriterOption {
+	return func(f *rsfWriter) {
+		f.producerVersion = version
+	}
+}
+
+// RejectNulStrings returns an error when a string field contains a NUL
+// (`\x00`) byte. Since strings are written with their lengths, NUL bytes are
+// valid, but consumers that treat strings as NUL-terminated will truncate
+// them. The error names the field.
+func RejectNulStrings(enabled bool) WriterOption {
+	return func(f *rsfWriter) {
+		f.rejectNul = enabled
+	}
 }
 
 func NewWriter(f io.Writer) Writer {
@@ -32,11 +334,130 @@ func NewWriter(f io.Writer) Writer {
 	}
 }
 
-func NewWriterWithVersion(f io.Writer, version int) Writer {
-	return &rsfWriter{
+func NewWriterWithVersion(f io.Writer, version int, opts ...WriterOption) Writer {
+	w := &rsfWriter{
 		writer:  f,
 		version: version,
 	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// features returns the feature flags recorded in the Version3 index header.
+func (f *rsfWriter) features() int {
+	var flags int
+	if f.stringTable {
+		flags |= FeatureStringTable
+	}
+	if f.objectKey != "" && f.keyWriter == nil {
+		flags |= FeatureKeyTable
+	}
+	if f.verboseIndex {
+		flags |= FeatureVerboseIndex
+	}
+	if f.fieldWidths {
+		flags |= FeatureFieldWidths
+	}
+	if f.fieldNameTable {
+		flags |= FeatureFieldNameTable
+	}
+	if f.packBools || f.boolCount > 0 {
+		flags |= FeaturePackedBools
+	}
+	if f.nilAsAbsent {
+		flags |= FeatureNilAsAbsent
+	}
+	if f.fingerprint {
+		flags |= FeatureFingerprint
+	}
+	if f.producerVersion != "" {
+		flags |= FeatureProducerVersion
+	}
+	if f.columnar {
+		flags |= FeatureColumnar
+	}
+	if f.codec == CodecZstd {
+		flags |= FeatureZstd
+	}
+	if f.schemas != nil {
+		flags |= FeatureMultiSchema
+	}
+	return flags
+}
+
+func (f *rsfWriter) Close() error {
+	// A trailer set after the first object can't be written with a footer
+	// index. The index is still written so the objects can be read.
+	var trailerErr error
+	if f.footer != nil && f.trailer != nil {
+		f.trailer = nil
+		trailerErr = ErrFooterIndexOption
+	}
+
+	if f.header != nil {
+		_, err := io.Copy(f.writer, f.header)
+		if err != nil {
+			return err
+		}
+	}
+
+	if f.stringTable && f.pos > 0 {
+		sz, err := f.writeStringTable()
+		if err != nil {
+			return err
+		}
+
+		// Objects follow the string table, so adjust the recorded
+		// object offsets.
+		for i := range f.keys {
+			f.keys[i].offset += int64(sz)
+		}
+	}
+
+	if f.pending != nil {
+		_, err := io.Copy(f.writer, f.pending)
+		if err != nil {
+			return err
+		}
+	}
+
+	if f.trailer != nil && f.pos > 0 {
+		err := f.writeTrailer()
+		if err != nil {
+			return err
+		}
+	}
+
+	if f.footer != nil && f.footer.Len() > 0 {
+		err := f.writeFooterIndex()
+		if err != nil {
+			return err
+		}
+	}
+
+	if f.objectKey != "" && f.pos > 0 {
+		var err error
+		if f.keyWriter != nil {
+			err = f.writeKeyEntries(f.keyWriter)
+		} else if f.trailer != nil {
+			// The trailer includes the end of objects marker.
+			err = f.writeKeyEntries(f.writer)
+		} else {
+			err = f.writeKeyTable()
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	f.header = nil
+	f.pending = nil
+	f.footer = nil
+	f.keys = nil
+	f.trailer = nil
+	return trailerErr
 }
 
 func (f *rsfWriter) WriteSizeField(pos int, val int, r io.Writer) (int, error) {
@@ -63,10 +484,24 @@ func (f *rsfWriter) WriteInt64Field(pos int, val int64, r io.Writer) (int, error
 	return pos + sz, nil
 }
 
+// WriteFixedInt64Field writes an int64 value as a fixed 8-byte little-endian
+// value. Unlike `WriteInt64Field`, the value can be read without decoding a
+// varint.
+func (f *rsfWriter) WriteFixedInt64Field(pos int, val int64, r io.Writer) (int, error) {
+	bs := make([]byte, sizeFixedInt64)
+	binary.LittleEndian.PutUint64(bs, uint64(val))
+	sz, err := r.Write(bs)
+	if err != nil {
+		return 0, err
+	}
+
+	return pos + sz, nil
+}
+
 func (f *rsfWriter) WriteFloatField(pos int, val float64, r io.Writer) (int, error) {
 	// Write float
 	bs := make([]byte, sizeFloat64)
-	binary.LittleEndian.PutUint64(bs, math.Float64bits(val))
+	binary.LittleEndian.PutUint64(bs, math.Float64bits(f.canonicalFloat(val)))
 	sz, err := r.Write(bs)
 	if err != nil {
 		return 0, err
@@ -76,6 +511,14 @@ func (f *rsfWriter) WriteFloatField(pos int, val float64, r io.Writer) (int, err
 }
 
 func (f *rsfWriter) WriteFixedStringField(pos, sz int, val string, r io.Writer) (int, error) {
+	if f.truncateFixed && len(val) > sz {
+		truncated := truncateString(val, sz)
+		if f.logger != nil {
+			f.logger.Printf("truncated fixed-length string %q to %q", val, truncated)
+		}
+		val = truncated
+	}
+
 	if sz != len(val) {
 		return 0, fmt.Errorf("size %d does not match expected size %d", len(val), sz)
 	}
@@ -92,6 +535,16 @@ func (f *rsfWriter) WriteFixedStringField(pos, sz int, val string, r io.Writer)
 	return pos + sz, nil
 }
 
+// truncateString truncates `s` to `sz` bytes without splitting a rune. If the
+// truncated string is shorter than `sz` bytes, it is padded with spaces.
+func truncateString(s string, sz int) string {
+	end := sz
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+	return s[:end] + strings.Repeat(" ", sz-end)
+}
+
 func (f *rsfWriter) WriteStringField(pos int, val string, r io.Writer) (int, error) {
 	// Write size
 	bs := make([]byte, sizeFieldLen)
@@ -111,6 +564,77 @@ func (f *rsfWriter) WriteStringField(pos int, val string, r io.Writer) (int, err
 	return pos + sz, nil
 }
 
+// WriteStringFromReader writes a variable-length string like
+// `WriteStringField`, copying the `length` bytes of the string from `src`
+// rather than from a Go string, so that very large values need not be held in
+// memory. The caller supplies the length (e.g., from a file size), and exactly
+// that many bytes are copied. If `src` ends before `length` bytes are copied,
+// `io.ErrUnexpectedEOF` is returned; the bytes already written remain.
+func (f *rsfWriter) WriteStringFromReader(pos int, length int, src io.Reader, dst io.Writer) (int, error) {
+	if length < 0 || uint64(length) > math.MaxUint32 {
+		return 0, fmt.Errorf("invalid string length %d", length)
+	}
+
+	sz, err := f.WriteSizeField(0, length, dst)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := io.CopyN(dst, src, int64(length))
+	if err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	sz += int(n)
+
+	return pos + sz, nil
+}
+
+func (f *rsfWriter) WriteCompressedStringField(pos int, val string, r io.Writer) (int, error) {
+	// Compress value
+	c, ok := codecs[f.codec]
+	if !ok {
+		return 0, fmt.Errorf("invalid compression codec %d", f.codec)
+	}
+	compressed, err := c.compress([]byte(val))
+	if err != nil {
+		return 0, err
+	}
+
+	// Write size
+	bs := make([]byte, sizeFieldLen)
+	binary.LittleEndian.PutUint32(bs, uint32(len(compressed)))
+	sz, err := r.Write(bs)
+	if err != nil {
+		return 0, err
+	}
+
+	// Write compressed value
+	i, err := r.Write(compressed)
+	if err != nil {
+		return 0, err
+	}
+	sz += i
+
+	return pos + sz, nil
+}
+
+// WriteIPField writes an IP address as a 1-byte length (4 for IPv4, 16 for
+// IPv6, or 0 for the zero address) followed by the address bytes. IPv6 zones
+// are not written.
+func (f *rsfWriter) WriteIPField(pos int, val netip.Addr, r io.Writer) (int, error) {
+	bs := []byte{0}
+	if val.IsValid() {
+		addr := val.AsSlice()
+		bs = append([]byte{byte(len(addr))}, addr...)
+	}
+	sz, err := r.Write(bs)
+	if err != nil {
+		return 0, err
+	}
+
+	return pos + sz, nil
+}
+
 func (f *rsfWriter) WriteBoolField(pos int, val bool, r io.Writer) (int, error) {
 	// Write value
 	var b []byte
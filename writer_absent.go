@@ -0,0 +1,77 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"errors"
+	"reflect"
+)
+
+/*
+
+When `NilAsAbsent` is enabled, nil top-level slices and maps are absent from
+an object rather than written as empty arrays, so readers can distinguish a
+nil slice from an empty one. Arrays in array elements are always written.
+
+Each top-level array records a presence bit number in its index entry,
+following the packed record size (if any) and preceding the subfield count.
+Presence bits share the bitmap of packed bools (see writer_bools.go), so the
+bitmap is written whenever there is a top-level array. The bit is set when
+the array is written in the object, and clear when it is absent. An absent
+array uses no bytes in the object.
+
+Index format (for each top-level array):
+
+  [field name]
+  [FieldTypeArray or FieldTypePackedArray]
+  ...
+  [packed record size (packed arrays only)]
+  [presence bit number]
+  [subfield count]
+  ...
+
+*/
+
+var ErrNilAsAbsentVersion = errors.New("nil as absent requires Version3 or greater")
+var ErrNilAsAbsentOption = errors.New("nil as absent cannot be used with a supplied index")
+
+// NilAsAbsent omits nil top-level slices and maps from each object, recording
+// their presence in the bitmap of packed bools (see `PackBools`). Readers then
+// decode absent fields as missing (`DecodeObject`) or nil (`Unmarshal`), and
+// present fields as empty or populated, even if they have no elements. By
+// default, nil slices and maps are written as empty arrays. Nil as absent
+// requires Version3 or greater, and cannot be used with columnar records or
+// multiple schemas.
+func NilAsAbsent(enabled bool) WriterOption {
+	return func(f *rsfWriter) {
+		f.nilAsAbsent = enabled
+	}
+}
+
+// absentArray records the presence of the field `v` described by `t` in the
+// object's bitmap, if it is an optional array, and returns true if it is
+// absent.
+func (f *rsfWriter) absentArray(v reflect.Value, t *tag) bool {
+	if isMarshalerType(v.Type()) || isIPType(v.Type()) || isTimeType(v.Type()) || isBytesType(v.Type()) {
+		return false
+	}
+	switch v.Kind() {
+	case reflect.Array, reflect.Slice, reflect.Map:
+	default:
+		return false
+	}
+	if !f.optionalArray(t) {
+		return false
+	}
+
+	absent := v.Kind() != reflect.Array && v.IsNil()
+	f.setBit(!absent)
+	return absent
+}
+
+// optionalArray returns true if the array described by `t` records its
+// presence in the object's bitmap, since it is a named top-level field
+// (including fields of nested structs, which are flattened) and nil as absent
+// is enabled.
+func (f *rsfWriter) optionalArray(t *tag) bool {
+	return f.nilAsAbsent && t.name != "" && t.path == t.name
+}
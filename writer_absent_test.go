@@ -0,0 +1,189 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WriterNilAsAbsentSuite struct {
+	suite.Suite
+}
+
+func TestWriterNilAsAbsentSuite(t *testing.T) {
+	suite.Run(t, &WriterNilAsAbsentSuite{})
+}
+
+type absentDep struct {
+	Name string   `rsf:"name"`
+	Tags []string `rsf:"tags"`
+}
+
+type absentRecord struct {
+	Name     string            `rsf:"name"`
+	Tags     []string          `rsf:"tags"`
+	Deps     []absentDep       `rsf:"deps"`
+	Labels   map[string]string `rsf:"labels"`
+	Verified bool              `rsf:"verified,falseomit"`
+	Sizes    [2]int            `rsf:"sizes"`
+}
+
+var testAbsentData = []absentRecord{
+	{
+		Name:     "rsf",
+		Tags:     []string{"a", "b"},
+		Deps:     []absentDep{{Name: "posit"}, {Name: "go", Tags: []string{}}},
+		Labels:   map[string]string{"team": "data"},
+		Verified: true,
+		Sizes:    [2]int{1, 2},
+	},
+	{
+		Name:   "nil",
+		Labels: nil,
+	},
+	{
+		Name:   "empty",
+		Tags:   []string{},
+		Deps:   []absentDep{},
+		Labels: map[string]string{},
+	},
+}
+
+func (s *WriterNilAsAbsentSuite) write(opts ...WriterOption) []byte {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version3, append(opts, Canonical(true))...)
+	for _, obj := range testAbsentData {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+	s.Require().Nil(w.Close())
+	return buf.Bytes()
+}
+
+func (s *WriterNilAsAbsentSuite) TestDecode() {
+	data := s.write(NilAsAbsent(true))
+
+	r := NewReader()
+	buf := bufio.NewReader(bytes.NewReader(data))
+	index, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	s.Assert().NotZero(r.Features() & FeatureNilAsAbsent)
+	s.Assert().Equal(BoolBitmapField, index[0].FieldName)
+	s.Assert().True(index[2].Optional)
+	s.Assert().Equal(0, index[2].PresenceBit)
+	s.Assert().False(index[3].Subfields[1].Optional)
+	s.Assert().Equal(3, index[5].FieldSize)
+	s.Assert().Contains(index.String(), "tags arr[string] optional(0)\n")
+
+	objs, err := r.DecodeAll(buf)
+	s.Require().Nil(err)
+	s.Require().Len(objs, 3)
+	s.Assert().Equal([]any{"a", "b"}, objs[0]["tags"])
+	s.Assert().Equal([]any{
+		map[string]any{"name": "posit", "tags": []any{}},
+		map[string]any{"name": "go", "tags": []any{}},
+	}, objs[0]["deps"])
+	s.Assert().Equal(map[string]any{
+		"name":     "nil",
+		"verified": false,
+		"sizes":    []any{int64(0), int64(0)},
+	}, objs[1])
+	s.Assert().Equal([]any{}, objs[2]["tags"])
+	s.Assert().Equal([]any{}, objs[2]["deps"])
+	s.Assert().Equal([]any{}, objs[2]["labels"])
+}
+
+func (s *WriterNilAsAbsentSuite) TestUnmarshal() {
+	data := s.write(NilAsAbsent(true))
+
+	r := NewReader()
+	buf := bufio.NewReader(bytes.NewReader(data))
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	for _, expected := range testAbsentData {
+		var rec absentRecord
+		s.Require().Nil(r.Unmarshal(buf, &rec))
+		s.Assert().Equal(expected.Name, rec.Name)
+		s.Assert().Equal(expected.Tags == nil, rec.Tags == nil)
+		s.Assert().Equal(expected.Deps == nil, rec.Deps == nil)
+		s.Assert().Equal(expected.Labels == nil, rec.Labels == nil)
+		s.Assert().Equal(len(expected.Tags), len(rec.Tags))
+		s.Assert().Equal(expected.Sizes, rec.Sizes)
+	}
+}
+
+func (s *WriterNilAsAbsentSuite) TestDefault() {
+	data := s.write()
+
+	r := NewReader()
+	buf := bufio.NewReader(bytes.NewReader(data))
+	index, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	s.Assert().Zero(r.Features() & FeatureNilAsAbsent)
+	s.Assert().False(index[2].Optional)
+
+	// Nil slices are written as empty arrays.
+	objs, err := r.DecodeAll(buf)
+	s.Require().Nil(err)
+	s.Require().Len(objs, 3)
+	s.Assert().Equal([]any{}, objs[1]["tags"])
+	s.Assert().Equal([]any{}, objs[1]["deps"])
+	s.Assert().Equal([]any{}, objs[1]["labels"])
+}
+
+func (s *WriterNilAsAbsentSuite) TestAdvanceTo() {
+	data := s.write(NilAsAbsent(true))
+
+	r := NewReader()
+	buf := bufio.NewReader(bytes.NewReader(data))
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	_, err = r.BeginObject(buf)
+	s.Require().Nil(err)
+	s.Require().Nil(r.SkipObject(buf))
+
+	// The second object has no arrays, so its fields are read around them.
+	_, err = r.BeginObject(buf)
+	s.Require().Nil(err)
+	s.Require().Nil(r.AdvanceTo(buf, "name"))
+	name, err := r.ReadStringField(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal("nil", name)
+	err = r.AdvanceTo(buf, "tags")
+	s.Assert().ErrorIs(err, ErrFieldAbsent)
+	s.Assert().EqualError(err, "field tags: field is absent from the object")
+	s.Require().Nil(r.AdvanceTo(buf, "sizes"))
+	_, err = r.ReadSizeField(buf)
+	s.Require().Nil(err)
+	n, err := r.ReadSizeField(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(2, n)
+}
+
+func (s *WriterNilAsAbsentSuite) TestPrint() {
+	data := s.write(NilAsAbsent(true))
+
+	out := &bytes.Buffer{}
+	s.Require().Nil(Print(out, bufio.NewReader(bytes.NewReader(data))))
+	s.Assert().Contains(out.String(), "name (string): nil\ntags (array): absent\ndeps (array): absent\nlabels (array): absent\n")
+
+	out = &bytes.Buffer{}
+	s.Require().Nil(PrintJSON(out, bufio.NewReader(bytes.NewReader(data))))
+	s.Assert().Contains(out.String(), `{"name":"nil","sizes":[0,0],"verified":false}`)
+}
+
+func (s *WriterNilAsAbsentSuite) TestOptions() {
+	w := NewWriterWithVersion(&bytes.Buffer{}, Version2, NilAsAbsent(true))
+	_, err := w.WriteObject(testAbsentData[0])
+	s.Assert().ErrorIs(err, ErrNilAsAbsentVersion)
+
+	w = NewWriterWithIndex(&bytes.Buffer{}, Version3, Index{{FieldName: "name", FieldType: FieldTypeVarStr}}, NilAsAbsent(true))
+	_, err = w.WriteObject(testAbsentData[0])
+	s.Assert().ErrorIs(err, ErrNilAsAbsentOption)
+
+	w = NewWriterWithVersion(&bytes.Buffer{}, Version3, NilAsAbsent(true))
+	s.Assert().ErrorIs(w.WriteColumnar(testAbsentData), ErrColumnarOption)
+}
@@ -0,0 +1,102 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bytes"
+)
+
+/*
+
+When packed bools are enabled with `PackBools`, the top-level bool fields of
+an object are written as bits in a bitmap at the start of the object instead
+of as one byte each. Bools in array elements are not packed.
+
+Individual bool fields can be packed without `PackBools` with the `falseomit`
+tag option (e.g., `rsf:"deleted,falseomit"`). This suits flags that are
+almost always false: a false flag uses no bytes in the object, since its bit
+in the bitmap is clear, and readers read it as false. The bitmap is only
+written if at least one field is packed.
+
+The bitmap is described by the first index entry, which is named with
+`BoolBitmapField` and records the bitmap size in bytes. Each packed bool field
+keeps its place in the index with the `FieldTypePackedBool` field type and
+records its bit number, but it is not written in the object.
+
+Index format:
+
+  [bitmap field name]
+  [FieldTypeBoolBitmap]
+  [bitmap size]
+  ...
+  [field n name]
+  [FieldTypePackedBool]
+  [field n bit number]
+
+Bit n is stored in byte n/8 of the bitmap, at bit position n%8 (where 0 is
+the least significant bit).
+
+*/
+
+// BoolBitmapField is the name of the index entry that describes the bitmap of
+// packed bool fields. See `PackBools`.
+const BoolBitmapField = "@bools"
+
+// packedBool returns true if the bool field described by `t` is packed in the
+// object's bitmap, either because all bools are packed with `PackBools` or
+// because the field is tagged `falseomit`. Only named top-level fields
+// (including fields of nested structs, which are flattened) are packed.
+func (f *rsfWriter) packedBool(t *tag) bool {
+	return (f.packBools || t.falseOmit) && t.name != "" && t.prefix == ""
+}
+
+// writeIndexPackedBool writes the index entry for a packed bool field and
+// assigns the field the next bit number.
+func (f *rsfWriter) writeIndexPackedBool(t *tag, buf *bytes.Buffer) (int, error) {
+	sz, err := f.writeIndexFixed(t, FieldTypePackedBool, buf)
+	if err != nil {
+		return 0, err
+	}
+
+	bitSz, err := f.WriteSizeField(0, f.boolCount, buf)
+	if err != nil {
+		return 0, err
+	}
+	f.boolCount++
+	return sz + bitSz, nil
+}
+
+// writeIndexBitmap writes the index entry for the bitmap of packed bool
+// fields, followed by the remainder of the index, `indexBuf`.
+func (f *rsfWriter) writeIndexBitmap(indexBuf *bytes.Buffer) (*bytes.Buffer, int, error) {
+	buf := &bytes.Buffer{}
+	t := &tag{name: BoolBitmapField, fixed: f.bitmapSize()}
+	sz, err := f.writeIndexFixed(t, FieldTypeBoolBitmap, buf)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sizeSz, err := f.WriteSizeField(0, t.fixed, buf)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	_, err = buf.Write(indexBuf.Bytes())
+	if err != nil {
+		return nil, 0, err
+	}
+	return buf, sz + sizeSz, nil
+}
+
+// bitmapSize returns the size in bytes of the bitmap of packed bool fields.
+func (f *rsfWriter) bitmapSize() int {
+	return (f.boolCount + 7) / 8
+}
+
+// setBit records the value of the next packed bool field in the current
+// object's bitmap.
+func (f *rsfWriter) setBit(val bool) {
+	if val {
+		f.bitmap[f.nextBit/8] |= 1 << (f.nextBit % 8)
+	}
+	f.nextBit++
+}
@@ -0,0 +1,253 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WriterPackBoolsSuite struct {
+	suite.Suite
+}
+
+func TestWriterPackBoolsSuite(t *testing.T) {
+	suite.Run(t, &WriterPackBoolsSuite{})
+}
+
+type flagsVersion struct {
+	Version string `rsf:"version"`
+	Yanked  bool   `rsf:"yanked"`
+}
+
+type flagsRecord struct {
+	Name     string         `rsf:"name"`
+	Verified bool           `rsf:"verified"`
+	Deleted  bool           `rsf:"deleted"`
+	Trust    bool           `rsf:"trust"`
+	Versions []flagsVersion `rsf:"versions"`
+	Portable bool           `rsf:"portable"`
+	Ready    bool           `rsf:"ready"`
+}
+
+var testFlagsData = []flagsRecord{
+	{
+		Name:     "rsf",
+		Verified: true,
+		Trust:    true,
+		Versions: []flagsVersion{{Version: "1.0", Yanked: true}, {Version: "1.1"}},
+		Ready:    true,
+	},
+	{
+		Name:     "posit",
+		Deleted:  true,
+		Portable: true,
+	},
+}
+
+// objectSizes returns the size of each object in the RSF data `data`.
+func objectSizes(s *suite.Suite, data []byte) []int {
+	r := NewReader()
+	buf := bufio.NewReader(bytes.NewReader(data))
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+
+	var sizes []int
+	for {
+		sz, err := r.BeginObject(buf)
+		if err != nil {
+			break
+		}
+		sizes = append(sizes, sz)
+		s.Require().Nil(r.SkipObject(buf))
+	}
+	return sizes
+}
+
+func (s *WriterPackBoolsSuite) TestPackBools() {
+	plain := &bytes.Buffer{}
+	w := NewWriterWithVersion(plain, Version3)
+	for _, obj := range testFlagsData {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+	s.Require().Nil(w.Close())
+
+	buf := &bytes.Buffer{}
+	w = NewWriterWithVersion(buf, Version3, PackBools(true))
+	for _, obj := range testFlagsData {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+	s.Require().Nil(w.Close())
+
+	r := NewReader()
+	rBuf := bufio.NewReader(bytes.NewReader(buf.Bytes()))
+	index, err := r.ReadIndex(rBuf)
+	s.Require().Nil(err)
+	s.Assert().Equal("@bools bitmap(1)\n"+
+		"name str\n"+
+		"verified bit(0)\n"+
+		"deleted bit(1)\n"+
+		"trust bit(2)\n"+
+		"versions arr[struct]\n"+
+		"  version str\n"+
+		"  yanked bool\n"+
+		"portable bit(3)\n"+
+		"ready bit(4)\n", index.String())
+
+	// The five top-level bools are packed into a single byte, which is
+	// the first byte of the object.
+	plainSizes := objectSizes(&s.Suite, plain.Bytes())
+	sizes := objectSizes(&s.Suite, buf.Bytes())
+	s.Assert().Equal([]int{plainSizes[0] - 4, plainSizes[1] - 4}, sizes)
+	s.Assert().Equal(byte(0b10101), buf.Bytes()[r.Pos()+sizeFieldLen])
+
+	var recs []flagsRecord
+	for range testFlagsData {
+		var rec flagsRecord
+		s.Require().Nil(r.Unmarshal(rBuf, &rec))
+		recs = append(recs, rec)
+	}
+	s.Assert().Equal(testFlagsData, recs)
+}
+
+func (s *WriterPackBoolsSuite) TestPackBoolsRead() {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version3, PackBools(true))
+	for _, obj := range testFlagsData {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+	s.Require().Nil(w.Close())
+
+	r := NewReader()
+	rBuf := bufio.NewReader(bytes.NewReader(buf.Bytes()))
+	_, err := r.ReadIndex(rBuf)
+	s.Require().Nil(err)
+	obj, err := r.DecodeObject(rBuf)
+	s.Require().Nil(err)
+	s.Assert().Equal(map[string]any{
+		"name":     "rsf",
+		"verified": true,
+		"deleted":  false,
+		"trust":    true,
+		"versions": []any{
+			map[string]any{"version": "1.0", "yanked": true},
+			map[string]any{"version": "1.1", "yanked": false},
+		},
+		"portable": false,
+		"ready":    true,
+	}, obj)
+	obj, err = r.ReadScalars(rBuf)
+	s.Require().Nil(err)
+	s.Assert().Equal(map[string]any{
+		"name":     "posit",
+		"verified": false,
+		"deleted":  true,
+		"trust":    false,
+		"portable": true,
+		"ready":    false,
+	}, obj)
+
+	out := &bytes.Buffer{}
+	s.Require().Nil(Print(out, bufio.NewReader(bytes.NewReader(buf.Bytes()))))
+	s.Assert().Contains(out.String(), "trust (bool): true\nversions (array(2)):")
+	s.Assert().Contains(out.String(), "portable (bool): true\nready (bool): false\n")
+}
+
+func (s *WriterPackBoolsSuite) TestPackBoolsVersion() {
+	w := NewWriterWithVersion(&bytes.Buffer{}, Version2, PackBools(true))
+	_, err := w.WriteObject(testFlagsData[0])
+	s.Assert().ErrorIs(err, ErrPackBoolsVersion)
+}
+
+type sparseFlagsRecord struct {
+	Name     string `rsf:"name"`
+	Deleted  bool   `rsf:"deleted,falseomit"`
+	Archived bool   `rsf:"archived,falseomit"`
+	Yanked   bool   `rsf:"yanked,falseomit"`
+	Private  bool   `rsf:"private,falseomit"`
+	Ready    bool   `rsf:"ready"`
+}
+
+type plainFlagsRecord struct {
+	Name     string `rsf:"name"`
+	Deleted  bool   `rsf:"deleted"`
+	Archived bool   `rsf:"archived"`
+	Yanked   bool   `rsf:"yanked"`
+	Private  bool   `rsf:"private"`
+	Ready    bool   `rsf:"ready"`
+}
+
+func (s *WriterPackBoolsSuite) TestFalseOmit() {
+	records := []sparseFlagsRecord{
+		{Name: "rsf", Ready: true},
+		{Name: "posit"},
+		{Name: "old", Archived: true},
+	}
+
+	sparse := &bytes.Buffer{}
+	w := NewWriterWithVersion(sparse, Version3)
+	for _, rec := range records {
+		_, err := w.WriteObject(rec)
+		s.Require().Nil(err)
+	}
+	s.Require().Nil(w.Close())
+
+	plain := &bytes.Buffer{}
+	w = NewWriterWithVersion(plain, Version3)
+	for _, rec := range records {
+		_, err := w.WriteObject(plainFlagsRecord(rec))
+		s.Require().Nil(err)
+	}
+	s.Require().Nil(w.Close())
+
+	// The four flags share a one-byte bitmap, and the untagged bool is
+	// written as usual.
+	sparseSizes := objectSizes(&s.Suite, sparse.Bytes())
+	plainSizes := objectSizes(&s.Suite, plain.Bytes())
+	for i := range records {
+		s.Assert().Equal(plainSizes[i]-3, sparseSizes[i])
+	}
+
+	r := NewReader()
+	buf := bufio.NewReader(bytes.NewReader(sparse.Bytes()))
+	index, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(BoolBitmapField, index[0].FieldName)
+	s.Assert().Equal(FieldTypePackedBool, index[2].FieldType)
+	s.Assert().Equal(FieldTypeBool, index[6].FieldType)
+	s.Assert().NotZero(r.Features() & FeaturePackedBools)
+	for _, expected := range records {
+		var rec sparseFlagsRecord
+		s.Require().Nil(r.Unmarshal(buf, &rec))
+		s.Assert().Equal(expected, rec)
+	}
+}
+
+func (s *WriterPackBoolsSuite) TestFalseOmitOptions() {
+	w := NewWriterWithVersion(&bytes.Buffer{}, Version2)
+	_, err := w.WriteObject(sparseFlagsRecord{Name: "rsf"})
+	s.Assert().ErrorIs(err, ErrPackBoolsVersion)
+
+	type notBool struct {
+		Name string `rsf:"name,falseomit"`
+	}
+	_, err = NewWriterWithVersion(&bytes.Buffer{}, Version3).WriteObject(notBool{})
+	s.Assert().EqualError(err, "field name: the falseomit option requires a bool field")
+
+	type element struct {
+		Deleted bool `rsf:"deleted,falseomit"`
+	}
+	type inArray struct {
+		Elements []element `rsf:"elements"`
+	}
+	_, err = NewWriterWithVersion(&bytes.Buffer{}, Version3).WriteObject(inArray{Elements: []element{{}}})
+	s.Assert().EqualError(err, "field deleted: the falseomit option cannot be used in arrays")
+
+	w = NewWriterWithVersion(&bytes.Buffer{}, Version3)
+	s.Assert().ErrorIs(w.WriteColumnar([]sparseFlagsRecord{{Name: "rsf"}}), ErrColumnarOption)
+}
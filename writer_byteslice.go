@@ -0,0 +1,40 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"io"
+	"reflect"
+)
+
+/*
+
+Byte slice fields (`[]byte`, or any slice of `uint8`) are written as a 4-byte
+size followed by the raw bytes. Although the encoding matches a variable-length
+string, the index entry records the field type `FieldTypeBytes`, so readers
+and the printer can tell binary data from text. Slices of `net.IP` are written
+as IP addresses instead (see `WriteIPField`).
+
+Arrays of byte slices are not supported.
+
+*/
+
+// isBytesType returns true if `t` is a slice of bytes. Since `net.IP` is a
+// byte slice, IP addresses must be checked first.
+func isBytesType(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+}
+
+// WriteBytesField writes a byte slice, prepended with a 4-byte size field that
+// indicates its length.
+func (f *rsfWriter) WriteBytesField(pos int, val []byte, r io.Writer) (int, error) {
+	sz, err := f.WriteSizeField(pos, len(val), r)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := r.Write(val)
+	if err != nil {
+		return 0, err
+	}
+	return sz + n, nil
+}
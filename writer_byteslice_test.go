@@ -0,0 +1,114 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WriterByteSliceSuite struct {
+	suite.Suite
+}
+
+func TestWriterByteSliceSuite(t *testing.T) {
+	suite.Run(t, &WriterByteSliceSuite{})
+}
+
+type bytesRecord struct {
+	Name string `rsf:"name"`
+	Data []byte `rsf:"data"`
+	Age  int    `rsf:"age"`
+}
+
+var testBytesData = []bytesRecord{
+	{Name: "binary", Data: []byte{0x00, 0x01, 0xfe, 0xff}, Age: 1},
+	{Name: "text", Data: []byte("text"), Age: 2},
+	{Name: "empty", Age: 3},
+}
+
+func (s *WriterByteSliceSuite) TestWriteBytesField() {
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf)
+	sz, err := w.WriteBytesField(0, []byte{0x00, 0xff}, buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(6, sz)
+	s.Assert().Equal([]byte{0x2, 0x0, 0x0, 0x0, 0x00, 0xff}, buf.Bytes())
+
+	r := NewReader()
+	bs, err := r.ReadBytesField(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal([]byte{0x00, 0xff}, bs)
+	s.Assert().Equal(int64(6), r.Pos())
+}
+
+func (s *WriterByteSliceSuite) TestBytes() {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	for _, rec := range testBytesData {
+		_, err := w.WriteObject(rec)
+		s.Require().Nil(err)
+	}
+	s.Require().Nil(w.Close())
+
+	r := NewReader()
+	rBuf := bufio.NewReader(bytes.NewReader(buf.Bytes()))
+	index, err := r.ReadIndex(rBuf)
+	s.Require().Nil(err)
+	s.Assert().Equal(FieldTypeBytes, index[1].FieldType)
+	s.Assert().Equal("name str\ndata bytes\nage i64\n", index.String())
+	objStart := r.Pos()
+
+	for _, rec := range testBytesData {
+		var got bytesRecord
+		s.Require().Nil(r.Unmarshal(rBuf, &got))
+		s.Assert().Equal(rec, got)
+	}
+
+	rBuf = bufio.NewReader(bytes.NewReader(buf.Bytes()[objStart:]))
+	obj, err := r.DecodeObject(rBuf)
+	s.Require().Nil(err)
+	s.Assert().Equal([]byte{0x00, 0x01, 0xfe, 0xff}, obj["data"])
+
+	// Advancing skips the bytes.
+	rBuf = bufio.NewReader(bytes.NewReader(buf.Bytes()[objStart:]))
+	_, err = r.BeginObject(rBuf)
+	s.Require().Nil(err)
+	s.Require().Nil(r.AdvanceTo(rBuf, "age"))
+	age, err := r.ReadIntField(rBuf)
+	s.Require().Nil(err)
+	s.Assert().Equal(int64(1), age)
+}
+
+func (s *WriterByteSliceSuite) TestPrintBytes() {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	for _, rec := range testBytesData {
+		_, err := w.WriteObject(rec)
+		s.Require().Nil(err)
+	}
+	s.Require().Nil(w.Close())
+
+	// Strings are printed as text, and byte slices, even those holding
+	// text, in hex with their lengths.
+	out := &bytes.Buffer{}
+	s.Require().Nil(Print(out, bufio.NewReader(bytes.NewReader(buf.Bytes()))))
+	s.Assert().Contains(out.String(), "name (string): binary\n")
+	s.Assert().Contains(out.String(), "data (bytes(4)): 0001feff\n")
+	s.Assert().Contains(out.String(), "name (string): text\n")
+	s.Assert().Contains(out.String(), "data (bytes(4)): 74657874\n")
+	s.Assert().Contains(out.String(), "data (bytes(0)): \n")
+}
+
+func (s *WriterByteSliceSuite) TestBytesArrays() {
+	type record struct {
+		Chunks [][]byte `rsf:"chunks"`
+	}
+	w := NewWriterWithVersion(&bytes.Buffer{}, Version2)
+	_, err := w.WriteObject(record{Chunks: [][]byte{{0x01}}})
+	s.Assert().EqualError(err, "array chunks: arrays of byte slices are not supported")
+	s.Assert().EqualError(CheckType(record{}), "array chunks: arrays of byte slices are not supported")
+	s.Assert().Nil(CheckType(bytesRecord{}))
+}
@@ -0,0 +1,238 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+)
+
+var ErrIndexRootType = errors.New("objects written with a supplied index must be structs")
+
+// NewWriterWithIndex returns a writer like `NewWriterWithVersion` that writes
+// the supplied index `idx` rather than an index derived from the first
+// object. This allows producers built from different versions of a struct to
+// agree on a single canonical schema.
+//
+// Each object written must be a struct with exactly the fields of the index,
+// in any order, with matching types. Otherwise, an error naming the first
+// incompatible field is returned and nothing is written. Fields are written in
+// index order. Since objects are converted to the canonical schema much like
+// `Rewrite` converts them, writing is slower than with `NewWriterWithVersion`.
+//
+// Packed bools are enabled if the index includes a bool bitmap (see
+// `PackBools`).
+func NewWriterWithIndex(w io.Writer, version int, idx Index, opts ...WriterOption) Writer {
+	f := NewWriterWithVersion(w, version, opts...).(*rsfWriter)
+	f.canonicalIndex = make(Index, 0, len(idx))
+	for _, entry := range idx {
+		if entry.FieldType == FieldTypeBoolBitmap {
+			f.packBools = true
+			continue
+		}
+		f.canonicalIndex = append(f.canonicalIndex, entry)
+	}
+	return f
+}
+
+// canonical converts the struct `v` to a struct of the canonical schema type,
+// after checking that `v` is compatible with the supplied index. The canonical
+// type is built with the first object.
+func (f *rsfWriter) canonical(v any) (any, error) {
+	if reflect.ValueOf(v).Kind() != reflect.Struct {
+		return nil, ErrIndexRootType
+	}
+
+	if f.schema == nil {
+		schema, err := newMapSchema(f.canonicalIndex, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error building index: %s", err)
+		}
+		f.schema = schema
+	}
+
+	// Write `v` with its own index and decode it. This takes care of
+	// flattened structs, array index keys, and the like.
+	buf := &bytes.Buffer{}
+	tw := &rsfWriter{
+		writer:        buf,
+		version:       Version2,
+		truncateFixed: f.truncateFixed,
+		rejectNul:     f.rejectNul,
+		canonicalForm: f.canonicalForm,
+	}
+	_, err := tw.WriteObject(v)
+	if err != nil {
+		// The object number is added by the caller.
+		return nil, errors.Unwrap(err)
+	}
+	br := bufio.NewReader(buf)
+	tr := &rsfReader{}
+	index, err := tr.ReadIndex(br)
+	if err != nil {
+		return nil, err
+	}
+	err = checkCompatible(f.canonicalIndex, index, "")
+	if err != nil {
+		return nil, err
+	}
+	obj, err := tr.DecodeObject(br)
+	if err != nil {
+		return nil, err
+	}
+
+	cv := reflect.New(f.schema.typ).Elem()
+	err = f.schema.set(cv, obj)
+	if err != nil {
+		return nil, err
+	}
+	return cv.Interface(), nil
+}
+
+// checkCompatible returns an error if the fields of `got` don't match the
+// fields of `want`, ignoring their order. The `prefix` is the path of the
+// enclosing array, if any.
+func checkCompatible(want, got Index, prefix string) error {
+	path := func(name string) string {
+		if prefix == "" {
+			return name
+		}
+		return prefix + rsfPathSep + name
+	}
+
+	fields := make(map[string]IndexEntry, len(got))
+	for _, entry := range got {
+		fields[entry.FieldName] = entry
+	}
+
+	for _, w := range want {
+		g, ok := fields[w.FieldName]
+		if !ok {
+			return withField(path(w.FieldName), errors.New("missing from the object"))
+		}
+		delete(fields, w.FieldName)
+
+		// Bools are packed when the canonical object is written.
+		wantType := w.FieldType
+		if wantType == FieldTypePackedBool {
+			wantType = FieldTypeBool
+		}
+		if g.FieldType != wantType {
+			return withField(path(w.FieldName), fmt.Errorf("type %s does not match the index type %s", typeTag(g.FieldType), typeTag(w.FieldType)))
+		}
+		if g.FieldSize != w.FieldSize {
+			return withField(path(w.FieldName), fmt.Errorf("size %d does not match the index size %d", g.FieldSize, w.FieldSize))
+		}
+		if g.FieldType != FieldTypeArray && g.FieldType != FieldTypePackedArray {
+			continue
+		}
+
+		if g.Indexed != w.Indexed || g.IndexType != w.IndexType || g.IndexSize != w.IndexSize {
+			return withField(path(w.FieldName), errors.New("array index does not match the index"))
+		}
+		if g.SubfieldType != w.SubfieldType {
+			return withField(path(w.FieldName), fmt.Errorf("element type %s does not match the index element type %s", reflect.Kind(g.SubfieldType), reflect.Kind(w.SubfieldType)))
+		}
+		err := checkCompatible(w.Subfields, g.Subfields, path(w.FieldName))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range got {
+		if _, ok := fields[entry.FieldName]; ok {
+			return withField(path(entry.FieldName), errors.New("not in the index"))
+		}
+	}
+	return nil
+}
+
+var ErrMapCanonical = errors.New("maps can only be written in canonical form")
+
+// Canonical writes objects in canonical form, so that objects that are
+// logically equal are written as identical bytes. This supports content
+// addressing, like deduplicating identical snapshots by a hash of their
+// bytes. Canonical form covers:
+//
+//   - Maps. Map fields are only supported in canonical form, and map keys
+//     must be strings or integers. Each map is written as an array of
+//     key/value structs with the fields `key` and `value`, sorted by key, so
+//     the map's iteration order doesn't matter. `Unmarshal` reads these
+//     arrays into map fields, and `DecodeObject` decodes them as arrays.
+//   - Floats. Negative zero is written as zero, and every NaN is written as
+//     the NaN returned by `math.NaN`. Other floats are written bit-exact, as
+//     they are in any mode.
+//   - Field order. Fields are always written in struct declaration order,
+//     and every field is written, even when it's the zero value, so objects
+//     of the same type always have the same fields in the same order. Nil
+//     and empty slices and maps are written identically.
+//
+// Canonical form doesn't cover objects of different types with the same
+// fields, and compressed strings depend on the compressor's output, which may
+// change between library versions.
+func Canonical(enabled bool) WriterOption {
+	return func(f *rsfWriter) {
+		f.canonicalForm = enabled
+	}
+}
+
+// canonicalFloat returns `val` in canonical form, if enabled. See
+// `Canonical`.
+func (f *rsfWriter) canonicalFloat(val float64) float64 {
+	if !f.canonicalForm {
+		return val
+	}
+	if math.IsNaN(val) {
+		return math.NaN()
+	}
+	if val == 0 {
+		return 0
+	}
+	return val
+}
+
+// mapEntriesType returns the type of the array of key/value structs used to
+// write the map type `v`. See `Canonical`.
+func (f *rsfWriter) mapEntriesType(v reflect.Type, t *tag) (reflect.Type, error) {
+	if !f.canonicalForm {
+		return nil, withField(t.path, ErrMapCanonical)
+	}
+	switch v.Key().Kind() {
+	case reflect.String, reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+	default:
+		return nil, withField(t.path, fmt.Errorf("map keys must be strings or integers, not %s", v.Key()))
+	}
+	return mapEntriesSliceType(v), nil
+}
+
+// mapEntriesSliceType returns the type of the array of key/value structs
+// holding the entries of the map type `v`.
+func mapEntriesSliceType(v reflect.Type) reflect.Type {
+	return reflect.SliceOf(reflect.StructOf([]reflect.StructField{
+		{Name: "Key", Type: v.Key(), Tag: `rsf:"key"`},
+		{Name: "Value", Type: v.Elem(), Tag: `rsf:"value"`},
+	}))
+}
+
+// mapEntries returns the entries of the map `v` as an array of key/value
+// structs sorted by key.
+func mapEntries(v reflect.Value) reflect.Value {
+	keys := v.MapKeys()
+	if v.Type().Key().Kind() == reflect.String {
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	} else {
+		sort.Slice(keys, func(i, j int) bool { return keys[i].Int() < keys[j].Int() })
+	}
+
+	entries := reflect.MakeSlice(mapEntriesSliceType(v.Type()), len(keys), len(keys))
+	for i, key := range keys {
+		entries.Index(i).Field(0).Set(key)
+		entries.Index(i).Field(1).Set(v.MapIndex(key))
+	}
+	return entries
+}
@@ -0,0 +1,219 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WriterCanonicalSuite struct {
+	suite.Suite
+}
+
+func TestWriterCanonicalSuite(t *testing.T) {
+	suite.Run(t, &WriterCanonicalSuite{})
+}
+
+type canonicalRecord struct {
+	Name  string   `rsf:"name"`
+	Count int64    `rsf:"count"`
+	Date  string   `rsf:"date,fixed:10"`
+	Tags  []string `rsf:"tags"`
+}
+
+func (s *WriterCanonicalSuite) index(v any) Index {
+	buf := &bytes.Buffer{}
+	s.Require().Nil(WriteSchema(v, buf, Version2))
+	index, err := NewReader().ReadIndex(buf)
+	s.Require().Nil(err)
+	return index
+}
+
+func (s *WriterCanonicalSuite) TestWriteCompatible() {
+	// A producer with the fields in a different order.
+	type reordered struct {
+		Tags  []string `rsf:"tags"`
+		Date  string   `rsf:"date,fixed:10"`
+		Count int      `rsf:"count"`
+		Name  string   `rsf:"name"`
+	}
+
+	index := s.index(canonicalRecord{})
+	buf := &bytes.Buffer{}
+	w := NewWriterWithIndex(buf, Version2, index)
+	_, err := w.WriteObject(reordered{
+		Tags:  []string{"a", "b"},
+		Date:  "2023-01-02",
+		Count: 3,
+		Name:  "rsf",
+	})
+	s.Require().Nil(err)
+
+	// The output matches an object written with the canonical struct.
+	expected := &bytes.Buffer{}
+	w = NewWriterWithVersion(expected, Version2)
+	_, err = w.WriteObject(canonicalRecord{
+		Name:  "rsf",
+		Count: 3,
+		Date:  "2023-01-02",
+		Tags:  []string{"a", "b"},
+	})
+	s.Require().Nil(err)
+	s.Assert().Equal(expected.Bytes(), buf.Bytes())
+}
+
+func (s *WriterCanonicalSuite) TestWriteComplex() {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithIndex(buf, Version2, s.index(FullPackageRecordPyPI{}))
+	for _, obj := range testComplexData {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+	s.Assert().Equal(getComplexData(&s.Suite).Bytes(), buf.Bytes())
+
+	r := NewReader()
+	br := bufio.NewReader(buf)
+	_, err := r.ReadIndex(br)
+	s.Require().Nil(err)
+	var rec FullPackageRecordPyPI
+	s.Require().Nil(r.Unmarshal(br, &rec))
+	s.Assert().Equal(testComplexData[0].Snapshots[1].Snapshot, rec.Snapshots[1].Snapshot)
+}
+
+func (s *WriterCanonicalSuite) TestWriteIncompatible() {
+	type missing struct {
+		Name string   `rsf:"name"`
+		Date string   `rsf:"date,fixed:10"`
+		Tags []string `rsf:"tags"`
+	}
+	type extra struct {
+		canonicalRecord
+		Extra bool `rsf:"extra"`
+	}
+	type wrongType struct {
+		Name  string   `rsf:"name"`
+		Count float64  `rsf:"count"`
+		Date  string   `rsf:"date,fixed:10"`
+		Tags  []string `rsf:"tags"`
+	}
+	type wrongSize struct {
+		Name  string   `rsf:"name"`
+		Count int64    `rsf:"count"`
+		Date  string   `rsf:"date,fixed:8"`
+		Tags  []string `rsf:"tags"`
+	}
+	type wrongElements struct {
+		Name  string  `rsf:"name"`
+		Count int64   `rsf:"count"`
+		Date  string  `rsf:"date,fixed:10"`
+		Tags  []int64 `rsf:"tags"`
+	}
+
+	index := s.index(canonicalRecord{})
+	for _, test := range []struct {
+		v   any
+		err string
+	}{
+		{missing{Date: "2023-01-02"}, "object 0 field count: missing from the object"},
+		{extra{canonicalRecord: canonicalRecord{Date: "2023-01-02"}}, "object 0 field extra: not in the index"},
+		{wrongType{Date: "2023-01-02"}, "object 0 field count: type f64 does not match the index type i64"},
+		{wrongSize{Date: "20230102"}, "object 0 field date: size 8 does not match the index size 10"},
+		{wrongElements{Date: "2023-01-02"}, "object 0 field tags: element type int64 does not match the index element type string"},
+		{"a string", "object 0: " + ErrIndexRootType.Error()},
+	} {
+		buf := &bytes.Buffer{}
+		w := NewWriterWithIndex(buf, Version2, index)
+		_, err := w.WriteObject(test.v)
+		s.Assert().EqualError(err, test.err)
+		s.Assert().Equal(0, buf.Len())
+	}
+
+	// Array element fields are checked too.
+	type snapshot struct {
+		Snapshot string `rsf:"snapshot,skip,fixed:10"`
+		Version  string `rsf:"version"`
+	}
+	type record struct {
+		Snapshots []snapshot `rsf:"snapshots,index:snapshot"`
+	}
+	for _, entry := range s.index(FullPackageRecordPyPI{}) {
+		if entry.FieldName == "snapshots" {
+			index = Index{entry}
+		}
+	}
+	w := NewWriterWithIndex(&bytes.Buffer{}, Version2, index)
+	_, err := w.WriteObject(record{})
+	s.Assert().EqualError(err, "object 0 field snapshots.description: missing from the object")
+}
+
+type canonicalMaps struct {
+	Name      string           `rsf:"name"`
+	Downloads map[string]int64 `rsf:"downloads"`
+	Releases  map[int]string   `rsf:"releases"`
+	Score     float64          `rsf:"score"`
+}
+
+func (s *WriterCanonicalSuite) TestCanonical() {
+	write := func(v canonicalMaps) []byte {
+		buf := &bytes.Buffer{}
+		w := NewWriterWithVersion(buf, Version2, Canonical(true))
+		_, err := w.WriteObject(v)
+		s.Require().Nil(err)
+		return buf.Bytes()
+	}
+
+	// The maps are populated in different orders.
+	a := canonicalMaps{Name: "numpy", Downloads: map[string]int64{}, Releases: map[int]string{}}
+	b := canonicalMaps{Name: "numpy", Downloads: map[string]int64{}, Releases: map[int]string{}}
+	for i := 0; i < 20; i++ {
+		a.Downloads[string(rune('a'+i))] = int64(i)
+		b.Downloads[string(rune('a'+19-i))] = int64(19 - i)
+		a.Releases[i] = string(rune('a' + i))
+		b.Releases[19-i] = string(rune('a' + 19 - i))
+	}
+	canonical := write(a)
+	s.Assert().Equal(canonical, write(b))
+
+	// The maps are read back.
+	var read canonicalMaps
+	r := NewReader()
+	buf := bufio.NewReader(bytes.NewReader(canonical))
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	s.Require().Nil(r.Unmarshal(buf, &read))
+	s.Assert().Equal(a, read)
+
+	// Entries are written in key order.
+	buf = bufio.NewReader(bytes.NewReader(canonical))
+	_, err = r.ReadIndex(buf)
+	s.Require().Nil(err)
+	obj, err := r.DecodeObject(buf)
+	s.Require().Nil(err)
+	releases := obj["releases"].([]any)
+	s.Assert().Equal(map[string]any{"key": int64(0), "value": "a"}, releases[0])
+	s.Assert().Equal(map[string]any{"key": int64(19), "value": "t"}, releases[19])
+
+	// Nil and empty maps are written identically.
+	s.Assert().Equal(write(canonicalMaps{Downloads: map[string]int64{}}), write(canonicalMaps{}))
+
+	// Negative zero and NaNs are normalized.
+	s.Assert().Equal(write(canonicalMaps{Score: 0}), write(canonicalMaps{Score: math.Copysign(0, -1)}))
+	s.Assert().Equal(write(canonicalMaps{Score: math.NaN()}), write(canonicalMaps{Score: math.Float64frombits(0x7ff8000000000002)}))
+}
+
+func (s *WriterCanonicalSuite) TestCanonicalRequired() {
+	w := NewWriterWithVersion(&bytes.Buffer{}, Version2)
+	_, err := w.WriteObject(canonicalMaps{})
+	s.Assert().ErrorIs(err, ErrMapCanonical)
+	s.Assert().EqualError(err, "field downloads: maps can only be written in canonical form")
+
+	w = NewWriterWithVersion(&bytes.Buffer{}, Version2, Canonical(true))
+	_, err = w.WriteObject(struct {
+		Flags map[bool]string `rsf:"flags"`
+	}{})
+	s.Assert().EqualError(err, "field flags: map keys must be strings or integers, not bool")
+}
@@ -0,0 +1,115 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// CheckType returns an error if the type of `v` cannot be written with
+// `WriteObject` or read back with `Unmarshal`. Unlike `WriteObject`, which
+// stops at the first problem, every problem found is reported, each with the
+// path of the field. Since no data is written, this can be used to validate
+// types at startup rather than partway through writing a file.
+//
+// Problems include unsupported field types (like maps, channels, and
+// pointers), recursive types, unexported fields with `rsf` tags, invalid tag
+// options, and array indexes that don't name a supported field.
+func CheckType(v any) error {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return fmt.Errorf("unsupported root type: %s", reflect.Invalid)
+	}
+
+	var errs []error
+	checkType(t, &tag{}, map[reflect.Type]bool{}, &errs)
+	return errors.Join(errs...)
+}
+
+// checkType appends the problems with the type `v`, described by `t`, to
+// `errs`. The `seen` map records the struct types being checked in order to
+// detect recursive types.
+func checkType(v reflect.Type, t *tag, seen map[reflect.Type]bool, errs *[]error) {
+	if isMarshalerType(v) || isIPType(v) || isBytesType(v) {
+		return
+	}
+	if isTimeType(v) {
+		if !t.days {
+			*errs = append(*errs, withField(t.path, errTimeDays))
+		}
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Array, reflect.Slice:
+		checkArrayType(v, t, seen, errs)
+	case reflect.Struct:
+		checkStructType(v, t, seen, errs)
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8,
+		reflect.Float32, reflect.Float64:
+	default:
+		if t.path == "" {
+			*errs = append(*errs, fmt.Errorf("unsupported root type: %s", v.Kind()))
+		} else {
+			*errs = append(*errs, fmt.Errorf("field %s: unsupported type %s", t.path, v))
+		}
+	}
+}
+
+func checkStructType(v reflect.Type, tParent *tag, seen map[reflect.Type]bool, errs *[]error) {
+	if seen[v] {
+		*errs = append(*errs, fmt.Errorf("field %s: recursive type %s", tParent.path, v))
+		return
+	}
+	seen[v] = true
+	defer delete(seen, v)
+
+	for i := 0; i < v.NumField(); i++ {
+		t := &tag{}
+		skip, err := getTagInfo(v, i, t, tParent, nil)
+		if err != nil {
+			*errs = append(*errs, err)
+			continue
+		}
+		if t.name != "" && !v.Field(i).IsExported() {
+			*errs = append(*errs, fmt.Errorf("field %s: unexported fields cannot be read", t.path))
+		}
+		if skip {
+			continue
+		}
+		checkType(v.Field(i).Type, t, seen, errs)
+	}
+}
+
+func checkArrayType(v reflect.Type, t *tag, seen map[reflect.Type]bool, errs *[]error) {
+	el := v.Elem()
+	if isIPType(el) {
+		*errs = append(*errs, fmt.Errorf("array %s: arrays of IP addresses are not supported", t.path))
+		return
+	} else if isTimeType(el) {
+		*errs = append(*errs, fmt.Errorf("array %s: arrays of times are not supported", t.path))
+		return
+	} else if isMarshalerType(el) {
+		*errs = append(*errs, fmt.Errorf("array %s: arrays of RSFMarshaler values are not supported", t.path))
+		return
+	} else if isBytesType(el) && !isIPType(el) {
+		*errs = append(*errs, fmt.Errorf("array %s: arrays of byte slices are not supported", t.path))
+		return
+	}
+
+	if el.Kind() == reflect.Struct && t.index != "" {
+		// Use a copy of the tag, since `getTagInfo` records array index
+		// information in the parent tag.
+		tCopy := *t
+		for i := 0; i < el.NumField(); i++ {
+			_, _ = getTagInfo(el, i, &tag{}, &tCopy, nil)
+		}
+		if tCopy.indexSz == 0 {
+			*errs = append(*errs, fmt.Errorf("array %s: could not calculate indexed field %s size", t.path, t.index))
+		}
+	}
+
+	checkType(el, t, seen, errs)
+}
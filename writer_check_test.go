@@ -0,0 +1,63 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WriterCheckSuite struct {
+	suite.Suite
+}
+
+func TestWriterCheckSuite(t *testing.T) {
+	suite.Run(t, &WriterCheckSuite{})
+}
+
+type checkNode struct {
+	Name     string      `rsf:"name"`
+	Children []checkNode `rsf:"children"`
+}
+
+func (s *WriterCheckSuite) TestCheckType() {
+	s.Assert().Nil(CheckType(testComplexData[0]))
+	s.Assert().Nil(CheckType(testFlagsData[0]))
+	s.Assert().Nil(CheckType(testIPData))
+	s.Assert().Nil(CheckType(struct {
+		Ignored map[string]string `rsf:"-"`
+		Name    string            `rsf:"name"`
+	}{}))
+}
+
+func (s *WriterCheckSuite) TestCheckTypeErrors() {
+	type snap struct {
+		Date  string         `rsf:"date,skip,fixed:10"`
+		Addrs []netip.Addr   `rsf:"addrs"`
+		Meta  map[string]any `rsf:"meta"`
+	}
+	type record struct {
+		Name     string            `rsf:"name"`
+		Labels   map[string]string `rsf:"labels"`
+		internal string            `rsf:"internal"`
+		Updates  chan bool         `rsf:"updates"`
+		Count    int               `rsf:"count,compress"`
+		Snaps    []snap            `rsf:"snaps,index:missing"`
+	}
+
+	err := CheckType(record{internal: "unused"})
+	s.Assert().EqualError(err, "field labels: unsupported type map[string]string\n"+
+		"field internal: unexported fields cannot be read\n"+
+		"field updates: unsupported type chan bool\n"+
+		"field count: the compress option requires a string field\n"+
+		"array snaps: could not calculate indexed field missing size\n"+
+		"array snaps.addrs: arrays of IP addresses are not supported\n"+
+		"field snaps.meta: unsupported type map[string]interface {}")
+
+	err = CheckType(checkNode{})
+	s.Assert().EqualError(err, "field children: recursive type rsf.checkNode")
+
+	err = CheckType(&record{})
+	s.Assert().EqualError(err, "unsupported root type: ptr")
+}
@@ -0,0 +1,154 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+/*
+
+Records written with `WriteColumnar` are stored in columns rather than as
+objects. Each column holds the values of one top-level index field for every
+record, in record order, encoded as the field would be encoded in an object.
+Since the size of each column is recorded, a reader can seek directly to a
+column and read it without reading the others (see `ReadColumn`).
+
+Format:
+
+  [index]                 // With the columnar feature flag
+  [record count]
+  [column count]          // The number of top-level index fields
+  [column 1 size]
+  [column n size]
+  [column 1]
+  [column n]
+
+Columnar files require Version3 or greater. They cannot use a string table,
+an object key, packed bools, overflow strings, a supplied index (see
+`NewWriterWithIndex`), or a footer index (see `FooterIndex`).
+
+*/
+
+var ErrColumnarType = errors.New("columnar records must be a slice of structs")
+var ErrColumnarVersion = errors.New("columnar files require Version3 or greater")
+var ErrColumnarOption = errors.New("columnar files cannot use a string table, an object key, packed bools, nil as absent, a supplied index, or a footer index")
+var ErrColumnarObjects = errors.New("columnar records cannot be written with other objects")
+
+// WriteColumnar writes `records`, a slice of structs, as a columnar file,
+// including the index. It must be the only write to the file, though a
+// trailer may follow (see `WriteTrailer`). Records are written as
+// `WriteObject` would write them, and then split into a column for each
+// top-level index field.
+func (f *rsfWriter) WriteColumnar(records any) error {
+	v := reflect.ValueOf(records)
+	if (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) || v.Type().Elem().Kind() != reflect.Struct {
+		return ErrColumnarType
+	}
+	if f.version < Version3 {
+		return ErrColumnarVersion
+	}
+	if f.stringTable || f.objectKey != "" || f.packBools || f.nilAsAbsent || f.canonicalIndex != nil || f.footerIndex {
+		return ErrColumnarOption
+	}
+	if f.pos > 0 || f.columnar {
+		return ErrColumnarObjects
+	}
+	f.columnar = true
+
+	indexBuf := &bytes.Buffer{}
+	_, err := f.writeIndex(v.Type().Elem(), &tag{}, indexBuf)
+	if err != nil {
+		return err
+	}
+	if f.boolCount > 0 {
+		f.columnar = false
+		return ErrColumnarOption
+	}
+
+	// Read the index back to find the boundaries of each field.
+	tr := &rsfReader{}
+	index, err := tr.ReadIndex(bytes.NewReader(indexBuf.Bytes()))
+	if err != nil {
+		return fmt.Errorf("error reading index: %s", err)
+	}
+	for _, entry := range index {
+		if entry.FieldType == FieldTypeOverflowStr {
+			return withField(entry.FieldName, errors.New("overflow strings cannot be written in columns"))
+		}
+	}
+
+	columns := make([]bytes.Buffer, len(index))
+	row := &bytes.Buffer{}
+	rowReader := &bytes.Reader{}
+	buf := bufio.NewReader(rowReader)
+	for i := 0; i < v.Len(); i++ {
+		f.pos = i
+		row.Reset()
+		_, err = f.writeObject(v.Index(i), &tag{}, row)
+		if err != nil {
+			return f.objectError(err)
+		}
+		rowReader.Reset(row.Bytes())
+		buf.Reset(rowReader)
+		err = splitColumns(index, row.Bytes(), buf, columns)
+		if err != nil {
+			return f.objectError(err)
+		}
+	}
+	f.pos = v.Len()
+
+	// Write the index, the column sizes, and the columns.
+	out := &bytes.Buffer{}
+	_, err = io.Copy(out, indexBuf)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteSizeField(0, v.Len(), out)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteSizeField(0, len(columns), out)
+	if err != nil {
+		return err
+	}
+	for i := range columns {
+		_, err = f.WriteSizeField(0, columns[i].Len(), out)
+		if err != nil {
+			return err
+		}
+	}
+	n, err := io.Copy(f.writer, out)
+	if err != nil {
+		return err
+	}
+	f.written += n
+	for i := range columns {
+		n, err = io.Copy(f.writer, &columns[i])
+		if err != nil {
+			return err
+		}
+		f.written += n
+	}
+	return nil
+}
+
+// splitColumns appends the bytes of each field of the object `row`, which
+// holds the fields described by `index`, to the field's column. The row is
+// read from `buf`.
+func splitColumns(index Index, row []byte, buf *bufio.Reader, columns []bytes.Buffer) error {
+	r := &rsfReader{}
+	for n, entry := range index {
+		start := r.pos
+		err := r.advance(entry, buf)
+		if err != nil {
+			return withField(entry.FieldName, err)
+		}
+		columns[n].Write(row[start:r.pos])
+	}
+	return nil
+}
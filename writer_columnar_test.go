@@ -0,0 +1,186 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WriterColumnarSuite struct {
+	suite.Suite
+}
+
+func TestWriterColumnarSuite(t *testing.T) {
+	suite.Run(t, &WriterColumnarSuite{})
+}
+
+type columnarRecord struct {
+	Company string   `rsf:"company"`
+	Age     int      `rsf:"age"`
+	Rating  float64  `rsf:"rating"`
+	Tags    []string `rsf:"tags"`
+	Code    string   `rsf:"code,fixed:3"`
+}
+
+func testColumnarRecords(n int) []columnarRecord {
+	records := make([]columnarRecord, n)
+	for i := range records {
+		records[i] = columnarRecord{
+			Company: fmt.Sprintf("company %d", i),
+			Age:     i,
+			Rating:  float64(i) / 4,
+			Tags:    []string{"tag", fmt.Sprintf("%d", i)},
+			Code:    fmt.Sprintf("%03d", i%1000),
+		}
+	}
+	return records
+}
+
+func (s *WriterColumnarSuite) getColumnarData(records []columnarRecord) []byte {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version3)
+	err := w.WriteColumnar(records)
+	s.Require().Nil(err)
+	s.Require().Nil(w.Close())
+	return buf.Bytes()
+}
+
+func (s *WriterColumnarSuite) TestReadColumn() {
+	records := testColumnarRecords(1000)
+	data := s.getColumnarData(records)
+
+	// The index describes the record type.
+	index, err := NewReader().ReadIndex(bytes.NewReader(data))
+	s.Require().Nil(err)
+	s.Assert().Equal("company str\nage i64\nrating f64\ntags arr[string]\ncode fstr(3)\n", index.String())
+	header := NewReader()
+	_, err = header.ReadIndex(bytes.NewReader(data))
+	s.Require().Nil(err)
+
+	// Reading the `rating` column reads only the index, the record and
+	// column counts, the sizes of the columns up to `rating`, and the
+	// column.
+	rs := &countingSeeker{ReadSeeker: bytes.NewReader(data)}
+	ratings, err := ReadColumn[float64](rs, "rating")
+	s.Require().Nil(err)
+	s.Require().Len(ratings, 1000)
+	for i, rating := range ratings {
+		s.Assert().Equal(records[i].Rating, rating)
+	}
+	s.Assert().Equal(int(header.Pos())+8+3*4+1000*sizeFloat64, rs.n)
+
+	// Other columns read back as well.
+	companies, err := ReadColumn[string](bytes.NewReader(data), "company")
+	s.Require().Nil(err)
+	s.Assert().Equal("company 999", companies[999])
+	ages, err := ReadColumn[int](bytes.NewReader(data), "age")
+	s.Require().Nil(err)
+	s.Assert().Equal(500, ages[500])
+	tags, err := ReadColumn[[]string](bytes.NewReader(data), "tags")
+	s.Require().Nil(err)
+	s.Assert().Equal([]string{"tag", "42"}, tags[42])
+	codes, err := ReadColumn[string](bytes.NewReader(data), "code")
+	s.Require().Nil(err)
+	s.Assert().Equal("042", codes[42])
+
+	// Interface values have the types used by `DecodeObject`.
+	decoded, err := ReadColumn[any](bytes.NewReader(data), "age")
+	s.Require().Nil(err)
+	s.Assert().Equal(int64(7), decoded[7])
+}
+
+func (s *WriterColumnarSuite) TestReadColumnErrors() {
+	data := s.getColumnarData(testColumnarRecords(3))
+
+	_, err := ReadColumn[float64](bytes.NewReader(data), "missing")
+	s.Assert().ErrorIs(err, ErrNoSuchField)
+
+	_, err = ReadColumn[int](bytes.NewReader(data), "company")
+	s.Assert().EqualError(err, "error reading column company row 0: cannot read string into int")
+
+	// Regular files are not columnar.
+	_, err = ReadColumn[float64](bytes.NewReader(getComplexData(&s.Suite).Bytes()), "rating")
+	s.Assert().ErrorIs(err, ErrNotColumnar)
+
+	// Columnar files can't be read as objects.
+	r := NewReader()
+	buf := bufio.NewReader(bytes.NewReader(data))
+	_, err = r.ReadIndex(buf)
+	s.Require().Nil(err)
+	_, err = r.DecodeObject(buf)
+	s.Assert().ErrorIs(err, ErrColumnarFile)
+}
+
+func (s *WriterColumnarSuite) TestColumnarRejected() {
+	data := s.getColumnarData(testColumnarRecords(3))
+
+	err := Merge(&bytes.Buffer{}, bytes.NewReader(data))
+	s.Assert().EqualError(err, "source 0 is columnar and cannot be merged")
+
+	dst := &bytes.Buffer{}
+	fixed, err := Repair(bytes.NewReader(data), dst)
+	s.Assert().ErrorIs(err, ErrRepairColumnar)
+	s.Assert().Zero(fixed)
+	s.Assert().Zero(dst.Len())
+
+	dst = &bytes.Buffer{}
+	err = MapField(bytes.NewReader(data), dst, "company", func(v any) any { return v })
+	s.Assert().ErrorIs(err, ErrMapColumnar)
+	s.Assert().Zero(dst.Len())
+}
+
+func (s *WriterColumnarSuite) TestWriteColumnarErrors() {
+	records := testColumnarRecords(3)
+
+	w := NewWriterWithVersion(&bytes.Buffer{}, Version2)
+	s.Assert().ErrorIs(w.WriteColumnar(records), ErrColumnarVersion)
+
+	w = NewWriterWithVersion(&bytes.Buffer{}, Version3)
+	s.Assert().ErrorIs(w.WriteColumnar(records[0]), ErrColumnarType)
+	s.Assert().ErrorIs(w.WriteColumnar([]int{1, 2}), ErrColumnarType)
+
+	w = NewWriterWithVersion(&bytes.Buffer{}, Version3, StringTable(true))
+	s.Assert().ErrorIs(w.WriteColumnar(records), ErrColumnarOption)
+
+	// Columnar records are the only records in the file.
+	w = NewWriterWithVersion(&bytes.Buffer{}, Version3)
+	s.Require().Nil(w.WriteColumnar(records))
+	s.Assert().ErrorIs(w.WriteColumnar(records), ErrColumnarObjects)
+	_, err := w.WriteObject(records[0])
+	s.Assert().ErrorIs(err, ErrColumnarObjects)
+
+	w = NewWriterWithVersion(&bytes.Buffer{}, Version3)
+	_, err = w.WriteObject(records[0])
+	s.Require().Nil(err)
+	s.Assert().ErrorIs(w.WriteColumnar(records), ErrColumnarObjects)
+
+	// Overflow strings can't be written in columns.
+	w = NewWriterWithVersion(&bytes.Buffer{}, Version3)
+	err = w.WriteColumnar(testOverflowData)
+	s.Assert().EqualError(err, "field description: overflow strings cannot be written in columns")
+
+	// Field errors report the record number.
+	records[1].Code = "toolong"
+	w = NewWriterWithVersion(&bytes.Buffer{}, Version3)
+	err = w.WriteColumnar(records)
+	s.Assert().EqualError(err, "object 1 field code: size 7 does not match expected size 3")
+}
+
+func (s *WriterColumnarSuite) TestColumnarTrailer() {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version3)
+	s.Require().Nil(w.WriteColumnar(testColumnarRecords(10)))
+	w.WriteTrailer([]byte("meta"))
+	s.Require().Nil(w.Close())
+
+	trailer, err := ReadTrailer(bytes.NewReader(buf.Bytes()))
+	s.Require().Nil(err)
+	s.Assert().Equal([]byte("meta"), trailer)
+	ratings, err := ReadColumn[float64](bytes.NewReader(buf.Bytes()), "rating")
+	s.Require().Nil(err)
+	s.Assert().Len(ratings, 10)
+}
@@ -0,0 +1,235 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WriterCompressSuite struct {
+	suite.Suite
+}
+
+func TestWriterCompressSuite(t *testing.T) {
+	suite.Run(t, &WriterCompressSuite{})
+}
+
+type compressedRecord struct {
+	Name        string  `rsf:"name"`
+	Description string  `rsf:"description,compress"`
+	Version     string  `rsf:"version,fixed:5"`
+	Downloads   int     `rsf:"downloads"`
+	Rating      float64 `rsf:"rating"`
+}
+
+func (s *WriterCompressSuite) TestCompressedField() {
+	rec := compressedRecord{
+		Name:        "numpy",
+		Description: strings.Repeat("The fundamental package for scientific computing. ", 100),
+		Version:     "1.2.3",
+		Downloads:   1000000,
+		Rating:      4.5,
+	}
+
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	sz, err := w.WriteObject(rec)
+	s.Require().Nil(err)
+	// The description is 5100 bytes uncompressed.
+	s.Assert().Less(sz, 500)
+	data := buf.Bytes()
+
+	// Check the index.
+	r := NewReader()
+	b := bufio.NewReader(bytes.NewReader(data))
+	idx, err := r.ReadIndex(b)
+	s.Require().Nil(err)
+	s.Assert().Equal(IndexEntry{
+		FieldName: "description",
+		FieldType: FieldTypeCompressedStr,
+	}, idx[1])
+
+	// Round trip.
+	var result compressedRecord
+	err = r.Unmarshal(b, &result)
+	s.Require().Nil(err)
+	s.Assert().Equal(rec, result)
+
+	// Skip the compressed field without decompressing it.
+	r = NewReader()
+	b = bufio.NewReader(bytes.NewReader(data))
+	_, err = r.ReadIndex(b)
+	s.Require().Nil(err)
+	_, err = r.ReadSizeField(b)
+	s.Require().Nil(err)
+	err = r.AdvanceTo(b, "version")
+	s.Require().Nil(err)
+	version, err := r.ReadFixedStringField(5, b)
+	s.Require().Nil(err)
+	s.Assert().Equal("1.2.3", version)
+	err = r.AdvanceTo(b, "rating")
+	s.Require().Nil(err)
+	rating, err := r.ReadFloatField(b)
+	s.Require().Nil(err)
+	s.Assert().Equal(4.5, rating)
+	_, err = r.ReadSizeField(b)
+	s.Assert().ErrorIs(err, io.EOF)
+
+	// Read the compressed field on demand.
+	r = NewReader()
+	b = bufio.NewReader(bytes.NewReader(data))
+	_, err = r.ReadIndex(b)
+	s.Require().Nil(err)
+	_, err = r.ReadSizeField(b)
+	s.Require().Nil(err)
+	err = r.AdvanceTo(b, "description")
+	s.Require().Nil(err)
+	description, err := r.ReadCompressedStringField(b)
+	s.Require().Nil(err)
+	s.Assert().Equal(rec.Description, description)
+
+	// Print
+	pbuf := &bytes.Buffer{}
+	err = Print(pbuf, bufio.NewReader(bytes.NewReader(data)))
+	s.Require().Nil(err)
+	s.Assert().Contains(pbuf.String(), "description (compressed string): The fundamental package")
+	s.Assert().Contains(pbuf.String(), "rating (float): 4.500000")
+}
+
+func (s *WriterCompressSuite) TestCompressedFieldErrors() {
+	w := NewWriterWithVersion(&bytes.Buffer{}, Version2)
+	_, err := w.WriteObject(struct {
+		Count int `rsf:"count,compress"`
+	}{})
+	s.Assert().EqualError(err, "field count: the compress option requires a string field")
+
+	_, err = w.WriteObject(struct {
+		Date string `rsf:"date,fixed:10,compress"`
+	}{})
+	s.Assert().EqualError(err, "field date: the compress option cannot be used with fixed-length strings")
+}
+
+func (s *WriterCompressSuite) TestCompressedFieldZstd() {
+	rec := compressedRecord{
+		Name:        "numpy",
+		Description: strings.Repeat("The fundamental package for scientific computing. ", 100),
+		Version:     "1.2.3",
+		Downloads:   1000000,
+		Rating:      4.5,
+	}
+
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version3, Compression(CodecZstd))
+	sz, err := w.WriteObject(rec)
+	s.Require().Nil(err)
+	s.Assert().Less(sz, 500)
+	data := buf.Bytes()
+
+	// The codec is recorded in the index header, so the reader needs no
+	// options.
+	r := NewReader()
+	b := bufio.NewReader(bytes.NewReader(data))
+	_, err = r.ReadIndex(b)
+	s.Require().Nil(err)
+	s.Assert().Equal(CodecZstd, r.(*rsfReader).codec())
+	var result compressedRecord
+	err = r.Unmarshal(b, &result)
+	s.Require().Nil(err)
+	s.Assert().Equal(rec, result)
+
+	pbuf := &bytes.Buffer{}
+	err = Print(pbuf, bufio.NewReader(bytes.NewReader(data)))
+	s.Require().Nil(err)
+	s.Assert().Contains(pbuf.String(), "description (compressed string): The fundamental package")
+
+	// The codec requires Version3.
+	w = NewWriterWithVersion(&bytes.Buffer{}, Version2, Compression(CodecZstd))
+	_, err = w.WriteObject(rec)
+	s.Assert().ErrorIs(err, ErrCompressionVersion)
+}
+
+func (s *WriterCompressSuite) TestDecompressedSize() {
+	// Values that decompress to more than the maximum are rejected.
+	rec := compressedRecord{Description: strings.Repeat("a", MaxDecompressedSize+1), Version: "1.0.0"}
+	for _, c := range []Codec{CodecGzip, CodecZstd} {
+		buf := &bytes.Buffer{}
+		w := NewWriterWithVersion(buf, Version3, Compression(c))
+		_, err := w.WriteObject(rec)
+		s.Require().Nil(err)
+		s.Assert().Less(buf.Len(), MaxDecompressedSize/100)
+
+		r := NewReader()
+		b := bufio.NewReader(buf)
+		_, err = r.ReadIndex(b)
+		s.Require().Nil(err)
+		var result compressedRecord
+		err = r.Unmarshal(b, &result)
+		s.Assert().ErrorIs(err, ErrDecompressedSize, "codec %d", c)
+	}
+}
+
+// getCompressedData returns records of the complex data with long compressed
+// descriptions.
+func getCompressedData() []compressedRecord {
+	recs := make([]compressedRecord, 0)
+	for _, pkg := range testComplexData {
+		for _, snap := range pkg.Snapshots {
+			recs = append(recs, compressedRecord{
+				Name:        pkg.CanonicalName,
+				Description: strings.Repeat(snap.Description+". "+snap.Summary+". ", 200),
+				Version:     "1.2.3",
+				Downloads:   int(pkg.Popularity),
+			})
+		}
+	}
+	return recs
+}
+
+func benchmarkCompression(b *testing.B, codec Codec) {
+	recs := getCompressedData()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	var size int
+	for i := 0; i < b.N; i++ {
+		buf := &bytes.Buffer{}
+		w := NewWriterWithVersion(buf, Version3, Compression(codec))
+		for _, rec := range recs {
+			_, err := w.WriteObject(rec)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+		size = buf.Len()
+
+		r := NewReader()
+		br := bufio.NewReader(buf)
+		_, err := r.ReadIndex(br)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var rec compressedRecord
+		for {
+			err = r.Unmarshal(br, &rec)
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	b.ReportMetric(float64(size), "file-bytes")
+}
+
+func BenchmarkCompressionGzip(b *testing.B) {
+	benchmarkCompression(b, CodecGzip)
+}
+
+func BenchmarkCompressionZstd(b *testing.B) {
+	benchmarkCompression(b, CodecZstd)
+}
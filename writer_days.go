@@ -0,0 +1,60 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"time"
+)
+
+/*
+
+Date-only `time.Time` fields tagged `days` (e.g., `rsf:"date,days"`) are
+written as a 4-byte little-endian signed integer giving the number of days
+since 1970-01-01 in UTC. Dates before the epoch are negative. This is smaller
+than a date written as a `fixed:10` string, and dates can be compared without
+parsing them. The time of day is not written; readers return the date at
+midnight UTC.
+
+The index entry records the field type `FieldTypeDays`.
+
+*/
+
+var errTimeDays = errors.New("time fields require the days option")
+
+// secondsPerDay is the number of seconds in a day in UTC.
+const secondsPerDay = 24 * 60 * 60
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// isTimeType returns true if `t` is `time.Time`. Times are written with
+// `WriteDaysField`.
+func isTimeType(t reflect.Type) bool {
+	return t == timeType
+}
+
+// WriteDaysField writes the date of `val` in UTC as a 4-byte number of days
+// since 1970-01-01. An error is returned if the date is out of range.
+func (f *rsfWriter) WriteDaysField(pos int, val time.Time, r io.Writer) (int, error) {
+	secs := val.Unix()
+	days := secs / secondsPerDay
+	if secs%secondsPerDay < 0 {
+		days--
+	}
+	if days < math.MinInt32 || days > math.MaxInt32 {
+		return 0, fmt.Errorf("date %s is out of range", val.Format(time.DateOnly))
+	}
+
+	bs := make([]byte, sizeInt32)
+	binary.LittleEndian.PutUint32(bs, uint32(int32(days)))
+	sz, err := r.Write(bs)
+	if err != nil {
+		return 0, err
+	}
+
+	return pos + sz, nil
+}
@@ -0,0 +1,130 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WriterDaysSuite struct {
+	suite.Suite
+}
+
+func TestWriterDaysSuite(t *testing.T) {
+	suite.Run(t, &WriterDaysSuite{})
+}
+
+type daysRecord struct {
+	Name string    `rsf:"name"`
+	Date time.Time `rsf:"date,days"`
+	Age  int       `rsf:"age"`
+}
+
+func (s *WriterDaysSuite) TestWriteDaysField() {
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf)
+	sz, err := w.WriteDaysField(0, time.Date(1970, 1, 11, 0, 0, 0, 0, time.UTC), buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(4, sz)
+	s.Assert().Equal([]byte{0xa, 0x0, 0x0, 0x0}, buf.Bytes())
+
+	// Times of day are not written, and dates before the epoch are
+	// negative.
+	buf.Reset()
+	_, err = w.WriteDaysField(0, time.Date(1969, 12, 31, 23, 59, 59, 0, time.UTC), buf)
+	s.Require().Nil(err)
+	s.Assert().Equal([]byte{0xff, 0xff, 0xff, 0xff}, buf.Bytes())
+
+	r := NewReader()
+	date, err := r.ReadDaysField(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC), date)
+	s.Assert().Equal(int64(4), r.Pos())
+}
+
+func (s *WriterDaysSuite) TestDays() {
+	dates := []string{"2020-10-11", "1970-01-01", "1969-07-20", "1900-02-28", "0001-01-01", "2400-02-29"}
+	recs := make([]daysRecord, 0)
+	for i, date := range dates {
+		t, err := time.Parse(time.DateOnly, date)
+		s.Require().Nil(err)
+		recs = append(recs, daysRecord{Name: date, Date: t, Age: i})
+	}
+
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	for _, rec := range recs {
+		_, err := w.WriteObject(rec)
+		s.Require().Nil(err)
+	}
+	s.Require().Nil(w.Close())
+
+	r := NewReader()
+	rBuf := bufio.NewReader(bytes.NewReader(buf.Bytes()))
+	index, err := r.ReadIndex(rBuf)
+	s.Require().Nil(err)
+	s.Assert().Equal(FieldTypeDays, index[1].FieldType)
+	objStart := r.Pos()
+
+	for _, rec := range recs {
+		var got daysRecord
+		s.Require().Nil(r.Unmarshal(rBuf, &got))
+		s.Assert().Equal(rec, got)
+	}
+
+	rBuf = bufio.NewReader(bytes.NewReader(buf.Bytes()[objStart:]))
+	for _, rec := range recs {
+		obj, err := r.DecodeObject(rBuf)
+		s.Require().Nil(err)
+		s.Assert().Equal(rec.Date, obj["date"])
+		s.Assert().Equal(rec.Name, obj["date"].(time.Time).Format(time.DateOnly))
+	}
+
+	// The object includes the size field, the name, the 4-byte date, and the
+	// age. Advancing skips the date.
+	rBuf = bufio.NewReader(bytes.NewReader(buf.Bytes()[objStart:]))
+	sz, err := r.BeginObject(rBuf)
+	s.Require().Nil(err)
+	s.Assert().Equal(4+4+10+4+10, sz)
+	s.Require().Nil(r.AdvanceTo(rBuf, "age"))
+	age, err := r.ReadIntField(rBuf)
+	s.Require().Nil(err)
+	s.Assert().Equal(int64(0), age)
+
+	// The printer renders each date.
+	out := &bytes.Buffer{}
+	s.Require().Nil(Print(out, bufio.NewReader(bytes.NewReader(buf.Bytes()))))
+	for _, date := range dates {
+		s.Assert().Contains(out.String(), "date (date): "+date+"\n")
+	}
+
+	rBuf = bufio.NewReader(bytes.NewReader(buf.Bytes()[objStart:]))
+	js, err := r.ObjectToJSON(rBuf)
+	s.Require().Nil(err)
+	s.Assert().Equal(`{"name":"2020-10-11","date":"2020-10-11T00:00:00Z","age":0}`, string(js))
+}
+
+func (s *WriterDaysSuite) TestDaysErrors() {
+	type noDays struct {
+		Date time.Time `rsf:"date"`
+	}
+	w := NewWriterWithVersion(&bytes.Buffer{}, Version2)
+	_, err := w.WriteObject(noDays{})
+	s.Assert().EqualError(err, "field date: time fields require the days option")
+
+	type notTime struct {
+		Date string `rsf:"date,days"`
+	}
+	w = NewWriterWithVersion(&bytes.Buffer{}, Version2)
+	_, err = w.WriteObject(notTime{})
+	s.Assert().EqualError(err, "field date: the days option requires a time.Time field")
+
+	type dates struct {
+		Dates []time.Time `rsf:"dates"`
+	}
+	s.Assert().EqualError(CheckType(dates{}), "array dates: arrays of times are not supported")
+}
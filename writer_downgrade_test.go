@@ -348,7 +348,7 @@ func (s *WriterDowngradeSuite) validateRead(b *bytes.Buffer) {
 			s.Assert().Equal("variation two", desc)
 
 			// Advance to the array end
-			err = r.AdvanceToNextElement(buf, "products", "")
+			err = r.AdvanceToNextElement(buf, "products", Top)
 			s.Assert().Nil(err)
 		}
 
@@ -388,7 +388,7 @@ func (s *WriterDowngradeSuite) validateRead(b *bytes.Buffer) {
 		s.Assert().Nil(err)
 
 		// Verify at end of array
-		s.Assert().Equal(arraySz, r.Pos()-objectStart)
+		s.Assert().Equal(int64(arraySz), r.Pos()-objectStart)
 	}
 
 	// Advance to ready
@@ -581,7 +581,7 @@ func (s *WriterDowngradeSuite) validateRead(b *bytes.Buffer) {
 	s.Assert().Nil(err)
 
 	// Verify at end of array
-	s.Assert().Equal(arraySz, r.Pos()-objectStart)
+	s.Assert().Equal(int64(arraySz), r.Pos()-objectStart)
 
 	// Advance to income
 	err = r.AdvanceTo(buf, "income")
@@ -623,3 +623,54 @@ func (s *WriterDowngradeSuite) validateRead(b *bytes.Buffer) {
 	_, err = r.ReadSizeField(buf)
 	s.Assert().ErrorIs(err, io.EOF)
 }
+
+func (s *WriterDowngradeSuite) TestUnknownSubfieldType() {
+	type record struct {
+		Name string   `rsf:"name"`
+		Tags []string `rsf:"tags"`
+		Age  int      `rsf:"age"`
+	}
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	_, err := w.WriteObject(record{Name: "numpy", Tags: []string{"math"}, Age: 18})
+	s.Require().Nil(err)
+
+	// Simulate a newer writer by changing the element type of the `tags`
+	// array to a kind this reader doesn't understand. The element type
+	// follows the field type and the indexed flag.
+	data := buf.Bytes()
+	at := bytes.Index(data, []byte("tags")) + len("tags") + 4 + 1
+	s.Require().Equal([]byte{24, 0x0, 0x0, 0x0}, data[at:at+4])
+	data[at] = 99
+
+	// The index is read, and the unknown type is recorded.
+	r := NewReader()
+	rBuf := bufio.NewReader(bytes.NewReader(data))
+	index, err := r.ReadIndex(rBuf)
+	s.Require().Nil(err)
+	s.Assert().Equal(99, index[1].SubfieldType)
+	s.Assert().Equal("name str\ntags arr[kind99]\nage i64\n", index.String())
+
+	// The array can be advanced past.
+	_, err = r.BeginObject(rBuf)
+	s.Require().Nil(err)
+	err = r.AdvanceTo(rBuf, "age")
+	s.Require().Nil(err)
+	age, err := r.ReadIntField(rBuf)
+	s.Require().Nil(err)
+	s.Assert().Equal(int64(18), age)
+
+	// Reading the array's elements fails.
+	r = NewReader()
+	rBuf = bufio.NewReader(bytes.NewReader(data))
+	_, err = r.ReadIndex(rBuf)
+	s.Require().Nil(err)
+	_, err = r.DecodeObject(rBuf)
+	s.Assert().EqualError(err, "error decoding field tags: cannot decode array elements of type kind99")
+
+	// The printer notes the array and continues.
+	out := &bytes.Buffer{}
+	err = Print(out, bufio.NewReader(bytes.NewReader(data)))
+	s.Require().Nil(err)
+	s.Assert().Contains(out.String(), "tags (array(1)):\n    - cannot print data for arrays of kind99\nage (int): 18\n")
+}
@@ -0,0 +1,93 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+)
+
+/*
+
+Numeric fields tagged `be` (e.g., `rsf:"magic,be"`) are written as fixed-width
+big-endian values, while all other values remain little-endian. This supports
+records shared with producers that require specific fields, like a magic
+number, in big-endian byte order.
+
+`int32`, `int16`, `int8`, and `float32` fields are written as 4 bytes, and
+other integer and float fields as 8 bytes. The index entry records the field
+type `FieldTypeBigEndianInt` or `FieldTypeBigEndianFloat`, followed by the
+width, so readers honor the byte order of each field.
+
+Index entry format:
+
+  [field name]
+  [field type]
+  [width]
+
+*/
+
+// bigEndianWidth returns the width of a big-endian field of kind `kind`, or zero
+// if fields of `kind` cannot be written big-endian.
+func bigEndianWidth(kind reflect.Kind) int {
+	switch kind {
+	case reflect.Int32, reflect.Int16, reflect.Int8, reflect.Float32:
+		return sizeInt32
+	case reflect.Int, reflect.Int64, reflect.Float64:
+		return sizeFixedInt64
+	default:
+		return 0
+	}
+}
+
+// writeBigEndian writes the integer or float `v` as a big-endian value of
+// `width` bytes.
+func (f *rsfWriter) writeBigEndian(v reflect.Value, width int, buf *bytes.Buffer) (int, error) {
+	var bits uint64
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if width == sizeFloat32 {
+			bits = uint64(math.Float32bits(float32(f.canonicalFloat(v.Float()))))
+		} else {
+			bits = math.Float64bits(f.canonicalFloat(v.Float()))
+		}
+	default:
+		bits = uint64(v.Int())
+	}
+
+	bs := make([]byte, width)
+	if width == sizeInt32 {
+		binary.BigEndian.PutUint32(bs, uint32(bits))
+	} else {
+		binary.BigEndian.PutUint64(bs, bits)
+	}
+	return buf.Write(bs)
+}
+
+// bigEndianType returns the type of the big-endian field described by `entry`,
+// whose width is the same as the field's width.
+func bigEndianType(entry IndexEntry) reflect.Type {
+	switch {
+	case entry.FieldType == FieldTypeBigEndianFloat && entry.FieldSize == sizeFloat32:
+		return reflect.TypeOf(float32(0))
+	case entry.FieldType == FieldTypeBigEndianFloat:
+		return reflect.TypeOf(float64(0))
+	case entry.FieldSize == sizeInt32:
+		return reflect.TypeOf(int32(0))
+	default:
+		return reflect.TypeOf(int64(0))
+	}
+}
+
+// writeIndexBigEndian writes the index entry of a big-endian field, including
+// its width.
+func (f *rsfWriter) writeIndexBigEndian(t *tag, fieldType int, buf *bytes.Buffer) (int, error) {
+	sz, err := f.writeIndexFixed(t, fieldType, buf)
+	if err != nil {
+		return 0, err
+	}
+
+	widthSz, err := f.WriteSizeField(0, t.bigEndian, buf)
+	return sz + widthSz, err
+}
@@ -0,0 +1,142 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WriterEndianSuite struct {
+	suite.Suite
+}
+
+func TestWriterEndianSuite(t *testing.T) {
+	suite.Run(t, &WriterEndianSuite{})
+}
+
+type endianRecord struct {
+	Magic int32   `rsf:"magic,be"`
+	Name  string  `rsf:"name"`
+	Size  int64   `rsf:"size,be"`
+	Ratio float64 `rsf:"ratio,be"`
+	Scale float32 `rsf:"scale,be"`
+	Age   int     `rsf:"age,fixedint"`
+}
+
+var testEndianData = endianRecord{
+	Magic: 0x52534631,
+	Name:  "rsf",
+	Size:  -1024,
+	Ratio: 1.5,
+	Scale: -0.25,
+	Age:   55,
+}
+
+func (s *WriterEndianSuite) TestBigEndian() {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	_, err := w.WriteObject(testEndianData)
+	s.Require().Nil(err)
+	s.Require().Nil(w.Close())
+
+	r := NewReader()
+	rBuf := bufio.NewReader(bytes.NewReader(buf.Bytes()))
+	index, err := r.ReadIndex(rBuf)
+	s.Require().Nil(err)
+	s.Assert().Equal(FieldTypeBigEndianInt, index[0].FieldType)
+	s.Assert().Equal(4, index[0].FieldSize)
+	s.Assert().Equal(FieldTypeBigEndianInt, index[2].FieldType)
+	s.Assert().Equal(8, index[2].FieldSize)
+	s.Assert().Equal(FieldTypeBigEndianFloat, index[3].FieldType)
+	s.Assert().Equal(8, index[3].FieldSize)
+	s.Assert().Equal(FieldTypeBigEndianFloat, index[4].FieldType)
+	s.Assert().Equal(4, index[4].FieldSize)
+	s.Assert().Equal(FieldTypeFixedInt64, index[5].FieldType)
+
+	// The magic number is written as 4 big-endian bytes, and the
+	// following fields are unaffected.
+	objStart := r.Pos()
+	_, err = r.BeginObject(rBuf)
+	s.Require().Nil(err)
+	s.Assert().Equal([]byte{0x52, 0x53, 0x46, 0x31}, buf.Bytes()[r.Pos():r.Pos()+4])
+
+	// Advancing skips each big-endian field by its width.
+	s.Require().Nil(r.AdvanceTo(rBuf, "name"))
+	s.Assert().Equal(objStart+4+4, r.Pos())
+	name, err := r.ReadStringField(rBuf)
+	s.Require().Nil(err)
+	s.Assert().Equal("rsf", name)
+	s.Require().Nil(r.AdvanceTo(rBuf, "age"))
+	s.Assert().Equal(objStart+4+4+4+3+8+8+4, r.Pos())
+	age, err := r.ReadFixedIntField(rBuf)
+	s.Require().Nil(err)
+	s.Assert().Equal(int64(55), age)
+
+	var rec endianRecord
+	rBuf = bufio.NewReader(bytes.NewReader(buf.Bytes()[objStart:]))
+	s.Require().Nil(r.Unmarshal(rBuf, &rec))
+	s.Assert().Equal(testEndianData, rec)
+
+	rBuf = bufio.NewReader(bytes.NewReader(buf.Bytes()[objStart:]))
+	obj, err := r.DecodeObject(rBuf)
+	s.Require().Nil(err)
+	s.Assert().Equal(map[string]any{
+		"magic": int64(0x52534631),
+		"name":  "rsf",
+		"size":  int64(-1024),
+		"ratio": 1.5,
+		"scale": -0.25,
+		"age":   int64(55),
+	}, obj)
+
+	out := &bytes.Buffer{}
+	s.Require().Nil(Print(out, bufio.NewReader(bytes.NewReader(buf.Bytes()))))
+	s.Assert().Contains(out.String(), "magic (big-endian int): 1381189169\n")
+	s.Assert().Contains(out.String(), "ratio (big-endian float): 1.500000\n")
+}
+
+func (s *WriterEndianSuite) TestBigEndianRewrite() {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	_, err := w.WriteObject(testEndianData)
+	s.Require().Nil(err)
+	s.Require().Nil(w.Close())
+
+	// Rewritten fields keep their byte order and width.
+	out := &bytes.Buffer{}
+	err = Rewrite(bufio.NewReader(bytes.NewReader(buf.Bytes())), out, func(obj map[string]any) map[string]any {
+		obj["magic"] = int64(0x52534632)
+		return obj
+	})
+	s.Require().Nil(err)
+
+	r := NewReader()
+	rBuf := bufio.NewReader(out)
+	index, err := r.ReadIndex(rBuf)
+	s.Require().Nil(err)
+	s.Assert().Equal(FieldTypeBigEndianInt, index[0].FieldType)
+	s.Assert().Equal(4, index[0].FieldSize)
+	var rec endianRecord
+	s.Require().Nil(r.Unmarshal(rBuf, &rec))
+	s.Assert().Equal(int32(0x52534632), rec.Magic)
+	s.Assert().Equal(testEndianData.Scale, rec.Scale)
+}
+
+func (s *WriterEndianSuite) TestBigEndianInvalid() {
+	type str struct {
+		Name string `rsf:"name,be"`
+	}
+	w := NewWriterWithVersion(&bytes.Buffer{}, Version2)
+	_, err := w.WriteObject(str{Name: "rsf"})
+	s.Assert().EqualError(err, "field name: the be option requires an integer or float field")
+
+	type fixed struct {
+		Age int `rsf:"age,be,fixedint"`
+	}
+	w = NewWriterWithVersion(&bytes.Buffer{}, Version2)
+	_, err = w.WriteObject(fixed{Age: 1})
+	s.Assert().EqualError(err, "field age: the be option cannot be used with the fixedint option")
+}
@@ -0,0 +1,36 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+const sizeFingerprint = 8
+
+// writeFingerprint writes the fingerprint of the index entries in `indexBuf`
+// to `out`. The fingerprint is calculated from the index as a reader parses
+// it, so that it matches `Index.Fingerprint` for the index read from the
+// file.
+func (f *rsfWriter) writeFingerprint(indexBuf *bytes.Buffer, out io.Writer) (int, error) {
+	tr := &rsfReader{
+		indexVersion: Version3,
+		features:     f.features(),
+	}
+	r := bytes.NewReader(indexBuf.Bytes())
+	if f.fieldNameTable {
+		err := tr.readFieldNameTable(r, int64(indexBuf.Len()))
+		if err != nil {
+			return 0, err
+		}
+	}
+	index, err := tr.readIndexEntries(r, int64(indexBuf.Len()), 0, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	bs := make([]byte, sizeFingerprint)
+	binary.LittleEndian.PutUint64(bs, index.Fingerprint())
+	return out.Write(bs)
+}
@@ -0,0 +1,145 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WriterFingerprintSuite struct {
+	suite.Suite
+}
+
+func TestWriterFingerprintSuite(t *testing.T) {
+	suite.Run(t, &WriterFingerprintSuite{})
+}
+
+// Same fields as `namedOption`, with a different Go type name.
+type namedOptionCopy struct {
+	Description string `rsf:"description"`
+	Maintainer  string `rsf:"maintainer"`
+	Published   bool   `rsf:"published"`
+}
+
+// Like `namedOption`, but `published` is retyped.
+type namedOptionRetyped struct {
+	Description string `rsf:"description"`
+	Maintainer  string `rsf:"maintainer"`
+	Published   int    `rsf:"published"`
+}
+
+// schemaIndex returns the index written for the struct `v`.
+func (s *WriterFingerprintSuite) schemaIndex(v any) Index {
+	buf := &bytes.Buffer{}
+	s.Require().Nil(WriteSchema(v, buf, Version3))
+	index, err := NewReader().ReadIndex(buf)
+	s.Require().Nil(err)
+	return index
+}
+
+func (s *WriterFingerprintSuite) TestIndexFingerprint() {
+	fp := s.schemaIndex(namedOption{}).Fingerprint()
+	s.Assert().NotZero(fp)
+
+	// Structurally identical schemas have the same fingerprint.
+	s.Assert().Equal(fp, s.schemaIndex(namedOptionCopy{}).Fingerprint())
+	s.Assert().Equal(fp, s.schemaIndex(namedOption{}).Fingerprint())
+
+	// A retyped field changes the fingerprint.
+	s.Assert().NotEqual(fp, s.schemaIndex(namedOptionRetyped{}).Fingerprint())
+
+	// So do changes to subfields.
+	s.Assert().NotEqual(
+		s.schemaIndex(namedRecord{}).Fingerprint(),
+		s.schemaIndex(struct {
+			Name     string               `rsf:"name"`
+			Imports  []namedOptionRetyped `rsf:"imports"`
+			Depends  []namedOption        `rsf:"depends"`
+			Suggests []namedOption        `rsf:"suggests"`
+			Enhances []namedOption        `rsf:"enhances"`
+		}{}).Fingerprint())
+}
+
+func (s *WriterFingerprintSuite) TestSchemaFingerprint() {
+	expected := s.schemaIndex(namedRecord{}).Fingerprint()
+
+	for _, opts := range [][]WriterOption{
+		{Fingerprint(true)},
+		{Fingerprint(true), FieldNameTable(true), PackBools(true), VerboseIndex(true)},
+		{},
+	} {
+		buf := &bytes.Buffer{}
+		w := NewWriterWithVersion(buf, Version3, opts...)
+		_, err := w.WriteObject(testNamedData)
+		s.Require().Nil(err)
+		s.Require().Nil(w.Close())
+
+		r := NewReader()
+		br := bufio.NewReader(buf)
+		_, err = r.ReadIndex(br)
+		s.Require().Nil(err)
+		s.Assert().Equal(expected, r.SchemaFingerprint())
+
+		// Objects follow the fingerprint.
+		var rec namedRecord
+		s.Require().Nil(r.Unmarshal(br, &rec))
+		s.Assert().Equal(testNamedData.Name, rec.Name)
+		s.Assert().Equal(testNamedData.Depends, rec.Depends)
+	}
+}
+
+func (s *WriterFingerprintSuite) TestFingerprintVersion() {
+	w := NewWriterWithVersion(&bytes.Buffer{}, Version2, Fingerprint(true))
+	_, err := w.WriteObject(testNamedData)
+	s.Assert().ErrorIs(err, ErrFingerprintVersion)
+}
+
+func (s *WriterFingerprintSuite) TestProducerVersion() {
+	write := func(opts ...WriterOption) []byte {
+		buf := &bytes.Buffer{}
+		w := NewWriterWithVersion(buf, Version3, opts...)
+		_, err := w.WriteObject(testNamedData)
+		s.Require().Nil(err)
+		s.Require().Nil(w.Close())
+		return buf.Bytes()
+	}
+
+	for _, opts := range [][]WriterOption{
+		{ProducerVersion("v1.4.0")},
+		{ProducerVersion("v1.4.0"), Fingerprint(true), StringTable(true)},
+	} {
+		data := write(opts...)
+		r := NewReader()
+		buf := bufio.NewReader(bytes.NewReader(data))
+		_, err := r.ReadIndex(buf)
+		s.Require().Nil(err)
+		s.Assert().Equal("v1.4.0", r.WriterVersion())
+		s.Assert().Equal(s.schemaIndex(namedRecord{}).Fingerprint(), r.SchemaFingerprint())
+
+		// The objects follow.
+		var rec namedRecord
+		s.Require().Nil(r.Unmarshal(buf, &rec))
+		s.Assert().Equal(testNamedData, rec)
+
+		// The version is also read when the index is skipped.
+		r = NewReader()
+		s.Require().Nil(r.SkipIndex(bytes.NewReader(data)))
+		s.Assert().Equal("v1.4.0", r.WriterVersion())
+	}
+
+	// Files without a producer version return an empty string, even when
+	// read by a reader that previously read a stamped file.
+	r := NewReader()
+	_, err := r.ReadIndex(bytes.NewReader(write(ProducerVersion("v1.4.0"))))
+	s.Require().Nil(err)
+	_, err = r.ReadIndex(bytes.NewReader(write()))
+	s.Require().Nil(err)
+	s.Assert().Equal("", r.WriterVersion())
+
+	w := NewWriterWithVersion(&bytes.Buffer{}, Version2, ProducerVersion("v1.4.0"))
+	_, err = w.WriteObject(testNamedData)
+	s.Assert().ErrorIs(err, ErrProducerVersionVersion)
+}
@@ -0,0 +1,106 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WriterFixedIntSuite struct {
+	suite.Suite
+}
+
+func TestWriterFixedIntSuite(t *testing.T) {
+	suite.Run(t, &WriterFixedIntSuite{})
+}
+
+type fixedIntPoint struct {
+	X int64 `rsf:"x,fixedint"`
+	Y int64 `rsf:"y,fixedint"`
+}
+
+type fixedIntRecord struct {
+	Name   string          `rsf:"name"`
+	Age    int             `rsf:"age,fixedint"`
+	Size   int64           `rsf:"size"`
+	Points []fixedIntPoint `rsf:"points,packed"`
+}
+
+var testFixedIntData = fixedIntRecord{
+	Name:   "rsf",
+	Age:    -55,
+	Size:   1024,
+	Points: []fixedIntPoint{{X: 1, Y: -2}, {X: 3, Y: 4}},
+}
+
+func (s *WriterFixedIntSuite) TestWriteFixedInt64Field() {
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf)
+	sz, err := w.WriteFixedInt64Field(0, 4567, buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(8, sz)
+	// Hex 11d7 equals 4567.
+	s.Assert().Equal([]byte{0xd7, 0x11, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0}, buf.Bytes())
+
+	r := NewReader()
+	i, err := r.ReadFixedIntField(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(int64(4567), i)
+	s.Assert().Equal(int64(8), r.Pos())
+}
+
+func (s *WriterFixedIntSuite) TestFixedInt() {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	_, err := w.WriteObject(testFixedIntData)
+	s.Require().Nil(err)
+	s.Require().Nil(w.Close())
+
+	r := NewReader()
+	rBuf := bufio.NewReader(bytes.NewReader(buf.Bytes()))
+	index, err := r.ReadIndex(rBuf)
+	s.Require().Nil(err)
+	s.Assert().Equal(FieldTypeFixedInt64, index[1].FieldType)
+	s.Assert().Equal(FieldTypeInt64, index[2].FieldType)
+
+	// Each point is two 8-byte values.
+	s.Assert().Equal(FieldTypePackedArray, index[3].FieldType)
+	s.Assert().Equal(16, index[3].FieldSize)
+
+	// The field is written as exactly 8 bytes.
+	objStart := r.Pos()
+	_, err = r.ReadSizeField(rBuf)
+	s.Require().Nil(err)
+	s.Require().Nil(r.AdvanceTo(rBuf, "age"))
+	agePos := r.Pos()
+	age, err := r.ReadFixedIntField(rBuf)
+	s.Require().Nil(err)
+	s.Assert().Equal(int64(-55), age)
+	s.Assert().Equal(int64(8), r.Pos()-agePos)
+
+	var rec fixedIntRecord
+	rBuf = bufio.NewReader(bytes.NewReader(buf.Bytes()[objStart:]))
+	s.Require().Nil(r.Unmarshal(rBuf, &rec))
+	s.Assert().Equal(testFixedIntData, rec)
+
+	rBuf = bufio.NewReader(bytes.NewReader(buf.Bytes()[objStart:]))
+	obj, err := r.DecodeObject(rBuf)
+	s.Require().Nil(err)
+	s.Assert().Equal(int64(-55), obj["age"])
+
+	out := &bytes.Buffer{}
+	s.Require().Nil(Print(out, bufio.NewReader(bytes.NewReader(buf.Bytes()))))
+	s.Assert().Contains(out.String(), "age (fixed int): -55\n")
+}
+
+func (s *WriterFixedIntSuite) TestFixedIntNonInteger() {
+	type record struct {
+		Name string `rsf:"name,fixedint"`
+	}
+	w := NewWriterWithVersion(&bytes.Buffer{}, Version2)
+	_, err := w.WriteObject(record{Name: "rsf"})
+	s.Assert().EqualError(err, "field name: the fixedint option requires an integer field")
+}
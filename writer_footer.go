@@ -0,0 +1,75 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"errors"
+)
+
+/*
+
+Writers created with the `FooterIndex` option write the index after the last
+object, rather than before the first, so each object is written as soon as
+`WriteObject` is called. The index is preceded by a zero-length object size,
+which marks the end of the objects, so the objects begin at the start of the
+file.
+
+Format:
+
+  [object 1]
+  [object n]
+  [end of objects marker (0)]
+  [index]
+  [index size]
+  [footer index marker]
+
+The index is written exactly as it would be at the start of the file,
+including the index version header and any schemas that follow it. The index
+size includes everything from the index version header through the end of the
+index so that readers can locate the index by reading the final eight bytes of
+the file with `ReadFooterIndex`.
+
+*/
+
+// FooterIndexMarker marks the end of a file whose index follows the objects.
+var FooterIndexMarker = []byte{0x52, 0x53, 0x46, 0x49} // "RSFI"
+
+var ErrFooterIndexOption = errors.New("the footer index cannot be used with a string table, an object key table, or a trailer")
+
+// FooterIndex writes the index after the last object when the writer is
+// closed, rather than before the first object. Each object is written (or
+// streamed, for files) as soon as it is passed to `WriteObject`, and only the
+// index is held in memory until `Close` is called. Since `WriteObject` does
+// not write the index, its result is the size of the object alone.
+//
+// Reading the file requires a seekable reader, since the index must be read
+// from the end of the file with `ReadFooterIndex` before the objects. The
+// footer index cannot be combined with a string table, an object key table
+// written to the same file, a trailer, or columnar records. Files with a
+// footer index cannot be merged or printed (see `HasFooterIndex`).
+func FooterIndex(enabled bool) WriterOption {
+	return func(f *rsfWriter) {
+		f.footerIndex = enabled
+	}
+}
+
+// writeFooterIndex writes the end of objects marker and the buffered index to
+// the underlying writer.
+func (f *rsfWriter) writeFooterIndex() error {
+	// Mark the end of the objects.
+	_, err := f.WriteSizeField(0, 0, f.writer)
+	if err != nil {
+		return err
+	}
+
+	sz := f.footer.Len()
+	_, err = f.footer.WriteTo(f.writer)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteSizeField(0, sz, f.writer)
+	if err != nil {
+		return err
+	}
+	_, err = f.writer.Write(FooterIndexMarker)
+	return err
+}
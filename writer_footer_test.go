@@ -0,0 +1,201 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WriterFooterSuite struct {
+	suite.Suite
+}
+
+func TestWriterFooterSuite(t *testing.T) {
+	suite.Run(t, &WriterFooterSuite{})
+}
+
+// decodeAll reads the index of the RSF data in `data`, from the footer if
+// `footer` is true, and decodes each object.
+func (s *WriterFooterSuite) decodeAll(data []byte, footer bool) (Index, []map[string]any) {
+	r := NewReader()
+	rs := bytes.NewReader(data)
+	var index Index
+	var err error
+	if footer {
+		index, err = r.ReadFooterIndex(rs)
+		s.Require().Nil(err)
+		s.Require().Equal(int64(0), r.Pos())
+	}
+	buf := bufio.NewReader(rs)
+	if !footer {
+		index, err = r.ReadIndex(buf)
+		s.Require().Nil(err)
+	}
+	objs, err := r.DecodeAll(buf)
+	s.Require().Nil(err)
+	return index, objs
+}
+
+func (s *WriterFooterSuite) TestFooterIndex() {
+	header := getComplexData(&s.Suite).Bytes()
+
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2, FooterIndex(true))
+	for _, obj := range testComplexData {
+		// Only the object is written, so the first object isn't larger
+		// than it would otherwise be.
+		sz, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+		s.Assert().Less(sz, len(header)/2)
+	}
+	s.Require().Nil(w.Close())
+	s.Assert().Equal(FooterIndexMarker, buf.Bytes()[buf.Len()-len(FooterIndexMarker):])
+
+	// Footer-index files decode identically to header-index files.
+	headerIndex, headerObjs := s.decodeAll(header, false)
+	footerIndex, footerObjs := s.decodeAll(buf.Bytes(), true)
+	s.Assert().Equal(headerIndex, footerIndex)
+	s.Assert().Equal(headerObjs, footerObjs)
+	s.Assert().Len(footerObjs, 2)
+
+	// Objects can also be unmarshaled.
+	r := NewReader()
+	rs := bytes.NewReader(buf.Bytes())
+	_, err := r.ReadFooterIndex(rs)
+	s.Require().Nil(err)
+	rbuf := bufio.NewReader(rs)
+	for _, obj := range testComplexData {
+		var rec FullPackageRecordPyPI
+		err = r.Unmarshal(rbuf, &rec)
+		s.Require().Nil(err)
+		s.Assert().Equal(obj.CanonicalName, rec.CanonicalName)
+		s.Assert().Len(rec.Snapshots, len(obj.Snapshots))
+	}
+	err = r.Unmarshal(rbuf, &FullPackageRecordPyPI{})
+	s.Assert().ErrorIs(err, io.EOF)
+}
+
+func (s *WriterFooterSuite) TestFooterIndexStreamed() {
+	// Objects are streamed to seekable writers, and the index still follows
+	// them.
+	tmp, err := os.CreateTemp("", "")
+	s.Require().Nil(err)
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	w := NewWriterWithVersion(tmp, Version3, FooterIndex(true), PackBools(true))
+	for _, obj := range testComplexData {
+		_, err = w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+	s.Require().Nil(w.Close())
+	data, err := os.ReadFile(tmp.Name())
+	s.Require().Nil(err)
+
+	buf := &bytes.Buffer{}
+	w = NewWriterWithVersion(buf, Version3, PackBools(true))
+	for _, obj := range testComplexData {
+		_, err = w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+	s.Require().Nil(w.Close())
+
+	headerIndex, headerObjs := s.decodeAll(buf.Bytes(), false)
+	footerIndex, footerObjs := s.decodeAll(data, true)
+	s.Assert().Equal(headerIndex, footerIndex)
+	s.Assert().Equal(headerObjs, footerObjs)
+}
+
+func (s *WriterFooterSuite) TestFooterIndexOptions() {
+	w := NewWriterWithVersion(&bytes.Buffer{}, Version3, FooterIndex(true), StringTable(true))
+	_, err := w.WriteObject(testComplexData[0])
+	s.Assert().ErrorIs(err, ErrFooterIndexOption)
+
+	w = NewWriterWithVersion(&bytes.Buffer{}, Version3, FooterIndex(true), ObjectKey("cname"))
+	_, err = w.WriteObject(testComplexData[0])
+	s.Assert().ErrorIs(err, ErrFooterIndexOption)
+
+	// A trailer is rejected before any objects are written.
+	w = NewWriterWithVersion(&bytes.Buffer{}, Version2, FooterIndex(true))
+	w.WriteTrailer(testTrailer)
+	_, err = w.WriteObject(testComplexData[0])
+	s.Assert().ErrorIs(err, ErrFooterIndexOption)
+
+	// A trailer set after the first object is rejected by the next
+	// object and by `Close`, which still writes a readable file.
+	buf := &bytes.Buffer{}
+	w = NewWriterWithVersion(buf, Version2, FooterIndex(true))
+	_, err = w.WriteObject(testComplexData[0])
+	s.Require().Nil(err)
+	w.WriteTrailer(testTrailer)
+	_, err = w.WriteObject(testComplexData[1])
+	s.Assert().ErrorIs(err, ErrFooterIndexOption)
+	s.Assert().ErrorIs(w.Close(), ErrFooterIndexOption)
+	_, objs := s.decodeAll(buf.Bytes(), true)
+	s.Assert().Len(objs, 1)
+	_, err = ReadTrailer(bytes.NewReader(buf.Bytes()))
+	s.Assert().ErrorIs(err, ErrNoTrailer)
+
+	w = NewWriterWithVersion(&bytes.Buffer{}, Version3, FooterIndex(true))
+	s.Assert().ErrorIs(w.WriteColumnar(testComplexData), ErrColumnarOption)
+}
+
+func (s *WriterFooterSuite) TestNoFooterIndex() {
+	r := NewReader()
+	_, err := r.ReadFooterIndex(bytes.NewReader(getComplexData(&s.Suite).Bytes()))
+	s.Assert().ErrorIs(err, ErrNoFooterIndex)
+	_, err = r.ReadFooterIndex(bytes.NewReader(nil))
+	s.Assert().ErrorIs(err, ErrNoFooterIndex)
+
+	found, err := HasFooterIndex(bytes.NewReader(getComplexData(&s.Suite).Bytes()))
+	s.Require().Nil(err)
+	s.Assert().False(found)
+	found, err = HasFooterIndex(bytes.NewReader(nil))
+	s.Require().Nil(err)
+	s.Assert().False(found)
+
+	// Data that happens to end with the marker bytes has no footer index.
+	type record struct {
+		Name string `rsf:"name"`
+	}
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version1)
+	_, err = w.WriteObject(record{Name: "abcdRSFI"})
+	s.Require().Nil(err)
+	s.Require().Nil(w.Close())
+	s.Require().Equal(FooterIndexMarker, buf.Bytes()[buf.Len()-len(FooterIndexMarker):])
+	found, err = HasFooterIndex(bytes.NewReader(buf.Bytes()))
+	s.Require().Nil(err)
+	s.Assert().False(found)
+	s.Assert().Nil(Merge(&bytes.Buffer{}, bytes.NewReader(buf.Bytes()), bytes.NewReader(buf.Bytes())))
+}
+
+func (s *WriterFooterSuite) TestFooterIndexMerge() {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2, FooterIndex(true))
+	for _, obj := range testComplexData {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+	s.Require().Nil(w.Close())
+
+	// The position of the source is restored.
+	rs := bytes.NewReader(buf.Bytes())
+	_, err := rs.Seek(4, io.SeekStart)
+	s.Require().Nil(err)
+	found, err := HasFooterIndex(rs)
+	s.Require().Nil(err)
+	s.Assert().True(found)
+	pos, err := rs.Seek(0, io.SeekCurrent)
+	s.Require().Nil(err)
+	s.Assert().Equal(int64(4), pos)
+
+	header := getComplexData(&s.Suite).Bytes()
+	err = Merge(&bytes.Buffer{}, bytes.NewReader(header), bytes.NewReader(buf.Bytes()))
+	s.Assert().EqualError(err, "source 1 has a footer index and cannot be merged")
+}
@@ -0,0 +1,39 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"hash"
+	"io"
+)
+
+// HashingWriter is a `Writer` that also computes a hash of all of the bytes it
+// writes.
+type HashingWriter interface {
+	Writer
+
+	// Sum returns the hash of the bytes written so far. Since some writers
+	// buffer data until `Close` is called, call `Sum` after `Close` to get
+	// the hash of the complete file.
+	Sum() []byte
+}
+
+type hashingWriter struct {
+	*rsfWriter
+	hash hash.Hash
+}
+
+// NewHashingWriter returns a writer like `NewWriterWithVersion` that writes all
+// bytes through `h` as they are written to `w`. This computes the hash of the
+// serialized file (e.g., for content-addressed storage) without reading it
+// again.
+func NewHashingWriter(w io.Writer, h hash.Hash, version int, opts ...WriterOption) HashingWriter {
+	f := NewWriterWithVersion(io.MultiWriter(w, h), version, opts...).(*rsfWriter)
+	return &hashingWriter{
+		rsfWriter: f,
+		hash:      h,
+	}
+}
+
+func (f *hashingWriter) Sum() []byte {
+	return f.hash.Sum(nil)
+}
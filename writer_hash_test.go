@@ -0,0 +1,55 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WriterHashSuite struct {
+	suite.Suite
+}
+
+func TestWriterHashSuite(t *testing.T) {
+	suite.Run(t, &WriterHashSuite{})
+}
+
+func (s *WriterHashSuite) TestHashingWriter() {
+	buf := &bytes.Buffer{}
+	w := NewHashingWriter(buf, sha256.New(), Version2)
+	for _, obj := range testComplexData {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+	s.Require().Nil(w.Close())
+
+	expected := sha256.Sum256(buf.Bytes())
+	s.Assert().Equal(expected[:], w.Sum())
+}
+
+func (s *WriterHashSuite) TestHashingWriterBuffered() {
+	// Writers with a string table and key table write data on `Close`.
+	buf := &bytes.Buffer{}
+	w := NewHashingWriter(buf, sha256.New(), Version3, StringTable(true), ObjectKey("cname"))
+	for _, obj := range testComplexData {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+	s.Require().Nil(w.Close())
+
+	expected := sha256.Sum256(buf.Bytes())
+	s.Assert().Equal(expected[:], w.Sum())
+
+	// The hash matches a file written without hashing.
+	plain := &bytes.Buffer{}
+	pw := NewWriterWithVersion(plain, Version3, StringTable(true), ObjectKey("cname"))
+	for _, obj := range testComplexData {
+		_, err := pw.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+	s.Require().Nil(pw.Close())
+	s.Assert().Equal(plain.Bytes(), buf.Bytes())
+}
@@ -65,22 +65,120 @@ const (
 	FieldTypeArray    = 4
 	FieldTypeFloat    = 6
 	FieldTypeInt64    = 7
+	// A compressed variable-length string. See `Compression`.
+	FieldTypeCompressedStr = 8
+	// An array of fixed-size struct elements written without per-element
+	// sizes. The record size is stored in the index as the field size.
+	FieldTypePackedArray = 9
+	// A string written in the object's overflow region. The field holds the
+	// offset and length of the string.
+	FieldTypeOverflowStr = 10
+	// An IP address (`net.IP` or `netip.Addr`). See `WriteIPField`.
+	FieldTypeIP = 11
+	// A value encoded by an `RSFMarshaler`, preceded by its size.
+	FieldTypeOpaque = 12
+	// An integer written as a fixed 8-byte little-endian value. See the
+	// `fixedint` struct tag option.
+	FieldTypeFixedInt64 = 13
+	// A bool field packed in the object's bitmap. The field size is the bit
+	// number. See `PackBools`.
+	FieldTypePackedBool = 14
+	// The bitmap of packed bool fields. The field size is the bitmap size.
+	FieldTypeBoolBitmap = 15
+	// An integer or float written as a fixed-width big-endian value. The
+	// field size is the width, 4 or 8 bytes. See the `be` struct tag option.
+	FieldTypeBigEndianInt   = 16
+	FieldTypeBigEndianFloat = 17
+	// A date written as a 4-byte number of days since 1970-01-01. See the
+	// `days` struct tag option.
+	FieldTypeDays = 18
+	// A byte slice written as a 4-byte size followed by the bytes. See
+	// `WriteBytesField`.
+	FieldTypeBytes = 19
 )
 
+// typeTags maps each field type to the type tag written in a verbose index.
+// See `VerboseIndex`.
+var typeTags = map[int]string{
+	FieldTypeVarStr:        "str",
+	FieldTypeFixedStr:      "fstr",
+	FieldTypeBool:          "bool",
+	FieldTypeArray:         "arr",
+	FieldTypeFloat:         "f64",
+	FieldTypeInt64:         "i64",
+	FieldTypeCompressedStr: "cstr",
+	FieldTypePackedArray:   "parr",
+	FieldTypeOverflowStr:   "ostr",
+	FieldTypeIP:            "ip",
+	FieldTypeOpaque:        "opaque",
+	FieldTypeFixedInt64:    "fi64",
+	FieldTypePackedBool:    "bit",
+	FieldTypeBoolBitmap:    "bitmap",
+
+	FieldTypeBigEndianInt:   "bei",
+	FieldTypeBigEndianFloat: "bef",
+	FieldTypeDays:           "days",
+	FieldTypeBytes:          "bytes",
+}
+
+// typeTag returns the type tag of `fieldType`, or a description of the
+// numeric type if it is unknown.
+func typeTag(fieldType int) string {
+	tag, ok := typeTags[fieldType]
+	if !ok {
+		return fmt.Sprintf("type(%d)", fieldType)
+	}
+	return tag
+}
+
 func (f *rsfWriter) writeIndexObject(v reflect.Type, t *tag, buf *bytes.Buffer) (int, error) {
+	if isMarshalerType(v) {
+		return f.writeIndexFixed(t, FieldTypeOpaque, buf)
+	}
+	if isIPType(v) {
+		return f.writeIndexFixed(t, FieldTypeIP, buf)
+	}
+	if isTimeType(v) {
+		if !t.days {
+			return 0, withField(t.path, errTimeDays)
+		}
+		return f.writeIndexFixed(t, FieldTypeDays, buf)
+	}
+	if isBytesType(v) {
+		return f.writeIndexFixed(t, FieldTypeBytes, buf)
+	}
+
 	switch v.Kind() {
 	case reflect.Array, reflect.Slice:
 		return f.writeIndexArray(v, t, buf)
+	case reflect.Map:
+		et, err := f.mapEntriesType(v, t)
+		if err != nil {
+			return 0, err
+		}
+		return f.writeIndexArray(et, t, buf)
 	case reflect.Struct:
 		sz, _, err := f.writeIndexStruct(v, t, buf)
 		return sz, err
 	case reflect.String:
 		return f.writeIndexString(t, buf)
 	case reflect.Bool:
+		if f.packedBool(t) {
+			return f.writeIndexPackedBool(t, buf)
+		}
 		return f.writeIndexFixed(t, FieldTypeBool, buf)
 	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+		if t.bigEndian > 0 {
+			return f.writeIndexBigEndian(t, FieldTypeBigEndianInt, buf)
+		}
+		if t.fixedInt {
+			return f.writeIndexFixed(t, FieldTypeFixedInt64, buf)
+		}
 		return f.writeIndexFixed(t, FieldTypeInt64, buf)
 	case reflect.Float32, reflect.Float64:
+		if t.bigEndian > 0 {
+			return f.writeIndexBigEndian(t, FieldTypeBigEndianFloat, buf)
+		}
 		return f.writeIndexFixed(t, FieldTypeFloat, buf)
 	default:
 		return 0, fmt.Errorf("unknown field type %#v: %#v", v.Kind(), v)
@@ -113,19 +211,33 @@ func (f *rsfWriter) writeIndexStruct(v reflect.Type, tParent *tag, buf *bytes.Bu
 
 func (f *rsfWriter) writeIndexArray(v reflect.Type, t *tag, buf *bytes.Buffer) (int, error) {
 	var totalSz int
-	sz, err := f.WriteStringField(0, t.name, buf)
+	sz, err := f.writeIndexName(t.name, buf)
 	if err != nil {
 		return 0, err
 	}
 	totalSz += sz
 
-	sz, err = f.WriteSizeField(0, FieldTypeArray, buf)
+	recordSz := f.packedSize(v, t)
+	fieldType := FieldTypeArray
+	if recordSz > 0 {
+		fieldType = FieldTypePackedArray
+	}
+	sz, err = f.writeIndexType(fieldType, t, buf)
 	if err != nil {
 		return 0, err
 	}
 	totalSz += sz
 
 	el := v.Elem()
+	if isIPType(el) {
+		return 0, fmt.Errorf("array %s: arrays of IP addresses are not supported", t.name)
+	} else if isTimeType(el) {
+		return 0, fmt.Errorf("array %s: arrays of times are not supported", t.name)
+	} else if isMarshalerType(el) {
+		return 0, fmt.Errorf("array %s: arrays of RSFMarshaler values are not supported", t.name)
+	} else if isBytesType(el) && !isIPType(el) {
+		return 0, fmt.Errorf("array %s: arrays of byte slices are not supported", t.name)
+	}
 
 	// For an indexed struct array, find the index size
 	if f.version > 1 {
@@ -193,6 +305,25 @@ func (f *rsfWriter) writeIndexArray(v reflect.Type, t *tag, buf *bytes.Buffer) (
 		totalSz += sz
 	}
 
+	// Write the record size for packed arrays
+	if recordSz > 0 {
+		sz, err = f.WriteSizeField(0, recordSz, buf)
+		if err != nil {
+			return 0, err
+		}
+		totalSz += sz
+	}
+
+	// Record the presence bit of optional arrays (see `NilAsAbsent`)
+	if f.optionalArray(t) {
+		sz, err = f.WriteSizeField(0, f.boolCount, buf)
+		if err != nil {
+			return 0, err
+		}
+		totalSz += sz
+		f.boolCount++
+	}
+
 	// Record the number of subfields in the array
 	sz, err = f.WriteSizeField(0, subfields, buf)
 	if err != nil {
@@ -214,6 +345,15 @@ func (f *rsfWriter) writeIndexArray(v reflect.Type, t *tag, buf *bytes.Buffer) (
 }
 
 func (f *rsfWriter) writeIndexString(t *tag, buf *bytes.Buffer) (int, error) {
+	if t.compress {
+		return f.writeIndexFixed(t, FieldTypeCompressedStr, buf)
+	}
+
+	if t.overflow {
+		f.overflowFields = true
+		return f.writeIndexFixed(t, FieldTypeOverflowStr, buf)
+	}
+
 	if t.fixed > 0 {
 		sz, err := f.writeIndexFixed(t, FieldTypeFixedStr, buf)
 		if err != nil {
@@ -225,13 +365,13 @@ func (f *rsfWriter) writeIndexString(t *tag, buf *bytes.Buffer) (int, error) {
 	}
 
 	var totalSz int
-	sz, err := f.WriteStringField(0, t.name, buf)
+	sz, err := f.writeIndexName(t.name, buf)
 	if err != nil {
 		return 0, err
 	}
 	totalSz += sz
 
-	sz, err = f.WriteSizeField(0, FieldTypeVarStr, buf)
+	sz, err = f.writeIndexType(FieldTypeVarStr, t, buf)
 	if err != nil {
 		return 0, err
 	}
@@ -242,13 +382,13 @@ func (f *rsfWriter) writeIndexString(t *tag, buf *bytes.Buffer) (int, error) {
 
 func (f *rsfWriter) writeIndexFixed(t *tag, fieldType int, buf *bytes.Buffer) (int, error) {
 	var totalSz int
-	sz, err := f.WriteStringField(0, t.name, buf)
+	sz, err := f.writeIndexName(t.name, buf)
 	if err != nil {
 		return 0, err
 	}
 	totalSz += sz
 
-	sz, err = f.WriteSizeField(0, fieldType, buf)
+	sz, err = f.writeIndexType(fieldType, t, buf)
 	if err != nil {
 		return 0, err
 	}
@@ -256,3 +396,101 @@ func (f *rsfWriter) writeIndexFixed(t *tag, fieldType int, buf *bytes.Buffer) (i
 
 	return totalSz, err
 }
+
+// writeIndexType writes the field type of an index entry, followed by the
+// type tag when the verbose index is enabled and the field width when field
+// widths are enabled.
+func (f *rsfWriter) writeIndexType(fieldType int, t *tag, buf *bytes.Buffer) (int, error) {
+	totalSz, err := f.WriteSizeField(0, fieldType, buf)
+	if err != nil {
+		return 0, err
+	}
+
+	if f.verboseIndex {
+		var sz int
+		sz, err = f.WriteStringField(0, typeTags[fieldType], buf)
+		if err != nil {
+			return 0, err
+		}
+		totalSz += sz
+	}
+
+	if f.fieldWidths {
+		var sz int
+		sz, err = f.WriteSizeField(0, f.fieldWidth(fieldType, t), buf)
+		if err != nil {
+			return 0, err
+		}
+		totalSz += sz
+	}
+
+	return totalSz, nil
+}
+
+// fieldWidth returns the byte width of a field of type `fieldType`, or zero if
+// the width varies.
+func (f *rsfWriter) fieldWidth(fieldType int, t *tag) int {
+	switch fieldType {
+	case FieldTypeFixedStr, FieldTypeBoolBitmap:
+		return t.fixed
+	case FieldTypeVarStr:
+		// With a string table, strings are written as table indexes.
+		if f.stringTable {
+			return sizeFieldLen
+		}
+		return 0
+	case FieldTypeBool:
+		return 1
+	case FieldTypeInt64:
+		return sizeInt64
+	case FieldTypeFloat:
+		return sizeFloat64
+	case FieldTypeFixedInt64:
+		return sizeFixedInt64
+	case FieldTypeBigEndianInt, FieldTypeBigEndianFloat:
+		return t.bigEndian
+	case FieldTypeDays:
+		return sizeInt32
+	case FieldTypeOverflowStr:
+		return sizeFieldLen + sizeFieldLen
+	default:
+		return 0
+	}
+}
+
+// writeIndexName writes the field name of an index entry, or its id in the
+// field name table when the table is enabled. See `FieldNameTable`.
+func (f *rsfWriter) writeIndexName(name string, buf *bytes.Buffer) (int, error) {
+	if !f.fieldNameTable {
+		return f.WriteStringField(0, name, buf)
+	}
+
+	if f.fieldNames == nil {
+		f.fieldNames = make(map[string]int)
+	}
+	id, ok := f.fieldNames[name]
+	if !ok {
+		id = len(f.fieldNameList)
+		f.fieldNames[name] = id
+		f.fieldNameList = append(f.fieldNameList, name)
+	}
+	return f.WriteSizeField(0, id, buf)
+}
+
+// writeFieldNameTable writes the count of field names followed by each name.
+// The table is written at the start of the index, before the first entry.
+func (f *rsfWriter) writeFieldNameTable(buf *bytes.Buffer) (int, error) {
+	totalSz, err := f.WriteSizeField(0, len(f.fieldNameList), buf)
+	if err != nil {
+		return 0, err
+	}
+	for _, name := range f.fieldNameList {
+		var sz int
+		sz, err = f.WriteStringField(0, name, buf)
+		if err != nil {
+			return 0, err
+		}
+		totalSz += sz
+	}
+	return totalSz, nil
+}
@@ -0,0 +1,114 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WriterIPSuite struct {
+	suite.Suite
+}
+
+func TestWriterIPSuite(t *testing.T) {
+	suite.Run(t, &WriterIPSuite{})
+}
+
+type ipRecord struct {
+	Host   string     `rsf:"host"`
+	Origin net.IP     `rsf:"origin"`
+	Edge   netip.Addr `rsf:"edge"`
+	Backup netip.Addr `rsf:"backup"`
+	Port   int        `rsf:"port"`
+}
+
+var testIPData = ipRecord{
+	Host:   "cdn.example.com",
+	Origin: net.ParseIP("192.0.2.10"),
+	Edge:   netip.MustParseAddr("2001:db8::1"),
+	Port:   443,
+}
+
+func (s *WriterIPSuite) TestWriteIPField() {
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf)
+	_, err := w.WriteIPField(0, netip.MustParseAddr("192.0.2.10"), buf)
+	s.Require().Nil(err)
+	_, err = w.WriteIPField(0, netip.Addr{}, buf)
+	s.Require().Nil(err)
+	s.Assert().Equal([]byte{
+		// IPv4 address
+		0x4, 0xc0, 0x0, 0x2, 0xa,
+		// Zero address
+		0x0,
+	}, buf.Bytes())
+
+	r := NewReader()
+	addr, err := r.ReadIPField(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal("192.0.2.10", addr.String())
+	addr, err = r.ReadIPField(buf)
+	s.Require().Nil(err)
+	s.Assert().False(addr.IsValid())
+	s.Assert().Equal(int64(6), r.Pos())
+
+	_, err = r.ReadIPField(bytes.NewReader([]byte{0x5, 0x1, 0x2, 0x3, 0x4, 0x5}))
+	s.Assert().EqualError(err, "invalid IP address length 5")
+}
+
+func (s *WriterIPSuite) TestIPRoundTrip() {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	_, err := w.WriteObject(testIPData)
+	s.Require().Nil(err)
+	data := buf.Bytes()
+
+	r := NewReader()
+	rBuf := bufio.NewReader(bytes.NewReader(data))
+	index, err := r.ReadIndex(rBuf)
+	s.Require().Nil(err)
+	s.Assert().Equal(FieldTypeIP, index[1].FieldType)
+	s.Assert().Equal(FieldTypeIP, index[2].FieldType)
+
+	var rec ipRecord
+	s.Require().Nil(r.Unmarshal(rBuf, &rec))
+	s.Assert().Equal("192.0.2.10", rec.Origin.String())
+	s.Assert().Equal(testIPData.Edge, rec.Edge)
+	s.Assert().False(rec.Backup.IsValid())
+	s.Assert().Equal(443, rec.Port)
+
+	// Advance past the IP addresses.
+	r = NewReader()
+	rBuf = bufio.NewReader(bytes.NewReader(data))
+	_, err = r.ReadIndex(rBuf)
+	s.Require().Nil(err)
+	_, err = r.BeginObject(rBuf)
+	s.Require().Nil(err)
+	err = r.AdvanceTo(rBuf, "port")
+	s.Require().Nil(err)
+	port, err := r.ReadIntField(rBuf)
+	s.Require().Nil(err)
+	s.Assert().Equal(int64(443), port)
+	s.Assert().Equal(0, r.RemainingInObject())
+
+	// Print
+	out := &bytes.Buffer{}
+	err = Print(out, bufio.NewReader(bytes.NewReader(data)))
+	s.Require().Nil(err)
+	s.Assert().Contains(out.String(), "origin (ip): 192.0.2.10\n")
+	s.Assert().Contains(out.String(), "edge (ip): 2001:db8::1\n")
+	s.Assert().Contains(out.String(), "backup (ip): \n")
+}
+
+func (s *WriterIPSuite) TestIPArrayError() {
+	w := NewWriterWithVersion(&bytes.Buffer{}, Version2)
+	_, err := w.WriteObject(struct {
+		Addrs []netip.Addr `rsf:"addrs"`
+	}{})
+	s.Assert().EqualError(err, "array addrs: arrays of IP addresses are not supported")
+}
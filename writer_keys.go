@@ -0,0 +1,156 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+/*
+
+When an object key is configured with `ObjectKey`, a key table is written
+after the last object when the writer is closed. The key table is preceded by
+a zero-length object size, which marks the end of the objects.
+
+Format:
+
+  [end of objects marker (0)]
+  [key type]
+  [key count]
+  [key 1]
+  [object 1 offset (8 bytes)]
+  [key n]
+  [object n offset (8 bytes)]
+  [key table size]
+  [key table marker]
+
+String keys are written as variable-length strings, and integer keys are
+written as int64 values. The key table size includes everything from the
+key type through the last offset so that readers can locate the table by
+reading the final eight bytes of the file.
+
+Writers created with `NewIndexedWriter` write the key table, without the end
+of objects marker, to a separate offset index file instead.
+
+*/
+
+// KeyTableMarker marks the end of a file that includes an object key table.
+var KeyTableMarker = []byte{0x52, 0x53, 0x46, 0x4b} // "RSFK"
+
+const sizeOffset = 8
+
+type objectKeyEntry struct {
+	key    any
+	offset int64
+}
+
+// recordKey records the value of the object key field for `v` along with the
+// object's offset.
+func (f *rsfWriter) recordKey(v reflect.Value, offset int64) error {
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("object key %s requires a struct object", f.objectKey)
+	}
+	fields, err := structFields(v.Type(), false)
+	if err != nil {
+		return err
+	}
+	keyField, ok := fields[f.objectKey]
+	if !ok {
+		return fmt.Errorf("object key field %s not found", f.objectKey)
+	}
+
+	var key any
+	field := v.FieldByIndex(keyField.path)
+	switch field.Kind() {
+	case reflect.String:
+		key = field.String()
+	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+		key = field.Int()
+	default:
+		return fmt.Errorf("object key field %s must be a string or integer", f.objectKey)
+	}
+
+	// All keys must share a type.
+	if len(f.keys) > 0 && reflect.TypeOf(f.keys[0].key) != reflect.TypeOf(key) {
+		return fmt.Errorf("object key field %s type changed", f.objectKey)
+	}
+
+	f.keys = append(f.keys, objectKeyEntry{key: key, offset: offset})
+	return nil
+}
+
+// NewIndexedWriter returns a writer like `NewWriterWithVersion` that writes
+// objects to `dataW` and, when the writer is closed, writes an offset index
+// to `idxW`. The offset index is a key table (see `ObjectKey`) that records
+// the value of the top-level field named `key` and the offset in `dataW` of
+// each object. Since the data file doesn't include the key table, the offset
+// index can be loaded into memory with `ReadObjectOffsets` and used to seek
+// directly to objects in the data file without scanning it.
+func NewIndexedWriter(dataW, idxW io.Writer, key string, version int, opts ...WriterOption) Writer {
+	f := NewWriterWithVersion(dataW, version, opts...).(*rsfWriter)
+	f.objectKey = key
+	f.keyWriter = idxW
+	return f
+}
+
+// writeKeyTable writes the end of objects marker and the key table to the
+// underlying writer.
+func (f *rsfWriter) writeKeyTable() error {
+	// Mark the end of the objects.
+	_, err := f.WriteSizeField(0, 0, f.writer)
+	if err != nil {
+		return err
+	}
+
+	return f.writeKeyEntries(f.writer)
+}
+
+// writeKeyEntries writes the key table, starting with the key type, to `w`.
+func (f *rsfWriter) writeKeyEntries(w io.Writer) error {
+	buf := &bytes.Buffer{}
+	keyType := reflect.String
+	if _, ok := f.keys[0].key.(int64); ok {
+		keyType = reflect.Int64
+	}
+	_, err := f.WriteSizeField(0, int(keyType), buf)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteSizeField(0, len(f.keys), buf)
+	if err != nil {
+		return err
+	}
+
+	bs := make([]byte, sizeOffset)
+	for _, entry := range f.keys {
+		switch key := entry.key.(type) {
+		case string:
+			_, err = f.WriteStringField(0, key, buf)
+		case int64:
+			_, err = f.WriteInt64Field(0, key, buf)
+		}
+		if err != nil {
+			return err
+		}
+		binary.LittleEndian.PutUint64(bs, uint64(entry.offset))
+		_, err = buf.Write(bs)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = f.WriteSizeField(0, buf.Len(), buf)
+	if err != nil {
+		return err
+	}
+	_, err = buf.Write(KeyTableMarker)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, buf)
+	return err
+}
@@ -0,0 +1,158 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WriteMap writes the generic map `m`, like one produced by decoding JSON, to
+// `w` as a single RSF object, preceded by its index. The index is inferred
+// from the map's keys and the kinds of its values, and fields are written in
+// sorted key order, so the output is deterministic.
+//
+// Values may be:
+//
+//   - `string`, written as a variable-length string.
+//   - `float64`, written as a float.
+//   - `bool`, written as a boolean.
+//   - `[]any`, written as an array. All elements must be of the same kind,
+//     and elements that are maps must have the same keys and value kinds.
+//     Empty arrays are written as string arrays.
+//   - `map[string]any`. Maps in arrays are written as struct elements. Since
+//     the index only records subfields for arrays, other nested maps are
+//     written as arrays with a single struct element, and `DecodeObject`
+//     reads them back as a `[]any` holding the map.
+//
+// The result can be read with `ReadIndex` and `DecodeObject`. Since `Version1`
+// indexes do not record array element types, use `Version2` or greater.
+func WriteMap(m map[string]any, w io.Writer, version int) error {
+	t, err := mapType(m, "")
+	if err != nil {
+		return err
+	}
+
+	v := reflect.New(t).Elem()
+	err = setMapFields(v, m)
+	if err != nil {
+		return err
+	}
+
+	f := &rsfWriter{
+		writer:  w,
+		version: version,
+	}
+	_, err = f.WriteObject(v.Interface())
+	return err
+}
+
+// mapType builds a struct type with a tagged field for each key of `m`, in
+// sorted order.
+func mapType(m map[string]any, path string) (reflect.Type, error) {
+	fields := make([]reflect.StructField, 0, len(m))
+	for i, key := range sortedKeys(m) {
+		if key == "" || key == rsfIgnore || strings.ContainsAny(key, rsfDelim+rsfSep) {
+			return nil, fmt.Errorf("map key %q cannot be used as a field name", path+key)
+		}
+
+		t, err := mapValueType(m[key], path+key)
+		if err != nil {
+			return nil, err
+		}
+
+		fields = append(fields, reflect.StructField{
+			Name: fmt.Sprintf("F%d", i),
+			Type: t,
+			Tag:  reflect.StructTag(tagName + ":" + strconv.Quote(key)),
+		})
+	}
+	return reflect.StructOf(fields), nil
+}
+
+// mapValueType returns the Go type used to write the map value `val`.
+func mapValueType(val any, path string) (reflect.Type, error) {
+	switch v := val.(type) {
+	case string, float64, bool:
+		return reflect.TypeOf(v), nil
+	case map[string]any:
+		t, err := mapType(v, path+rsfPathSep)
+		if err != nil {
+			return nil, err
+		}
+		return reflect.SliceOf(t), nil
+	case []any:
+		if len(v) == 0 {
+			return reflect.TypeOf([]string{}), nil
+		}
+
+		var el reflect.Type
+		for i, item := range v {
+			var itemType reflect.Type
+			var err error
+			switch item := item.(type) {
+			case map[string]any:
+				itemType, err = mapType(item, path+rsfPathSep)
+			case []any:
+				return nil, fmt.Errorf("array %s: nested arrays are not supported", path)
+			default:
+				itemType, err = mapValueType(item, path)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if el != nil && itemType != el {
+				return nil, fmt.Errorf("array %s: element %d does not match the type of the preceding elements", path, i)
+			}
+			el = itemType
+		}
+		return reflect.SliceOf(el), nil
+	default:
+		return nil, fmt.Errorf("map value %s has unsupported type %T", path, val)
+	}
+}
+
+// setMapFields sets the fields of the struct `v`, built by `mapType`, from
+// the values of `m`.
+func setMapFields(v reflect.Value, m map[string]any) error {
+	for i, key := range sortedKeys(m) {
+		field := v.Field(i)
+		switch val := m[key].(type) {
+		case []any:
+			slice := reflect.MakeSlice(field.Type(), len(val), len(val))
+			for n, item := range val {
+				if nested, ok := item.(map[string]any); ok {
+					err := setMapFields(slice.Index(n), nested)
+					if err != nil {
+						return err
+					}
+				} else {
+					slice.Index(n).Set(reflect.ValueOf(item))
+				}
+			}
+			field.Set(slice)
+		case map[string]any:
+			slice := reflect.MakeSlice(field.Type(), 1, 1)
+			err := setMapFields(slice.Index(0), val)
+			if err != nil {
+				return err
+			}
+			field.Set(slice)
+		default:
+			field.Set(reflect.ValueOf(val))
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
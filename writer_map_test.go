@@ -0,0 +1,153 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WriterMapSuite struct {
+	suite.Suite
+}
+
+func TestWriterMapSuite(t *testing.T) {
+	suite.Run(t, &WriterMapSuite{})
+}
+
+const testMapJSON = `{
+	"name": "rsf",
+	"score": 4.5,
+	"ready": true,
+	"tags": ["a", "b"],
+	"weights": [1, 2.5],
+	"empty": [],
+	"snapshots": [
+		{"date": "2023-01-01", "count": 2, "verified": true},
+		{"date": "2023-02-01", "count": 3, "verified": false}
+	]
+}`
+
+func (s *WriterMapSuite) TestWriteMap() {
+	var m map[string]any
+	s.Require().Nil(json.Unmarshal([]byte(testMapJSON), &m))
+
+	buf := &bytes.Buffer{}
+	s.Require().Nil(WriteMap(m, buf, Version2))
+
+	r := NewReader()
+	rBuf := bufio.NewReader(buf)
+	index, err := r.ReadIndex(rBuf)
+	s.Require().Nil(err)
+
+	// Fields are written in sorted order.
+	var names []string
+	for _, entry := range index {
+		names = append(names, entry.FieldName)
+	}
+	s.Assert().Equal([]string{"empty", "name", "ready", "score", "snapshots", "tags", "weights"}, names)
+
+	obj, err := r.DecodeObject(rBuf)
+	s.Require().Nil(err)
+	s.Assert().Equal(m, obj)
+}
+
+func (s *WriterMapSuite) TestWriteMapDeterministic() {
+	var m map[string]any
+	s.Require().Nil(json.Unmarshal([]byte(testMapJSON), &m))
+
+	first := &bytes.Buffer{}
+	s.Require().Nil(WriteMap(m, first, Version2))
+	for i := 0; i < 10; i++ {
+		buf := &bytes.Buffer{}
+		s.Require().Nil(WriteMap(m, buf, Version2))
+		s.Assert().Equal(first.Bytes(), buf.Bytes())
+	}
+}
+
+func (s *WriterMapSuite) TestWriteMapNested() {
+	var m map[string]any
+	s.Require().Nil(json.Unmarshal([]byte(`{
+		"name": "rsf",
+		"owners": [
+			{"email": "rsf@example.com", "admin": true},
+			{"email": "posit@example.com", "admin": false}
+		]
+	}`), &m))
+	buf := &bytes.Buffer{}
+	s.Require().Nil(WriteMap(m, buf, Version2))
+
+	// Nested maps in arrays read back as maps.
+	r := NewReader()
+	rBuf := bufio.NewReader(buf)
+	_, err := r.ReadIndex(rBuf)
+	s.Require().Nil(err)
+	obj, err := r.DecodeObject(rBuf)
+	s.Require().Nil(err)
+	s.Assert().Equal(m, obj)
+
+	// Other nested maps read back as arrays with a single map.
+	s.Require().Nil(json.Unmarshal([]byte(`{
+		"name": "rsf",
+		"owner": {"email": "rsf@example.com", "team": {"name": "data"}},
+		"owners": [{"contact": {"email": "posit@example.com"}}]
+	}`), &m))
+	buf = &bytes.Buffer{}
+	s.Require().Nil(WriteMap(m, buf, Version2))
+	rBuf = bufio.NewReader(buf)
+	_, err = r.ReadIndex(rBuf)
+	s.Require().Nil(err)
+	obj, err = r.DecodeObject(rBuf)
+	s.Require().Nil(err)
+	s.Assert().Equal(map[string]any{
+		"name": "rsf",
+		"owner": []any{map[string]any{
+			"email": "rsf@example.com",
+			"team":  []any{map[string]any{"name": "data"}},
+		}},
+		"owners": []any{map[string]any{
+			"contact": []any{map[string]any{"email": "posit@example.com"}},
+		}},
+	}, obj)
+}
+
+func (s *WriterMapSuite) TestWriteMapKeys() {
+	// Keys are quoted in the struct tags, so they are written unchanged.
+	m := map[string]any{}
+	for _, key := range []string{`back\slash`, "new\nline", `"quoted"`, "tab\there", "ünïcode"} {
+		m[key] = key
+	}
+	buf := &bytes.Buffer{}
+	s.Require().Nil(WriteMap(m, buf, Version2))
+
+	r := NewReader()
+	rBuf := bufio.NewReader(buf)
+	_, err := r.ReadIndex(rBuf)
+	s.Require().Nil(err)
+	obj, err := r.DecodeObject(rBuf)
+	s.Require().Nil(err)
+	s.Assert().Equal(m, obj)
+}
+
+func (s *WriterMapSuite) TestWriteMapErrors() {
+	err := WriteMap(map[string]any{"mixed": []any{"a", 1.0}}, &bytes.Buffer{}, Version2)
+	s.Assert().ErrorContains(err, "array mixed: element 1 does not match the type of the preceding elements")
+
+	err = WriteMap(map[string]any{"mixed": []any{
+		map[string]any{"a": "b"},
+		map[string]any{"a": true},
+	}}, &bytes.Buffer{}, Version2)
+	s.Assert().ErrorContains(err, "array mixed: element 1 does not match the type of the preceding elements")
+
+	err = WriteMap(map[string]any{"nested": []any{[]any{"a"}}}, &bytes.Buffer{}, Version2)
+	s.Assert().ErrorContains(err, "array nested: nested arrays are not supported")
+
+	err = WriteMap(map[string]any{"missing": nil}, &bytes.Buffer{}, Version2)
+	s.Assert().ErrorContains(err, "map value missing has unsupported type <nil>")
+
+	err = WriteMap(map[string]any{"a,b": "c"}, &bytes.Buffer{}, Version2)
+	s.Assert().ErrorContains(err, `map key "a,b" cannot be used as a field name`)
+}
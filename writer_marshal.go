@@ -0,0 +1,75 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+)
+
+// RSFMarshaler is implemented by types that write their own encoding. Fields
+// of these types are recorded in the index as `FieldTypeOpaque`, and the
+// encoding is written with a 4-byte size field so that readers can skip it.
+type RSFMarshaler interface {
+	// MarshalRSF writes the value to `w`, typically using the field methods
+	// of `rw`, and returns the number of bytes written.
+	MarshalRSF(rw Writer, w io.Writer) (int, error)
+}
+
+// RSFUnmarshaler is implemented by types that read the encoding written by
+// their `RSFMarshaler` implementation. `Unmarshal` passes the encoded bytes of
+// opaque fields to the destination field's `UnmarshalRSF`. Pointer fields are
+// allocated before the value is read, and are left nil for nil pointers.
+type RSFUnmarshaler interface {
+	// UnmarshalRSF reads the value from `r`, typically using the field
+	// methods of `rr`. The reader `r` is limited to the encoded value.
+	UnmarshalRSF(rr Reader, r io.Reader) error
+}
+
+var (
+	marshalerType   = reflect.TypeOf((*RSFMarshaler)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*RSFUnmarshaler)(nil)).Elem()
+)
+
+// isMarshalerType returns true if `t` or a pointer to `t` implements
+// `RSFMarshaler`.
+func isMarshalerType(t reflect.Type) bool {
+	return t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType)
+}
+
+// writeMarshaler writes the value `v`, which must be a marshaler type, with a
+// size field followed by the value's encoding. A nil pointer is written as an
+// empty encoding.
+func (f *rsfWriter) writeMarshaler(v reflect.Value, buf *bytes.Buffer) (int, error) {
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		return f.WriteSizeField(0, 0, buf)
+	}
+
+	// Use a pointer for marshalers with pointer receivers.
+	if !v.Type().Implements(marshalerType) {
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		v = p
+	}
+
+	encoded := &bytes.Buffer{}
+	_, err := v.Interface().(RSFMarshaler).MarshalRSF(f, encoded)
+	if err != nil {
+		return 0, err
+	}
+
+	sz, err := f.WriteSizeField(0, encoded.Len(), buf)
+	if err != nil {
+		return 0, err
+	}
+	n, err := buf.Write(encoded.Bytes())
+	return sz + n, err
+}
+
+// opaqueBytes writes an opaque value that was read as bytes without
+// modification. This is used to rewrite opaque fields. See `Rewrite`.
+type opaqueBytes []byte
+
+func (o opaqueBytes) MarshalRSF(_ Writer, w io.Writer) (int, error) {
+	return w.Write(o)
+}
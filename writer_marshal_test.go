@@ -0,0 +1,191 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WriterMarshalSuite struct {
+	suite.Suite
+}
+
+func TestWriterMarshalSuite(t *testing.T) {
+	suite.Run(t, &WriterMarshalSuite{})
+}
+
+// semver is written in a compact 3-byte form.
+type semver struct {
+	Major, Minor, Patch uint8
+}
+
+func (v semver) MarshalRSF(_ Writer, w io.Writer) (int, error) {
+	return w.Write([]byte{v.Major, v.Minor, v.Patch})
+}
+
+func (v *semver) UnmarshalRSF(_ Reader, r io.Reader) error {
+	bs, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if len(bs) != 3 {
+		return io.ErrUnexpectedEOF
+	}
+	v.Major, v.Minor, v.Patch = bs[0], bs[1], bs[2]
+	return nil
+}
+
+type semverRecord struct {
+	Name    string `rsf:"name"`
+	Version semver `rsf:"version"`
+	Latest  bool   `rsf:"latest"`
+}
+
+var testSemverData = semverRecord{
+	Name:    "rsf",
+	Version: semver{Major: 1, Minor: 2, Patch: 3},
+	Latest:  true,
+}
+
+func (s *WriterMarshalSuite) getSemverData() []byte {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	_, err := w.WriteObject(testSemverData)
+	s.Require().Nil(err)
+	return buf.Bytes()
+}
+
+func (s *WriterMarshalSuite) TestMarshaler() {
+	data := s.getSemverData()
+
+	r := NewReader()
+	buf := bufio.NewReader(bytes.NewReader(data))
+	index, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(IndexEntry{FieldName: "version", FieldType: FieldTypeOpaque}, index[1])
+
+	s.Assert().Equal([]byte{
+		// Object size
+		0x13, 0x0, 0x0, 0x0,
+		// "rsf"
+		0x3, 0x0, 0x0, 0x0,
+		0x72, 0x73, 0x66,
+		// version size and custom form
+		0x3, 0x0, 0x0, 0x0,
+		0x1, 0x2, 0x3,
+		// latest
+		0x1,
+	}, data[r.Pos():])
+
+	var rec semverRecord
+	s.Require().Nil(r.Unmarshal(buf, &rec))
+	s.Assert().Equal(testSemverData, rec)
+}
+
+func (s *WriterMarshalSuite) TestMarshalerSkip() {
+	data := s.getSemverData()
+
+	r := NewReader()
+	buf := bufio.NewReader(bytes.NewReader(data))
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	_, err = r.BeginObject(buf)
+	s.Require().Nil(err)
+	err = r.AdvanceTo(buf, "latest")
+	s.Require().Nil(err)
+	latest, err := r.ReadBoolField(buf)
+	s.Require().Nil(err)
+	s.Assert().True(latest)
+
+	// Decoding returns the encoded bytes.
+	r = NewReader()
+	buf = bufio.NewReader(bytes.NewReader(data))
+	_, err = r.ReadIndex(buf)
+	s.Require().Nil(err)
+	m, err := r.DecodeObject(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal([]byte{0x1, 0x2, 0x3}, m["version"])
+
+	out := &bytes.Buffer{}
+	err = Print(out, bufio.NewReader(bytes.NewReader(data)))
+	s.Require().Nil(err)
+	s.Assert().Contains(out.String(), "version (opaque(3)): 010203\n")
+}
+
+func (s *WriterMarshalSuite) TestMarshalerRewrite() {
+	src := bufio.NewReader(bytes.NewReader(s.getSemverData()))
+	dst := &bytes.Buffer{}
+	err := Rewrite(src, dst, func(m map[string]any) map[string]any {
+		return m
+	})
+	s.Require().Nil(err)
+
+	r := NewReader()
+	buf := bufio.NewReader(dst)
+	_, err = r.ReadIndex(buf)
+	s.Require().Nil(err)
+	var rec semverRecord
+	s.Require().Nil(r.Unmarshal(buf, &rec))
+	s.Assert().Equal(testSemverData, rec)
+}
+
+func (s *WriterMarshalSuite) TestUnmarshalerPointer() {
+	type record struct {
+		Name    string  `rsf:"name"`
+		Version *semver `rsf:"version"`
+	}
+
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	_, err := w.WriteObject(record{Name: "rsf", Version: &semver{Major: 4, Minor: 5, Patch: 6}})
+	s.Require().Nil(err)
+	_, err = w.WriteObject(record{Name: "none"})
+	s.Require().Nil(err)
+
+	r := NewReader()
+	rbuf := bufio.NewReader(buf)
+	_, err = r.ReadIndex(rbuf)
+	s.Require().Nil(err)
+
+	// Pointer fields are allocated, and nil pointers are read as nil.
+	rec := record{Version: &semver{Major: 9}}
+	s.Require().Nil(r.Unmarshal(rbuf, &rec))
+	s.Assert().Equal(record{Name: "rsf", Version: &semver{Major: 4, Minor: 5, Patch: 6}}, rec)
+	s.Require().Nil(r.Unmarshal(rbuf, &rec))
+	s.Assert().Equal(record{Name: "none"}, rec)
+}
+
+func (s *WriterMarshalSuite) TestUnmarshalerArrayElements() {
+	type release struct {
+		Version semver `rsf:"version"`
+		Yanked  bool   `rsf:"yanked"`
+	}
+	type record struct {
+		Name     string    `rsf:"name"`
+		Releases []release `rsf:"releases"`
+	}
+	expected := record{
+		Name: "rsf",
+		Releases: []release{
+			{Version: semver{Major: 1}},
+			{Version: semver{Major: 1, Minor: 1}, Yanked: true},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	_, err := w.WriteObject(expected)
+	s.Require().Nil(err)
+
+	r := NewReader()
+	rbuf := bufio.NewReader(buf)
+	_, err = r.ReadIndex(rbuf)
+	s.Require().Nil(err)
+	var rec record
+	s.Require().Nil(r.Unmarshal(rbuf, &rec))
+	s.Assert().Equal(expected, rec)
+}
@@ -0,0 +1,110 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WriterFieldNameTableSuite struct {
+	suite.Suite
+}
+
+func TestWriterFieldNameTableSuite(t *testing.T) {
+	suite.Run(t, &WriterFieldNameTableSuite{})
+}
+
+type namedOption struct {
+	Description string `rsf:"description"`
+	Maintainer  string `rsf:"maintainer"`
+	Published   bool   `rsf:"published"`
+}
+
+type namedRecord struct {
+	Name     string        `rsf:"name"`
+	Imports  []namedOption `rsf:"imports"`
+	Depends  []namedOption `rsf:"depends"`
+	Suggests []namedOption `rsf:"suggests"`
+	Enhances []namedOption `rsf:"enhances"`
+}
+
+var testNamedData = namedRecord{
+	Name: "rsf",
+	Imports: []namedOption{
+		{Description: "a", Maintainer: "b", Published: true},
+	},
+	Depends: []namedOption{
+		{Description: "c", Maintainer: "d"},
+		{Description: "e", Maintainer: "f", Published: true},
+	},
+}
+
+func (s *WriterFieldNameTableSuite) TestFieldNameTable() {
+	plain := &bytes.Buffer{}
+	w := NewWriterWithVersion(plain, Version3)
+	_, err := w.WriteObject(testNamedData)
+	s.Require().Nil(err)
+	s.Require().Nil(w.Close())
+
+	named := &bytes.Buffer{}
+	w = NewWriterWithVersion(named, Version3, FieldNameTable(true))
+	_, err = w.WriteObject(testNamedData)
+	s.Require().Nil(err)
+	s.Require().Nil(w.Close())
+
+	// Each repeated subfield name is written only once.
+	s.Assert().Less(named.Len(), plain.Len())
+	s.Assert().Equal(1, bytes.Count(named.Bytes(), []byte("description")))
+	s.Assert().Equal(4, bytes.Count(plain.Bytes(), []byte("description")))
+
+	plainReader := NewReader()
+	plainIndex, err := plainReader.ReadIndex(bufio.NewReader(plain))
+	s.Require().Nil(err)
+	r := NewReader()
+	rBuf := bufio.NewReader(named)
+	index, err := r.ReadIndex(rBuf)
+	s.Require().Nil(err)
+	s.Assert().True(plainIndex.Equal(index))
+
+	// Ids are resolved to names.
+	entries, pos, err := entrySet(index, "suggests", "maintainer")
+	s.Require().Nil(err)
+	s.Assert().Equal(1, pos)
+	s.Assert().Equal("maintainer", entries[pos].FieldName)
+	s.Assert().Equal(3, entries[pos].FieldID)
+	entries, pos, err = entrySet(index, "enhances")
+	s.Require().Nil(err)
+	s.Assert().Equal("enhances", entries[pos].FieldName)
+	s.Assert().Equal(7, entries[pos].FieldID)
+
+	var rec namedRecord
+	s.Require().Nil(r.Unmarshal(rBuf, &rec))
+	s.Assert().Equal(testNamedData, rec)
+}
+
+func (s *WriterFieldNameTableSuite) TestFieldNameTableInvalidID() {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version3, FieldNameTable(true))
+	_, err := w.WriteObject(testNamedData)
+	s.Require().Nil(err)
+	s.Require().Nil(w.Close())
+
+	// The name of the first entry follows the last name in the table.
+	// Point it past the end of the table.
+	data := buf.Bytes()
+	at := bytes.Index(data, []byte("enhances")) + len("enhances")
+	data[at] = 0x40
+
+	r := NewReader()
+	_, err = r.ReadIndex(bufio.NewReader(bytes.NewReader(data)))
+	s.Assert().ErrorContains(err, "field name id 64 out of range; table has 8 names")
+}
+
+func (s *WriterFieldNameTableSuite) TestFieldNameTableVersion() {
+	w := NewWriterWithVersion(&bytes.Buffer{}, Version2, FieldNameTable(true))
+	_, err := w.WriteObject(testNamedData)
+	s.Assert().ErrorIs(err, ErrFieldNameTableVersion)
+}
@@ -7,96 +7,629 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"net"
+	"net/netip"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var ErrInvalidIndexFieldType = errors.New("invalid index field type")
+var ErrStringTableVersion = errors.New("the string table requires Version3 or greater")
+var ErrKeyTableVersion = errors.New("the object key table requires Version3 or greater")
+var ErrVerboseIndexVersion = errors.New("the verbose index requires Version3 or greater")
+var ErrFieldWidthsVersion = errors.New("field widths require Version3 or greater")
+var ErrFieldNameTableVersion = errors.New("the field name table requires Version3 or greater")
+var ErrPackBoolsVersion = errors.New("packed bools require Version3 or greater")
+var ErrFingerprintVersion = errors.New("the schema fingerprint requires Version3 or greater")
+var ErrProducerVersionVersion = errors.New("the producer version requires Version3 or greater")
+var ErrCompressionVersion = errors.New("the compression codec requires Version3 or greater")
+var ErrArrayTooLong = errors.New("array length exceeds the maximum of 2^32-1 elements")
+
+var errNulString = errors.New("string contains a NUL byte")
+
+// fieldError is an error writing the field with the path `path`. Errors
+// writing objects are reported with the object number and, when known, the
+// field path, like "object 12 field snapshots.snapshot: ...".
+type fieldError struct {
+	path string
+	err  error
+}
+
+func (e *fieldError) Error() string {
+	return fmt.Sprintf("field %s: %s", e.path, e.err)
+}
+
+func (e *fieldError) Unwrap() error {
+	return e.err
+}
+
+// objectError adds the number of the object being written to `err`.
+func (f *rsfWriter) objectError(err error) error {
+	var fe *fieldError
+	if errors.As(err, &fe) {
+		return fmt.Errorf("object %d %w", f.pos, err)
+	}
+	return fmt.Errorf("object %d: %w", f.pos, err)
+}
+
+// withField wraps `err` with the field path `path`, unless the path is
+// unknown or the error already names a field.
+func withField(path string, err error) error {
+	var fe *fieldError
+	if path == "" || errors.As(err, &fe) {
+		return err
+	}
+	return &fieldError{path: path, err: err}
+}
+
+// WriteOptions are options for writing a single object with
+// `WriteObjectWith`.
+type WriteOptions struct {
+	// FixedOverrides maps field paths to fixed-length string sizes. The
+	// sizes override the `fixed` struct tag option, or supply it for fields
+	// that lack it. This supports fixed sizes that are only known at runtime,
+	// like the length of a hash.
+	//
+	// Paths are the `rsf` names of the field and any enclosing arrays, joined
+	// with ".". For example, "snapshots.snapshot" names the `snapshot` field of
+	// the elements of the `snapshots` array. Paths that don't name a field are
+	// ignored.
+	FixedOverrides map[string]int
+
+	// IndexFuncs maps the paths of struct arrays to functions that compute
+	// the array index key of each element, which is passed to the function.
+	// The key is written to the array index like the value of an `index`
+	// field, but it isn't a field of the element, so the array index can be
+	// derived from the element (e.g., a hash of a field) without storing
+	// it. Arrays with an index function cannot also use the `index` tag
+	// option.
+	//
+	// Keys are ints unless `FixedOverrides` gives a size for the array's
+	// path, in which case they are fixed-length strings of that size.
+	IndexFuncs map[string]func(elem any) any
+}
+
+// rootTag returns the tag used to write the root of an object with `o`.
+func (o WriteOptions) rootTag() *tag {
+	return &tag{overrides: o.FixedOverrides, indexFuncs: o.IndexFuncs}
+}
 
 func (f *rsfWriter) WriteObject(v any) (int, error) {
-	var indexBuf = &bytes.Buffer{}
-	var indexSz int
+	return f.WriteObjectWith(v, WriteOptions{})
+}
+
+// WriteObjectWith writes an object like `WriteObject`, using the provided
+// options. Since the index is written with the first object, the same options
+// should be used for every object. Errors report the number of the object
+// (counting from zero) and, for field errors, the path of the field.
+func (f *rsfWriter) WriteObjectWith(v any, opts WriteOptions) (int, error) {
+	if f.columnar {
+		return 0, ErrColumnarObjects
+	}
+	if f.nilAsAbsent && f.canonicalIndex != nil {
+		return 0, ErrNilAsAbsentOption
+	}
+	if f.canonicalIndex != nil {
+		var err error
+		v, err = f.canonical(v)
+		if err != nil {
+			return 0, f.objectError(err)
+		}
+	}
+
+	switch kind := reflect.ValueOf(v).Kind(); kind {
+	case reflect.Struct, reflect.Array, reflect.Slice, reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8,
+		reflect.Float32, reflect.Float64:
+	default:
+		return 0, fmt.Errorf("unsupported root type: %s", kind)
+	}
+	if f.objectKey != "" && f.keyWriter == nil && f.version < Version3 {
+		return 0, ErrKeyTableVersion
+	}
+	if f.verboseIndex && f.version < Version3 {
+		return 0, ErrVerboseIndexVersion
+	}
+	if f.fieldWidths && f.version < Version3 {
+		return 0, ErrFieldWidthsVersion
+	}
+	if f.fieldNameTable && f.version < Version3 {
+		return 0, ErrFieldNameTableVersion
+	}
+	if f.packBools && f.version < Version3 {
+		return 0, ErrPackBoolsVersion
+	}
+	if f.nilAsAbsent && f.version < Version3 {
+		return 0, ErrNilAsAbsentVersion
+	}
+	if f.fingerprint && f.version < Version3 {
+		return 0, ErrFingerprintVersion
+	}
+	if f.producerVersion != "" && f.version < Version3 {
+		return 0, ErrProducerVersionVersion
+	}
+	if f.codec != CodecGzip && f.version < Version3 {
+		return 0, ErrCompressionVersion
+	}
+	if f.footerIndex {
+		if f.stringTable || (f.objectKey != "" && f.keyWriter == nil) || f.trailer != nil {
+			return 0, ErrFooterIndexOption
+		}
+		// The index is written after the objects, when `Close` is
+		// called.
+		if f.footer == nil {
+			f.footer = &bytes.Buffer{}
+		}
+	}
+	if f.stringTable {
+		if f.version < Version3 {
+			return 0, ErrStringTableVersion
+		}
+		// The string table is written after the index, but it can't be
+		// written until all objects are written. Buffer the index and the
+		// objects until `Close` is called.
+		if f.pending == nil {
+			f.header = &bytes.Buffer{}
+			f.pending = &bytes.Buffer{}
+		}
+	}
+
+	schemaID := -1
+	if f.schemas != nil {
+		var err error
+		schemaID, err = f.schemaID(v)
+		if err != nil {
+			return 0, f.objectError(err)
+		}
+	}
+
 	var totalSz int
 	var err error
 	var sz int
-	if f.pos == 0 && reflect.TypeOf(v).Kind() == reflect.Struct {
-		if f.version > 1 {
-			// Write the index version first
-			sz, err = f.writer.Write(IndexVersion2)
-			if err != nil {
-				return 0, err
-			}
-			totalSz += sz
+	if f.pos == 0 && f.schemas != nil {
+		totalSz, err = f.writeSchemas(opts.rootTag(), f.indexWriter())
+		if err != nil {
+			return 0, err
 		}
+	} else if f.pos == 0 && reflect.TypeOf(v).Kind() == reflect.Struct {
+		totalSz, err = f.writeIndex(reflect.TypeOf(v), opts.rootTag(), f.indexWriter())
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// The object begins after the index, if one was written. A footer index
+	// is written by `Close`, so it isn't included.
+	if f.footerIndex {
+		totalSz = 0
+	}
+	offset := f.written + int64(totalSz)
 
-		indexSz, err = f.writeIndexObject(reflect.TypeOf(v), &tag{}, indexBuf)
+	// Objects are streamed to seekable, truncatable writers rather than
+	// buffered.
+	f.overflow.reset()
+	if ws, ok := f.streamWriter(); ok {
+		sz, err = f.streamObject(ws, reflect.ValueOf(v), opts.rootTag(), schemaID)
+	} else {
+		sz, err = f.bufferObject(reflect.ValueOf(v), opts.rootTag(), schemaID)
+	}
+	if err != nil {
+		return 0, err
+	}
+	totalSz += sz
+
+	// Record the object key and offset, if needed.
+	if f.objectKey != "" {
+		err = f.recordKey(reflect.ValueOf(v), offset)
 		if err != nil {
 			return 0, err
 		}
-		totalSz += indexSz
+	}
+
+	// Increment once per object
+	f.pos++
+	f.written += int64(totalSz)
 
-		// Write index size
-		bs := make([]byte, sizeFieldLen)
-		indexRecordSize := indexBuf.Len() + sizeFieldLen
-		binary.LittleEndian.PutUint32(bs, uint32(indexRecordSize))
-		sz, err = f.writer.Write(bs)
+	return totalSz, nil
+}
+
+// bufferObject writes the object `v`, including its size, to the object
+// writer. The object is buffered so that its size can be written first. The
+// object begins with the schema id, if it is at least zero.
+func (f *rsfWriter) bufferObject(v reflect.Value, t *tag, schemaID int) (int, error) {
+	var buf = &bytes.Buffer{}
+	var err error
+
+	// The schema id, if any, begins the object.
+	if schemaID >= 0 {
+		_, err = f.WriteSizeField(0, schemaID, buf)
 		if err != nil {
 			return 0, err
 		}
-		totalSz += sz
+	}
 
-		// Write index
-		_, err = io.Copy(f.writer, indexBuf)
+	// Reserve space for the bitmap of packed bools, which is filled as the
+	// fields are written.
+	bitmapPos := buf.Len()
+	if f.boolCount > 0 {
+		f.bitmap = make([]byte, f.bitmapSize())
+		f.nextBit = 0
+		_, err = buf.Write(f.bitmap)
 		if err != nil {
 			return 0, err
 		}
 	}
 
-	var buf = &bytes.Buffer{}
-	var objectSz int
-	objectSz, err = f.writeObject(reflect.ValueOf(v), &tag{}, buf)
+	_, err = f.writeObject(v, t, buf)
+	if err != nil {
+		return 0, f.objectError(err)
+	}
+	copy(buf.Bytes()[bitmapPos:], f.bitmap)
+
+	// Append the overflow region, if any.
+	_, err = f.overflow.writeTo(buf)
 	if err != nil {
 		return 0, err
 	}
-	totalSz += objectSz
+
+	// Pad the object to a multiple of the block size, if needed.
+	if f.padTo > 0 {
+		pad := (f.padTo - (buf.Len()+sizeFieldLen)%f.padTo) % f.padTo
+		_, err = buf.Write(make([]byte, pad))
+		if err != nil {
+			return 0, err
+		}
+	}
 
 	// Write size of full record
-	bs := make([]byte, sizeFieldLen)
-	recordSize := buf.Len() + sizeFieldLen
-	binary.LittleEndian.PutUint32(bs, uint32(recordSize))
-	sz, err = f.writer.Write(bs)
+	out := f.objectWriter()
+	totalSz, err := f.WriteSizeField(0, buf.Len()+sizeFieldLen, out)
 	if err != nil {
 		return 0, err
 	}
-	totalSz += sz
 
 	// Write initial buffer. This includes the name and the number
 	// of snapshots.
-	_, err = io.Copy(f.writer, buf)
+	n, err := io.Copy(out, buf)
 	if err != nil {
 		return 0, err
 	}
+	return totalSz + int(n), nil
+}
 
-	// Increment once per object
-	f.pos++
+// truncateWriteSeeker is a seekable destination that can be truncated, like
+// an `*os.File`.
+type truncateWriteSeeker interface {
+	io.WriteSeeker
+	Truncate(size int64) error
+}
+
+// streamWriter returns the destination of objects if it is seekable and can
+// be truncated, so that objects can be streamed to it (see `streamObject`).
+// Objects with overflow strings, and objects written before a string table,
+// are always buffered.
+func (f *rsfWriter) streamWriter() (truncateWriteSeeker, bool) {
+	if f.pending != nil || f.overflowFields {
+		return nil, false
+	}
+	ws, ok := f.writer.(truncateWriteSeeker)
+	return ws, ok
+}
+
+// streamObject writes the object `v` to `ws` like `bufferObject`, but writes
+// each top-level field as soon as it is written rather than buffering the
+// whole object, which reduces the memory used to write large objects. A
+// placeholder is written for the object size, and for the bitmap of packed
+// bools, if any; once the object is written, the writer seeks back to write
+// them, and then seeks to the end of the object.
+//
+// If an error occurs, the destination is truncated to the start of the object
+// and positioned there, so that nothing of the object remains, as when an
+// object fails to buffer.
+func (f *rsfWriter) streamObject(ws truncateWriteSeeker, v reflect.Value, t *tag, schemaID int) (int, error) {
+	start, err := ws.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	sz, err := f.streamFields(ws, v, t, schemaID)
+	if err != nil {
+		truncErr := ws.Truncate(start)
+		if truncErr != nil {
+			return 0, truncErr
+		}
+		_, seekErr := ws.Seek(start, io.SeekStart)
+		if seekErr != nil {
+			return 0, seekErr
+		}
+		return 0, err
+	}
+	return sz, nil
+}
+
+// streamFields writes the fields of an object streamed with `streamObject`,
+// beginning at `ws`'s current position.
+func (f *rsfWriter) streamFields(ws io.WriteSeeker, v reflect.Value, t *tag, schemaID int) (int, error) {
+	start, err := ws.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+
+	// Write a placeholder for the object size.
+	totalSz, err := f.WriteSizeField(0, 0, ws)
+	if err != nil {
+		return 0, err
+	}
+
+	// Fields are written to `buf`, which is flushed after each top-level
+	// field.
+	buf := &bytes.Buffer{}
+	flush := func() error {
+		n, err := buf.WriteTo(ws)
+		totalSz += int(n)
+		return err
+	}
+
+	// The schema id, if any, begins the object.
+	if schemaID >= 0 {
+		_, err = f.WriteSizeField(0, schemaID, buf)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// Write a placeholder for the bitmap of packed bools, which is filled
+	// as the fields are written.
+	bitmapPos := int64(totalSz + buf.Len())
+	if f.boolCount > 0 {
+		f.bitmap = make([]byte, f.bitmapSize())
+		f.nextBit = 0
+		_, err = buf.Write(f.bitmap)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if v.Kind() == reflect.Struct && !isMarshalerType(v.Type()) && !isIPType(v.Type()) && !isTimeType(v.Type()) {
+		_, err = f.writeFields(v, t, buf, flush)
+	} else {
+		_, err = f.writeObject(v, t, buf)
+	}
+	if err != nil {
+		return 0, f.objectError(err)
+	}
+
+	// Pad the object to a multiple of the block size, if needed.
+	if f.padTo > 0 {
+		pad := (f.padTo - (totalSz+buf.Len())%f.padTo) % f.padTo
+		_, err = buf.Write(make([]byte, pad))
+		if err != nil {
+			return 0, err
+		}
+	}
+	err = flush()
+	if err != nil {
+		return 0, err
+	}
+
+	// Write the object size and the bitmap, and seek to the end of the
+	// object.
+	_, err = ws.Seek(start, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+	_, err = f.WriteSizeField(0, totalSz, ws)
+	if err != nil {
+		return 0, err
+	}
+	if f.boolCount > 0 {
+		_, err = ws.Seek(start+bitmapPos, io.SeekStart)
+		if err != nil {
+			return 0, err
+		}
+		_, err = ws.Write(f.bitmap)
+		if err != nil {
+			return 0, err
+		}
+	}
+	_, err = ws.Seek(start+int64(totalSz), io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+	return totalSz, nil
+}
+
+// writeIndex writes the index version header (if any) and the index for the
+// struct type `v` to `out`.
+func (f *rsfWriter) writeIndex(v reflect.Type, t *tag, out io.Writer) (int, error) {
+	var sz int
+	indexBuf, totalSz, err := f.indexEntries(v, t)
+	if err != nil {
+		return 0, err
+	}
+
+	if f.version > 2 {
+		// Write the index version and feature flags before the index
+		sz, err = out.Write(IndexVersion3)
+		if err != nil {
+			return 0, err
+		}
+		totalSz += sz
+
+		sz, err = f.WriteSizeField(0, f.features(), out)
+		if err != nil {
+			return 0, err
+		}
+		totalSz += sz
+
+		// The schema fingerprint, if enabled, follows the feature flags.
+		if f.fingerprint {
+			sz, err = f.writeFingerprint(indexBuf, out)
+			if err != nil {
+				return 0, err
+			}
+			totalSz += sz
+		}
+
+		// The producer version, if any, follows the fingerprint.
+		if f.producerVersion != "" {
+			sz, err = f.WriteStringField(0, f.producerVersion, out)
+			if err != nil {
+				return 0, err
+			}
+			totalSz += sz
+		}
+	} else if f.version > 1 {
+		// Write the index version before the index
+		sz, err = out.Write(IndexVersion2)
+		if err != nil {
+			return 0, err
+		}
+		totalSz += sz
+	}
+
+	// Write index size
+	bs := make([]byte, sizeFieldLen)
+	indexRecordSize := indexBuf.Len() + sizeFieldLen
+	binary.LittleEndian.PutUint32(bs, uint32(indexRecordSize))
+	sz, err = out.Write(bs)
+	if err != nil {
+		return 0, err
+	}
+	totalSz += sz
 
+	// Write index
+	_, err = io.Copy(out, indexBuf)
+	if err != nil {
+		return 0, err
+	}
 	return totalSz, nil
 }
 
+// indexEntries returns the index entries for the struct type `v`, preceded by
+// the field name table, if enabled, and the number of bytes written.
+func (f *rsfWriter) indexEntries(v reflect.Type, t *tag) (*bytes.Buffer, int, error) {
+	var totalSz int
+	var sz int
+
+	var indexBuf = &bytes.Buffer{}
+	f.boolCount = 0
+	indexSz, err := f.writeIndexObject(v, t, indexBuf)
+	if err != nil {
+		return nil, 0, err
+	}
+	totalSz += indexSz
+
+	// Bools tagged falseomit are packed even without `PackBools`.
+	if f.boolCount > 0 && f.version < Version3 {
+		return nil, 0, ErrPackBoolsVersion
+	}
+
+	// The bitmap of packed bools, if any, is the first index entry.
+	if f.boolCount > 0 {
+		indexBuf, sz, err = f.writeIndexBitmap(indexBuf)
+		if err != nil {
+			return nil, 0, err
+		}
+		totalSz += sz
+	}
+
+	// The field name table, if enabled, precedes the index entries.
+	if f.fieldNameTable {
+		tableBuf := &bytes.Buffer{}
+		sz, err = f.writeFieldNameTable(tableBuf)
+		if err != nil {
+			return nil, 0, err
+		}
+		totalSz += sz
+		_, err = io.Copy(tableBuf, indexBuf)
+		if err != nil {
+			return nil, 0, err
+		}
+		indexBuf = tableBuf
+	}
+	return indexBuf, totalSz, nil
+}
+
+// indexWriter returns the destination for the index.
+func (f *rsfWriter) indexWriter() io.Writer {
+	if f.footer != nil {
+		return f.footer
+	}
+	if f.header != nil {
+		return f.header
+	}
+	return f.writer
+}
+
+// objectWriter returns the destination for objects.
+func (f *rsfWriter) objectWriter() io.Writer {
+	if f.pending != nil {
+		return f.pending
+	}
+	return f.writer
+}
+
 func (f *rsfWriter) writeObject(v reflect.Value, t *tag, buf *bytes.Buffer) (int, error) {
+	if !isMarshalerType(v.Type()) && !isIPType(v.Type()) && !isTimeType(v.Type()) && !isBytesType(v.Type()) {
+		switch v.Type().Kind() {
+		case reflect.Array, reflect.Slice:
+			return f.writeArray(v, t, buf)
+		case reflect.Map:
+			return f.writeArray(mapEntries(v), t, buf)
+		case reflect.Struct:
+			return f.writeStruct(v, t, buf)
+		}
+	}
+
+	sz, err := f.writeScalar(v, t, buf)
+	if err != nil {
+		return 0, withField(t.path, err)
+	}
+	return sz, nil
+}
+
+// writeScalar writes a value that is not an array or struct.
+func (f *rsfWriter) writeScalar(v reflect.Value, t *tag, buf *bytes.Buffer) (int, error) {
+	if isMarshalerType(v.Type()) {
+		return f.writeMarshaler(v, buf)
+	}
+	if isIPType(v.Type()) {
+		return f.WriteIPField(0, ipAddr(v), buf)
+	}
+	if isTimeType(v.Type()) {
+		if !t.days {
+			return 0, errTimeDays
+		}
+		return f.WriteDaysField(0, v.Interface().(time.Time), buf)
+	}
+	if isBytesType(v.Type()) {
+		return f.WriteBytesField(0, v.Bytes(), buf)
+	}
+
 	switch v.Type().Kind() {
-	case reflect.Array, reflect.Slice:
-		return f.writeArray(v, t, buf)
-	case reflect.Struct:
-		return f.writeStruct(v, t, buf)
 	case reflect.String:
 		return f.writeString(v.String(), t, buf)
 	case reflect.Bool:
+		if f.packedBool(t) {
+			f.setBit(v.Bool())
+			return 0, nil
+		}
 		return f.WriteBoolField(0, v.Bool(), buf)
 	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+		if t.bigEndian > 0 {
+			return f.writeBigEndian(v, t.bigEndian, buf)
+		}
+		if t.fixedInt {
+			return f.WriteFixedInt64Field(0, v.Int(), buf)
+		}
 		return f.WriteInt64Field(0, v.Int(), buf)
 	case reflect.Float32, reflect.Float64:
+		if t.bigEndian > 0 {
+			return f.writeBigEndian(v, t.bigEndian, buf)
+		}
 		return f.WriteFloatField(0, v.Float(), buf)
 	default:
 		return 0, fmt.Errorf("unknown field type %#v: %#v", v.Type().Kind(), v)
@@ -104,18 +637,30 @@ func (f *rsfWriter) writeObject(v reflect.Value, t *tag, buf *bytes.Buffer) (int
 }
 
 func (f *rsfWriter) writeStruct(v reflect.Value, tParent *tag, buf *bytes.Buffer) (int, error) {
+	return f.writeFields(v, tParent, buf, nil)
+}
+
+// writeFields writes the fields of the struct `v` to `buf`. If `flush` is not
+// nil, it is called after each field is written (see `streamObject`).
+func (f *rsfWriter) writeFields(v reflect.Value, tParent *tag, buf *bytes.Buffer, flush func() error) (int, error) {
 	var totalSz int
 	for i := 0; i < v.NumField(); i++ {
 		t := &tag{}
 
 		// `fieldVal` is used for indexing arrays. We currently only support
-		// fixed strings and integers.
+		// fixed strings, integers, and fixed-size byte arrays.
 		var fieldVal any
 		switch v.Field(i).Type().Kind() {
 		case reflect.String:
 			fieldVal = v.Field(i).String()
 		case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
 			fieldVal = v.Field(i).Int()
+		case reflect.Array:
+			if isByteArray(v.Field(i).Type()) {
+				bs := make([]byte, v.Field(i).Len())
+				reflect.Copy(reflect.ValueOf(bs), v.Field(i))
+				fieldVal = bs
+			}
 		}
 
 		skip, err := getTagInfo(v.Type(), i, t, tParent, fieldVal)
@@ -123,7 +668,7 @@ func (f *rsfWriter) writeStruct(v reflect.Value, tParent *tag, buf *bytes.Buffer
 			return 0, err
 		}
 
-		if !skip {
+		if !skip && !f.absentArray(v.Field(i), t) {
 			var sz int
 			sz, err = f.writeObject(v.Field(i), t, buf)
 			if err != nil {
@@ -131,10 +676,40 @@ func (f *rsfWriter) writeStruct(v reflect.Value, tParent *tag, buf *bytes.Buffer
 			}
 			totalSz += sz
 		}
+		if flush != nil {
+			err = flush()
+			if err != nil {
+				return 0, err
+			}
+		}
 	}
 	return totalSz, nil
 }
 
+// hasNoFields returns true if the struct type `v` has no fields to write,
+// like a struct whose fields are all tagged `rsf:"-"`.
+func hasNoFields(v reflect.Type, tParent *tag) bool {
+	// Use a copy of the tag, since `getTagInfo` records array index
+	// information in the parent tag.
+	tCopy := *tParent
+	for i := 0; i < v.NumField(); i++ {
+		skip, err := getTagInfo(v, i, &tag{}, &tCopy, nil)
+		if err != nil || !skip {
+			return false
+		}
+	}
+	return true
+}
+
+// writeEmptyElement writes an element of a non-indexed array of structs with no
+// fields. Since the index records no subfields for such an array, the element
+// would otherwise occupy no bytes, so each element is written as a size field
+// giving the size of the element's fields, which is always zero. Readers
+// discard that many bytes, so elements may be extended in the future.
+func (f *rsfWriter) writeEmptyElement(buf *bytes.Buffer) (int, error) {
+	return f.WriteSizeField(0, 0, buf)
+}
+
 func getTagInfo(v reflect.Type, index int, t, tParent *tag, fieldVal any) (bool, error) {
 	// Get the field tag value
 	rawTag := v.Field(index).Tag.Get(tagName)
@@ -142,8 +717,15 @@ func getTagInfo(v reflect.Type, index int, t, tParent *tag, fieldVal any) (bool,
 		return true, nil
 	}
 
+	// Fields inherit the fixed size overrides and, for untagged nested
+	// structs, the path prefix.
+	t.overrides = tParent.overrides
+	t.indexFuncs = tParent.indexFuncs
+	t.prefix = tParent.prefix
+
 	var skip bool
 	if rawTag != "" {
+		var bigEndian bool
 		tagParts := strings.Split(rawTag, rsfDelim)
 		t.name = tagParts[0]
 		for j := 1; j < len(tagParts); j++ {
@@ -151,6 +733,27 @@ func getTagInfo(v reflect.Type, index int, t, tParent *tag, fieldVal any) (bool,
 			if part == rsfSkip {
 				skip = true
 			}
+			if part == rsfCompress {
+				t.compress = true
+			}
+			if part == rsfPacked {
+				t.packed = true
+			}
+			if part == rsfOverflow {
+				t.overflow = true
+			}
+			if part == rsfFixedInt {
+				t.fixedInt = true
+			}
+			if part == rsfBigEndian {
+				bigEndian = true
+			}
+			if part == rsfDays {
+				t.days = true
+			}
+			if part == rsfFalseOmit {
+				t.falseOmit = true
+			}
 			if strings.HasPrefix(part, rsfIndex+rsfSep) && len(part) > 6 {
 				indexParts := strings.Split(part, rsfSep)
 				t.index = indexParts[1]
@@ -164,6 +767,73 @@ func getTagInfo(v reflect.Type, index int, t, tParent *tag, fieldVal any) (bool,
 				}
 			}
 		}
+
+		// Record the field path and apply any fixed size override.
+		kind := v.Field(index).Type.Kind()
+		t.path = t.name
+		if tParent.prefix != "" {
+			t.path = tParent.prefix + rsfPathSep + t.name
+		}
+		if kind == reflect.Array || kind == reflect.Slice || kind == reflect.Map {
+			t.prefix = t.path
+		}
+		if fn, ok := t.indexFuncs[t.path]; ok {
+			err := setIndexFunc(v.Field(index).Type, t, fn)
+			if err != nil {
+				return false, err
+			}
+		} else if sz, ok := t.overrides[t.path]; ok {
+			if kind != reflect.String {
+				return false, fmt.Errorf("field %s: the fixed size override requires a string field", t.path)
+			} else if sz <= 0 {
+				return false, fmt.Errorf("field %s: invalid fixed size override %d", t.path, sz)
+			}
+			t.fixed = sz
+		}
+		if t.fixed > 0 && kind != reflect.String {
+			return false, fmt.Errorf("field %s: the fixed option requires a string field", t.name)
+		}
+		if t.overflow {
+			if kind != reflect.String {
+				return false, fmt.Errorf("field %s: the overflow option requires a string field", t.name)
+			} else if t.fixed > 0 || t.compress {
+				return false, fmt.Errorf("field %s: the overflow option cannot be used with fixed-length or compressed strings", t.name)
+			} else if tParent.prefix != "" {
+				return false, fmt.Errorf("field %s: the overflow option cannot be used in arrays", t.name)
+			}
+		}
+		if t.fixedInt {
+			switch kind {
+			case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+			default:
+				return false, fmt.Errorf("field %s: the fixedint option requires an integer field", t.name)
+			}
+		}
+		if bigEndian {
+			t.bigEndian = bigEndianWidth(kind)
+			if t.bigEndian == 0 {
+				return false, fmt.Errorf("field %s: the be option requires an integer or float field", t.name)
+			} else if t.fixedInt {
+				return false, fmt.Errorf("field %s: the be option cannot be used with the fixedint option", t.name)
+			}
+		}
+		if t.days && !isTimeType(v.Field(index).Type) {
+			return false, fmt.Errorf("field %s: the days option requires a time.Time field", t.name)
+		}
+		if t.falseOmit {
+			if kind != reflect.Bool {
+				return false, fmt.Errorf("field %s: the falseomit option requires a bool field", t.name)
+			} else if tParent.prefix != "" {
+				return false, fmt.Errorf("field %s: the falseomit option cannot be used in arrays", t.name)
+			}
+		}
+		if t.compress {
+			if v.Field(index).Type.Kind() != reflect.String {
+				return false, fmt.Errorf("field %s: the compress option requires a string field", t.name)
+			} else if t.fixed > 0 {
+				return false, fmt.Errorf("field %s: the compress option cannot be used with fixed-length strings", t.name)
+			}
+		}
 		if tParent.index == t.name {
 			tParent.indexVal = fieldVal
 			switch v.Field(index).Type.Kind() {
@@ -173,38 +843,132 @@ func getTagInfo(v reflect.Type, index int, t, tParent *tag, fieldVal any) (bool,
 			case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
 				tParent.indexSz = sizeInt64
 				tParent.indexType = int(reflect.Int64)
+			case reflect.Array:
+				if isByteArray(v.Field(index).Type) {
+					tParent.indexSz = v.Field(index).Type.Len()
+					tParent.indexType = int(reflect.Array)
+				}
 			}
 		}
 	}
 	return skip, nil
 }
 
+// setIndexFunc records `fn` as the function that computes the index keys of
+// the struct array of type `v` described by `t`. String keys are sized by the
+// fixed size override for the array, if any. See `WriteOptions`.
+func setIndexFunc(v reflect.Type, t *tag, fn func(elem any) any) error {
+	if (v.Kind() != reflect.Array && v.Kind() != reflect.Slice) || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("field %s: the index function requires an array of structs", t.path)
+	} else if t.index != "" {
+		return fmt.Errorf("field %s: the index function cannot be used with the index option", t.path)
+	}
+
+	// The key isn't a field, so the index names the key itself.
+	t.index = IndexKeyField
+	t.indexFunc = fn
+	t.indexType = int(reflect.Int64)
+	t.indexSz = sizeInt64
+	if sz, ok := t.overrides[t.path]; ok {
+		if sz <= 0 {
+			return fmt.Errorf("field %s: invalid fixed size override %d", t.path, sz)
+		}
+		t.indexType = int(reflect.String)
+		t.indexSz = sz
+	}
+	return nil
+}
+
+// indexFuncKey returns the index key computed by the index function of the
+// array described by `t` for the element `el`, as the type of the array's
+// index keys.
+func indexFuncKey(t *tag, el reflect.Value) (any, error) {
+	key := t.indexFunc(el.Interface())
+	switch k := key.(type) {
+	case string:
+		if t.indexType == int(reflect.String) {
+			return k, nil
+		}
+	case int, int64, int32, int16, int8:
+		if t.indexType == int(reflect.Int64) {
+			return reflect.ValueOf(k).Int(), nil
+		}
+	}
+	return nil, withField(t.path, fmt.Errorf("invalid index key type %T", key))
+}
+
+// checkWriteArrayLen returns an error if an array of `n` elements cannot be
+// written. Array lengths are written as 32-bit size fields, so longer arrays
+// would silently wrap.
+func checkWriteArrayLen(n int) error {
+	if uint64(n) > math.MaxUint32 {
+		return ErrArrayTooLong
+	}
+	return nil
+}
+
 func (f *rsfWriter) writeArray(v reflect.Value, t *tag, buf *bytes.Buffer) (int, error) {
+	err := checkWriteArrayLen(v.Len())
+	if err != nil {
+		return 0, withField(t.path, err)
+	}
+
 	snapBuf := &bytes.Buffer{}
 	var snapIndexBuf *bytes.Buffer
 	if t.index != "" {
 		snapIndexBuf = &bytes.Buffer{}
 	}
 
+	recordSz := f.packedSize(v.Type(), t)
+	if recordSz > 0 && v.Type().Elem().Kind() != reflect.Struct {
+		return f.writePackedNumbers(v, recordSz, buf)
+	}
+
+	empty := f.version > Version1 && t.index == "" && v.Type().Elem().Kind() == reflect.Struct &&
+		hasNoFields(v.Type().Elem(), t)
+
 	var totalSz int
 	var lastLen int
-	var err error
 	var sz int
 	for i := 0; i < v.Len(); i++ {
+		if empty {
+			sz, err = f.writeEmptyElement(snapBuf)
+			if err != nil {
+				return 0, err
+			}
+			totalSz += sz
+			continue
+		}
+
 		el := v.Index(i)
 		sz, err = f.writeObject(el, t, snapBuf)
 		if err != nil {
 			return 0, err
 		}
 		totalSz += sz
+		if t.indexFunc != nil {
+			t.indexVal, err = indexFuncKey(t, el)
+			if err != nil {
+				return 0, err
+			}
+		}
 		bufLen := snapBuf.Len()
 
+		// Packed array elements must all be the record size. Since the
+		// element size is known, it is not written in the array index.
+		if recordSz > 0 && bufLen-lastLen != recordSz {
+			return 0, withField(t.path, fmt.Errorf("element size %d does not match the packed record size %d", bufLen-lastLen, recordSz))
+		}
+
 		if t.index != "" {
 			switch v := t.indexVal.(type) {
 			case string:
+				if f.rejectNul && strings.IndexByte(v, 0) >= 0 {
+					return 0, withField(t.path+rsfPathSep+t.index, errNulString)
+				}
 				sz, err = f.WriteFixedStringField(0, t.indexSz, v, snapIndexBuf)
 				if err != nil {
-					return 0, err
+					return 0, withField(t.path+rsfPathSep+t.index, err)
 				}
 				totalSz += sz
 			case int64:
@@ -213,16 +977,24 @@ func (f *rsfWriter) writeArray(v reflect.Value, t *tag, buf *bytes.Buffer) (int,
 					return 0, err
 				}
 				totalSz += sz
+			case []byte:
+				sz, err = snapIndexBuf.Write(v)
+				if err != nil {
+					return 0, err
+				}
+				totalSz += sz
 			default:
 				return 0, ErrInvalidIndexFieldType
 			}
-			sz, err = f.WriteSizeField(0, bufLen-lastLen, snapIndexBuf)
-			if err != nil {
-				return 0, err
+			if recordSz == 0 {
+				sz, err = f.WriteSizeField(0, bufLen-lastLen, snapIndexBuf)
+				if err != nil {
+					return 0, err
+				}
+				totalSz += sz
 			}
-			totalSz += sz
-			lastLen = bufLen
 		}
+		lastLen = bufLen
 	}
 
 	// Write the size of the entire array, including the size, length, index, and elements.
@@ -255,11 +1027,49 @@ func (f *rsfWriter) writeArray(v reflect.Value, t *tag, buf *bytes.Buffer) (int,
 	return totalSz, nil
 }
 
+// isByteArray returns true if `t` is a fixed-size byte array (e.g., `[16]byte`).
+// Byte arrays are supported as array index keys, which are written as raw
+// bytes in the array index.
+func isByteArray(t reflect.Type) bool {
+	return t.Kind() == reflect.Array && t.Elem().Kind() == reflect.Uint8
+}
+
+var (
+	netIPType     = reflect.TypeOf(net.IP{})
+	netipAddrType = reflect.TypeOf(netip.Addr{})
+)
+
+// isIPType returns true if `t` is an IP address type (`net.IP` or
+// `netip.Addr`). IP addresses are written with `WriteIPField`.
+func isIPType(t reflect.Type) bool {
+	return t == netIPType || t == netipAddrType
+}
+
+// ipAddr returns the IP address value of `v`, which must be an IP address
+// type.
+func ipAddr(v reflect.Value) netip.Addr {
+	if v.Type() == netipAddrType {
+		return v.Interface().(netip.Addr).WithZone("")
+	}
+	addr, _ := netip.AddrFromSlice(v.Interface().(net.IP))
+	return addr.Unmap()
+}
+
 func (f *rsfWriter) writeString(s string, t *tag, buf *bytes.Buffer) (int, error) {
+	if f.rejectNul && strings.IndexByte(s, 0) >= 0 {
+		return 0, errNulString
+	}
+
 	var err error
 	var sz int
 	if t.fixed > 0 {
 		sz, err = f.WriteFixedStringField(0, t.fixed, s, buf)
+	} else if t.overflow {
+		sz, err = f.overflow.add(s, buf)
+	} else if t.compress {
+		sz, err = f.WriteCompressedStringField(0, s, buf)
+	} else if f.stringTable {
+		sz, err = f.WriteSizeField(0, f.intern(s), buf)
 	} else {
 		sz, err = f.WriteStringField(0, s, buf)
 	}
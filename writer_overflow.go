@@ -0,0 +1,73 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+/*
+Strings tagged `overflow` are written out-of-line, in an overflow region
+that follows the object's other fields. In place of the string, the object
+holds an 8-byte pointer to the string:
+
+  0x2a, 0x0, 0x0, 0x0,  // Offset of the string from the start of the object
+  0x5, 0x0, 0x0, 0x0,   // String length
+
+The offset is measured from the start of the object's size field. Since the
+pointer has a fixed size, large optional values (like descriptions) don't
+affect the layout of the object's other fields, and advancing past the field
+only discards the pointer. The overflow option can only be used for fields
+that are not in arrays.
+*/
+
+// overflowRegion collects the strings written to an object's overflow region.
+type overflowRegion struct {
+	data bytes.Buffer
+
+	// The position of each pointer in the object buffer.
+	pointers []int
+}
+
+func (o *overflowRegion) reset() {
+	o.data.Reset()
+	o.pointers = o.pointers[:0]
+}
+
+// add writes a pointer to `s` to `buf` and adds `s` to the overflow region.
+// Until the size of the object is known, the pointer offset is relative to the
+// start of the overflow region. See `writeTo`.
+func (o *overflowRegion) add(s string, buf *bytes.Buffer) (int, error) {
+	o.pointers = append(o.pointers, buf.Len())
+
+	bs := make([]byte, sizeFieldLen+sizeFieldLen)
+	binary.LittleEndian.PutUint32(bs, uint32(o.data.Len()))
+	binary.LittleEndian.PutUint32(bs[sizeFieldLen:], uint32(len(s)))
+	_, err := buf.Write(bs)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = o.data.WriteString(s)
+	return len(bs), err
+}
+
+// writeTo adjusts the pointers in the object buffer `buf` to be relative to
+// the start of the object, and then appends the overflow region to `buf`.
+func (o *overflowRegion) writeTo(buf *bytes.Buffer) (int, error) {
+	if len(o.pointers) == 0 {
+		return 0, nil
+	}
+
+	// The overflow region follows the object size field and the object's
+	// other fields.
+	start := sizeFieldLen + buf.Len()
+	bs := buf.Bytes()
+	for _, pos := range o.pointers {
+		offset := binary.LittleEndian.Uint32(bs[pos:])
+		binary.LittleEndian.PutUint32(bs[pos:], offset+uint32(start))
+	}
+
+	n, err := buf.Write(o.data.Bytes())
+	return n, err
+}
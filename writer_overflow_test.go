@@ -0,0 +1,174 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WriterOverflowSuite struct {
+	suite.Suite
+}
+
+func TestWriterOverflowSuite(t *testing.T) {
+	suite.Run(t, &WriterOverflowSuite{})
+}
+
+type overflowRecord struct {
+	Name        string `rsf:"name"`
+	Description string `rsf:"description,overflow"`
+	Downloads   int64  `rsf:"downloads"`
+	Notes       string `rsf:"notes,overflow"`
+}
+
+var testOverflowData = []overflowRecord{
+	{
+		Name:        "numpy",
+		Description: strings.Repeat("The fundamental package for scientific computing. ", 20),
+		Downloads:   1000,
+		Notes:       "notes",
+	},
+	{
+		Name:      "django",
+		Downloads: 500,
+	},
+}
+
+func (s *WriterOverflowSuite) getOverflowData() []byte {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	for _, obj := range testOverflowData {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+	return buf.Bytes()
+}
+
+func (s *WriterOverflowSuite) TestOverflowLayout() {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	_, err := w.WriteObject(struct {
+		Name  string `rsf:"name"`
+		Notes string `rsf:"notes,overflow"`
+		Ready bool   `rsf:"ready"`
+	}{
+		Name:  "rsf",
+		Notes: "hello",
+		Ready: true,
+	})
+	s.Require().Nil(err)
+
+	data := buf.Bytes()
+	r := NewReader()
+	index, err := r.ReadIndex(bufio.NewReader(bytes.NewReader(data)))
+	s.Require().Nil(err)
+	s.Assert().Equal(FieldTypeOverflowStr, index[1].FieldType)
+
+	s.Assert().Equal([]byte{
+		// Object size
+		0x19, 0x0, 0x0, 0x0,
+		// "rsf"
+		0x3, 0x0, 0x0, 0x0,
+		0x72, 0x73, 0x66,
+		// "notes" offset and length
+		0x14, 0x0, 0x0, 0x0,
+		0x5, 0x0, 0x0, 0x0,
+		// ready
+		0x1,
+		// Overflow region: "hello"
+		0x68, 0x65, 0x6c, 0x6c, 0x6f,
+	}, data[r.Pos():])
+}
+
+func (s *WriterOverflowSuite) TestOverflowScan() {
+	data := s.getOverflowData()
+
+	// Scanning the scalar fields only discards the 8-byte pointers.
+	r := NewReader()
+	rs := bytes.NewReader(data)
+	buf := bufio.NewReader(rs)
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	for _, obj := range testOverflowData {
+		_, err = r.BeginObject(buf)
+		s.Require().Nil(err)
+		err = r.AdvanceTo(buf, "downloads")
+		s.Require().Nil(err)
+		downloads, err := r.ReadIntField(buf)
+		s.Require().Nil(err)
+		s.Assert().Equal(obj.Downloads, downloads)
+		s.Assert().Greater(r.RemainingInObject(), len(obj.Description))
+		s.Require().Nil(r.SkipObject(buf))
+	}
+
+	// Read the description on demand.
+	r = NewReader()
+	rs = bytes.NewReader(data)
+	_, err = r.ReadIndex(rs)
+	s.Require().Nil(err)
+	_, err = r.BeginObject(rs)
+	s.Require().Nil(err)
+	_, err = r.ReadStringField(rs)
+	s.Require().Nil(err)
+	description, err := r.ReadOverflowStringField(rs)
+	s.Require().Nil(err)
+	s.Assert().Equal(testOverflowData[0].Description, description)
+
+	// The reader is positioned after the pointer.
+	downloads, err := r.ReadIntField(rs)
+	s.Require().Nil(err)
+	s.Assert().Equal(int64(1000), downloads)
+}
+
+func (s *WriterOverflowSuite) TestOverflowRoundTrip() {
+	data := s.getOverflowData()
+
+	r := NewReader()
+	buf := bufio.NewReader(bytes.NewReader(data))
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	for _, obj := range testOverflowData {
+		var rec overflowRecord
+		s.Require().Nil(r.Unmarshal(buf, &rec))
+		s.Assert().Equal(obj, rec)
+	}
+
+	r = NewReader()
+	buf = bufio.NewReader(bytes.NewReader(data))
+	_, err = r.ReadIndex(buf)
+	s.Require().Nil(err)
+	m, err := r.DecodeObject(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(testOverflowData[0].Description, m["description"])
+	s.Assert().Equal("notes", m["notes"])
+
+	out := &bytes.Buffer{}
+	err = Print(out, bufio.NewReader(bytes.NewReader(data)))
+	s.Require().Nil(err)
+	s.Assert().Contains(out.String(), "description (overflow string): offset 39, length 1000")
+	s.Assert().Contains(out.String(), "name (string): django")
+}
+
+func (s *WriterOverflowSuite) TestOverflowErrors() {
+	w := NewWriterWithVersion(&bytes.Buffer{}, Version2)
+	_, err := w.WriteObject(struct {
+		Count int `rsf:"count,overflow"`
+	}{})
+	s.Assert().EqualError(err, "field count: the overflow option requires a string field")
+
+	_, err = w.WriteObject(struct {
+		Notes string `rsf:"notes,overflow,compress"`
+	}{})
+	s.Assert().EqualError(err, "field notes: the overflow option cannot be used with fixed-length or compressed strings")
+
+	_, err = w.WriteObject(struct {
+		List []struct {
+			Notes string `rsf:"notes,overflow"`
+		} `rsf:"list"`
+	}{})
+	s.Assert().EqualError(err, "field notes: the overflow option cannot be used in arrays")
+}
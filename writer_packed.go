@@ -0,0 +1,178 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+)
+
+/*
+
+Arrays tagged with the `packed` option (e.g., `rsf:"points,packed"`) whose
+elements are structs with only fixed-size fields are written packed. Since
+each element is the same size, the record size is written once in the index
+(following the array element type, with the `FieldTypePackedArray` field
+type), and the array index omits the per-element size fields. This allows a reader
+to compute the offset of any element with `SeekToArrayElement`.
+
+Format:
+
+  [array size]
+  [array length]
+  [element 1 index key]             // Only if indexed
+  [element n index key]             // Only if indexed
+  [element 1]
+  [element n]
+
+Fixed-size fields are fixed-length strings, bools, integers, and floats, as
+well as nested structs that contain only fixed-size fields. If an array is
+tagged `packed` but its elements include other fields, it is written with the
+regular array format. Packed arrays require Version2 or greater.
+
+Arrays of numbers tagged `packed` are written as a contiguous block of
+fixed-width little-endian values, with no per-element overhead. The element
+width is recorded as the record size in the index:
+
+  int32, float32      4 bytes
+  int, int64, float64 8 bytes
+
+Packed arrays of numbers are never indexed, and can be read all at once with
+`ReadPackedInt32Slice`, `ReadPackedInt64Slice`, `ReadPackedFloat32Slice`, or
+`ReadPackedFloat64Slice`. Arrays of other integer types tagged `packed` are
+written with the regular array format.
+
+*/
+
+// packedSize returns the record size of the elements of the array type `v` if
+// the array is written packed, or zero if it is not.
+func (f *rsfWriter) packedSize(v reflect.Type, t *tag) int {
+	if !t.packed || f.version < Version2 {
+		return 0
+	}
+	if v.Elem().Kind() != reflect.Struct {
+		if isMarshalerType(v.Elem()) {
+			return 0
+		}
+		return packedWidth(v.Elem().Kind())
+	}
+
+	// Use a copy of the tag, since `getTagInfo` records array index
+	// information in the parent tag.
+	tCopy := *t
+	sz, ok := fixedSize(v.Elem(), &tCopy)
+	if !ok {
+		return 0
+	}
+	return sz
+}
+
+// fixedSize returns the serialized size of the struct type `v`, and false if
+// the struct includes any fields that are not fixed-size.
+func fixedSize(v reflect.Type, tParent *tag) (int, bool) {
+	var totalSz int
+	for i := 0; i < v.NumField(); i++ {
+		t := &tag{}
+		skip, err := getTagInfo(v, i, t, tParent, nil)
+		if err != nil {
+			return 0, false
+		}
+		if skip {
+			continue
+		}
+
+		fieldType := v.Field(i).Type
+		if isTimeType(fieldType) {
+			totalSz += sizeInt32
+			continue
+		}
+		if isIPType(fieldType) || isMarshalerType(fieldType) {
+			// IP addresses and custom encodings vary in size.
+			return 0, false
+		}
+		switch fieldType.Kind() {
+		case reflect.String:
+			if t.fixed == 0 || t.compress {
+				return 0, false
+			}
+			totalSz += t.fixed
+		case reflect.Bool:
+			totalSz += 1
+		case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+			if t.bigEndian > 0 {
+				totalSz += t.bigEndian
+			} else if t.fixedInt {
+				totalSz += sizeFixedInt64
+			} else {
+				totalSz += sizeInt64
+			}
+		case reflect.Float32, reflect.Float64:
+			if t.bigEndian > 0 {
+				totalSz += t.bigEndian
+			} else {
+				totalSz += sizeFloat64
+			}
+		case reflect.Struct:
+			sz, ok := fixedSize(fieldType, t)
+			if !ok {
+				return 0, false
+			}
+			totalSz += sz
+		default:
+			return 0, false
+		}
+	}
+	return totalSz, totalSz > 0
+}
+
+// packedWidth returns the width of the elements of a packed array of numbers
+// of kind `kind`, or zero if arrays of `kind` are not packed.
+func packedWidth(kind reflect.Kind) int {
+	switch kind {
+	case reflect.Int32:
+		return sizeInt32
+	case reflect.Int, reflect.Int64:
+		return sizeFixedInt64
+	case reflect.Float32:
+		return sizeFloat32
+	case reflect.Float64:
+		return sizeFloat64
+	default:
+		return 0
+	}
+}
+
+// writePackedNumbers writes the array of numbers `v` as a packed array with
+// elements of `width` bytes.
+func (f *rsfWriter) writePackedNumbers(v reflect.Value, width int, buf *bytes.Buffer) (int, error) {
+	totalSz := sizeFieldLen + sizeFieldLen + v.Len()*width
+	_, err := f.WriteSizeField(0, totalSz, buf)
+	if err != nil {
+		return 0, err
+	}
+	_, err = f.WriteSizeField(0, v.Len(), buf)
+	if err != nil {
+		return 0, err
+	}
+
+	bs := make([]byte, v.Len()*width)
+	for i := 0; i < v.Len(); i++ {
+		el := bs[i*width:]
+		switch v.Type().Elem().Kind() {
+		case reflect.Int32:
+			binary.LittleEndian.PutUint32(el, uint32(v.Index(i).Int()))
+		case reflect.Int, reflect.Int64:
+			binary.LittleEndian.PutUint64(el, uint64(v.Index(i).Int()))
+		case reflect.Float32:
+			binary.LittleEndian.PutUint32(el, math.Float32bits(float32(f.canonicalFloat(v.Index(i).Float()))))
+		case reflect.Float64:
+			binary.LittleEndian.PutUint64(el, math.Float64bits(f.canonicalFloat(v.Index(i).Float())))
+		}
+	}
+	_, err = buf.Write(bs)
+	if err != nil {
+		return 0, err
+	}
+	return totalSz, nil
+}
@@ -0,0 +1,393 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WriterPackedSuite struct {
+	suite.Suite
+}
+
+func TestWriterPackedSuite(t *testing.T) {
+	suite.Run(t, &WriterPackedSuite{})
+}
+
+type packedPoint struct {
+	ID    int     `rsf:"id,skip"`
+	Label string  `rsf:"label,fixed:4"`
+	X     float64 `rsf:"x"`
+	Y     float64 `rsf:"y"`
+	Valid bool    `rsf:"valid"`
+}
+
+type packedShape struct {
+	Name   string        `rsf:"name"`
+	Points []packedPoint `rsf:"points,packed,index:id"`
+	Sides  int           `rsf:"sides"`
+}
+
+var testPackedData = packedShape{
+	Name: "square",
+	Points: []packedPoint{
+		{ID: 10, Label: "sw  ", X: 0, Y: 0, Valid: true},
+		{ID: 20, Label: "nw  ", X: 0, Y: 1, Valid: true},
+		{ID: 30, Label: "ne  ", X: 1, Y: 1, Valid: false},
+		{ID: 40, Label: "se  ", X: 1, Y: 0, Valid: true},
+	},
+	Sides: 4,
+}
+
+func (s *WriterPackedSuite) getPackedData() []byte {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	_, err := w.WriteObject(testPackedData)
+	s.Require().Nil(err)
+	return buf.Bytes()
+}
+
+func (s *WriterPackedSuite) TestPackedIndex() {
+	r := NewReader()
+	index, err := r.ReadIndex(bufio.NewReader(bytes.NewReader(s.getPackedData())))
+	s.Require().Nil(err)
+	s.Assert().Equal(IndexEntry{
+		FieldName:    "points",
+		FieldType:    FieldTypePackedArray,
+		FieldSize:    4 + sizeFloat64 + sizeFloat64 + 1,
+		Indexed:      true,
+		IndexSize:    sizeInt64,
+		IndexType:    int(reflect.Int64),
+		SubfieldType: int(reflect.Struct),
+		Subfields: Index{
+			{FieldName: "label", FieldType: FieldTypeFixedStr, FieldSize: 4},
+			{FieldName: "x", FieldType: FieldTypeFloat},
+			{FieldName: "y", FieldType: FieldTypeFloat},
+			{FieldName: "valid", FieldType: FieldTypeBool},
+		},
+	}, index[1])
+}
+
+func (s *WriterPackedSuite) TestPackedFallback() {
+	// Arrays with variable-size fields use the regular array layout.
+	type varPoint struct {
+		Label string  `rsf:"label"`
+		X     float64 `rsf:"x"`
+	}
+	type varShape struct {
+		Points []varPoint `rsf:"points,packed"`
+	}
+
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	_, err := w.WriteObject(varShape{Points: []varPoint{{Label: "a", X: 1}}})
+	s.Require().Nil(err)
+
+	r := NewReader()
+	index, err := r.ReadIndex(bufio.NewReader(buf))
+	s.Require().Nil(err)
+	s.Assert().Equal(FieldTypeArray, index[0].FieldType)
+	s.Assert().Equal(0, index[0].FieldSize)
+}
+
+func (s *WriterPackedSuite) TestSeekToArrayElement() {
+	data := s.getPackedData()
+	buf := bufio.NewReader(bytes.NewReader(data))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	_, err = r.ReadSizeField(buf)
+	s.Require().Nil(err)
+
+	// Advance to the array and record its position.
+	err = r.AdvanceTo(buf, "points")
+	s.Require().Nil(err)
+	arrayPos := r.Pos()
+
+	// Seek directly to each element, in any order.
+	rs := bytes.NewReader(data)
+	for _, n := range []int{2, 0, 3, 1} {
+		err = r.Seek(int(arrayPos), rs, "points")
+		s.Require().Nil(err)
+		err = r.SeekToArrayElement(rs, n, "points")
+		s.Require().Nil(err)
+
+		// Size + length + 4 index keys, then 21-byte records.
+		s.Assert().Equal(arrayPos+int64(8+4*sizeInt64+n*21), r.Pos())
+
+		buf = bufio.NewReader(rs)
+		err = r.AdvanceTo(buf, "points", "label")
+		s.Require().Nil(err)
+		label, err := r.ReadFixedStringField(4, buf)
+		s.Require().Nil(err)
+		s.Assert().Equal(testPackedData.Points[n].Label, label)
+
+		err = r.AdvanceTo(buf, "points", "y")
+		s.Require().Nil(err)
+		y, err := r.ReadFloatField(buf)
+		s.Require().Nil(err)
+		s.Assert().Equal(testPackedData.Points[n].Y, y)
+	}
+
+	// Out of range.
+	err = r.Seek(int(arrayPos), rs, "points")
+	s.Require().Nil(err)
+	err = r.SeekToArrayElement(rs, 4, "points")
+	s.Assert().EqualError(err, "array element 4 out of range; array length is 4")
+
+	// Not a packed array.
+	err = r.Seek(int(arrayPos), rs, "points")
+	s.Require().Nil(err)
+	err = r.SeekToArrayElement(rs, 0, "name")
+	s.Assert().ErrorIs(err, ErrNotPackedArray)
+}
+
+func (s *WriterPackedSuite) TestPackedRoundTrip() {
+	buf := bufio.NewReader(bytes.NewReader(s.getPackedData()))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+
+	var shape packedShape
+	err = r.Unmarshal(buf, &shape)
+	s.Require().Nil(err)
+	s.Assert().Equal("square", shape.Name)
+	s.Assert().Equal(4, shape.Sides)
+	s.Assert().Len(shape.Points, 4)
+	s.Assert().Equal("ne  ", shape.Points[2].Label)
+	s.Assert().Equal(1.0, shape.Points[2].X)
+	s.Assert().False(shape.Points[2].Valid)
+
+	out := &bytes.Buffer{}
+	err = PrintJSON(out, bufio.NewReader(bytes.NewReader(s.getPackedData())))
+	s.Require().Nil(err)
+	s.Assert().Contains(out.String(), `{"@key":30,"label":"ne  ","valid":false,"x":1,"y":1}`)
+	s.Assert().Contains(out.String(), `"sides":4`)
+
+	out.Reset()
+	err = Print(out, bufio.NewReader(bytes.NewReader(s.getPackedData())))
+	s.Require().Nil(err)
+	s.Assert().Contains(out.String(), "    - 30\n    label (string(4)): ne  \n")
+	s.Assert().Contains(out.String(), "sides (int): 4\n")
+}
+
+type packedSeries struct {
+	Name     string    `rsf:"name"`
+	Counts   []int32   `rsf:"counts,packed"`
+	Totals   []int64   `rsf:"totals,packed"`
+	Ratios   []float32 `rsf:"ratios,packed"`
+	Averages []float64 `rsf:"averages,packed"`
+}
+
+var testPackedSeries = packedSeries{
+	Name:     "downloads",
+	Counts:   []int32{1, -2, 2147483647, -2147483648},
+	Totals:   []int64{9223372036854775807, -1, 0},
+	Ratios:   []float32{0.5, -1.25},
+	Averages: []float64{3.14159, 2.71828, 1.41421},
+}
+
+func (s *WriterPackedSuite) getPackedSeries() []byte {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	_, err := w.WriteObject(testPackedSeries)
+	s.Require().Nil(err)
+	return buf.Bytes()
+}
+
+func (s *WriterPackedSuite) TestPackedNumbersIndex() {
+	r := NewReader()
+	index, err := r.ReadIndex(bufio.NewReader(bytes.NewReader(s.getPackedSeries())))
+	s.Require().Nil(err)
+	s.Assert().Equal(Index{
+		{FieldName: "name", FieldType: FieldTypeVarStr},
+		{FieldName: "counts", FieldType: FieldTypePackedArray, FieldSize: 4, SubfieldType: int(reflect.Int32)},
+		{FieldName: "totals", FieldType: FieldTypePackedArray, FieldSize: 8, SubfieldType: int(reflect.Int64)},
+		{FieldName: "ratios", FieldType: FieldTypePackedArray, FieldSize: 4, SubfieldType: int(reflect.Float32)},
+		{FieldName: "averages", FieldType: FieldTypePackedArray, FieldSize: 8, SubfieldType: int(reflect.Float64)},
+	}, index)
+}
+
+func (s *WriterPackedSuite) TestPackedNumbersRoundTrip() {
+	buf := bufio.NewReader(bytes.NewReader(s.getPackedSeries()))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+
+	var series packedSeries
+	err = r.Unmarshal(buf, &series)
+	s.Require().Nil(err)
+	s.Assert().Equal(testPackedSeries, series)
+
+	buf = bufio.NewReader(bytes.NewReader(s.getPackedSeries()))
+	r = NewReader()
+	_, err = r.ReadIndex(buf)
+	s.Require().Nil(err)
+	obj, err := r.DecodeObject(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal([]any{int64(1), int64(-2), int64(2147483647), int64(-2147483648)}, obj["counts"])
+	s.Assert().Equal([]any{int64(9223372036854775807), int64(-1), int64(0)}, obj["totals"])
+	s.Assert().Equal([]any{0.5, -1.25}, obj["ratios"])
+	s.Assert().Equal([]any{3.14159, 2.71828, 1.41421}, obj["averages"])
+
+	out := &bytes.Buffer{}
+	err = Print(out, bufio.NewReader(bytes.NewReader(s.getPackedSeries())))
+	s.Require().Nil(err)
+	s.Assert().Contains(out.String(), "counts (array(4)):\n    -1\n    --2\n    -2147483647\n    --2147483648\n")
+	s.Assert().Contains(out.String(), "ratios (array(2)):\n    -0.500000\n    --1.250000\n")
+}
+
+func (s *WriterPackedSuite) TestReadPackedSlices() {
+	buf := bufio.NewReader(bytes.NewReader(s.getPackedSeries()))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	_, err = r.ReadSizeField(buf)
+	s.Require().Nil(err)
+
+	err = r.AdvanceTo(buf, "counts")
+	s.Require().Nil(err)
+	start := r.Pos()
+	counts, err := r.ReadPackedInt32Slice(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(testPackedSeries.Counts, counts)
+
+	// The size and length are followed by the elements, with no
+	// per-element overhead.
+	s.Assert().Equal(int64(8+4*4), r.Pos()-start)
+
+	totals, err := r.ReadPackedInt64Slice(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(testPackedSeries.Totals, totals)
+
+	ratios, err := r.ReadPackedFloat32Slice(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(testPackedSeries.Ratios, ratios)
+
+	averages, err := r.ReadPackedFloat64Slice(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(testPackedSeries.Averages, averages)
+
+	// Reading with the wrong element width fails.
+	buf = bufio.NewReader(bytes.NewReader(s.getPackedSeries()))
+	r = NewReader()
+	_, err = r.ReadIndex(buf)
+	s.Require().Nil(err)
+	_, err = r.ReadSizeField(buf)
+	s.Require().Nil(err)
+	err = r.AdvanceTo(buf, "ratios")
+	s.Require().Nil(err)
+	_, err = r.ReadPackedFloat64Slice(buf)
+	s.Assert().EqualError(err, "array size 16 does not match 2 elements of 8 bytes")
+}
+
+func (s *WriterPackedSuite) TestPackedNumbersRewrite() {
+	out := &bytes.Buffer{}
+	err := Rewrite(bufio.NewReader(bytes.NewReader(s.getPackedSeries())), out, func(obj map[string]any) map[string]any {
+		return obj
+	})
+	s.Require().Nil(err)
+	s.Assert().Equal(s.getPackedSeries(), out.Bytes())
+}
+
+func benchmarkPackedNumbers(b *testing.B, v any) {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	_, err := w.WriteObject(v)
+	if err != nil {
+		b.Fatal(err)
+	}
+	data := buf.Bytes()
+	b.ReportMetric(float64(len(data)), "bytes/file")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := NewReader()
+		rbuf := bufio.NewReader(bytes.NewReader(data))
+		_, err = r.ReadIndex(rbuf)
+		if err != nil {
+			b.Fatal(err)
+		}
+		dst := reflect.New(reflect.TypeOf(v))
+		err = r.Unmarshal(rbuf, dst.Interface())
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadIntArray(b *testing.B) {
+	type series struct {
+		Values []int `rsf:"values"`
+	}
+	v := series{Values: make([]int, 10000)}
+	for i := range v.Values {
+		v.Values[i] = i * 1000
+	}
+	benchmarkPackedNumbers(b, v)
+}
+
+func BenchmarkReadPackedInt32Array(b *testing.B) {
+	type series struct {
+		Values []int32 `rsf:"values,packed"`
+	}
+	v := series{Values: make([]int32, 10000)}
+	for i := range v.Values {
+		v.Values[i] = int32(i * 1000)
+	}
+	benchmarkPackedNumbers(b, v)
+}
+
+func BenchmarkReadPackedInt64Array(b *testing.B) {
+	type series struct {
+		Values []int64 `rsf:"values,packed"`
+	}
+	v := series{Values: make([]int64, 10000)}
+	for i := range v.Values {
+		v.Values[i] = int64(i * 1000)
+	}
+	benchmarkPackedNumbers(b, v)
+}
+
+func BenchmarkReadPackedInt32Slice(b *testing.B) {
+	type series struct {
+		Values []int32 `rsf:"values,packed"`
+	}
+	v := series{Values: make([]int32, 10000)}
+	for i := range v.Values {
+		v.Values[i] = int32(i * 1000)
+	}
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	_, err := w.WriteObject(v)
+	if err != nil {
+		b.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := NewReader()
+		rbuf := bufio.NewReader(bytes.NewReader(data))
+		_, err = r.ReadIndex(rbuf)
+		if err != nil {
+			b.Fatal(err)
+		}
+		_, err = r.ReadSizeField(rbuf)
+		if err != nil {
+			b.Fatal(err)
+		}
+		err = r.AdvanceTo(rbuf, "values")
+		if err != nil {
+			b.Fatal(err)
+		}
+		_, err = r.ReadPackedInt32Slice(rbuf)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
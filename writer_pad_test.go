@@ -0,0 +1,82 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WriterPadSuite struct {
+	suite.Suite
+}
+
+func TestWriterPadSuite(t *testing.T) {
+	suite.Run(t, &WriterPadSuite{})
+}
+
+func (s *WriterPadSuite) TestPadObjects() {
+	plain := &bytes.Buffer{}
+	w := NewWriterWithVersion(plain, Version2)
+	for _, obj := range testComplexData {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+	s.Require().Nil(w.Close())
+
+	buf := &bytes.Buffer{}
+	w = NewWriterWithVersion(buf, Version2, PadObjects(64))
+	for _, obj := range testComplexData {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+	s.Require().Nil(w.Close())
+
+	// Each object is rounded up to a multiple of 64 bytes.
+	plainSizes := objectSizes(&s.Suite, plain.Bytes())
+	sizes := objectSizes(&s.Suite, buf.Bytes())
+	s.Require().Len(sizes, len(plainSizes))
+	for i := range sizes {
+		s.Assert().Equal(0, sizes[i]%64)
+		s.Assert().GreaterOrEqual(sizes[i], plainSizes[i])
+		s.Assert().Less(sizes[i]-plainSizes[i], 64)
+	}
+
+	// The objects read back identically.
+	plainReader := NewReader()
+	plainBuf := bufio.NewReader(bytes.NewReader(plain.Bytes()))
+	_, err := plainReader.ReadIndex(plainBuf)
+	s.Require().Nil(err)
+	r := NewReader()
+	rBuf := bufio.NewReader(bytes.NewReader(buf.Bytes()))
+	_, err = r.ReadIndex(rBuf)
+	s.Require().Nil(err)
+	for range testComplexData {
+		var plainRec, rec FullPackageRecordPyPI
+		s.Require().Nil(plainReader.Unmarshal(plainBuf, &plainRec))
+		s.Require().Nil(r.Unmarshal(rBuf, &rec))
+		s.Assert().Equal(plainRec, rec)
+	}
+
+	// The padding is not printed.
+	plainOut := &bytes.Buffer{}
+	s.Require().Nil(Print(plainOut, bufio.NewReader(bytes.NewReader(plain.Bytes()))))
+	out := &bytes.Buffer{}
+	s.Require().Nil(Print(out, bufio.NewReader(bytes.NewReader(buf.Bytes()))))
+	s.Assert().Equal(plainOut.String(), out.String())
+}
+
+func (s *WriterPadSuite) TestPadObjectsAligned() {
+	type record struct {
+		Name string `rsf:"name,fixed:12"`
+	}
+
+	// Objects that are already a multiple of the block size aren't padded.
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2, PadObjects(16))
+	_, err := w.WriteObject(record{Name: "posit-studio"})
+	s.Require().Nil(err)
+	s.Assert().Equal([]int{16}, objectSizes(&s.Suite, buf.Bytes()))
+}
@@ -0,0 +1,27 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"errors"
+	"io"
+	"reflect"
+)
+
+var ErrInvalidSchemaType = errors.New("schema type must be a struct")
+
+// WriteSchema writes only the index version header and the index for the
+// struct `v` to `w`, without any objects. The result documents the schema of
+// `v` and can be loaded with `ReadIndex` (and described with `Index.String`).
+func WriteSchema(v any, w io.Writer, version int) error {
+	t := reflect.TypeOf(v)
+	if t == nil || t.Kind() != reflect.Struct {
+		return ErrInvalidSchemaType
+	}
+
+	f := &rsfWriter{
+		writer:  w,
+		version: version,
+	}
+	_, err := f.writeIndex(t, &tag{}, w)
+	return err
+}
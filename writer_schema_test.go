@@ -0,0 +1,71 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WriterSchemaSuite struct {
+	suite.Suite
+}
+
+func TestWriterSchemaSuite(t *testing.T) {
+	suite.Run(t, &WriterSchemaSuite{})
+}
+
+func (s *WriterSchemaSuite) TestWriteSchema() {
+	for _, version := range []int{Version1, Version2, Version3} {
+		full := &bytes.Buffer{}
+		w := NewWriterWithVersion(full, version)
+		for _, obj := range testComplexData {
+			_, err := w.WriteObject(obj)
+			s.Require().Nil(err)
+		}
+		s.Require().Nil(w.Close())
+		fullLen := full.Len()
+		expected, err := NewReader().ReadIndex(bufio.NewReader(full))
+		s.Require().Nil(err)
+
+		schema := &bytes.Buffer{}
+		err = WriteSchema(FullPackageRecordPyPI{}, schema, version)
+		s.Require().Nil(err)
+		s.Assert().Less(schema.Len(), fullLen)
+		index, err := NewReader().ReadIndex(bufio.NewReader(schema))
+		s.Require().Nil(err)
+		s.Assert().Equal(expected, index)
+	}
+}
+
+func (s *WriterSchemaSuite) TestWriteSchemaInvalidType() {
+	s.Assert().ErrorIs(WriteSchema(nil, &bytes.Buffer{}, Version2), ErrInvalidSchemaType)
+	s.Assert().ErrorIs(WriteSchema([]string{}, &bytes.Buffer{}, Version2), ErrInvalidSchemaType)
+}
+
+func (s *WriterSchemaSuite) TestIndexString() {
+	schema := &bytes.Buffer{}
+	err := WriteSchema(FullPackageRecordPyPI{}, schema, Version2)
+	s.Require().Nil(err)
+	index, err := NewReader().ReadIndex(bufio.NewReader(schema))
+	s.Require().Nil(err)
+
+	s.Assert().Equal(`homepage str
+cname str
+pname str
+classifiers arr[struct]
+  name str
+  type i64
+  values arr[string]
+author str
+snapshots arr[struct] index:string(10)
+  description str
+  deleted bool
+  version str
+  summary str
+  license str
+popularity i64
+`, index.String())
+}
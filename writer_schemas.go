@@ -0,0 +1,125 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+/*
+
+A multi-schema file holds objects of several struct types, each described by
+its own index. The first index is written as usual, with the multi-schema
+feature flag, and the indexes of the other types follow it. Each object
+begins with the id of its index, which is the position of the index in the
+header, counting from zero.
+
+Format:
+
+  [index 0]        // With the multi-schema feature flag
+  [schema count]   // Including index 0
+  [index 1 size]
+  [index 1]
+  [index n size]
+  [index n]
+
+  [object size]
+  [schema id]
+  [object fields]
+
+Multi-schema files require Version3 or greater. They cannot use a string
+table, an object key, packed bools, nil as absent, a field name table, or a
+supplied index (see `NewWriterWithIndex`).
+
+*/
+
+var ErrSchemasType = errors.New("schemas must be structs")
+var ErrSchemasVersion = errors.New("multi-schema files require Version3 or greater")
+var ErrSchemasOption = errors.New("multi-schema files cannot use a string table, an object key, packed bools, nil as absent, a field name table, or a supplied index")
+var ErrNoSchema = errors.New("the object type is not one of the schemas")
+
+// NewWriterWithSchemas returns a writer like `NewWriterWithVersion` that
+// writes objects of several struct types to one file. The `schemas` are
+// values of each struct type, like `[]any{Package{}, Deletion{}}`. The index of
+// every type is written in the header, and each object is written with the
+// id of its type, which is the type's position in `schemas`. Readers select
+// the index for each object by its id (see `SchemaID`).
+//
+// Each object written must be of one of the schema types. See the format
+// description for the options that cannot be used.
+func NewWriterWithSchemas(w io.Writer, version int, schemas []any, opts ...WriterOption) Writer {
+	f := NewWriterWithVersion(w, version, opts...).(*rsfWriter)
+	f.schemas = make([]reflect.Type, 0, len(schemas))
+	for _, schema := range schemas {
+		f.schemas = append(f.schemas, reflect.TypeOf(schema))
+	}
+	return f
+}
+
+// schemaID returns the id of the schema of the object `v`.
+func (f *rsfWriter) schemaID(v any) (int, error) {
+	if len(f.schemas) == 0 {
+		return 0, ErrSchemasType
+	}
+	for _, t := range f.schemas {
+		if t == nil || t.Kind() != reflect.Struct {
+			return 0, ErrSchemasType
+		}
+	}
+	if f.version < Version3 {
+		return 0, ErrSchemasVersion
+	}
+	if f.stringTable || f.objectKey != "" || f.packBools || f.nilAsAbsent || f.fieldNameTable || f.canonicalIndex != nil {
+		return 0, ErrSchemasOption
+	}
+
+	t := reflect.TypeOf(v)
+	for id, schema := range f.schemas {
+		if t == schema {
+			return id, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %v", ErrNoSchema, t)
+}
+
+// writeSchemas writes the index of each schema to `out`. The first index is
+// written with the index version header, like `writeIndex`.
+func (f *rsfWriter) writeSchemas(t *tag, out io.Writer) (int, error) {
+	totalSz, err := f.writeIndex(f.schemas[0], t, out)
+	if err != nil {
+		return 0, err
+	}
+	if f.boolCount > 0 {
+		return 0, ErrSchemasOption
+	}
+
+	sz, err := f.WriteSizeField(0, len(f.schemas), out)
+	if err != nil {
+		return 0, err
+	}
+	totalSz += sz
+
+	for _, schema := range f.schemas[1:] {
+		indexBuf, _, err := f.indexEntries(schema, t)
+		if err != nil {
+			return 0, err
+		}
+		if f.boolCount > 0 {
+			return 0, ErrSchemasOption
+		}
+		sz, err = f.WriteSizeField(0, indexBuf.Len()+sizeFieldLen, out)
+		if err != nil {
+			return 0, err
+		}
+		totalSz += sz
+
+		n, err := indexBuf.WriteTo(out)
+		if err != nil {
+			return 0, err
+		}
+		totalSz += int(n)
+	}
+	return totalSz, nil
+}
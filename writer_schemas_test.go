@@ -0,0 +1,139 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WriterSchemasSuite struct {
+	suite.Suite
+}
+
+func TestWriterSchemasSuite(t *testing.T) {
+	suite.Run(t, &WriterSchemasSuite{})
+}
+
+type schemaPackage struct {
+	Name     string   `rsf:"name"`
+	Version  string   `rsf:"version"`
+	Authors  []string `rsf:"authors"`
+	Verified bool     `rsf:"verified"`
+}
+
+type schemaDeletion struct {
+	Name   string `rsf:"name"`
+	Reason string `rsf:"reason,fixed:4"`
+	Count  int    `rsf:"count"`
+}
+
+func (s *WriterSchemasSuite) writeEvents() []byte {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithSchemas(buf, Version3, []any{schemaPackage{}, schemaDeletion{}})
+	for _, obj := range []any{
+		schemaPackage{Name: "numpy", Version: "1.2.3", Authors: []string{"ann", "bo"}, Verified: true},
+		schemaDeletion{Name: "leftpad", Reason: "spam", Count: 3},
+		schemaPackage{Name: "django", Version: "5.0"},
+		schemaDeletion{Name: "pandas", Reason: "dupe", Count: 1},
+	} {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+	return buf.Bytes()
+}
+
+func (s *WriterSchemasSuite) TestDecodeObject() {
+	buf := bufio.NewReader(bytes.NewReader(s.writeEvents()))
+	r := NewReader()
+	index, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+	s.Assert().Len(index, 4)
+	s.Require().Len(r.Schemas(), 2)
+	s.Assert().Equal(Index{
+		{FieldName: "name", FieldType: FieldTypeVarStr},
+		{FieldName: "reason", FieldType: FieldTypeFixedStr, FieldSize: 4},
+		{FieldName: "count", FieldType: FieldTypeInt64},
+	}, r.Schemas()[1])
+
+	objs := make([]map[string]any, 0)
+	for {
+		obj, err := r.DecodeObject(buf)
+		if err == io.EOF {
+			break
+		}
+		s.Require().Nil(err)
+		objs = append(objs, obj)
+	}
+	s.Assert().Equal([]map[string]any{
+		{SchemaIDField: 0, "name": "numpy", "version": "1.2.3", "authors": []any{"ann", "bo"}, "verified": true},
+		{SchemaIDField: 1, "name": "leftpad", "reason": "spam", "count": int64(3)},
+		{SchemaIDField: 0, "name": "django", "version": "5.0", "authors": []any{}, "verified": false},
+		{SchemaIDField: 1, "name": "pandas", "reason": "dupe", "count": int64(1)},
+	}, objs)
+}
+
+func (s *WriterSchemasSuite) TestUnmarshal() {
+	buf := bufio.NewReader(bytes.NewReader(s.writeEvents()))
+	r := NewReader()
+	_, err := r.ReadIndex(buf)
+	s.Require().Nil(err)
+
+	// Dispatch on the schema id of each object.
+	var pkgs []schemaPackage
+	var deletions []schemaDeletion
+	for {
+		err = r.NextObject(buf)
+		if err == io.EOF {
+			break
+		}
+		s.Require().Nil(err)
+		switch r.SchemaID() {
+		case 0:
+			var pkg schemaPackage
+			s.Require().Nil(r.Unmarshal(buf, &pkg))
+			pkgs = append(pkgs, pkg)
+		case 1:
+			var deletion schemaDeletion
+			s.Require().Nil(r.Unmarshal(buf, &deletion))
+			deletions = append(deletions, deletion)
+		}
+	}
+	s.Assert().Equal([]string{"numpy", "django"}, []string{pkgs[0].Name, pkgs[1].Name})
+	s.Assert().Equal([]schemaDeletion{
+		{Name: "leftpad", Reason: "spam", Count: 3},
+		{Name: "pandas", Reason: "dupe", Count: 1},
+	}, deletions)
+}
+
+func (s *WriterSchemasSuite) TestPrint() {
+	out := &bytes.Buffer{}
+	err := Print(out, bufio.NewReader(bytes.NewReader(s.writeEvents())))
+	s.Require().Nil(err)
+	s.Assert().Contains(out.String(), "reason (string(4)): spam")
+	s.Assert().Contains(out.String(), "version (string): 5.0")
+}
+
+func (s *WriterSchemasSuite) TestErrors() {
+	w := NewWriterWithSchemas(&bytes.Buffer{}, Version3, []any{schemaPackage{}})
+	_, err := w.WriteObject(schemaDeletion{})
+	s.Assert().ErrorIs(err, ErrNoSchema)
+
+	w = NewWriterWithSchemas(&bytes.Buffer{}, Version2, []any{schemaPackage{}})
+	_, err = w.WriteObject(schemaPackage{})
+	s.Assert().ErrorIs(err, ErrSchemasVersion)
+
+	w = NewWriterWithSchemas(&bytes.Buffer{}, Version3, []any{schemaPackage{}}, PackBools(true))
+	_, err = w.WriteObject(schemaPackage{})
+	s.Assert().ErrorIs(err, ErrSchemasOption)
+
+	w = NewWriterWithSchemas(&bytes.Buffer{}, Version3, []any{"package"})
+	_, err = w.WriteObject("package")
+	s.Assert().ErrorIs(err, ErrSchemasType)
+
+	err = Merge(&bytes.Buffer{}, bytes.NewReader(s.writeEvents()))
+	s.Assert().EqualError(err, "source 0 has several schemas and cannot be merged")
+}
@@ -0,0 +1,145 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WriterStreamSuite struct {
+	suite.Suite
+}
+
+func TestWriterStreamSuite(t *testing.T) {
+	suite.Run(t, &WriterStreamSuite{})
+}
+
+// writeStreamed writes `objs` to a temporary file, which is seekable, and
+// returns the contents of the file.
+func (s *WriterStreamSuite) writeStreamed(version int, objs []any, opts ...WriterOption) []byte {
+	tmp, err := os.CreateTemp("", "")
+	s.Require().Nil(err)
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	w := NewWriterWithVersion(tmp, version, opts...)
+	for _, obj := range objs {
+		_, err = w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+	s.Require().Nil(w.Close())
+
+	data, err := os.ReadFile(tmp.Name())
+	s.Require().Nil(err)
+	return data
+}
+
+// writeBuffered writes `objs` to a buffer, which is not seekable.
+func (s *WriterStreamSuite) writeBuffered(version int, objs []any, opts ...WriterOption) []byte {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, version, opts...)
+	for _, obj := range objs {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+	s.Require().Nil(w.Close())
+	return buf.Bytes()
+}
+
+func (s *WriterStreamSuite) TestStreamComplex() {
+	objs := make([]any, 0)
+	for _, obj := range testComplexData {
+		objs = append(objs, obj)
+	}
+	buffered := s.writeBuffered(Version2, objs)
+	s.Assert().Equal(buffered, s.writeStreamed(Version2, objs))
+	s.Assert().Len(buffered, 888)
+}
+
+func (s *WriterStreamSuite) TestStreamPatched() {
+	// The bitmap of packed bools and the padding are written once the
+	// object is complete.
+	type record struct {
+		Name    string `rsf:"name"`
+		Ready   bool   `rsf:"ready"`
+		Count   int    `rsf:"count"`
+		Deleted bool   `rsf:"deleted"`
+	}
+	objs := []any{
+		record{Name: "numpy", Ready: true, Count: 3},
+		record{Name: "django", Deleted: true},
+	}
+	opts := []WriterOption{PackBools(true), PadObjects(64)}
+	s.Assert().Equal(s.writeBuffered(Version3, objs, opts...), s.writeStreamed(Version3, objs, opts...))
+}
+
+func (s *WriterStreamSuite) TestStreamError() {
+	type record struct {
+		Name string `rsf:"name"`
+		Code string `rsf:"code,fixed:2"`
+	}
+
+	tmp, err := os.CreateTemp("", "")
+	s.Require().Nil(err)
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	w := NewWriterWithVersion(tmp, Version2)
+	sz, err := w.WriteObject(record{Name: "numpy", Code: "np"})
+	s.Require().Nil(err)
+
+	// After an error, the file is positioned at the start of the object, so
+	// the next object replaces it.
+	_, err = w.WriteObject(record{Name: "django", Code: "dj2"})
+	s.Assert().EqualError(err, "object 1 field code: size 3 does not match expected size 2")
+	pos, err := tmp.Seek(0, io.SeekCurrent)
+	s.Require().Nil(err)
+	s.Assert().Equal(int64(sz), pos)
+}
+
+func (s *WriterStreamSuite) TestStreamErrorTruncated() {
+	type record struct {
+		Name string `rsf:"name"`
+		Code string `rsf:"code,fixed:2"`
+	}
+	good := record{Name: "numpy", Code: "np"}
+	bad := record{Name: strings.Repeat("django", 100), Code: "dj2"}
+	expected := s.writeBuffered(Version2, []any{good})
+
+	tmp, err := os.CreateTemp("", "")
+	s.Require().Nil(err)
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	// The fields of the failed object written before the error are
+	// removed, so the file ends with the last complete object.
+	w := NewWriterWithVersion(tmp, Version2)
+	_, err = w.WriteObject(good)
+	s.Require().Nil(err)
+	_, err = w.WriteObject(bad)
+	s.Assert().EqualError(err, "object 1 field code: size 3 does not match expected size 2")
+	s.Require().Nil(w.Close())
+	data, err := os.ReadFile(tmp.Name())
+	s.Require().Nil(err)
+	s.Assert().Equal(expected, data)
+
+	// Seekable writers that can't be truncated are buffered.
+	tmp2, err := os.CreateTemp("", "")
+	s.Require().Nil(err)
+	defer os.Remove(tmp2.Name())
+	defer tmp2.Close()
+	w = NewWriterWithVersion(struct{ io.WriteSeeker }{tmp2}, Version2)
+	_, err = w.WriteObject(good)
+	s.Require().Nil(err)
+	_, err = w.WriteObject(bad)
+	s.Assert().NotNil(err)
+	s.Require().Nil(w.Close())
+	data, err = os.ReadFile(tmp2.Name())
+	s.Require().Nil(err)
+	s.Assert().Equal(expected, data)
+}
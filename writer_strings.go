@@ -0,0 +1,62 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bytes"
+	"io"
+)
+
+/*
+
+When the string table is enabled, variable-length string values are replaced
+with a 4-byte index into a table of unique strings. The table immediately
+follows the object index.
+
+Format:
+
+  [table size]
+  [string count]
+  [string 1 size]
+  [string 1]
+  [string n size]
+  [string n]
+
+*/
+
+// intern returns the string table index for `s`, adding `s` to the table
+// if needed.
+func (f *rsfWriter) intern(s string) int {
+	if f.strings == nil {
+		f.strings = make(map[string]int)
+	}
+	if id, ok := f.strings[s]; ok {
+		return id
+	}
+	id := len(f.stringList)
+	f.strings[s] = id
+	f.stringList = append(f.stringList, s)
+	return id
+}
+
+// writeStringTable writes the string table to the underlying writer and
+// returns the number of bytes written.
+func (f *rsfWriter) writeStringTable() (int, error) {
+	buf := &bytes.Buffer{}
+	_, err := f.WriteSizeField(0, len(f.stringList), buf)
+	if err != nil {
+		return 0, err
+	}
+	for _, s := range f.stringList {
+		_, err = f.WriteStringField(0, s, buf)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	sz, err := f.WriteSizeField(0, buf.Len()+sizeFieldLen, f.writer)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f.writer, buf)
+	return sz + int(n), err
+}
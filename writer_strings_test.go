@@ -0,0 +1,199 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WriterStringTableSuite struct {
+	suite.Suite
+}
+
+func TestWriterStringTableSuite(t *testing.T) {
+	suite.Run(t, &WriterStringTableSuite{})
+}
+
+func (s *WriterStringTableSuite) TestStringTable() {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version3, StringTable(true))
+	_, err := w.WriteObject(struct {
+		Company string   `rsf:"company"`
+		Tags    []string `rsf:"tags"`
+		Date    string   `rsf:"date,fixed:10"`
+	}{
+		Company: "posit",
+		Tags:    []string{"posit", "rsf", "posit"},
+		Date:    "2023-01-01",
+	})
+	s.Require().Nil(err)
+
+	// Nothing is written until the writer is closed.
+	s.Assert().Equal(0, buf.Len())
+	s.Require().Nil(w.Close())
+
+	s.Assert().Equal([]byte{
+		// Index version 3
+		0x0, 0x8, 0x33,
+		// Feature flags (string table)
+		0x1, 0x0, 0x0, 0x0,
+		//
+		// Index size
+		0x38, 0x0, 0x0, 0x0,
+		// "company"
+		0x7, 0x0, 0x0, 0x0,
+		0x63, 0x6f, 0x6d, 0x70, 0x61, 0x6e, 0x79,
+		0x1, 0x0, 0x0, 0x0,
+		// "tags"
+		0x4, 0x0, 0x0, 0x0,
+		0x74, 0x61, 0x67, 0x73,
+		0x4, 0x0, 0x0, 0x0,
+		// not indexed
+		0x0,
+		// string subtype
+		0x18, 0x0, 0x0, 0x0,
+		// zero subfields
+		0x0, 0x0, 0x0, 0x0,
+		// "date"
+		0x4, 0x0, 0x0, 0x0,
+		0x64, 0x61, 0x74, 0x65,
+		0x2, 0x0, 0x0, 0x0,
+		0xa, 0x0, 0x0, 0x0,
+		//
+		// String table size
+		0x18, 0x0, 0x0, 0x0,
+		// Two strings
+		0x2, 0x0, 0x0, 0x0,
+		// "posit"
+		0x5, 0x0, 0x0, 0x0,
+		0x70, 0x6f, 0x73, 0x69, 0x74,
+		// "rsf"
+		0x3, 0x0, 0x0, 0x0,
+		0x72, 0x73, 0x66,
+		//
+		// Object size
+		0x26, 0x0, 0x0, 0x0,
+		// "posit" (string 0)
+		0x0, 0x0, 0x0, 0x0,
+		// "tags" array size
+		0x14, 0x0, 0x0, 0x0,
+		// array length
+		0x3, 0x0, 0x0, 0x0,
+		// "posit" (string 0)
+		0x0, 0x0, 0x0, 0x0,
+		// "rsf" (string 1)
+		0x1, 0x0, 0x0, 0x0,
+		// "posit" (string 0)
+		0x0, 0x0, 0x0, 0x0,
+		// "2023-01-01" (fixed strings are not included in the table)
+		0x32, 0x30, 0x32, 0x33, 0x2d, 0x30, 0x31, 0x2d, 0x30, 0x31,
+	}, buf.Bytes())
+}
+
+func (s *WriterStringTableSuite) TestStringTableRoundTrip() {
+	plain := &bytes.Buffer{}
+	w := NewWriterWithVersion(plain, Version2)
+	for _, obj := range testComplexData {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+
+	table := &bytes.Buffer{}
+	w = NewWriterWithVersion(table, Version3, StringTable(true))
+	for _, obj := range testComplexData {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+	s.Require().Nil(w.Close())
+	tableBytes := table.Bytes()
+
+	// Both files print identically.
+	plainOut := &bytes.Buffer{}
+	err := Print(plainOut, bufio.NewReader(bytes.NewReader(plain.Bytes())))
+	s.Require().Nil(err)
+	tableOut := &bytes.Buffer{}
+	err = Print(tableOut, bufio.NewReader(bytes.NewReader(tableBytes)))
+	s.Require().Nil(err)
+	s.Assert().Equal(plainOut.String(), tableOut.String())
+
+	// Unmarshal both files and compare.
+	plainBuf := bufio.NewReader(plain)
+	plainReader := NewReader()
+	_, err = plainReader.ReadIndex(plainBuf)
+	s.Require().Nil(err)
+	tableBuf := bufio.NewReader(table)
+	tableReader := NewReader()
+	_, err = tableReader.ReadIndex(tableBuf)
+	s.Require().Nil(err)
+	for range testComplexData {
+		var plainRec, tableRec FullPackageRecordPyPI
+		s.Require().Nil(plainReader.Unmarshal(plainBuf, &plainRec))
+		s.Require().Nil(tableReader.Unmarshal(tableBuf, &tableRec))
+		s.Assert().Equal(plainRec, tableRec)
+	}
+
+	// Advance past string fields in a file with a string table.
+	r := NewReader()
+	buf := bufio.NewReader(bytes.NewReader(tableBytes))
+	_, err = r.ReadIndex(buf)
+	s.Require().Nil(err)
+	_, err = r.ReadSizeField(buf)
+	s.Require().Nil(err)
+	err = r.AdvanceTo(buf, "author")
+	s.Require().Nil(err)
+	author, err := r.ReadStringField(buf)
+	s.Require().Nil(err)
+	s.Assert().Equal("an-author", author)
+}
+
+func (s *WriterStringTableSuite) TestStringTableVersion() {
+	w := NewWriterWithVersion(&bytes.Buffer{}, Version2, StringTable(true))
+	_, err := w.WriteObject(testComplexData[0])
+	s.Assert().ErrorIs(err, ErrStringTableVersion)
+}
+
+func (s *WriterStringTableSuite) TestStringTableMerge() {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version3, StringTable(true))
+	_, err := w.WriteObject(testComplexData[0])
+	s.Require().Nil(err)
+	s.Require().Nil(w.Close())
+
+	err = Merge(&bytes.Buffer{}, buf)
+	s.Assert().EqualError(err, "source 0 uses a string table and cannot be merged")
+}
+
+func benchmarkStringTable(b *testing.B, opts ...WriterOption) {
+	// Repeat the complex data to simulate a larger file with many
+	// repeated values.
+	var sz int
+	for i := 0; i < b.N; i++ {
+		buf := &bytes.Buffer{}
+		w := NewWriterWithVersion(buf, Version3, opts...)
+		for j := 0; j < 100; j++ {
+			for _, obj := range testComplexData {
+				_, err := w.WriteObject(obj)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+		err := w.Close()
+		if err != nil {
+			b.Fatal(err)
+		}
+		sz = buf.Len()
+	}
+	b.ReportMetric(float64(sz), "bytes/file")
+}
+
+func BenchmarkWriteWithoutStringTable(b *testing.B) {
+	benchmarkStringTable(b)
+}
+
+func BenchmarkWriteWithStringTable(b *testing.B) {
+	benchmarkStringTable(b, StringTable(true))
+}
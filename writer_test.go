@@ -6,8 +6,12 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io"
+	"log"
 	"math"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/suite"
@@ -91,6 +95,41 @@ func (s *WriterSuite) TestDiscreteWrites() {
 	s.Assert().Equal(math.MaxFloat64, math.Float64frombits(binary.LittleEndian.Uint64(buf.Bytes())))
 }
 
+func (s *WriterSuite) TestWriteStringFromReader() {
+	w := NewWriterWithVersion(nil, Version2)
+
+	// A multi-megabyte payload is written like the equivalent string.
+	payload := strings.Repeat("description blob ", 256*1024)
+	expected := &bytes.Buffer{}
+	_, err := w.WriteStringField(0, payload, expected)
+	s.Require().Nil(err)
+
+	buf := &bytes.Buffer{}
+	sz, err := w.WriteStringFromReader(0, len(payload), strings.NewReader(payload), buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(len(payload)+4, sz)
+	s.Assert().Equal(expected.Bytes(), buf.Bytes())
+
+	// The string reads back.
+	r := NewReader()
+	val, err := r.ReadStringField(bufio.NewReader(buf))
+	s.Require().Nil(err)
+	s.Assert().Equal(payload, val)
+
+	// Only `length` bytes are copied, and the position is added to the size.
+	buf.Reset()
+	sz, err = w.WriteStringFromReader(3, 4, strings.NewReader("test-more"), buf)
+	s.Require().Nil(err)
+	s.Assert().Equal(11, sz)
+	s.Assert().Equal([]byte{0x4, 0x0, 0x0, 0x0, 0x74, 0x65, 0x73, 0x74}, buf.Bytes())
+
+	// Readers that end early are an error.
+	_, err = w.WriteStringFromReader(0, 10, strings.NewReader("test"), &bytes.Buffer{})
+	s.Assert().ErrorIs(err, io.ErrUnexpectedEOF)
+	_, err = w.WriteStringFromReader(0, -1, strings.NewReader("test"), &bytes.Buffer{})
+	s.Assert().EqualError(err, "invalid string length -1")
+}
+
 func (s *WriterSuite) TestInternalWriteString() {
 	buf := &bytes.Buffer{}
 	w := NewWriterWithVersion(buf, Version2)
@@ -1006,6 +1045,17 @@ func (s *WriterSuite) TestWriteObjectNoArrayIndex() {
 	}, buf.Bytes())
 }
 
+func (s *WriterSuite) TestArrayTooLong() {
+	// Array lengths are written as 32-bit size fields.
+	s.Assert().Nil(checkWriteArrayLen(0))
+	s.Assert().Nil(checkWriteArrayLen(math.MaxInt32))
+	if strconv.IntSize == 64 {
+		limit := int64(math.MaxUint32)
+		s.Assert().Nil(checkWriteArrayLen(int(limit)))
+		s.Assert().ErrorIs(checkWriteArrayLen(int(limit+1)), ErrArrayTooLong)
+	}
+}
+
 func (s *WriterSuite) TestWriteObjectArray() {
 	buf := &bytes.Buffer{}
 	w := NewWriterWithVersion(buf, Version2)
@@ -1191,6 +1241,92 @@ func (s *WriterSuite) TestWriteObjectArrayOfStructs() {
 	}, buf.Bytes())
 }
 
+func (s *WriterSuite) TestWriteObjectArrayOfEmptyStructs() {
+	type empty struct {
+		Name string `rsf:"-"`
+		Skip bool   `rsf:"-"`
+	}
+	type record struct {
+		Empty []empty `rsf:"empty"`
+		After string  `rsf:"after"`
+	}
+	rec := record{Empty: make([]empty, 3), After: "after"}
+
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	_, err := w.WriteObject(rec)
+	s.Require().Nil(err)
+	_, err = w.WriteObject(rec)
+	s.Require().Nil(err)
+	s.Require().Nil(w.Close())
+	data := buf.Bytes()
+
+	// Each element is a size field with no fields.
+	br := bufio.NewReader(bytes.NewReader(data))
+	r := NewReader()
+	index, err := r.ReadIndex(br)
+	s.Require().Nil(err)
+	s.Require().Len(index, 2)
+	s.Assert().Equal(int(reflect.Struct), index[0].SubfieldType)
+	s.Assert().Len(index[0].Subfields, 0)
+	_, err = r.BeginObject(br)
+	s.Require().Nil(err)
+	arraySz, err := r.ReadSizeField(br)
+	s.Require().Nil(err)
+	s.Assert().Equal(sizeFieldLen*5, arraySz)
+
+	// Unmarshal
+	br = bufio.NewReader(bytes.NewReader(data))
+	r = NewReader()
+	_, err = r.ReadIndex(br)
+	s.Require().Nil(err)
+	for i := 0; i < 2; i++ {
+		var got record
+		s.Require().Nil(r.Unmarshal(br, &got))
+		s.Assert().Equal(rec, got)
+	}
+
+	// DecodeObject
+	br = bufio.NewReader(bytes.NewReader(data))
+	r = NewReader()
+	_, err = r.ReadIndex(br)
+	s.Require().Nil(err)
+	for i := 0; i < 2; i++ {
+		obj, err := r.DecodeObject(br)
+		s.Require().Nil(err)
+		s.Assert().Equal(map[string]any{
+			"empty": []any{map[string]any{}, map[string]any{}, map[string]any{}},
+			"after": "after",
+		}, obj)
+	}
+
+	// SkipArrayElement
+	br = bufio.NewReader(bytes.NewReader(data))
+	r = NewReader()
+	_, err = r.ReadIndex(br)
+	s.Require().Nil(err)
+	_, err = r.BeginObject(br)
+	s.Require().Nil(err)
+	_, err = r.ReadSizeField(br)
+	s.Require().Nil(err)
+	arrayLen, err := r.ReadSizeField(br)
+	s.Require().Nil(err)
+	s.Require().Equal(3, arrayLen)
+	for i := 0; i < arrayLen; i++ {
+		s.Require().Nil(r.SkipArrayElement(br, "empty"))
+	}
+	s.Require().Nil(r.AdvanceTo(br, "after"))
+	after, err := r.ReadStringField(br)
+	s.Require().Nil(err)
+	s.Assert().Equal("after", after)
+
+	// Print
+	out := &bytes.Buffer{}
+	s.Require().Nil(Print(out, bufio.NewReader(bytes.NewReader(data))))
+	s.Assert().Equal(2, strings.Count(out.String(), "empty (array(3)):"))
+	s.Assert().Equal(2, strings.Count(out.String(), "after (string): after"))
+}
+
 func (s *WriterSuite) TestWriteObjectString() {
 	buf := &bytes.Buffer{}
 	w := NewWriterWithVersion(buf, Version2)
@@ -1305,3 +1441,292 @@ arrays (array(2)):
     - cannot print data for arrays of arrays
 `, "\n"+pbuf.String())
 }
+
+func (s *WriterSuite) TestWriteObjectWithFixedOverrides() {
+	type file struct {
+		Name string `rsf:"name,skip,fixed:4"`
+		Hash string `rsf:"hash"`
+	}
+	type release struct {
+		Hash  string `rsf:"hash,fixed:32"`
+		Files []file `rsf:"files,index:name"`
+	}
+
+	// The hash length is only known at runtime.
+	rel := release{
+		Hash: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+		Files: []file{
+			{Name: "a.gz", Hash: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"},
+			{Name: "b.gz", Hash: "fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"},
+		},
+	}
+	opts := WriteOptions{
+		FixedOverrides: map[string]int{
+			"hash":       64,
+			"files.hash": 64,
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	_, err := w.WriteObjectWith(rel, opts)
+	s.Require().Nil(err)
+
+	// Without the override, the tagged size is used.
+	_, err = NewWriterWithVersion(&bytes.Buffer{}, Version2).WriteObject(rel)
+	s.Assert().EqualError(err, "object 0 field hash: size 64 does not match expected size 32")
+
+	// The index records the effective sizes.
+	b := bufio.NewReader(buf)
+	r := NewReader()
+	index, err := r.ReadIndex(b)
+	s.Require().Nil(err)
+	s.Assert().Equal(IndexEntry{FieldName: "hash", FieldType: FieldTypeFixedStr, FieldSize: 64}, index[0])
+	s.Assert().Equal(IndexEntry{FieldName: "hash", FieldType: FieldTypeFixedStr, FieldSize: 64}, index[1].Subfields[0])
+
+	var read release
+	err = r.Unmarshal(b, &read)
+	s.Require().Nil(err)
+	s.Assert().Equal(rel, read)
+
+	// Overrides require string fields.
+	_, err = NewWriterWithVersion(&bytes.Buffer{}, Version2).WriteObjectWith(rel, WriteOptions{
+		FixedOverrides: map[string]int{"files": 10},
+	})
+	s.Assert().EqualError(err, "field files: the fixed size override requires a string field")
+}
+
+func (s *WriterSuite) TestWriteObjectWithIndexFuncs() {
+	type pkg struct {
+		Name    string `rsf:"name"`
+		Version string `rsf:"version"`
+	}
+	type repo struct {
+		Packages []pkg `rsf:"packages"`
+		Sizes    []pkg `rsf:"sizes"`
+	}
+	rec := repo{
+		Packages: []pkg{{Name: "NumPy", Version: "1.26"}, {Name: "SciPy", Version: "1.11"}},
+		Sizes:    []pkg{{Name: "a", Version: "1"}, {Name: "bcd", Version: "2"}},
+	}
+
+	// The keys are the lowercased names, and the lengths of the names.
+	opts := WriteOptions{
+		FixedOverrides: map[string]int{"packages": 5},
+		IndexFuncs: map[string]func(elem any) any{
+			"packages": func(elem any) any {
+				return strings.ToLower(elem.(pkg).Name)
+			},
+			"sizes": func(elem any) any {
+				return len(elem.(pkg).Name)
+			},
+		},
+	}
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	_, err := w.WriteObjectWith(rec, opts)
+	s.Require().Nil(err)
+
+	r := NewReader()
+	index, err := r.ReadIndex(bufio.NewReader(bytes.NewReader(buf.Bytes())))
+	s.Require().Nil(err)
+	s.Assert().True(index[0].Indexed)
+	s.Assert().Equal(int(reflect.String), index[0].IndexType)
+	s.Assert().Equal(5, index[0].IndexSize)
+	s.Assert().Equal(int(reflect.Int64), index[1].IndexType)
+
+	// The computed keys can be used to find elements.
+	find := func(key any, array string) string {
+		b := bufio.NewReader(bytes.NewReader(buf.Bytes()))
+		r := NewReader()
+		_, err := r.ReadIndex(b)
+		s.Require().Nil(err)
+		_, err = r.BeginObject(b)
+		s.Require().Nil(err)
+		s.Require().Nil(r.AdvanceTo(b, array))
+		found, err := r.SeekToIndexValue(b, key, array)
+		s.Require().Nil(err)
+		s.Require().True(found)
+		s.Require().Nil(r.AdvanceTo(b, array, "name"))
+		name, err := r.ReadStringField(b)
+		s.Require().Nil(err)
+		return name
+	}
+	s.Assert().Equal("SciPy", find("scipy", "packages"))
+	s.Assert().Equal("bcd", find(3, "sizes"))
+
+	// The keys aren't fields of the elements.
+	var read repo
+	r = NewReader()
+	b := bufio.NewReader(bytes.NewReader(buf.Bytes()))
+	_, err = r.ReadIndex(b)
+	s.Require().Nil(err)
+	s.Require().Nil(r.Unmarshal(b, &read))
+	s.Assert().Equal(rec, read)
+
+	// Keys must have the index type.
+	opts.IndexFuncs["sizes"] = func(elem any) any {
+		return elem.(pkg).Name
+	}
+	_, err = NewWriterWithVersion(&bytes.Buffer{}, Version2).WriteObjectWith(rec, opts)
+	s.Assert().EqualError(err, "object 0 field sizes: invalid index key type string")
+}
+
+func (s *WriterSuite) TestWriteObjectFixedNonString() {
+	w := NewWriterWithVersion(&bytes.Buffer{}, Version2)
+	_, err := w.WriteObject(struct {
+		Age int `rsf:"age,fixed:4"`
+	}{})
+	s.Assert().EqualError(err, "field age: the fixed option requires a string field")
+
+	_, err = w.WriteObject(struct {
+		Tags []string `rsf:"tags,fixed:4"`
+	}{})
+	s.Assert().EqualError(err, "field tags: the fixed option requires a string field")
+}
+
+func (s *WriterSuite) TestWriteObjectUnsupportedRoot() {
+	w := NewWriterWithVersion(&bytes.Buffer{}, Version2)
+	_, err := w.WriteObject(map[string]string{"company": "posit"})
+	s.Assert().EqualError(err, "unsupported root type: map")
+
+	_, err = w.WriteObject(func() {})
+	s.Assert().EqualError(err, "unsupported root type: func")
+
+	_, err = w.WriteObject(nil)
+	s.Assert().EqualError(err, "unsupported root type: invalid")
+}
+
+func (s *WriterSuite) TestWriteFixedStringFieldTruncate() {
+	buf := &bytes.Buffer{}
+	logBuf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2, TruncateFixed(true), WarningLogger(log.New(logBuf, "", 0)))
+
+	sz, err := w.WriteFixedStringField(0, 10, "2020-10-01-mistake", buf)
+	s.Assert().Nil(err)
+	s.Assert().Equal(10, sz)
+	s.Assert().Equal("2020-10-01", buf.String())
+	s.Assert().Equal("truncated fixed-length string \"2020-10-01-mistake\" to \"2020-10-01\"\n", logBuf.String())
+
+	// Strings that are too short are still an error.
+	_, err = w.WriteFixedStringField(0, 10, "2020-10", buf)
+	s.Assert().ErrorContains(err, "size 7 does not match expected size 10")
+
+	// Runes are not split. "é" is two bytes, so the string is truncated
+	// before it and padded.
+	buf.Reset()
+	sz, err = w.WriteFixedStringField(0, 4, "caféteria", buf)
+	s.Assert().Nil(err)
+	s.Assert().Equal(4, sz)
+	s.Assert().Equal("caf ", buf.String())
+
+	buf.Reset()
+	sz, err = w.WriteFixedStringField(0, 5, "caféteria", buf)
+	s.Assert().Nil(err)
+	s.Assert().Equal(5, sz)
+	s.Assert().Equal("café", buf.String())
+}
+
+func (s *WriterSuite) TestWriteObjectTruncateFixed() {
+	type record struct {
+		Date string `rsf:"date,fixed:10"`
+		Name string `rsf:"name"`
+	}
+	obj := record{Date: "2020-10-01-mistake", Name: "From 2020"}
+
+	// By default, long fixed-length strings are an error.
+	w := NewWriterWithVersion(&bytes.Buffer{}, Version2)
+	_, err := w.WriteObject(obj)
+	s.Assert().ErrorContains(err, "size 18 does not match expected size 10")
+
+	// No logger is required.
+	buf := &bytes.Buffer{}
+	w = NewWriterWithVersion(buf, Version2, TruncateFixed(true))
+	_, err = w.WriteObject(obj)
+	s.Require().Nil(err)
+
+	r := NewReader()
+	rBuf := bufio.NewReader(buf)
+	_, err = r.ReadIndex(rBuf)
+	s.Require().Nil(err)
+	var rec record
+	s.Require().Nil(r.Unmarshal(rBuf, &rec))
+	s.Assert().Equal(record{Date: "2020-10-01", Name: "From 2020"}, rec)
+}
+
+func (s *WriterSuite) TestWriteObjectRejectNulStrings() {
+	type snap struct {
+		Date string `rsf:"date,skip,fixed:3"`
+		Name string `rsf:"name"`
+	}
+	type record struct {
+		Name  string `rsf:"name"`
+		Snaps []snap `rsf:"snaps,index:date"`
+	}
+	obj := record{Name: "a\x00b", Snaps: []snap{{Date: "abc", Name: "c"}}}
+
+	// NUL bytes are valid by default.
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	_, err := w.WriteObject(obj)
+	s.Require().Nil(err)
+
+	r := NewReader()
+	rBuf := bufio.NewReader(buf)
+	_, err = r.ReadIndex(rBuf)
+	s.Require().Nil(err)
+	var rec record
+	s.Require().Nil(r.Unmarshal(rBuf, &rec))
+	s.Assert().Equal("a\x00b", rec.Name)
+
+	// With the option, the field is named in the error.
+	w = NewWriterWithVersion(&bytes.Buffer{}, Version2, RejectNulStrings(true))
+	_, err = w.WriteObject(obj)
+	s.Assert().EqualError(err, "object 0 field name: string contains a NUL byte")
+
+	w = NewWriterWithVersion(&bytes.Buffer{}, Version2, RejectNulStrings(true))
+	_, err = w.WriteObject(record{Name: "a", Snaps: []snap{{Date: "abc", Name: "\x00"}}})
+	s.Assert().EqualError(err, "object 0 field snaps.name: string contains a NUL byte")
+
+	w = NewWriterWithVersion(&bytes.Buffer{}, Version2, RejectNulStrings(true))
+	_, err = w.WriteObject(record{Name: "a", Snaps: []snap{{Date: "a\x00c", Name: "c"}}})
+	s.Assert().EqualError(err, "object 0 field snaps.date: string contains a NUL byte")
+
+	w = NewWriterWithVersion(&bytes.Buffer{}, Version2, RejectNulStrings(true))
+	_, err = w.WriteObject(record{Name: "a", Snaps: []snap{{Date: "abc", Name: "c"}}})
+	s.Assert().Nil(err)
+}
+
+func (s *WriterSuite) TestWriteObjectErrorContext() {
+	w := NewWriterWithVersion(&bytes.Buffer{}, Version2)
+	for _, obj := range testComplexData {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+
+	// The object number and the path of the array index key are reported.
+	bad := testComplexData[0]
+	bad.Snapshots = []FullManifestSnapshotPyPI{{Snapshot: "2020-10-11T00:00:00"}}
+	_, err := w.WriteObject(bad)
+	s.Assert().EqualError(err, "object 2 field snapshots.snapshot: size 19 does not match expected size 10")
+
+	// Fields of array elements are reported with their paths.
+	type hash struct {
+		Value string `rsf:"value,fixed:4"`
+	}
+	type record struct {
+		Name   string `rsf:"name"`
+		Hashes []hash `rsf:"hashes"`
+	}
+	w = NewWriterWithVersion(&bytes.Buffer{}, Version2)
+	_, err = w.WriteObject(record{Name: "a", Hashes: []hash{{Value: "abcd"}}})
+	s.Require().Nil(err)
+	_, err = w.WriteObject(record{Name: "b", Hashes: []hash{{Value: "abcd"}, {Value: "abc"}}})
+	s.Assert().EqualError(err, "object 1 field hashes.value: size 3 does not match expected size 4")
+
+	// The underlying error is wrapped.
+	_, err = w.WriteObject(record{Name: "c", Hashes: []hash{{Value: "abcde"}}})
+	var fe *fieldError
+	s.Require().ErrorAs(err, &fe)
+	s.Assert().Equal("hashes.value", fe.path)
+}
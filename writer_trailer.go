@@ -0,0 +1,58 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+/*
+
+A trailer is a free-form metadata blob, like JSON build information, that is
+written after the last object when the writer is closed (see `WriteTrailer`).
+The trailer is preceded by a zero-length object size, which marks the end of
+the objects, so readers of the objects ignore it.
+
+Format:
+
+  [end of objects marker (0)]
+  [trailer]
+  [trailer size]
+  [trailer marker]
+
+If the file also includes an object key table, the key table follows the
+trailer marker, without another end of objects marker. Readers locate the
+trailer by reading the end of the file with `ReadTrailer`.
+
+*/
+
+// TrailerMarker marks the end of a trailer.
+var TrailerMarker = []byte{0x52, 0x53, 0x46, 0x54} // "RSFT"
+
+// WriteTrailer sets a metadata blob that is written after the last object
+// when the writer is closed. The trailer is not part of any object, and can be
+// read with `ReadTrailer`. Calling WriteTrailer again replaces the trailer. As
+// with the object key table, nothing is written if no objects were written.
+//
+// Trailers cannot be used with `FooterIndex`. Once a trailer is set, writing
+// an object returns `ErrFooterIndexOption`, and `Close` writes the index
+// without the trailer and returns `ErrFooterIndexOption`.
+func (f *rsfWriter) WriteTrailer(meta []byte) {
+	f.trailer = append([]byte{}, meta...)
+}
+
+// writeTrailer writes the end of objects marker and the trailer to the
+// underlying writer.
+func (f *rsfWriter) writeTrailer() error {
+	// Mark the end of the objects.
+	_, err := f.WriteSizeField(0, 0, f.writer)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.writer.Write(f.trailer)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteSizeField(0, len(f.trailer), f.writer)
+	if err != nil {
+		return err
+	}
+	_, err = f.writer.Write(TrailerMarker)
+	return err
+}
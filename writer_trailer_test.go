@@ -0,0 +1,107 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WriterTrailerSuite struct {
+	suite.Suite
+}
+
+func TestWriterTrailerSuite(t *testing.T) {
+	suite.Run(t, &WriterTrailerSuite{})
+}
+
+var testTrailer = []byte(`{"build":"2023-06-01","sources":["https://pypi.org/simple"]}`)
+
+func (s *WriterTrailerSuite) TestTrailer() {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	w.WriteTrailer(testTrailer)
+	for _, obj := range testComplexData {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+	s.Require().Nil(w.Close())
+
+	// The objects decode normally.
+	rbuf := bufio.NewReader(bytes.NewReader(buf.Bytes()))
+	r := NewReader()
+	_, err := r.ReadIndex(rbuf)
+	s.Require().Nil(err)
+	objs, err := r.DecodeAll(rbuf)
+	s.Require().Nil(err)
+	s.Assert().Len(objs, 2)
+	s.Assert().Equal("django", objs[1]["cname"])
+
+	// The trailer reads back exactly.
+	trailer, err := ReadTrailer(bytes.NewReader(buf.Bytes()))
+	s.Require().Nil(err)
+	s.Assert().Equal(testTrailer, trailer)
+
+	// The trailer is not printed.
+	pbuf := &bytes.Buffer{}
+	err = Print(pbuf, bufio.NewReader(bytes.NewReader(buf.Bytes())))
+	s.Require().Nil(err)
+	s.Assert().Contains(pbuf.String(), "Object[2]")
+	s.Assert().NotContains(pbuf.String(), "Object[3]")
+}
+
+func (s *WriterTrailerSuite) TestTrailerWithKeyTable() {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version3, StringTable(true), ObjectKey("cname"))
+	for _, obj := range testComplexData {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+	w.WriteTrailer(testTrailer)
+	s.Require().Nil(w.Close())
+
+	trailer, err := ReadTrailer(bytes.NewReader(buf.Bytes()))
+	s.Require().Nil(err)
+	s.Assert().Equal(testTrailer, trailer)
+
+	// The key table still works.
+	f := bytes.NewReader(buf.Bytes())
+	r := NewReader()
+	_, err = r.ReadIndex(f)
+	s.Require().Nil(err)
+	found, err := r.FindObject(f, "django")
+	s.Require().Nil(err)
+	s.Assert().True(found)
+	obj, err := r.DecodeObject(bufio.NewReader(f))
+	s.Require().Nil(err)
+	s.Assert().Equal("django", obj["cname"])
+}
+
+func (s *WriterTrailerSuite) TestEmptyTrailer() {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	_, err := w.WriteObject(testComplexData[0])
+	s.Require().Nil(err)
+	w.WriteTrailer(nil)
+	s.Require().Nil(w.Close())
+
+	trailer, err := ReadTrailer(bytes.NewReader(buf.Bytes()))
+	s.Require().Nil(err)
+	s.Assert().Empty(trailer)
+}
+
+func (s *WriterTrailerSuite) TestNoTrailer() {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version2)
+	_, err := w.WriteObject(testComplexData[0])
+	s.Require().Nil(err)
+	s.Require().Nil(w.Close())
+
+	_, err = ReadTrailer(bytes.NewReader(buf.Bytes()))
+	s.Assert().ErrorIs(err, ErrNoTrailer)
+
+	_, err = ReadTrailer(bytes.NewReader([]byte{1, 2}))
+	s.Assert().ErrorIs(err, ErrNoTrailer)
+}
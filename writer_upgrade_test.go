@@ -279,7 +279,7 @@ func (s *WriterUpgradeSuite) validateRead(b *bytes.Buffer) {
 	s.Assert().Nil(err)
 
 	// Verify at end of array
-	s.Assert().Equal(arraySz, r.Pos()-objectStart)
+	s.Assert().Equal(int64(arraySz), r.Pos()-objectStart)
 
 	// Advance to age
 	err = r.AdvanceTo(buf, "age")
@@ -0,0 +1,107 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WriterVerboseIndexSuite struct {
+	suite.Suite
+}
+
+func TestWriterVerboseIndexSuite(t *testing.T) {
+	suite.Run(t, &WriterVerboseIndexSuite{})
+}
+
+func (s *WriterVerboseIndexSuite) TestVerboseIndex() {
+	type verboseRecord struct {
+		Name    string    `rsf:"name"`
+		Date    string    `rsf:"date,fixed:10"`
+		Notes   string    `rsf:"notes,compress"`
+		Active  bool      `rsf:"active"`
+		Count   int64     `rsf:"count"`
+		Score   float64   `rsf:"score"`
+		Weights []float64 `rsf:"weights"`
+	}
+
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version3, VerboseIndex(true))
+	_, err := w.WriteObject(verboseRecord{
+		Name:    "rsf",
+		Date:    "2023-01-01",
+		Notes:   "some notes",
+		Active:  true,
+		Count:   42,
+		Score:   0.5,
+		Weights: []float64{1, 2},
+	})
+	s.Require().Nil(err)
+	s.Require().Nil(w.Close())
+
+	r := NewReader()
+	rBuf := bufio.NewReader(buf)
+	index, err := r.ReadIndex(rBuf)
+	s.Require().Nil(err)
+
+	var tags []string
+	for _, entry := range index {
+		tags = append(tags, entry.TypeTag)
+	}
+	s.Assert().Equal([]string{"str", "fstr", "cstr", "bool", "i64", "f64", "arr"}, tags)
+
+	var rec verboseRecord
+	s.Require().Nil(r.Unmarshal(rBuf, &rec))
+	s.Assert().Equal("some notes", rec.Notes)
+	s.Assert().Equal([]float64{1, 2}, rec.Weights)
+}
+
+func (s *WriterVerboseIndexSuite) TestVerboseIndexRoundTrip() {
+	plain := &bytes.Buffer{}
+	w := NewWriterWithVersion(plain, Version3)
+	for _, obj := range testComplexData {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+
+	verbose := &bytes.Buffer{}
+	w = NewWriterWithVersion(verbose, Version3, VerboseIndex(true))
+	for _, obj := range testComplexData {
+		_, err := w.WriteObject(obj)
+		s.Require().Nil(err)
+	}
+	s.Require().Nil(w.Close())
+
+	// The verbose index is larger, but describes the same fields.
+	s.Assert().Greater(verbose.Len(), plain.Len())
+	plainBuf := bufio.NewReader(plain)
+	plainReader := NewReader()
+	plainIndex, err := plainReader.ReadIndex(plainBuf)
+	s.Require().Nil(err)
+	verboseBuf := bufio.NewReader(verbose)
+	verboseReader := NewReader()
+	verboseIndex, err := verboseReader.ReadIndex(verboseBuf)
+	s.Require().Nil(err)
+	s.Assert().True(plainIndex.Equal(verboseIndex))
+
+	// Subfields are also tagged.
+	s.Assert().Equal("", plainIndex[3].TypeTag)
+	s.Assert().Equal("arr", verboseIndex[3].TypeTag)
+	s.Assert().Equal("i64", verboseIndex[3].Subfields[1].TypeTag)
+
+	for range testComplexData {
+		var plainRec, verboseRec FullPackageRecordPyPI
+		s.Require().Nil(plainReader.Unmarshal(plainBuf, &plainRec))
+		s.Require().Nil(verboseReader.Unmarshal(verboseBuf, &verboseRec))
+		s.Assert().Equal(plainRec, verboseRec)
+	}
+}
+
+func (s *WriterVerboseIndexSuite) TestVerboseIndexVersion() {
+	w := NewWriterWithVersion(&bytes.Buffer{}, Version2, VerboseIndex(true))
+	_, err := w.WriteObject(testComplexData[0])
+	s.Assert().ErrorIs(err, ErrVerboseIndexVersion)
+}
@@ -0,0 +1,131 @@
+// Copyright (C) 2023 by Posit Software, PBC
+package rsf
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WriterFieldWidthsSuite struct {
+	suite.Suite
+}
+
+func TestWriterFieldWidthsSuite(t *testing.T) {
+	suite.Run(t, &WriterFieldWidthsSuite{})
+}
+
+type widthsRecord struct {
+	Name  string  `rsf:"name"`
+	Date  string  `rsf:"date,fixed:10"`
+	Extra int64   `rsf:"extra"`
+	Tags  []int64 `rsf:"tags"`
+	Ready bool    `rsf:"ready"`
+	Age   int64   `rsf:"age"`
+}
+
+var testWidthsData = widthsRecord{
+	Name:  "rsf",
+	Date:  "2023-01-01",
+	Extra: 99,
+	Tags:  []int64{1, 2},
+	Ready: true,
+	Age:   42,
+}
+
+func (s *WriterFieldWidthsSuite) TestFieldWidths() {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version3, FieldWidths(true))
+	_, err := w.WriteObject(testWidthsData)
+	s.Require().Nil(err)
+	s.Require().Nil(w.Close())
+
+	r := NewReader()
+	rBuf := bufio.NewReader(buf)
+	index, err := r.ReadIndex(rBuf)
+	s.Require().Nil(err)
+
+	var widths []int
+	for _, entry := range index {
+		widths = append(widths, entry.FieldWidth)
+	}
+	s.Assert().Equal([]int{0, 10, 10, 0, 1, 10}, widths)
+
+	var rec widthsRecord
+	s.Require().Nil(r.Unmarshal(rBuf, &rec))
+	s.Assert().Equal(testWidthsData, rec)
+}
+
+func (s *WriterFieldWidthsSuite) TestAdvanceUnknownType() {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version3, FieldWidths(true))
+	_, err := w.WriteObject(testWidthsData)
+	s.Require().Nil(err)
+	s.Require().Nil(w.Close())
+
+	// Simulate a newer writer by changing the type of the `extra` field to
+	// a type this reader doesn't understand. The field width is unchanged.
+	data := buf.Bytes()
+	at := bytes.Index(data, []byte("extra")) + len("extra")
+	s.Require().Equal([]byte{FieldTypeInt64, 0x0, 0x0, 0x0}, data[at:at+4])
+	data[at] = 99
+
+	r := NewReader()
+	rBuf := bufio.NewReader(bytes.NewReader(data))
+	index, err := r.ReadIndex(rBuf)
+	s.Require().Nil(err)
+	s.Assert().Equal(99, index[2].FieldType)
+
+	// Advance past the unknown field.
+	_, err = r.BeginObject(rBuf)
+	s.Require().Nil(err)
+	err = r.AdvanceTo(rBuf, "age")
+	s.Require().Nil(err)
+	age, err := r.ReadIntField(rBuf)
+	s.Require().Nil(err)
+	s.Assert().Equal(int64(42), age)
+	s.Require().Nil(r.SkipObject(rBuf))
+
+	// Unmarshal also skips the unknown field when the destination doesn't
+	// include it.
+	r = NewReader()
+	rBuf = bufio.NewReader(bytes.NewReader(data))
+	_, err = r.ReadIndex(rBuf)
+	s.Require().Nil(err)
+	var rec struct {
+		Name string `rsf:"name"`
+		Age  int64  `rsf:"age"`
+	}
+	s.Require().Nil(r.Unmarshal(rBuf, &rec))
+	s.Assert().Equal("rsf", rec.Name)
+	s.Assert().Equal(int64(42), rec.Age)
+}
+
+func (s *WriterFieldWidthsSuite) TestAdvanceUnknownTypeWithoutWidths() {
+	buf := &bytes.Buffer{}
+	w := NewWriterWithVersion(buf, Version3)
+	_, err := w.WriteObject(testWidthsData)
+	s.Require().Nil(err)
+	s.Require().Nil(w.Close())
+
+	data := buf.Bytes()
+	at := bytes.Index(data, []byte("extra")) + len("extra")
+	data[at] = 99
+
+	r := NewReader()
+	rBuf := bufio.NewReader(bytes.NewReader(data))
+	_, err = r.ReadIndex(rBuf)
+	s.Require().Nil(err)
+	_, err = r.BeginObject(rBuf)
+	s.Require().Nil(err)
+	err = r.AdvanceTo(rBuf, "age")
+	s.Assert().EqualError(err, "unexpected index field type 99")
+}
+
+func (s *WriterFieldWidthsSuite) TestFieldWidthsVersion() {
+	w := NewWriterWithVersion(&bytes.Buffer{}, Version2, FieldWidths(true))
+	_, err := w.WriteObject(testWidthsData)
+	s.Assert().ErrorIs(err, ErrFieldWidthsVersion)
+}